@@ -0,0 +1,237 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"plants/plants"
+	"plants/store"
+	"plants/telemetry"
+	"time"
+)
+
+// Sink delivers alert notifications, it is called once when an alert fires and once when it resolves
+type Sink interface {
+	Send(ctx context.Context, alert Alert, plant plants.Plant) error
+}
+
+// SinkFunc lets a plain function be used as a Sink
+type SinkFunc func(ctx context.Context, alert Alert, plant plants.Plant) error
+
+func (f SinkFunc) Send(ctx context.Context, alert Alert, plant plants.Plant) error {
+	return f(ctx, alert, plant)
+}
+
+// MultiSink sends to every sink, one failing doesnt stop the others
+type MultiSink []Sink
+
+func (m MultiSink) Send(ctx context.Context, alert Alert, plant plants.Plant) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Send(ctx, alert, plant); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogSink logs every notification, it is always there so alerts show up even without other sinks
+func LogSink(logger *slog.Logger) Sink {
+	return SinkFunc(func(ctx context.Context, alert Alert, plant plants.Plant) error {
+		logger.WarnContext(ctx, fmt.Sprintf("alert '%s' %s for plant '%s'", alert.RuleName, alert.State, plant.Name),
+			slog.String("alertId", alert.ID), slog.String("ruleId", alert.RuleID), slog.String("plantId", plant.ID))
+		return nil
+	})
+}
+
+// Sources are the stores rules are evaluated against
+type Sources struct {
+	Plants       store.Store
+	Care         store.CareStore
+	Measurements store.MeasurementStore
+	Telemetry    telemetry.Store
+}
+
+// Evaluator checks every rule against every plant it applies to and moves their alerts between states
+type Evaluator struct {
+	store   Store
+	sources Sources
+	sink    Sink
+	now     func() time.Time
+}
+
+func NewEvaluator(store Store, sources Sources, sink Sink) *Evaluator {
+	return &Evaluator{store: store, sources: sources, sink: sink, now: time.Now}
+}
+
+type alertKey struct {
+	ruleID  string
+	plantID string
+}
+
+// Evaluate runs every rule once. Matching plants get a pending alert that fires once the rule has matched
+// for the rule's For duration, alerts that stop matching resolve when they fired and are dropped otherwise.
+// Failing rules keep their alerts as they are and are reported in the joined error.
+func (e *Evaluator) Evaluate(ctx context.Context) error {
+	now := e.now().UTC()
+	rules, err := e.store.ListRules(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve alert rules: %w", err)
+	}
+	plantList, err := e.sources.Plants.List(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve all plants: %w", err)
+	}
+	existing, err := e.store.ListAlerts(ctx, AlertFilter{})
+	if err != nil {
+		return fmt.Errorf("retrieve alerts: %w", err)
+	}
+	open := make(map[alertKey]Alert)
+	for _, alert := range existing {
+		if alert.State != StateResolved {
+			open[alertKey{ruleID: alert.RuleID, plantID: alert.PlantID}] = alert
+		}
+	}
+
+	var errs []error
+	// seen are the open alerts still matching, or whose rule couldnt be evaluated
+	seen := make(map[alertKey]bool)
+	for _, rule := range rules {
+		for _, plant := range plantList {
+			if len(rule.Tags) > 0 && !plant.HasAnyTag(rule.Tags) {
+				continue
+			}
+			key := alertKey{ruleID: rule.ID, plantID: plant.ID}
+			matched, value, err := e.check(ctx, rule, plant, now)
+			if err != nil {
+				seen[key] = true
+				errs = append(errs, fmt.Errorf("evaluate rule '%s' for plant '%s': %w", rule.Name, plant.ID, err))
+				continue
+			}
+			if !matched {
+				continue
+			}
+
+			seen[key] = true
+			alert, ok := open[key]
+			if !ok {
+				alert = Alert{RuleID: rule.ID, PlantID: plant.ID, State: StatePending, ActiveAt: now}
+			}
+			alert.RuleName = rule.Name
+			alert.PlantName = plant.Name
+			alert.Value = value
+			fired := alert.State == StatePending && now.Sub(alert.ActiveAt) >= time.Duration(rule.For)
+			if fired {
+				alert.State = StateFiring
+				alert.FiredAt = &now
+			}
+			if err := e.save(ctx, alert, plant, fired); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	plantsByID := make(map[string]plants.Plant)
+	for _, plant := range plantList {
+		plantsByID[plant.ID] = plant
+	}
+	for key, alert := range open {
+		if seen[key] {
+			continue
+		}
+		// NOTE: pending alerts never notified anyone, so there is nothing to resolve
+		if alert.State == StatePending {
+			if err := e.store.DeleteAlert(ctx, alert.ID); err != nil {
+				errs = append(errs, fmt.Errorf("delete alert: %w", err))
+			}
+			continue
+		}
+		plant, ok := plantsByID[alert.PlantID]
+		if !ok {
+			// NOTE: the plant was deleted, the alert resolves with what it remembers about it
+			plant = plants.Plant{ID: alert.PlantID, Name: alert.PlantName}
+		}
+		alert.State = StateResolved
+		alert.ResolvedAt = &now
+		if err := e.save(ctx, alert, plant, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// save stores the alert and, when it changed state, notifies the sink.
+// NOTE: the state is saved before sending, a failed notification is reported but not retried
+func (e *Evaluator) save(ctx context.Context, alert Alert, plant plants.Plant, notify bool) error {
+	saved, err := e.store.SaveAlert(ctx, alert)
+	if err != nil {
+		return fmt.Errorf("save alert: %w", err)
+	}
+	if !notify {
+		return nil
+	}
+	if err := e.sink.Send(ctx, *saved, plant); err != nil {
+		return fmt.Errorf("send alert '%s' for plant '%s': %w", saved.RuleName, plant.ID, err)
+	}
+	return nil
+}
+
+// check reports whether the rule matches the plant and the value it matched on
+func (e *Evaluator) check(ctx context.Context, rule Rule, plant plants.Plant, now time.Time) (bool, *float64, error) {
+	switch rule.Type {
+	case RuleThreshold:
+		readings, err := e.sources.Telemetry.LatestReadings(ctx, plant.ID)
+		if err != nil {
+			return false, nil, fmt.Errorf("retrieve latest telemetry: %w", err)
+		}
+		for _, r := range readings {
+			if r.Metric != rule.Metric {
+				continue
+			}
+			// NOTE: a stale reading means the sensor went quiet, the plant may have been watered since
+			if now.Sub(r.Time) > rule.maxAge() {
+				return false, nil, nil
+			}
+			return rule.compare(r.Value), &r.Value, nil
+		}
+		// NOTE: no readings means no sensor, that isnt something to alert on
+		return false, nil, nil
+
+	case RuleHeightUnchanged:
+		measurements, err := e.sources.Measurements.ListMeasurements(ctx, plant.ID, time.Time{}, time.Time{})
+		if err != nil {
+			return false, nil, fmt.Errorf("retrieve measurements: %w", err)
+		}
+		if len(measurements) == 0 {
+			return false, nil, nil
+		}
+		// NOTE: the height is unchanged since the first reading of the latest run of equal heights
+		last := len(measurements) - 1
+		since := measurements[last].Time
+		for i := last - 1; i >= 0 && measurements[i].Height() == measurements[last].Height(); i-- {
+			since = measurements[i].Time
+		}
+		return olderThan(since, rule.Days, now)
+
+	case RuleCareMissed:
+		events, err := e.sources.Care.ListCareEvents(ctx, plant.ID, rule.CareType)
+		if err != nil {
+			return false, nil, fmt.Errorf("retrieve care events: %w", err)
+		}
+		if len(events) == 0 {
+			return true, nil, nil
+		}
+		return olderThan(events[0].Time, rule.Days, now)
+	}
+
+	return false, nil, fmt.Errorf("unknown rule type '%s'", rule.Type)
+}
+
+// olderThan reports whether at least days have passed since t, along with the number of whole days that have
+func olderThan(t time.Time, days int, now time.Time) (bool, *float64, error) {
+	elapsed := math.Floor(now.Sub(t).Hours() / 24)
+	return !now.Before(t.AddDate(0, 0, days)), &elapsed, nil
+}
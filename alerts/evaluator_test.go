@@ -0,0 +1,167 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"plants/plants"
+	"plants/store"
+	"plants/telemetry"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// summarize describes alerts as "rule/plant: state value", sorted so the order doesnt matter
+func summarize(alerts []Alert) []string {
+	summary := []string{}
+	for _, a := range alerts {
+		value := "-"
+		if a.Value != nil {
+			value = fmt.Sprint(*a.Value)
+		}
+		summary = append(summary, fmt.Sprintf("%s/%s: %s %s", a.RuleName, a.PlantName, a.State, value))
+	}
+	slices.Sort(summary)
+	return summary
+}
+
+func threshold(value float64) *float64 {
+	return &value
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	plantStore := store.NewMemoryStore(nil)
+	measurements := store.NewMemoryMeasurementStore()
+	readings := telemetry.NewMemoryStore(0, nil)
+	fern, err := plantStore.Create(ctx, plants.Plant{Name: "fern", Tags: []string{"office"}})
+	require.NoError(t, err)
+	cactus, err := plantStore.Create(ctx, plants.Plant{Name: "cactus", Tags: []string{"home"}})
+	require.NoError(t, err)
+	_, err = plantStore.CreateCareEvent(ctx, plants.CareEvent{PlantID: fern.ID, Type: plants.CareWatering, Time: start.AddDate(0, 0, -11)})
+	require.NoError(t, err)
+	for _, m := range []plants.Measurement{
+		{PlantID: cactus.ID, Time: start.AddDate(0, 0, -40), Value: 10, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: cactus.ID, Time: start.AddDate(0, 0, -35), Value: 12, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: cactus.ID, Time: start.AddDate(0, 0, -31), Value: 120, Unit: plants.UNIT_MILLIMETERS},
+		{PlantID: fern.ID, Time: start.AddDate(0, 0, -60), Value: 5, Unit: plants.UNIT_CENTIMETERS},
+	} {
+		_, err := measurements.CreateMeasurement(ctx, m)
+		require.NoError(t, err)
+	}
+	reading := func(plantID, metric string, value float64, at time.Time) {
+		t.Helper()
+		require.NoError(t, readings.AppendReadings(ctx, []telemetry.Reading{{SensorID: "s1", PlantID: plantID, Metric: metric, Time: at, Value: value}}))
+	}
+	reading(fern.ID, telemetry.METRIC_SOIL_MOISTURE, 15, start.Add(-10*time.Minute))
+	reading(fern.ID, telemetry.METRIC_TEMPERATURE, 35, start.Add(-10*time.Minute))
+	reading(cactus.ID, telemetry.METRIC_SOIL_MOISTURE, 50, start.Add(-10*time.Minute))
+	// NOTE: older than an hour, so dark never matches
+	reading(cactus.ID, telemetry.METRIC_LIGHT, 5, start.Add(-2*time.Hour))
+
+	alertStore := NewMemoryStore(10)
+	for _, rule := range []Rule{
+		{Name: "dry", Type: RuleThreshold, Metric: telemetry.METRIC_SOIL_MOISTURE, Operator: OPERATOR_LESS, Threshold: threshold(20), For: Duration(2 * time.Hour), MaxAge: Duration(3 * time.Hour)},
+		{Name: "dark", Type: RuleThreshold, Metric: telemetry.METRIC_LIGHT, Operator: OPERATOR_LESS, Threshold: threshold(100)},
+		{Name: "hot", Type: RuleThreshold, Metric: telemetry.METRIC_TEMPERATURE, Operator: OPERATOR_GREATER, Threshold: threshold(30), For: Duration(time.Hour)},
+		{Name: "stunted", Type: RuleHeightUnchanged, Days: 30, Tags: []string{"home"}},
+		{Name: "thirsty", Type: RuleCareMissed, CareType: plants.CareWatering, Days: 10},
+	} {
+		require.Empty(t, rule.Valid())
+		_, err := alertStore.CreateRule(ctx, rule)
+		require.NoError(t, err)
+	}
+
+	var sent []Alert
+	now := start
+	evaluator := NewEvaluator(alertStore, Sources{Plants: plantStore, Care: plantStore, Measurements: measurements, Telemetry: readings},
+		SinkFunc(func(ctx context.Context, alert Alert, plant plants.Plant) error {
+			sent = append(sent, alert)
+			return nil
+		}))
+	evaluator.now = func() time.Time { return now }
+	evaluate := func(at time.Time) ([]string, []string) {
+		t.Helper()
+		now, sent = at, nil
+		require.NoError(t, evaluator.Evaluate(ctx))
+		all, err := alertStore.ListAlerts(ctx, AlertFilter{})
+		require.NoError(t, err)
+		return summarize(all), summarize(sent)
+	}
+
+	gotAlerts, gotSent := evaluate(start)
+	assert.Equal(t, []string{"dry/fern: pending 15", "hot/fern: pending 35", "stunted/cactus: firing 35", "thirsty/cactus: firing -", "thirsty/fern: firing 11"}, gotAlerts)
+	assert.Equal(t, []string{"stunted/cactus: firing 35", "thirsty/cactus: firing -", "thirsty/fern: firing 11"}, gotSent)
+
+	// NOTE: hot stops matching before it fired, so it is dropped without a notification
+	reading(fern.ID, telemetry.METRIC_TEMPERATURE, 20, start.Add(50*time.Minute))
+	gotAlerts, gotSent = evaluate(start.Add(time.Hour))
+	assert.Equal(t, []string{"dry/fern: pending 15", "stunted/cactus: firing 35", "thirsty/cactus: firing -", "thirsty/fern: firing 11"}, gotAlerts)
+	assert.Empty(t, gotSent)
+
+	gotAlerts, gotSent = evaluate(start.Add(2 * time.Hour))
+	assert.Equal(t, []string{"dry/fern: firing 15", "stunted/cactus: firing 35", "thirsty/cactus: firing -", "thirsty/fern: firing 11"}, gotAlerts)
+	assert.Equal(t, []string{"dry/fern: firing 15"}, gotSent)
+
+	_, err = plantStore.CreateCareEvent(ctx, plants.CareEvent{PlantID: fern.ID, Type: plants.CareWatering, Time: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	reading(fern.ID, telemetry.METRIC_SOIL_MOISTURE, 40, start.Add(2*time.Hour))
+	_, err = plantStore.Delete(ctx, cactus.ID)
+	require.NoError(t, err)
+	gotAlerts, gotSent = evaluate(start.Add(3 * time.Hour))
+	assert.Equal(t, []string{"dry/fern: resolved 15", "stunted/cactus: resolved 35", "thirsty/cactus: resolved -", "thirsty/fern: resolved 11"}, gotAlerts)
+	assert.Equal(t, gotAlerts, gotSent)
+
+	// NOTE: matching again opens a new alert, the resolved one stays in the history
+	gotAlerts, gotSent = evaluate(start.AddDate(0, 0, 11))
+	assert.Contains(t, gotAlerts, "thirsty/fern: firing 10")
+	assert.Contains(t, gotAlerts, "thirsty/fern: resolved 11")
+	assert.Equal(t, []string{"thirsty/fern: firing 10"}, gotSent)
+}
+
+func TestRuleValid(t *testing.T) {
+	tests := map[string]struct {
+		rule Rule
+
+		want map[string]string
+	}{
+		"threshold": {
+			rule: Rule{Name: "dry", Type: RuleThreshold, Metric: "wind", Operator: "=", For: Duration(-time.Second), MaxAge: Duration(-time.Minute)},
+
+			want: map[string]string{
+				"metric":    "metric must be one of: soil_moisture, temperature, light",
+				"operator":  "operator must be one of: <, <=, >, >=",
+				"threshold": "threshold is required for threshold rules",
+				"for":       "for cannot be negative",
+				"maxAge":    "maxAge cannot be negative",
+			},
+		},
+		"care missed": {
+			rule: Rule{Name: " ", Type: RuleCareMissed, CareType: "singing"},
+
+			want: map[string]string{
+				"name":     "name cannot be empty",
+				"careType": "care type must be one of: watering, fertilizing, repotting, pruning",
+				"days":     "days must be at least 1",
+			},
+		},
+		"unknown type": {
+			rule: Rule{Name: "tall", Type: "tall", Tags: []string{""}},
+
+			want: map[string]string{
+				"type": "type must be one of: threshold, heightUnchanged, careMissed",
+				"tags": "tags cannot be empty",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.rule.Valid())
+		})
+	}
+}
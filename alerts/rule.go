@@ -0,0 +1,174 @@
+package alerts
+
+import (
+	"fmt"
+	"plants/plants"
+	"plants/telemetry"
+	"slices"
+	"strings"
+	"time"
+)
+
+type RuleType string
+
+const (
+	// RuleThreshold compares the latest telemetry reading of a metric to a threshold, e.g. soil_moisture < 20
+	RuleThreshold RuleType = "threshold"
+	// RuleHeightUnchanged matches plants whose measured height hasnt changed in a number of days
+	RuleHeightUnchanged RuleType = "heightUnchanged"
+	// RuleCareMissed matches plants without a care event of a type in a number of days
+	RuleCareMissed RuleType = "careMissed"
+)
+
+var ruleTypes = []RuleType{RuleThreshold, RuleHeightUnchanged, RuleCareMissed}
+
+// threshold comparison operators
+const (
+	OPERATOR_LESS          = "<"
+	OPERATOR_LESS_EQUAL    = "<="
+	OPERATOR_GREATER       = ">"
+	OPERATOR_GREATER_EQUAL = ">="
+)
+
+var operators = []string{OPERATOR_LESS, OPERATOR_LESS_EQUAL, OPERATOR_GREATER, OPERATOR_GREATER_EQUAL}
+
+// DEFAULT_MAX_AGE is how old a reading can be for threshold rules without a maxAge
+const DEFAULT_MAX_AGE = time.Hour
+
+// Duration is a time.Duration written as a string like "2h" in JSON
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("duration '%s' must be like 30m or 2h", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Rule describes when plants need attention, every plant it matches gets its own alert
+type Rule struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Type RuleType `json:"type"`
+	// Tags limits the rule to plants with any of them, empty means every plant
+	Tags []string `json:"tags,omitempty"`
+	// Metric, Operator and Threshold are set on RuleThreshold rules
+	Metric    string   `json:"metric,omitempty"`
+	Operator  string   `json:"operator,omitempty"`
+	Threshold *float64 `json:"threshold,omitempty"`
+	// MaxAge is set on RuleThreshold rules, older readings are ignored, zero means DEFAULT_MAX_AGE
+	MaxAge Duration `json:"maxAge,omitempty"`
+	// Days is set on RuleHeightUnchanged and RuleCareMissed rules
+	Days int `json:"days,omitempty"`
+	// CareType is set on RuleCareMissed rules
+	CareType plants.CareType `json:"careType,omitempty"`
+	// For is how long the condition has to hold before the alert fires, until then the alert is pending
+	For Duration `json:"for,omitempty"`
+}
+
+func (r Rule) Valid() map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(r.Name) == "" {
+		problems["name"] = "name cannot be empty"
+	}
+
+	if slices.Contains(r.Tags, "") {
+		problems["tags"] = "tags cannot be empty"
+	}
+
+	if r.For < 0 {
+		problems["for"] = "for cannot be negative"
+	}
+
+	switch r.Type {
+	case RuleThreshold:
+		if !telemetry.ValidMetric(r.Metric) {
+			problems["metric"] = fmt.Sprintf("metric must be one of: %s, %s, %s", telemetry.METRIC_SOIL_MOISTURE, telemetry.METRIC_TEMPERATURE, telemetry.METRIC_LIGHT)
+		}
+		if !slices.Contains(operators, r.Operator) {
+			problems["operator"] = "operator must be one of: " + strings.Join(operators, ", ")
+		}
+		if r.Threshold == nil {
+			problems["threshold"] = "threshold is required for threshold rules"
+		}
+		if r.MaxAge < 0 {
+			problems["maxAge"] = "maxAge cannot be negative"
+		}
+	case RuleCareMissed:
+		if !r.CareType.Valid() {
+			problems["careType"] = fmt.Sprintf("care type must be one of: %s, %s, %s, %s", plants.CareWatering, plants.CareFertilizing, plants.CareRepotting, plants.CarePruning)
+		}
+		fallthrough
+	case RuleHeightUnchanged:
+		if r.Days < 1 {
+			problems["days"] = "days must be at least 1"
+		}
+	default:
+		problems["type"] = fmt.Sprintf("type must be one of: %s, %s, %s", RuleThreshold, RuleHeightUnchanged, RuleCareMissed)
+	}
+
+	return problems
+}
+
+// compare reports whether value matches a RuleThreshold rule
+func (r Rule) compare(value float64) bool {
+	switch r.Operator {
+	case OPERATOR_LESS:
+		return value < *r.Threshold
+	case OPERATOR_LESS_EQUAL:
+		return value <= *r.Threshold
+	case OPERATOR_GREATER:
+		return value > *r.Threshold
+	case OPERATOR_GREATER_EQUAL:
+		return value >= *r.Threshold
+	}
+	return false
+}
+
+// maxAge is how old a reading can be for a RuleThreshold rule
+func (r Rule) maxAge() time.Duration {
+	if r.MaxAge == 0 {
+		return DEFAULT_MAX_AGE
+	}
+	return time.Duration(r.MaxAge)
+}
+
+type State string
+
+const (
+	// StatePending alerts match their rule, but not for long enough yet
+	StatePending State = "pending"
+	StateFiring  State = "firing"
+	// StateResolved alerts stopped matching after they fired, they are kept as history
+	StateResolved State = "resolved"
+)
+
+var states = []State{StatePending, StateFiring, StateResolved}
+
+// Valid reports whether s is one of the known states
+func (s State) Valid() bool {
+	return slices.Contains(states, s)
+}
+
+// Alert is a rule matching a single plant, there is at most one pending or firing alert per rule and plant
+type Alert struct {
+	ID        string `json:"id"`
+	RuleID    string `json:"ruleId"`
+	RuleName  string `json:"ruleName"`
+	PlantID   string `json:"plantId"`
+	PlantName string `json:"plantName"`
+	State     State  `json:"state"`
+	// Value is what the rule matched, the reading for threshold rules and days for the others,
+	// it is nil when there is nothing to compare, e.g. care that never happened
+	Value *float64 `json:"value,omitempty"`
+	// ActiveAt is when the rule started matching
+	ActiveAt   time.Time  `json:"activeAt"`
+	FiredAt    *time.Time `json:"firedAt,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
@@ -0,0 +1,165 @@
+package alerts
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"plants/store"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// AlertFilter narrows ListAlerts down, empty fields match everything
+type AlertFilter struct {
+	State   State
+	RuleID  string
+	PlantID string
+}
+
+// Store keeps rules and their alerts, missing ones are reported with store.ErrorResourceDoesNotExist
+type Store interface {
+	// ListRules returns all rules, ordered by name
+	ListRules(ctx context.Context) ([]Rule, error)
+	FindRule(ctx context.Context, id string) (*Rule, error)
+	CreateRule(ctx context.Context, rule Rule) (*Rule, error)
+	UpdateRule(ctx context.Context, rule Rule) (*Rule, error)
+	// DeleteRule removes a rule, its open alerts are resolved by the next evaluation
+	DeleteRule(ctx context.Context, id string) error
+	// ListAlerts returns alerts, the most recently active first
+	ListAlerts(ctx context.Context, filter AlertFilter) ([]Alert, error)
+	// SaveAlert creates an alert when its ID is empty and replaces it otherwise
+	SaveAlert(ctx context.Context, alert Alert) (*Alert, error)
+	DeleteAlert(ctx context.Context, id string) error
+}
+
+type MemoryStore struct {
+	mu     sync.RWMutex
+	rules  map[string]Rule
+	alerts map[string]Alert
+	// historySize is how many resolved alerts are kept, the oldest ones are dropped first
+	historySize int
+}
+
+func NewMemoryStore(historySize int) *MemoryStore {
+	return &MemoryStore{
+		rules:       make(map[string]Rule),
+		alerts:      make(map[string]Alert),
+		historySize: historySize,
+	}
+}
+
+func ruleNotFound(id string) error {
+	return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("alert rule with ID '%s' does not exist", id)}
+}
+
+func (s *MemoryStore) ListRules(ctx context.Context) ([]Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Rule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		list = append(list, rule)
+	}
+	slices.SortFunc(list, func(a, b Rule) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return list, nil
+}
+
+func (s *MemoryStore) FindRule(ctx context.Context, id string) (*Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rule, ok := s.rules[id]
+	if !ok {
+		return nil, ruleNotFound(id)
+	}
+	return &rule, nil
+}
+
+func (s *MemoryStore) CreateRule(ctx context.Context, rule Rule) (*Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule.ID = uuid.New().String()
+	s.rules[rule.ID] = rule
+	return &rule, nil
+}
+
+func (s *MemoryStore) UpdateRule(ctx context.Context, rule Rule) (*Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[rule.ID]; !ok {
+		return nil, ruleNotFound(rule.ID)
+	}
+	s.rules[rule.ID] = rule
+	return &rule, nil
+}
+
+func (s *MemoryStore) DeleteRule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[id]; !ok {
+		return ruleNotFound(id)
+	}
+	delete(s.rules, id)
+	return nil
+}
+
+func (s *MemoryStore) ListAlerts(ctx context.Context, filter AlertFilter) ([]Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := []Alert{}
+	for _, alert := range s.alerts {
+		if (filter.State == "" || alert.State == filter.State) &&
+			(filter.RuleID == "" || alert.RuleID == filter.RuleID) &&
+			(filter.PlantID == "" || alert.PlantID == filter.PlantID) {
+			list = append(list, alert)
+		}
+	}
+	slices.SortFunc(list, func(a, b Alert) int {
+		return cmp.Or(b.ActiveAt.Compare(a.ActiveAt), cmp.Compare(a.ID, b.ID))
+	})
+	return list, nil
+}
+
+func (s *MemoryStore) SaveAlert(ctx context.Context, alert Alert) (*Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if alert.ID == "" {
+		alert.ID = uuid.New().String()
+	} else if _, ok := s.alerts[alert.ID]; !ok {
+		return nil, store.ErrorResourceDoesNotExist{Err: fmt.Errorf("alert with ID '%s' does not exist", alert.ID)}
+	}
+	s.alerts[alert.ID] = alert
+	if alert.State == StateResolved {
+		s.trimHistory()
+	}
+	return &alert, nil
+}
+
+// trimHistory drops the oldest resolved alerts over historySize, the caller holds the lock
+func (s *MemoryStore) trimHistory() {
+	var resolved []Alert
+	for _, alert := range s.alerts {
+		if alert.State == StateResolved {
+			resolved = append(resolved, alert)
+		}
+	}
+	if len(resolved) <= s.historySize {
+		return
+	}
+	slices.SortFunc(resolved, func(a, b Alert) int { return a.ResolvedAt.Compare(*b.ResolvedAt) })
+	for _, alert := range resolved[:len(resolved)-s.historySize] {
+		delete(s.alerts, alert.ID)
+	}
+}
+
+func (s *MemoryStore) DeleteAlert(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.alerts[id]; !ok {
+		return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("alert with ID '%s' does not exist", id)}
+	}
+	delete(s.alerts, id)
+	return nil
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+)
+
+// methods an identity can be established with
+const METHOD_MTLS = "mtls"
+const METHOD_TOKEN = "token"
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Subject uniquely names the caller, for client certificates its the subject common name
+	Subject string
+	Method  string
+	// DNSNames are the subject alternative names of a client certificate, if any
+	DNSNames []string
+}
+
+type identityCtxKey string
+
+const CONTEXT_IDENTITY identityCtxKey = "ctx.identity"
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, CONTEXT_IDENTITY, identity)
+}
+
+func IdentityFromCtx(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(CONTEXT_IDENTITY).(Identity)
+	return identity, ok
+}
+
+// FromCertificate builds an identity out of an already verified client certificate
+func FromCertificate(cert *x509.Certificate) Identity {
+	return Identity{
+		Subject:  cert.Subject.CommonName,
+		Method:   METHOD_MTLS,
+		DNSNames: cert.DNSNames,
+	}
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// REDACTED replaces secret values when printing the config
+const REDACTED = "[REDACTED]"
+
+// ENV_FILE_SUFFIX lets any env variable be read from a file instead, e.g. API_ADMIN_TOKEN_FILE=/run/secrets/token,
+// which is how docker and kubernetes secrets are usually mounted
+const ENV_FILE_SUFFIX = "_FILE"
+
+// setting describes a single config value and where it can come from in each layer
+type setting struct {
+	// key is the dotted path in the config file, e.g. "tls.certFile"
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	// boolFlag allows the flag to be passed without a value, e.g. -log-add-source
+	boolFlag bool
+	set      func(s *Server, value string) error
+	get      func(s Server) string
+}
+
+// flagValue collects raw flag input, so flags go through the same setters as the other layers
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.value }
+func (v *flagValue) Set(s string) error { v.value = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+func stringSetting(key, env, flagName, usage string, field func(s *Server) *string) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		flag:  flagName,
+		usage: usage,
+		set: func(s *Server, value string) error {
+			*field(s) = value
+			return nil
+		},
+		get: func(s Server) string { return *field(&s) },
+	}
+}
+
+func secretSetting(key, env, flagName, usage string, field func(s *Server) *string) setting {
+	st := stringSetting(key, env, flagName, usage, field)
+	st.secret = true
+	return st
+}
+
+func durationSetting(key, env, flagName, usage string, field func(s *Server) *time.Duration) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		flag:  flagName,
+		usage: usage,
+		set: func(s *Server, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			*field(s) = d
+			return nil
+		},
+		get: func(s Server) string { return field(&s).String() },
+	}
+}
+
+func intSetting(key, env, flagName, usage string, field func(s *Server) *int) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		flag:  flagName,
+		usage: usage,
+		set: func(s *Server, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			*field(s) = n
+			return nil
+		},
+		get: func(s Server) string { return strconv.Itoa(*field(&s)) },
+	}
+}
+
+func floatSetting(key, env, flagName, usage string, field func(s *Server) *float64) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		flag:  flagName,
+		usage: usage,
+		set: func(s *Server, value string) error {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			*field(s) = f
+			return nil
+		},
+		get: func(s Server) string { return strconv.FormatFloat(*field(&s), 'f', -1, 64) },
+	}
+}
+
+func boolSetting(key, env, flagName, usage string, field func(s *Server) *bool) setting {
+	return setting{
+		key:      key,
+		env:      env,
+		flag:     flagName,
+		usage:    usage,
+		boolFlag: true,
+		set: func(s *Server, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			*field(s) = b
+			return nil
+		},
+		get: func(s Server) string { return strconv.FormatBool(*field(&s)) },
+	}
+}
+
+// listSetting reads comma separated values, e.g. "GET, POST"
+func listSetting(key, env, flagName, usage string, field func(s *Server) *[]string) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		flag:  flagName,
+		usage: usage,
+		set: func(s *Server, value string) error {
+			var list []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			*field(s) = list
+			return nil
+		},
+		get: func(s Server) string { return strings.Join(*field(&s), ",") },
+	}
+}
+
+var settings = []setting{
+	stringSetting("host", ENV_API_HOST, "host", "host to listen on", func(s *Server) *string { return &s.Host }),
+	stringSetting("port", ENV_API_PORT, "port", "port to listen on", func(s *Server) *string { return &s.Port }),
+	durationSetting("shutdownTimeout", ENV_API_SHUTDOWN_TIMEOUT, "shutdown-timeout", "how long to drain requests on shutdown", func(s *Server) *time.Duration { return &s.ShutdownTimeout }),
+	durationSetting("shutdownDelay", ENV_API_SHUTDOWN_DELAY, "shutdown-delay", "how long readyz reports not-ready before the listener closes on shutdown", func(s *Server) *time.Duration { return &s.ShutdownDelay }),
+	intSetting("maxBodyBytes", ENV_API_MAX_BODY_BYTES, "max-body-bytes", "maximum size of JSON request bodies", func(s *Server) *int { return &s.MaxBodyBytes }),
+	intSetting("compressionMinBytes", ENV_API_COMPRESSION_MIN_BYTES, "compression-min-bytes", "smallest response body worth compressing", func(s *Server) *int { return &s.CompressionMinBytes }),
+	secretSetting("adminToken", ENV_API_ADMIN_TOKEN, "admin-token", "bearer token for admin-only routes", func(s *Server) *string { return &s.AdminToken }),
+	stringSetting("tls.certFile", ENV_API_TLS_CERT_FILE, "tls-cert-file", "PEM certificate file, enables https", func(s *Server) *string { return &s.TLS.CertFile }),
+	stringSetting("tls.keyFile", ENV_API_TLS_KEY_FILE, "tls-key-file", "PEM private key file, enables https", func(s *Server) *string { return &s.TLS.KeyFile }),
+	stringSetting("tls.clientCAFile", ENV_API_TLS_CLIENT_CA_FILE, "tls-client-ca-file", "PEM CA bundle for verifying client certificates", func(s *Server) *string { return &s.TLS.ClientCAFile }),
+	stringSetting("tls.clientAuth", ENV_API_TLS_CLIENT_AUTH, "tls-client-auth", "client certificate policy: none, optional or require", func(s *Server) *string { return &s.TLS.ClientAuth }),
+	stringSetting("tls.minVersion", ENV_API_TLS_MIN_VERSION, "tls-min-version", "minimum tls version: 1.2 or 1.3", func(s *Server) *string { return &s.TLS.MinVersion }),
+	stringSetting("log.level", ENV_API_LOG_LEVEL, "log-level", "initial log level: debug, info, warn or error", func(s *Server) *string { return &s.Log.Level }),
+	stringSetting("log.format", ENV_API_LOG_FORMAT, "log-format", "log format: text or json", func(s *Server) *string { return &s.Log.Format }),
+	boolSetting("log.addSource", ENV_API_LOG_ADD_SOURCE, "log-add-source", "include source file and line in logs", func(s *Server) *bool { return &s.Log.AddSource }),
+	boolSetting("log.redact", ENV_API_LOG_REDACT, "log-redact", "mask sensitive attributes, query parameters and headers", func(s *Server) *bool { return &s.Log.Redact }),
+	durationSetting("log.sampleInterval", ENV_API_LOG_SAMPLE_INTERVAL, "log-sample-interval", "window identical log records are counted in", func(s *Server) *time.Duration { return &s.Log.SampleInterval }),
+	intSetting("log.sampleFirst", ENV_API_LOG_SAMPLE_FIRST, "log-sample-first", "identical log records kept per interval, 0 disables sampling", func(s *Server) *int { return &s.Log.SampleFirst }),
+	intSetting("log.sampleThereafter", ENV_API_LOG_SAMPLE_THEREAFTER, "log-sample-thereafter", "keep one in this many identical records after the first ones", func(s *Server) *int { return &s.Log.SampleThereafter }),
+	stringSetting("log.output", ENV_API_LOG_OUTPUT, "log-output", "log destination: stderr, stdout or a file path", func(s *Server) *string { return &s.Log.Output }),
+	floatSetting("rateLimit.readRate", ENV_API_RATE_LIMIT_READ_RATE, "rate-limit-read-rate", "read requests per second per client, 0 disables", func(s *Server) *float64 { return &s.RateLimit.ReadRate }),
+	intSetting("rateLimit.readBurst", ENV_API_RATE_LIMIT_READ_BURST, "rate-limit-read-burst", "read requests a client can burst", func(s *Server) *int { return &s.RateLimit.ReadBurst }),
+	floatSetting("rateLimit.writeRate", ENV_API_RATE_LIMIT_WRITE_RATE, "rate-limit-write-rate", "write requests per second per client, 0 disables", func(s *Server) *float64 { return &s.RateLimit.WriteRate }),
+	intSetting("rateLimit.writeBurst", ENV_API_RATE_LIMIT_WRITE_BURST, "rate-limit-write-burst", "write requests a client can burst", func(s *Server) *int { return &s.RateLimit.WriteBurst }),
+	listSetting("cors.allowedOrigins", ENV_API_CORS_ALLOWED_ORIGINS, "cors-allowed-origins", "comma separated origins allowed to call the API, e.g. https://*.example.com, empty disables CORS", func(s *Server) *[]string { return &s.CORS.AllowedOrigins }),
+	listSetting("cors.allowedMethods", ENV_API_CORS_ALLOWED_METHODS, "cors-allowed-methods", "comma separated methods allowed in cross-origin requests", func(s *Server) *[]string { return &s.CORS.AllowedMethods }),
+	listSetting("cors.allowedHeaders", ENV_API_CORS_ALLOWED_HEADERS, "cors-allowed-headers", "comma separated request headers allowed in cross-origin requests", func(s *Server) *[]string { return &s.CORS.AllowedHeaders }),
+	listSetting("cors.exposedHeaders", ENV_API_CORS_EXPOSED_HEADERS, "cors-exposed-headers", "comma separated response headers readable by cross-origin scripts", func(s *Server) *[]string { return &s.CORS.ExposedHeaders }),
+	boolSetting("cors.allowCredentials", ENV_API_CORS_ALLOW_CREDENTIALS, "cors-allow-credentials", "allow cookies and authorization headers in cross-origin requests", func(s *Server) *bool { return &s.CORS.AllowCredentials }),
+	durationSetting("cors.maxAge", ENV_API_CORS_MAX_AGE, "cors-max-age", "how long browsers cache preflight responses", func(s *Server) *time.Duration { return &s.CORS.MaxAge }),
+	intSetting("events.replaySize", ENV_API_EVENTS_REPLAY_SIZE, "events-replay-size", "recent plant events kept for resuming clients", func(s *Server) *int { return &s.Events.ReplaySize }),
+	intSetting("events.clientBuffer", ENV_API_EVENTS_CLIENT_BUFFER, "events-client-buffer", "plant events a client can fall behind by before it is disconnected", func(s *Server) *int { return &s.Events.ClientBuffer }),
+	durationSetting("events.heartbeat", ENV_API_EVENTS_HEARTBEAT, "events-heartbeat", "how often idle event streams get a heartbeat", func(s *Server) *time.Duration { return &s.Events.Heartbeat }),
+	intSetting("webSocket.maxMessageBytes", ENV_API_WEBSOCKET_MAX_MESSAGE_BYTES, "websocket-max-message-bytes", "maximum size of websocket client messages", func(s *Server) *int { return &s.WebSocket.MaxMessageBytes }),
+	durationSetting("webSocket.pingInterval", ENV_API_WEBSOCKET_PING_INTERVAL, "websocket-ping-interval", "how often websocket clients are pinged", func(s *Server) *time.Duration { return &s.WebSocket.PingInterval }),
+	intSetting("webhooks.workers", ENV_API_WEBHOOKS_WORKERS, "webhooks-workers", "webhook deliveries sent concurrently", func(s *Server) *int { return &s.Webhooks.Workers }),
+	intSetting("webhooks.maxAttempts", ENV_API_WEBHOOKS_MAX_ATTEMPTS, "webhooks-max-attempts", "attempts before a webhook delivery is dead-lettered", func(s *Server) *int { return &s.Webhooks.MaxAttempts }),
+	durationSetting("webhooks.initialBackoff", ENV_API_WEBHOOKS_INITIAL_BACKOFF, "webhooks-initial-backoff", "wait before the first webhook retry, doubled for every retry", func(s *Server) *time.Duration { return &s.Webhooks.InitialBackoff }),
+	durationSetting("webhooks.maxBackoff", ENV_API_WEBHOOKS_MAX_BACKOFF, "webhooks-max-backoff", "longest wait between webhook retries", func(s *Server) *time.Duration { return &s.Webhooks.MaxBackoff }),
+	durationSetting("webhooks.timeout", ENV_API_WEBHOOKS_TIMEOUT, "webhooks-timeout", "timeout of a single webhook delivery attempt", func(s *Server) *time.Duration { return &s.Webhooks.Timeout }),
+	intSetting("webhooks.historySize", ENV_API_WEBHOOKS_HISTORY_SIZE, "webhooks-history-size", "webhook deliveries kept per subscription", func(s *Server) *int { return &s.Webhooks.HistorySize }),
+	stringSetting("scheduler.instance", ENV_API_SCHEDULER_INSTANCE, "scheduler-instance", "name of this replica for job leader election, defaults to the hostname", func(s *Server) *string { return &s.Scheduler.Instance }),
+	stringSetting("scheduler.timeZone", ENV_API_SCHEDULER_TIME_ZONE, "scheduler-time-zone", "IANA time zone job schedules are evaluated in", func(s *Server) *string { return &s.Scheduler.TimeZone }),
+	durationSetting("scheduler.jitter", ENV_API_SCHEDULER_JITTER, "scheduler-jitter", "longest random delay added to every job run", func(s *Server) *time.Duration { return &s.Scheduler.Jitter }),
+	stringSetting("scheduler.overdueReminders", ENV_API_SCHEDULER_OVERDUE_REMINDERS, "scheduler-overdue-reminders", "cron schedule of overdue watering reminders, empty disables", func(s *Server) *string { return &s.Scheduler.OverdueReminders }),
+	stringSetting("scheduler.overdueDigest", ENV_API_SCHEDULER_OVERDUE_DIGEST, "scheduler-overdue-digest", "cron schedule of the overdue plants digest, empty disables", func(s *Server) *string { return &s.Scheduler.OverdueDigest }),
+	stringSetting("scheduler.alertEvaluation", ENV_API_SCHEDULER_ALERT_EVALUATION, "scheduler-alert-evaluation", "cron schedule of alert rule evaluation, empty disables", func(s *Server) *string { return &s.Scheduler.AlertEvaluation }),
+	stringSetting("smtp.host", ENV_API_SMTP_HOST, "smtp-host", "SMTP server for email notifications, empty disables email", func(s *Server) *string { return &s.SMTP.Host }),
+	stringSetting("smtp.port", ENV_API_SMTP_PORT, "smtp-port", "SMTP server port", func(s *Server) *string { return &s.SMTP.Port }),
+	stringSetting("smtp.username", ENV_API_SMTP_USERNAME, "smtp-username", "SMTP username, empty disables authentication", func(s *Server) *string { return &s.SMTP.Username }),
+	secretSetting("smtp.password", ENV_API_SMTP_PASSWORD, "smtp-password", "SMTP password", func(s *Server) *string { return &s.SMTP.Password }),
+	stringSetting("smtp.from", ENV_API_SMTP_FROM, "smtp-from", "sender of email notifications, e.g. Plants <plants@example.com>", func(s *Server) *string { return &s.SMTP.From }),
+	intSetting("smtp.attempts", ENV_API_SMTP_ATTEMPTS, "smtp-attempts", "attempts before an email is given up on", func(s *Server) *int { return &s.SMTP.Attempts }),
+	durationSetting("smtp.backoff", ENV_API_SMTP_BACKOFF, "smtp-backoff", "wait before the first email retry, doubled for every retry", func(s *Server) *time.Duration { return &s.SMTP.Backoff }),
+	durationSetting("smtp.timeout", ENV_API_SMTP_TIMEOUT, "smtp-timeout", "timeout of a single email attempt", func(s *Server) *time.Duration { return &s.SMTP.Timeout }),
+	intSetting("telemetry.queueSize", ENV_API_TELEMETRY_QUEUE_SIZE, "telemetry-queue-size", "telemetry batches waiting to be stored before ingestion answers 503", func(s *Server) *int { return &s.Telemetry.QueueSize }),
+	intSetting("telemetry.workers", ENV_API_TELEMETRY_WORKERS, "telemetry-workers", "telemetry batches stored concurrently", func(s *Server) *int { return &s.Telemetry.Workers }),
+	intSetting("telemetry.maxBatchBytes", ENV_API_TELEMETRY_MAX_BATCH_BYTES, "telemetry-max-batch-bytes", "maximum size of a telemetry batch", func(s *Server) *int { return &s.Telemetry.MaxBatchBytes }),
+	durationSetting("telemetry.retention", ENV_API_TELEMETRY_RETENTION, "telemetry-retention", "how long telemetry readings are kept", func(s *Server) *time.Duration { return &s.Telemetry.Retention }),
+	intSetting("alerts.historySize", ENV_API_ALERTS_HISTORY_SIZE, "alerts-history-size", "resolved alerts kept", func(s *Server) *int { return &s.Alerts.HistorySize }),
+	stringSetting("photos.dir", ENV_API_PHOTOS_DIR, "photos-dir", "directory plant photos are stored in", func(s *Server) *string { return &s.Photos.Dir }),
+	intSetting("photos.maxUploadBytes", ENV_API_PHOTOS_MAX_UPLOAD_BYTES, "photos-max-upload-bytes", "maximum size of a photo upload", func(s *Server) *int { return &s.Photos.MaxUploadBytes }),
+	intSetting("photos.thumbnailSize", ENV_API_PHOTOS_THUMBNAIL_SIZE, "photos-thumbnail-size", "longest side of photo thumbnails in pixels", func(s *Server) *int { return &s.Photos.ThumbnailSize }),
+	boolSetting("species.loadBundled", ENV_API_SPECIES_LOAD_BUNDLED, "species-load-bundled", "load the bundled species catalog on startup", func(s *Server) *bool { return &s.Species.LoadBundled }),
+}
+
+// Options are command line switches that are not part of the server config itself
+type Options struct {
+	// PrintConfig asks to print the effective config and exit
+	PrintConfig bool
+}
+
+// Load builds the effective config out of layers, each overriding the previous one:
+// defaults < config file (yaml, json or toml) < environment variables < command line flags.
+// All problems found along the way are returned together.
+func Load(args []string, getenv func(string) string, output io.Writer) (Server, Options, error) {
+	cfg := NewDefaultServer()
+	var opts Options
+
+	name := "plants"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	configFile := fs.String("config", getenv(ENV_API_CONFIG_FILE), "path to a .yaml, .json or .toml config file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+	flagValues := make(map[string]*flagValue, len(settings))
+	for _, st := range settings {
+		flagValues[st.flag] = &flagValue{isBool: st.boolFlag}
+		fs.Var(flagValues[st.flag], st.flag, st.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, opts, fmt.Errorf("parse flags: %w", err)
+	}
+
+	var errs []error
+	if *configFile != "" {
+		errs = append(errs, applyFile(&cfg, *configFile))
+	}
+
+	errs = append(errs, applyEnv(&cfg, getenv))
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, st := range settings {
+			if st.flag == f.Name {
+				if err := st.set(&cfg, flagValues[f.Name].value); err != nil {
+					errs = append(errs, fmt.Errorf("flag -%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return cfg, opts, err
+	}
+
+	return cfg, opts, cfg.Validate()
+}
+
+func applyEnv(cfg *Server, getenv func(string) string) error {
+	var errs []error
+	for _, st := range settings {
+		value := getenv(st.env)
+		if path := getenv(st.env + ENV_FILE_SUFFIX); path != "" {
+			if value != "" {
+				errs = append(errs, fmt.Errorf("env %s and %s are mutually exclusive", st.env, st.env+ENV_FILE_SUFFIX))
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", st.env+ENV_FILE_SUFFIX, err))
+				continue
+			}
+			value = strings.TrimSpace(string(content))
+		}
+
+		if value == "" {
+			continue
+		}
+		if err := st.set(cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("env %s: %w", st.env, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func applyFile(cfg *Server, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".json":
+		// NOTE: numbers are kept as written, as float64 large ones would print like 2.097152e+06
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return fmt.Errorf("unsupported config file extension '%s'", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file: %w", err)
+	}
+
+	values := make(map[string]string)
+	flatten("", raw, values)
+
+	var errs []error
+	for _, st := range settings {
+		value, ok := values[st.key]
+		if !ok {
+			continue
+		}
+		delete(values, st.key)
+		if err := st.set(cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("config file key '%s': %w", st.key, err))
+		}
+	}
+
+	for key := range values {
+		errs = append(errs, fmt.Errorf("config file key '%s' is unknown", key))
+	}
+
+	return errors.Join(errs...)
+}
+
+// flatten turns nested maps into dotted keys, e.g. {"tls": {"certFile": "x"}} into "tls.certFile"
+func flatten(prefix string, raw map[string]any, into map[string]string) {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		if nested, ok := v.(map[string]any); ok {
+			flatten(key, nested, into)
+			continue
+		}
+		// NOTE: lists are joined the same way they are written in env variables and flags
+		if list, ok := v.([]any); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			into[key] = strings.Join(items, ",")
+			continue
+		}
+		into[key] = fmt.Sprint(v)
+	}
+}
+
+// Print writes the effective config, one "key: value" per line, with secrets redacted
+func (s Server) Print(w io.Writer) error {
+	for _, st := range settings {
+		value := st.get(s)
+		if st.secret && value != "" {
+			value = REDACTED
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", st.key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
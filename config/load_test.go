@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", "host: 0.0.0.0\nport: 9000\nshutdownTimeout: 30s\ntls:\n  minVersion: \"1.3\"\n")
+	jsonFile := writeConfigFile(t, "config.json", `{"host": "0.0.0.0", "port": 9001, "tls": {"minVersion": "1.3"}}`)
+	tomlFile := writeConfigFile(t, "config.toml", "host = \"0.0.0.0\"\nport = 9002\n[tls]\nminVersion = \"1.3\"\n")
+	tokenFile := writeConfigFile(t, "token", "file-secret\n")
+
+	tests := map[string]struct {
+		args []string
+		env  map[string]string
+
+		want    func(s *Server)
+		wantErr []string
+	}{
+		"defaults": {
+			want: func(s *Server) {},
+		},
+		"yaml file": {
+			args: []string{"plants", "-config", yamlFile},
+
+			want: func(s *Server) {
+				s.Host = "0.0.0.0"
+				s.Port = "9000"
+				s.ShutdownTimeout = 30 * time.Second
+				s.TLS.MinVersion = "1.3"
+			},
+		},
+		"json file from env": {
+			env: map[string]string{ENV_API_CONFIG_FILE: jsonFile},
+
+			want: func(s *Server) {
+				s.Host = "0.0.0.0"
+				s.Port = "9001"
+				s.TLS.MinVersion = "1.3"
+			},
+		},
+		"json file with large numbers": {
+			args: []string{"plants", "-config", writeConfigFile(t, "limits.json", `{"maxBodyBytes": 2097152, "photos": {"maxUploadBytes": 10485760}}`)},
+
+			want: func(s *Server) {
+				s.MaxBodyBytes = 2097152
+				s.Photos.MaxUploadBytes = 10485760
+			},
+		},
+		"toml file": {
+			args: []string{"plants", "-config", tomlFile},
+
+			want: func(s *Server) {
+				s.Host = "0.0.0.0"
+				s.Port = "9002"
+				s.TLS.MinVersion = "1.3"
+			},
+		},
+		"env overrides file and flags override env": {
+			args: []string{"plants", "-config", yamlFile, "-port", "9100"},
+			env:  map[string]string{ENV_API_PORT: "9050", ENV_API_HOST: "127.0.0.1"},
+
+			want: func(s *Server) {
+				s.Host = "127.0.0.1"
+				s.Port = "9100"
+				s.ShutdownTimeout = 30 * time.Second
+				s.TLS.MinVersion = "1.3"
+			},
+		},
+		"lists from file and env": {
+			args: []string{"plants", "-config", writeConfigFile(t, "cors.yaml", "cors:\n  allowedOrigins:\n    - https://dashboard.example.com\n    - https://*.example.org\n")},
+			env:  map[string]string{ENV_API_CORS_ALLOWED_METHODS: "GET, POST"},
+
+			want: func(s *Server) {
+				s.CORS.AllowedOrigins = []string{"https://dashboard.example.com", "https://*.example.org"}
+				s.CORS.AllowedMethods = []string{"GET", "POST"}
+			},
+		},
+		"secret read from file": {
+			env: map[string]string{ENV_API_ADMIN_TOKEN + ENV_FILE_SUFFIX: tokenFile},
+
+			want: func(s *Server) { s.AdminToken = "file-secret" },
+		},
+		"value and file variable are exclusive": {
+			env: map[string]string{ENV_API_ADMIN_TOKEN: "a", ENV_API_ADMIN_TOKEN + ENV_FILE_SUFFIX: tokenFile},
+
+			wantErr: []string{"env API_ADMIN_TOKEN and API_ADMIN_TOKEN_FILE are mutually exclusive"},
+		},
+		"unknown file key": {
+			args: []string{"plants", "-config", writeConfigFile(t, "typo.yaml", "hots: x\n")},
+
+			wantErr: []string{"config file key 'hots' is unknown"},
+		},
+		"unsupported file extension": {
+			args: []string{"plants", "-config", writeConfigFile(t, "config.ini", "")},
+
+			wantErr: []string{"unsupported config file extension '.ini'"},
+		},
+		"validation errors are aggregated": {
+			args: []string{"plants", "-port", "http", "-tls-cert-file", "server.crt", "-tls-client-auth", "always"},
+			env:  map[string]string{ENV_API_TLS_MIN_VERSION: "1.1"},
+
+			wantErr: []string{
+				"port 'http' is not a valid port number",
+				"tls cert file and key file must be set together",
+				"tls client auth 'always' must be one of",
+				"tls min version '1.1' must be 1.2 or 1.3",
+			},
+		},
+		"invalid shutdown delay": {
+			env: map[string]string{ENV_API_SHUTDOWN_DELAY: "-1s"},
+
+			wantErr: []string{"shutdown delay cannot be negative"},
+		},
+		"invalid cors origins": {
+			args: []string{"plants", "-cors-allowed-origins", "*,example.com,https://a.*.example.com", "-cors-allow-credentials"},
+
+			wantErr: []string{
+				"cors origin 'example.com' must be",
+				"cors origin 'https://a.*.example.com' must be",
+				"cors credentials cannot be allowed for any origin '*'",
+			},
+		},
+		"invalid scheduler": {
+			env: map[string]string{ENV_API_SCHEDULER_TIME_ZONE: "Mars/Olympus", ENV_API_SCHEDULER_OVERDUE_REMINDERS: "0 25 * * *"},
+
+			wantErr: []string{
+				"scheduler time zone 'Mars/Olympus' must be an IANA time zone like Europe/Riga",
+				"scheduler overdue reminders: cron expression '0 25 * * *': hour '25' must be a number from 0 to 23",
+			},
+		},
+		"invalid smtp": {
+			env: map[string]string{ENV_API_SMTP_HOST: "smtp.example.com", ENV_API_SMTP_FROM: "plants", ENV_API_SMTP_ATTEMPTS: "0"},
+
+			wantErr: []string{
+				"smtp from 'plants' must be an address like Plants <plants@example.com>",
+				"smtp attempts must be at least 1",
+			},
+		},
+		"invalid telemetry": {
+			env: map[string]string{ENV_API_TELEMETRY_QUEUE_SIZE: "0", ENV_API_TELEMETRY_MAX_BATCH_BYTES: "-1", ENV_API_TELEMETRY_RETENTION: "0s"},
+
+			wantErr: []string{
+				"telemetry queue size must be at least 1",
+				"telemetry max batch bytes must be positive",
+				"telemetry retention must be positive",
+			},
+		},
+		"invalid alerts": {
+			env: map[string]string{ENV_API_ALERTS_HISTORY_SIZE: "0", ENV_API_SCHEDULER_ALERT_EVALUATION: "@every soon"},
+
+			wantErr: []string{
+				"alerts history size must be at least 1",
+				"scheduler alert evaluation",
+			},
+		},
+		"invalid photos": {
+			env: map[string]string{ENV_API_PHOTOS_DIR: " ", ENV_API_PHOTOS_MAX_UPLOAD_BYTES: "0", ENV_API_PHOTOS_THUMBNAIL_SIZE: "8"},
+
+			wantErr: []string{
+				"photos dir cannot be empty",
+				"photos max upload bytes must be positive",
+				"photos thumbnail size must be at least 16",
+			},
+		},
+		"parse errors are aggregated across layers": {
+			args: []string{"plants", "-shutdown-timeout", "soon"},
+			env:  map[string]string{ENV_API_SHUTDOWN_TIMEOUT: "later"},
+
+			wantErr: []string{"env API_SHUTDOWN_TIMEOUT", "flag -shutdown-timeout"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := Load(tc.args, func(k string) string { return tc.env[k] }, io.Discard)
+			if len(tc.wantErr) > 0 {
+				for _, want := range tc.wantErr {
+					assert.ErrorContains(t, err, want)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			want := NewDefaultServer()
+			tc.want(&want)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := NewDefaultServer()
+	cfg.AdminToken = "supersecret"
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Print(&buf))
+
+	assert.Contains(t, buf.String(), "adminToken: "+REDACTED+"\n")
+	assert.Contains(t, buf.String(), "port: 8080\n")
+	assert.NotContains(t, buf.String(), "supersecret")
+}
+
+func TestLoadPrintConfigFlag(t *testing.T) {
+	_, opts, err := Load([]string{"plants", "-print-config"}, func(string) string { return "" }, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, opts.PrintConfig)
+}
+
+func TestLoadHelpFlag(t *testing.T) {
+	var buf bytes.Buffer
+	_, _, err := Load([]string{"plants", "-h"}, func(string) string { return "" }, &buf)
+	assert.ErrorIs(t, err, flag.ErrHelp, "callers tell help apart from a bad flag")
+	assert.Contains(t, buf.String(), "-shutdown-timeout")
+}
@@ -1,49 +1,650 @@
 package config
 
-import "log/slog"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"plants/scheduler"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // env variable keys
+const ENV_API_CONFIG_FILE = "API_CONFIG_FILE"
 const ENV_API_HOST = "API_HOST"
 const ENV_API_PORT = "API_PORT"
+const ENV_API_SHUTDOWN_TIMEOUT = "API_SHUTDOWN_TIMEOUT"
+const ENV_API_SHUTDOWN_DELAY = "API_SHUTDOWN_DELAY"
+const ENV_API_MAX_BODY_BYTES = "API_MAX_BODY_BYTES"
+const ENV_API_COMPRESSION_MIN_BYTES = "API_COMPRESSION_MIN_BYTES"
+const ENV_API_ADMIN_TOKEN = "API_ADMIN_TOKEN"
+const ENV_API_TLS_CERT_FILE = "API_TLS_CERT_FILE"
+const ENV_API_TLS_KEY_FILE = "API_TLS_KEY_FILE"
+const ENV_API_TLS_CLIENT_CA_FILE = "API_TLS_CLIENT_CA_FILE"
+const ENV_API_TLS_CLIENT_AUTH = "API_TLS_CLIENT_AUTH"
+const ENV_API_TLS_MIN_VERSION = "API_TLS_MIN_VERSION"
+const ENV_API_LOG_LEVEL = "API_LOG_LEVEL"
+const ENV_API_LOG_FORMAT = "API_LOG_FORMAT"
+const ENV_API_LOG_ADD_SOURCE = "API_LOG_ADD_SOURCE"
+const ENV_API_LOG_OUTPUT = "API_LOG_OUTPUT"
+const ENV_API_LOG_REDACT = "API_LOG_REDACT"
+const ENV_API_LOG_SAMPLE_INTERVAL = "API_LOG_SAMPLE_INTERVAL"
+const ENV_API_LOG_SAMPLE_FIRST = "API_LOG_SAMPLE_FIRST"
+const ENV_API_LOG_SAMPLE_THEREAFTER = "API_LOG_SAMPLE_THEREAFTER"
+const ENV_API_RATE_LIMIT_READ_RATE = "API_RATE_LIMIT_READ_RATE"
+const ENV_API_RATE_LIMIT_READ_BURST = "API_RATE_LIMIT_READ_BURST"
+const ENV_API_RATE_LIMIT_WRITE_RATE = "API_RATE_LIMIT_WRITE_RATE"
+const ENV_API_RATE_LIMIT_WRITE_BURST = "API_RATE_LIMIT_WRITE_BURST"
+const ENV_API_CORS_ALLOWED_ORIGINS = "API_CORS_ALLOWED_ORIGINS"
+const ENV_API_CORS_ALLOWED_METHODS = "API_CORS_ALLOWED_METHODS"
+const ENV_API_CORS_ALLOWED_HEADERS = "API_CORS_ALLOWED_HEADERS"
+const ENV_API_CORS_EXPOSED_HEADERS = "API_CORS_EXPOSED_HEADERS"
+const ENV_API_CORS_ALLOW_CREDENTIALS = "API_CORS_ALLOW_CREDENTIALS"
+const ENV_API_CORS_MAX_AGE = "API_CORS_MAX_AGE"
+const ENV_API_EVENTS_REPLAY_SIZE = "API_EVENTS_REPLAY_SIZE"
+const ENV_API_EVENTS_CLIENT_BUFFER = "API_EVENTS_CLIENT_BUFFER"
+const ENV_API_EVENTS_HEARTBEAT = "API_EVENTS_HEARTBEAT"
+const ENV_API_WEBSOCKET_MAX_MESSAGE_BYTES = "API_WEBSOCKET_MAX_MESSAGE_BYTES"
+const ENV_API_WEBSOCKET_PING_INTERVAL = "API_WEBSOCKET_PING_INTERVAL"
+const ENV_API_WEBHOOKS_WORKERS = "API_WEBHOOKS_WORKERS"
+const ENV_API_WEBHOOKS_MAX_ATTEMPTS = "API_WEBHOOKS_MAX_ATTEMPTS"
+const ENV_API_WEBHOOKS_INITIAL_BACKOFF = "API_WEBHOOKS_INITIAL_BACKOFF"
+const ENV_API_WEBHOOKS_MAX_BACKOFF = "API_WEBHOOKS_MAX_BACKOFF"
+const ENV_API_WEBHOOKS_TIMEOUT = "API_WEBHOOKS_TIMEOUT"
+const ENV_API_WEBHOOKS_HISTORY_SIZE = "API_WEBHOOKS_HISTORY_SIZE"
+const ENV_API_SCHEDULER_INSTANCE = "API_SCHEDULER_INSTANCE"
+const ENV_API_SCHEDULER_TIME_ZONE = "API_SCHEDULER_TIME_ZONE"
+const ENV_API_SCHEDULER_JITTER = "API_SCHEDULER_JITTER"
+const ENV_API_SCHEDULER_OVERDUE_REMINDERS = "API_SCHEDULER_OVERDUE_REMINDERS"
+const ENV_API_SCHEDULER_OVERDUE_DIGEST = "API_SCHEDULER_OVERDUE_DIGEST"
+const ENV_API_SCHEDULER_ALERT_EVALUATION = "API_SCHEDULER_ALERT_EVALUATION"
+const ENV_API_SMTP_HOST = "API_SMTP_HOST"
+const ENV_API_SMTP_PORT = "API_SMTP_PORT"
+const ENV_API_SMTP_USERNAME = "API_SMTP_USERNAME"
+const ENV_API_SMTP_PASSWORD = "API_SMTP_PASSWORD"
+const ENV_API_SMTP_FROM = "API_SMTP_FROM"
+const ENV_API_SMTP_ATTEMPTS = "API_SMTP_ATTEMPTS"
+const ENV_API_SMTP_BACKOFF = "API_SMTP_BACKOFF"
+const ENV_API_SMTP_TIMEOUT = "API_SMTP_TIMEOUT"
+const ENV_API_TELEMETRY_QUEUE_SIZE = "API_TELEMETRY_QUEUE_SIZE"
+const ENV_API_TELEMETRY_WORKERS = "API_TELEMETRY_WORKERS"
+const ENV_API_TELEMETRY_MAX_BATCH_BYTES = "API_TELEMETRY_MAX_BATCH_BYTES"
+const ENV_API_TELEMETRY_RETENTION = "API_TELEMETRY_RETENTION"
+const ENV_API_ALERTS_HISTORY_SIZE = "API_ALERTS_HISTORY_SIZE"
+const ENV_API_PHOTOS_DIR = "API_PHOTOS_DIR"
+const ENV_API_PHOTOS_MAX_UPLOAD_BYTES = "API_PHOTOS_MAX_UPLOAD_BYTES"
+const ENV_API_PHOTOS_THUMBNAIL_SIZE = "API_PHOTOS_THUMBNAIL_SIZE"
+const ENV_API_SPECIES_LOAD_BUNDLED = "API_SPECIES_LOAD_BUNDLED"
 
 // default values
 const API_DEFAULT_HOST = "localhost"
 const API_DEFAULT_PORT = "8080"
+const API_DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+const API_DEFAULT_SHUTDOWN_DELAY = 5 * time.Second
+const API_DEFAULT_MAX_BODY_BYTES = 1 << 20
+const API_DEFAULT_COMPRESSION_MIN_BYTES = 1024
+const API_DEFAULT_TLS_CLIENT_AUTH = TLS_CLIENT_AUTH_NONE
+const API_DEFAULT_TLS_MIN_VERSION = "1.2"
+const API_DEFAULT_LOG_LEVEL = "info"
+const API_DEFAULT_LOG_FORMAT = LOG_FORMAT_TEXT
+const API_DEFAULT_LOG_OUTPUT = LOG_OUTPUT_STDERR
+const API_DEFAULT_LOG_SAMPLE_INTERVAL = time.Second
+const API_DEFAULT_LOG_SAMPLE_FIRST = 100
+const API_DEFAULT_LOG_SAMPLE_THEREAFTER = 100
+const API_DEFAULT_RATE_LIMIT_READ_RATE = 20.0
+const API_DEFAULT_RATE_LIMIT_READ_BURST = 40
+const API_DEFAULT_RATE_LIMIT_WRITE_RATE = 2.0
+const API_DEFAULT_RATE_LIMIT_WRITE_BURST = 5
+const API_DEFAULT_CORS_MAX_AGE = 10 * time.Minute
+const API_DEFAULT_EVENTS_REPLAY_SIZE = 1000
+const API_DEFAULT_EVENTS_CLIENT_BUFFER = 64
+const API_DEFAULT_EVENTS_HEARTBEAT = 15 * time.Second
+const API_DEFAULT_WEBSOCKET_MAX_MESSAGE_BYTES = 16 << 10
+const API_DEFAULT_WEBSOCKET_PING_INTERVAL = 30 * time.Second
+const API_DEFAULT_WEBHOOKS_WORKERS = 4
+const API_DEFAULT_WEBHOOKS_MAX_ATTEMPTS = 8
+const API_DEFAULT_WEBHOOKS_INITIAL_BACKOFF = 10 * time.Second
+const API_DEFAULT_WEBHOOKS_MAX_BACKOFF = time.Hour
+const API_DEFAULT_WEBHOOKS_TIMEOUT = 10 * time.Second
+const API_DEFAULT_WEBHOOKS_HISTORY_SIZE = 100
+const API_DEFAULT_SCHEDULER_TIME_ZONE = "UTC"
+const API_DEFAULT_SCHEDULER_JITTER = time.Minute
+const API_DEFAULT_SCHEDULER_OVERDUE_REMINDERS = "0 8 * * *"
+const API_DEFAULT_SCHEDULER_OVERDUE_DIGEST = "0 7 * * *"
+const API_DEFAULT_SCHEDULER_ALERT_EVALUATION = "@every 1m"
+const API_DEFAULT_SMTP_PORT = "587"
+const API_DEFAULT_SMTP_ATTEMPTS = 3
+const API_DEFAULT_SMTP_BACKOFF = 30 * time.Second
+const API_DEFAULT_SMTP_TIMEOUT = 10 * time.Second
+const API_DEFAULT_TELEMETRY_QUEUE_SIZE = 64
+const API_DEFAULT_TELEMETRY_WORKERS = 2
+const API_DEFAULT_TELEMETRY_MAX_BATCH_BYTES = 4 << 20
+const API_DEFAULT_TELEMETRY_RETENTION = 30 * 24 * time.Hour
+const API_DEFAULT_ALERTS_HISTORY_SIZE = 1000
+const API_DEFAULT_PHOTOS_DIR = "data/photos"
+const API_DEFAULT_PHOTOS_MAX_UPLOAD_BYTES = 10 << 20
+const API_DEFAULT_PHOTOS_THUMBNAIL_SIZE = 320
+const API_DEFAULT_SPECIES_LOAD_BUNDLED = true
+
+// list defaults are variables, Go has no constant slices
+var API_DEFAULT_CORS_ALLOWED_METHODS = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+var API_DEFAULT_CORS_ALLOWED_HEADERS = []string{"Accept", "Authorization", "Content-Type", "Content-Encoding"}
+var API_DEFAULT_CORS_EXPOSED_HEADERS = []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
+
+// log formats
+const LOG_FORMAT_TEXT = "text"
+const LOG_FORMAT_JSON = "json"
+
+// log outputs, anything else is treated as a file path
+const LOG_OUTPUT_STDERR = "stderr"
+const LOG_OUTPUT_STDOUT = "stdout"
+
+// client certificate policies
+const TLS_CLIENT_AUTH_NONE = "none"
+const TLS_CLIENT_AUTH_OPTIONAL = "optional"
+const TLS_CLIENT_AUTH_REQUIRE = "require"
 
 type Server struct {
 	Host string
 	Port string
+	// ShutdownTimeout is how long in-flight requests get to finish, shutdown hooks get the same again
+	ShutdownTimeout time.Duration
+	// ShutdownDelay is how long readyz reports not-ready before the listener closes,
+	// so load balancers stop routing new requests to the instance first
+	ShutdownDelay time.Duration
+	// MaxBodyBytes caps JSON request bodies, larger ones are answered with 413
+	MaxBodyBytes int
+	// CompressionMinBytes is the smallest response body worth compressing
+	CompressionMinBytes int
+	// AdminToken protects admin-only routes, it is a secret and never printed
+	AdminToken string
+	TLS        TLS
+	Log        Log
+	RateLimit  RateLimit
+	CORS       CORS
+	Events     Events
+	WebSocket  WebSocket
+	Webhooks   Webhooks
+	Scheduler  Scheduler
+	SMTP       SMTP
+	Telemetry  Telemetry
+	Alerts     Alerts
+	Photos     Photos
+	Species    Species
 }
 
-func FromEnv(getenv func(string) string) Server {
-	logger := slog.Default()
-	host := getenv(ENV_API_HOST)
-	if host == "" {
-		fallbackWarning(logger, "host", API_DEFAULT_HOST)
-		host = API_DEFAULT_HOST
-	}
+// Telemetry configures sensor reading ingestion
+type Telemetry struct {
+	// QueueSize is how many batches can wait to be stored, more are answered with 503
+	QueueSize int
+	// Workers is how many batches are stored concurrently
+	Workers int
+	// MaxBatchBytes caps a single batch, it replaces MaxBodyBytes for the ingestion route
+	MaxBatchBytes int
+	// Retention is how long readings are kept, older ones are dropped
+	Retention time.Duration
+}
 
-	port := getenv(ENV_API_PORT)
-	if port == "" {
+// Species configures the species catalog
+type Species struct {
+	// LoadBundled fills the catalog with the species shipped with the API on startup
+	LoadBundled bool
+}
 
-		fallbackWarning(logger, "port", API_DEFAULT_PORT)
-		port = API_DEFAULT_PORT
-	}
+// Photos configures plant photo uploads
+type Photos struct {
+	// Dir is where photos and their thumbnails are stored
+	Dir string
+	// MaxUploadBytes caps a single upload, it replaces MaxBodyBytes for the upload route
+	MaxUploadBytes int
+	// ThumbnailSize is the longest side of thumbnails in pixels
+	ThumbnailSize int
+}
 
-	return Server{
-		Host: host,
-		Port: port,
-	}
+// Alerts configures alert rule evaluation, how often rules are evaluated is Scheduler.AlertEvaluation
+type Alerts struct {
+	// HistorySize is how many resolved alerts are kept
+	HistorySize int
+}
+
+// SMTP configures the email notifier, email notifications are disabled while Host is empty
+type SMTP struct {
+	Host string
+	Port string
+	// Username and Password authenticate with PLAIN, the password is a secret and never printed
+	Username string
+	Password string
+	// From is the sender address, e.g. "Plants <plants@example.com>"
+	From string
+	// Attempts is how many times an email is sent before giving up, only temporary failures are retried
+	Attempts int
+	// Backoff is the wait before the first retry, it doubles with every retry
+	Backoff time.Duration
+	// Timeout caps a single attempt
+	Timeout time.Duration
+}
 
+// Scheduler configures background jobs, job schedules are cron expressions and an empty one disables the job
+type Scheduler struct {
+	// Instance identifies this replica when electing which one runs a job, it defaults to the hostname
+	Instance string
+	// TimeZone is the IANA time zone schedules are evaluated in
+	TimeZone string
+	// Jitter is the longest random delay added to every run
+	Jitter time.Duration
+	// OverdueReminders publishes a reminder event for every plant that is overdue for watering
+	OverdueReminders string
+	// OverdueDigest notifies users who opted in about all plants overdue for care
+	OverdueDigest string
+	// AlertEvaluation evaluates every alert rule, a rule's For duration is only as precise as this schedule
+	AlertEvaluation string
 }
 
-func fallbackWarning(logger *slog.Logger, fieldName, value string) {
-	logger.Warn("using fallback " + fieldName + " value: " + value)
+// Webhooks configures outbound deliveries of plant events to subscriber URLs
+type Webhooks struct {
+	// Workers is how many deliveries are sent concurrently
+	Workers int
+	// MaxAttempts is how many times a delivery is sent before it is dead-lettered
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, it doubles with every retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout caps a single delivery attempt
+	Timeout time.Duration
+	// HistorySize is how many deliveries are kept per subscription
+	HistorySize int
+}
+
+// WebSocket configures the live plant updates socket
+type WebSocket struct {
+	// MaxMessageBytes caps client messages, larger ones close the connection
+	MaxMessageBytes int
+	// PingInterval is how often clients are pinged, clients silent for two intervals are disconnected
+	PingInterval time.Duration
+}
+
+// Events configures the plant change feed
+type Events struct {
+	// ReplaySize is how many recent events are kept for clients resuming with Last-Event-ID
+	ReplaySize int
+	// ClientBuffer is how many events a client can fall behind by before it is disconnected
+	ClientBuffer int
+	// Heartbeat is how often idle streams get a comment, so proxies dont close them
+	Heartbeat time.Duration
+}
+
+// CORS lets browser apps on other origins call the API, it is disabled while AllowedOrigins is empty
+type CORS struct {
+	// AllowedOrigins are exact origins like "https://dashboard.example.com",
+	// "https://*.example.com" for any subdomain, or "*" for any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts are allowed to read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers can cache a preflight response
+	MaxAge time.Duration
+}
+
+// RateLimit configures per-client token buckets for each route group,
+// rates are requests per second and a zero rate disables limiting for that group
+type RateLimit struct {
+	ReadRate   float64
+	ReadBurst  int
+	WriteRate  float64
+	WriteBurst int
+}
+
+type Log struct {
+	// Level is the initial level, it can be changed at runtime through the admin API
+	Level string
+	// Format is one of LOG_FORMAT_* values
+	Format string
+	// AddSource includes the file and line of the log call in every record
+	AddSource bool
+	// Output is LOG_OUTPUT_STDERR, LOG_OUTPUT_STDOUT or a file path to append to
+	Output string
+	// Redact masks sensitive attributes, query parameters and headers
+	Redact bool
+	// SampleFirst identical records per SampleInterval are kept, then one in SampleThereafter.
+	// Zero SampleFirst disables sampling.
+	SampleInterval   time.Duration
+	SampleFirst      int
+	SampleThereafter int
+}
+
+// TLS is disabled unless both CertFile and KeyFile are set
+type TLS struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle used to verify client certificates for mTLS
+	ClientCAFile string
+	// ClientAuth is one of TLS_CLIENT_AUTH_* values
+	ClientAuth string
+	// MinVersion is either "1.2" or "1.3"
+	MinVersion string
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
 }
 
 func NewDefaultServer() Server {
 	return Server{
-		Host: API_DEFAULT_HOST,
-		Port: API_DEFAULT_PORT,
+		Host:                API_DEFAULT_HOST,
+		Port:                API_DEFAULT_PORT,
+		ShutdownTimeout:     API_DEFAULT_SHUTDOWN_TIMEOUT,
+		ShutdownDelay:       API_DEFAULT_SHUTDOWN_DELAY,
+		MaxBodyBytes:        API_DEFAULT_MAX_BODY_BYTES,
+		CompressionMinBytes: API_DEFAULT_COMPRESSION_MIN_BYTES,
+		TLS: TLS{
+			ClientAuth: API_DEFAULT_TLS_CLIENT_AUTH,
+			MinVersion: API_DEFAULT_TLS_MIN_VERSION,
+		},
+		Log: Log{
+			Level:  API_DEFAULT_LOG_LEVEL,
+			Format: API_DEFAULT_LOG_FORMAT,
+			Output: API_DEFAULT_LOG_OUTPUT,
+			Redact: true,
+
+			SampleInterval:   API_DEFAULT_LOG_SAMPLE_INTERVAL,
+			SampleFirst:      API_DEFAULT_LOG_SAMPLE_FIRST,
+			SampleThereafter: API_DEFAULT_LOG_SAMPLE_THEREAFTER,
+		},
+		RateLimit: RateLimit{
+			ReadRate:   API_DEFAULT_RATE_LIMIT_READ_RATE,
+			ReadBurst:  API_DEFAULT_RATE_LIMIT_READ_BURST,
+			WriteRate:  API_DEFAULT_RATE_LIMIT_WRITE_RATE,
+			WriteBurst: API_DEFAULT_RATE_LIMIT_WRITE_BURST,
+		},
+		CORS: CORS{
+			AllowedMethods: slices.Clone(API_DEFAULT_CORS_ALLOWED_METHODS),
+			AllowedHeaders: slices.Clone(API_DEFAULT_CORS_ALLOWED_HEADERS),
+			ExposedHeaders: slices.Clone(API_DEFAULT_CORS_EXPOSED_HEADERS),
+			MaxAge:         API_DEFAULT_CORS_MAX_AGE,
+		},
+		Events: Events{
+			ReplaySize:   API_DEFAULT_EVENTS_REPLAY_SIZE,
+			ClientBuffer: API_DEFAULT_EVENTS_CLIENT_BUFFER,
+			Heartbeat:    API_DEFAULT_EVENTS_HEARTBEAT,
+		},
+		WebSocket: WebSocket{
+			MaxMessageBytes: API_DEFAULT_WEBSOCKET_MAX_MESSAGE_BYTES,
+			PingInterval:    API_DEFAULT_WEBSOCKET_PING_INTERVAL,
+		},
+		Webhooks: Webhooks{
+			Workers:        API_DEFAULT_WEBHOOKS_WORKERS,
+			MaxAttempts:    API_DEFAULT_WEBHOOKS_MAX_ATTEMPTS,
+			InitialBackoff: API_DEFAULT_WEBHOOKS_INITIAL_BACKOFF,
+			MaxBackoff:     API_DEFAULT_WEBHOOKS_MAX_BACKOFF,
+			Timeout:        API_DEFAULT_WEBHOOKS_TIMEOUT,
+			HistorySize:    API_DEFAULT_WEBHOOKS_HISTORY_SIZE,
+		},
+		Scheduler: Scheduler{
+			TimeZone:         API_DEFAULT_SCHEDULER_TIME_ZONE,
+			Jitter:           API_DEFAULT_SCHEDULER_JITTER,
+			OverdueReminders: API_DEFAULT_SCHEDULER_OVERDUE_REMINDERS,
+			OverdueDigest:    API_DEFAULT_SCHEDULER_OVERDUE_DIGEST,
+			AlertEvaluation:  API_DEFAULT_SCHEDULER_ALERT_EVALUATION,
+		},
+		SMTP: SMTP{
+			Port:     API_DEFAULT_SMTP_PORT,
+			Attempts: API_DEFAULT_SMTP_ATTEMPTS,
+			Backoff:  API_DEFAULT_SMTP_BACKOFF,
+			Timeout:  API_DEFAULT_SMTP_TIMEOUT,
+		},
+		Telemetry: Telemetry{
+			QueueSize:     API_DEFAULT_TELEMETRY_QUEUE_SIZE,
+			Workers:       API_DEFAULT_TELEMETRY_WORKERS,
+			MaxBatchBytes: API_DEFAULT_TELEMETRY_MAX_BATCH_BYTES,
+			Retention:     API_DEFAULT_TELEMETRY_RETENTION,
+		},
+		Alerts: Alerts{
+			HistorySize: API_DEFAULT_ALERTS_HISTORY_SIZE,
+		},
+		Photos: Photos{
+			Dir:            API_DEFAULT_PHOTOS_DIR,
+			MaxUploadBytes: API_DEFAULT_PHOTOS_MAX_UPLOAD_BYTES,
+			ThumbnailSize:  API_DEFAULT_PHOTOS_THUMBNAIL_SIZE,
+		},
+		Species: Species{
+			LoadBundled: API_DEFAULT_SPECIES_LOAD_BUNDLED,
+		},
+	}
+}
+
+// Validate checks the whole config and reports every problem at once,
+// so a broken deployment doesnt need one restart per typo
+func (s Server) Validate() error {
+	var errs []error
+	if s.Host == "" {
+		errs = append(errs, errors.New("host cannot be empty"))
+	}
+
+	if port, err := strconv.Atoi(s.Port); err != nil || port < 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port '%s' is not a valid port number", s.Port))
+	}
+
+	if s.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+
+	if s.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("shutdown delay cannot be negative"))
+	}
+
+	if s.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("max body bytes must be positive"))
+	}
+
+	if s.CompressionMinBytes < 0 {
+		errs = append(errs, errors.New("compression min bytes cannot be negative"))
+	}
+
+	if (s.TLS.CertFile == "") != (s.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls cert file and key file must be set together"))
+	}
+
+	switch s.TLS.ClientAuth {
+	case TLS_CLIENT_AUTH_NONE:
+	case TLS_CLIENT_AUTH_OPTIONAL, TLS_CLIENT_AUTH_REQUIRE:
+		if !s.TLS.Enabled() {
+			errs = append(errs, errors.New("tls client auth requires tls to be enabled"))
+		}
+		if s.TLS.ClientCAFile == "" {
+			errs = append(errs, errors.New("tls client auth requires a client CA file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tls client auth '%s' must be one of: %s, %s, %s", s.TLS.ClientAuth, TLS_CLIENT_AUTH_NONE, TLS_CLIENT_AUTH_OPTIONAL, TLS_CLIENT_AUTH_REQUIRE))
+	}
+
+	if s.TLS.MinVersion != "1.2" && s.TLS.MinVersion != "1.3" {
+		errs = append(errs, fmt.Errorf("tls min version '%s' must be 1.2 or 1.3", s.TLS.MinVersion))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log level '%s' must be one of: debug, info, warn, error", s.Log.Level))
+	}
+
+	if s.Log.Format != LOG_FORMAT_TEXT && s.Log.Format != LOG_FORMAT_JSON {
+		errs = append(errs, fmt.Errorf("log format '%s' must be %s or %s", s.Log.Format, LOG_FORMAT_TEXT, LOG_FORMAT_JSON))
+	}
+
+	if s.Log.Output == "" {
+		errs = append(errs, errors.New("log output cannot be empty"))
+	}
+
+	if s.Log.SampleFirst > 0 && s.Log.SampleInterval <= 0 {
+		errs = append(errs, errors.New("log sample interval must be positive when sampling is enabled"))
+	}
+
+	if s.Log.SampleFirst < 0 || s.Log.SampleThereafter < 0 {
+		errs = append(errs, errors.New("log sample counts cannot be negative"))
+	}
+
+	if s.RateLimit.ReadRate > 0 && s.RateLimit.ReadBurst < 1 {
+		errs = append(errs, errors.New("rate limit read burst must be at least 1"))
+	}
+
+	if s.RateLimit.WriteRate > 0 && s.RateLimit.WriteBurst < 1 {
+		errs = append(errs, errors.New("rate limit write burst must be at least 1"))
+	}
+
+	for _, origin := range s.CORS.AllowedOrigins {
+		if !validOrigin(origin) {
+			errs = append(errs, fmt.Errorf("cors origin '%s' must be '*' or a scheme and host like https://*.example.com", origin))
+		}
+		if origin == "*" && s.CORS.AllowCredentials {
+			errs = append(errs, errors.New("cors credentials cannot be allowed for any origin '*'"))
+		}
+	}
+
+	if s.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors max age cannot be negative"))
+	}
+
+	if s.Events.ReplaySize < 0 {
+		errs = append(errs, errors.New("events replay size cannot be negative"))
+	}
+
+	if s.Events.ClientBuffer < 1 {
+		errs = append(errs, errors.New("events client buffer must be at least 1"))
+	}
+
+	if s.Events.Heartbeat <= 0 {
+		errs = append(errs, errors.New("events heartbeat must be positive"))
+	}
+
+	if s.WebSocket.MaxMessageBytes <= 0 {
+		errs = append(errs, errors.New("websocket max message bytes must be positive"))
+	}
+
+	if s.WebSocket.PingInterval <= 0 {
+		errs = append(errs, errors.New("websocket ping interval must be positive"))
+	}
+
+	if s.Webhooks.Workers < 1 {
+		errs = append(errs, errors.New("webhooks workers must be at least 1"))
+	}
+
+	if s.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks max attempts must be at least 1"))
+	}
+
+	if s.Webhooks.InitialBackoff <= 0 || s.Webhooks.MaxBackoff < s.Webhooks.InitialBackoff {
+		errs = append(errs, errors.New("webhooks initial backoff must be positive and no longer than max backoff"))
+	}
+
+	if s.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks timeout must be positive"))
+	}
+
+	if s.Webhooks.HistorySize < 1 {
+		errs = append(errs, errors.New("webhooks history size must be at least 1"))
+	}
+
+	loc, err := time.LoadLocation(s.Scheduler.TimeZone)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("scheduler time zone '%s' must be an IANA time zone like Europe/Riga", s.Scheduler.TimeZone))
+	}
+
+	if s.Scheduler.Jitter < 0 {
+		errs = append(errs, errors.New("scheduler jitter cannot be negative"))
+	}
+
+	for _, job := range []struct{ name, schedule string }{
+		{"overdue reminders", s.Scheduler.OverdueReminders},
+		{"overdue digest", s.Scheduler.OverdueDigest},
+		{"alert evaluation", s.Scheduler.AlertEvaluation},
+	} {
+		if job.schedule == "" {
+			continue
+		}
+		if _, err := scheduler.Parse(job.schedule, loc); err != nil {
+			errs = append(errs, fmt.Errorf("scheduler %s: %w", job.name, err))
+		}
 	}
+
+	if s.SMTP.Host != "" {
+		if port, err := strconv.Atoi(s.SMTP.Port); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("smtp port '%s' is not a valid port number", s.SMTP.Port))
+		}
+		if _, err := mail.ParseAddress(s.SMTP.From); err != nil {
+			errs = append(errs, fmt.Errorf("smtp from '%s' must be an address like Plants <plants@example.com>", s.SMTP.From))
+		}
+	}
+
+	if s.SMTP.Attempts < 1 {
+		errs = append(errs, errors.New("smtp attempts must be at least 1"))
+	}
+
+	if s.SMTP.Backoff < 0 {
+		errs = append(errs, errors.New("smtp backoff cannot be negative"))
+	}
+
+	if s.SMTP.Timeout <= 0 {
+		errs = append(errs, errors.New("smtp timeout must be positive"))
+	}
+
+	if s.Telemetry.QueueSize < 1 {
+		errs = append(errs, errors.New("telemetry queue size must be at least 1"))
+	}
+
+	if s.Telemetry.Workers < 1 {
+		errs = append(errs, errors.New("telemetry workers must be at least 1"))
+	}
+
+	if s.Telemetry.MaxBatchBytes <= 0 {
+		errs = append(errs, errors.New("telemetry max batch bytes must be positive"))
+	}
+
+	if s.Telemetry.Retention <= 0 {
+		errs = append(errs, errors.New("telemetry retention must be positive"))
+	}
+
+	if s.Alerts.HistorySize < 1 {
+		errs = append(errs, errors.New("alerts history size must be at least 1"))
+	}
+
+	if strings.TrimSpace(s.Photos.Dir) == "" {
+		errs = append(errs, errors.New("photos dir cannot be empty"))
+	}
+
+	if s.Photos.MaxUploadBytes <= 0 {
+		errs = append(errs, errors.New("photos max upload bytes must be positive"))
+	}
+
+	if s.Photos.ThumbnailSize < 16 {
+		errs = append(errs, errors.New("photos thumbnail size must be at least 16"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validOrigin accepts "*" and scheme://host[:port] origins, where the host may start with a "*." wildcard label
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "*.")
+	return host != "" && !strings.Contains(host, "*")
+}
+
+// Addr is the host:port the API listens on
+func (s Server) Addr() string {
+	return net.JoinHostPort(s.Host, s.Port)
 }
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"errors"
+	"plants/plants"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeCreated Type = "created"
+	TypeUpdated Type = "updated"
+	TypeDeleted Type = "deleted"
+	// TypeReminder is not a change, it is published by scheduled jobs when a plant needs care
+	TypeReminder Type = "reminder"
+)
+
+// Event is a single plant change or reminder, IDs increase by one per event and restart with the process
+type Event struct {
+	ID    uint64       `json:"id"`
+	Type  Type         `json:"type"`
+	Time  time.Time    `json:"time"`
+	Plant plants.Plant `json:"plant"`
+	// Reminder is set on TypeReminder events
+	Reminder *plants.OverdueCare `json:"reminder,omitempty"`
+}
+
+var ErrBrokerClosed = errors.New("event broker is closed")
+
+// Broker fans out plant changes to subscribers in-process and keeps the latest events,
+// so reconnecting subscribers can resume where they left off
+type Broker struct {
+	mu     sync.Mutex
+	lastID uint64
+	// replay is a ring buffer of the latest events, the oldest one is at replayHead
+	replay       []Event
+	replayHead   int
+	replaySize   int
+	clientBuffer int
+	subscribers  map[*Subscription]struct{}
+	closed       bool
+	now          func() time.Time
+}
+
+// NewBroker keeps up to replaySize events for resuming, every subscriber can fall behind
+// by up to clientBuffer events before it is disconnected
+func NewBroker(replaySize, clientBuffer int) *Broker {
+	return &Broker{
+		replaySize:   replaySize,
+		clientBuffer: clientBuffer,
+		subscribers:  make(map[*Subscription]struct{}),
+		now:          time.Now,
+	}
+}
+
+// Publish never blocks, subscribers that cant keep up are disconnected instead of slowing down the publisher
+func (b *Broker) Publish(eventType Type, plant plants.Plant) Event {
+	return b.publish(Event{Type: eventType, Plant: plant})
+}
+
+// PublishReminder tells subscribers the plant is overdue for care
+func (b *Broker) PublishReminder(plant plants.Plant, overdue plants.OverdueCare) Event {
+	return b.publish(Event{Type: TypeReminder, Plant: plant, Reminder: &overdue})
+}
+
+func (b *Broker) publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	event.Time = b.now().UTC()
+	if b.closed {
+		return event
+	}
+
+	if b.replaySize > 0 {
+		// NOTE: once the buffer is full the oldest event is overwritten in place
+		if len(b.replay) < b.replaySize {
+			b.replay = append(b.replay, event)
+		} else {
+			b.replay[b.replayHead] = event
+			b.replayHead = (b.replayHead + 1) % b.replaySize
+		}
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.lagged = true
+			b.remove(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe starts receiving events, a non-zero lastEventID resumes after that event.
+// Subscription.Missed is set when events after lastEventID are no longer buffered.
+func (b *Broker) Subscribe(lastEventID uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	sub := &Subscription{broker: b, events: make(chan Event, b.clientBuffer)}
+	if lastEventID > 0 {
+		// NOTE: an ID from the future means the process restarted and IDs started over
+		sub.Missed = lastEventID > b.lastID
+		if lastEventID < b.lastID {
+			sub.Missed = len(b.replay) == 0 || b.replay[b.replayHead].ID > lastEventID+1
+			for i := range b.replay {
+				event := b.replay[(b.replayHead+i)%len(b.replay)]
+				if event.ID > lastEventID {
+					sub.Replay = append(sub.Replay, event)
+				}
+			}
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Close disconnects all subscribers, later subscriptions fail with ErrBrokerClosed
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+type Subscription struct {
+	// Replay are buffered events after the requested lastEventID, they are not sent to Events
+	Replay []Event
+	// Missed means some events after the requested lastEventID are gone,
+	// the subscriber should reload the full state
+	Missed bool
+
+	broker *Broker
+	events chan Event
+	lagged bool
+}
+
+// Events is closed when the subscription ends, check Lagged to see why
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Lagged reports whether the subscriber was disconnected for not keeping up with events
+func (s *Subscription) Lagged() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.lagged
+}
+
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
@@ -0,0 +1,203 @@
+package events
+
+import (
+	"context"
+	"plants/plants"
+	"plants/store"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(events []Event) []uint64 {
+	ids := []uint64{}
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestBrokerSubscribe(t *testing.T) {
+	tests := map[string]struct {
+		replaySize  int
+		published   int
+		lastEventID uint64
+
+		wantReplay []uint64
+		wantMissed bool
+	}{
+		"new subscriber gets no replay": {
+			replaySize: 5,
+			published:  3,
+
+			wantReplay: []uint64{},
+		},
+		"resumes after last event": {
+			replaySize:  5,
+			published:   4,
+			lastEventID: 2,
+
+			wantReplay: []uint64{3, 4},
+		},
+		"up to date subscriber": {
+			replaySize:  5,
+			published:   4,
+			lastEventID: 4,
+
+			wantReplay: []uint64{},
+		},
+		"events dropped from replay buffer are missed": {
+			replaySize:  2,
+			published:   5,
+			lastEventID: 1,
+
+			wantReplay: []uint64{4, 5},
+			wantMissed: true,
+		},
+		"last event right before replay buffer": {
+			replaySize:  2,
+			published:   5,
+			lastEventID: 3,
+
+			wantReplay: []uint64{4, 5},
+		},
+		"replay buffer wrapped around": {
+			replaySize:  3,
+			published:   7,
+			lastEventID: 5,
+
+			wantReplay: []uint64{6, 7},
+		},
+		"unknown future id after restart": {
+			replaySize:  5,
+			published:   1,
+			lastEventID: 100,
+
+			wantReplay: []uint64{},
+			wantMissed: true,
+		},
+		"no replay buffer": {
+			published:   2,
+			lastEventID: 1,
+
+			wantReplay: []uint64{},
+			wantMissed: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := NewBroker(tc.replaySize, 10)
+			for range tc.published {
+				b.Publish(TypeCreated, plants.Plant{ID: "1"})
+			}
+
+			sub, err := b.Subscribe(tc.lastEventID)
+			require.NoError(t, err)
+			defer sub.Close()
+
+			assert.Equal(t, tc.wantReplay, ids(sub.Replay))
+			assert.Equal(t, tc.wantMissed, sub.Missed)
+		})
+	}
+}
+
+func TestBrokerPublish(t *testing.T) {
+	b := NewBroker(10, 2)
+	fast, err := b.Subscribe(0)
+	require.NoError(t, err)
+	slow, err := b.Subscribe(0)
+	require.NoError(t, err)
+
+	b.Publish(TypeCreated, plants.Plant{ID: "1", Name: "fern"})
+	got := <-fast.Events()
+	assert.Equal(t, Event{ID: 1, Type: TypeCreated, Time: got.Time, Plant: plants.Plant{ID: "1", Name: "fern"}}, got)
+
+	b.Publish(TypeUpdated, plants.Plant{ID: "1"})
+	<-fast.Events()
+	// NOTE: slow never reads, its buffer of 2 is full now and the third event disconnects it
+	b.Publish(TypeDeleted, plants.Plant{ID: "1"})
+	assert.Equal(t, TypeDeleted, (<-fast.Events()).Type)
+
+	assert.Equal(t, []uint64{1, 2}, ids(drain(slow)))
+	assert.True(t, slow.Lagged())
+	assert.False(t, fast.Lagged())
+
+	b.Close()
+	_, ok := <-fast.Events()
+	assert.False(t, ok)
+	assert.False(t, fast.Lagged())
+
+	_, err = b.Subscribe(0)
+	assert.ErrorIs(t, err, ErrBrokerClosed)
+	// NOTE: closing an already ended subscription is a no-op
+	fast.Close()
+}
+
+func drain(sub *Subscription) []Event {
+	var events []Event
+	for e := range sub.Events() {
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestPublishingStore(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(10, 10)
+	s := NewPublishingStore(store.NewMemoryStore(nil), b)
+	sub, err := b.Subscribe(0)
+	require.NoError(t, err)
+
+	created, err := s.Create(ctx, plants.Plant{Name: "fern", Height: 2})
+	require.NoError(t, err)
+	created.Height = 3
+	_, err = s.Update(ctx, *created)
+	require.NoError(t, err)
+	_, err = s.Update(ctx, plants.Plant{ID: "missing"})
+	require.Error(t, err)
+	_, err = s.Delete(ctx, created.ID)
+	require.NoError(t, err)
+	sub.Close()
+
+	var got []Type
+	for _, e := range drain(sub) {
+		assert.Equal(t, created.ID, e.Plant.ID)
+		got = append(got, e.Type)
+	}
+	// NOTE: failed mutations are not published
+	assert.Equal(t, []Type{TypeCreated, TypeUpdated, TypeDeleted}, got)
+}
+
+func TestPublishingStoreOrder(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker(100, 100)
+	s := NewPublishingStore(store.NewMemoryStore(nil), b)
+	created, err := s.Create(ctx, plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	sub, err := b.Subscribe(0)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for height := 1; height <= 50; height++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plant := *created
+			plant.Height = height
+			_, err := s.Update(ctx, plant)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	sub.Close()
+
+	events := drain(sub)
+	require.Len(t, events, 50)
+	stored, err := s.Find(ctx, created.ID)
+	require.NoError(t, err)
+	// NOTE: the newest event has to carry what the store ended up with
+	assert.Equal(t, stored.Height, events[len(events)-1].Plant.Height)
+}
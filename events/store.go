@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"plants/plants"
+	"plants/store"
+	"sync"
+	"time"
+)
+
+// PublishingStore decorates a store.Store, successful mutations are published to the broker.
+// Mutations are serialized with their publishing, so events are numbered in the order the changes were made.
+type PublishingStore struct {
+	store.Store
+	broker *Broker
+	mu     sync.Mutex
+}
+
+func NewPublishingStore(inner store.Store, broker *Broker) *PublishingStore {
+	return &PublishingStore{Store: inner, broker: broker}
+}
+
+func (s *PublishingStore) Create(ctx context.Context, plant plants.Plant) (*plants.Plant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created, err := s.Store.Create(ctx, plant)
+	if err != nil {
+		return nil, err
+	}
+	s.broker.Publish(TypeCreated, *created)
+	return created, nil
+}
+
+func (s *PublishingStore) Update(ctx context.Context, plant plants.Plant) (*plants.Plant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated, err := s.Store.Update(ctx, plant)
+	if err != nil {
+		return nil, err
+	}
+	s.broker.Publish(TypeUpdated, *updated)
+	return updated, nil
+}
+
+func (s *PublishingStore) Delete(ctx context.Context, id string) (*plants.Plant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted, err := s.Store.Delete(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.broker.Publish(TypeDeleted, *deleted)
+	return deleted, nil
+}
+
+func (s *PublishingStore) SetHeight(ctx context.Context, id string, height int, measuredAt time.Time) (*plants.Plant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plant, updated, err := s.Store.SetHeight(ctx, id, height, measuredAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if updated {
+		s.broker.Publish(TypeUpdated, *plant)
+	}
+	return plant, updated, nil
+}
+
+func (s *PublishingStore) SetLastWatered(ctx context.Context, id string, wateredAt time.Time) (*plants.Plant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plant, updated, err := s.Store.SetLastWatered(ctx, id, wateredAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if updated {
+		s.broker.Publish(TypeUpdated, *plant)
+	}
+	return plant, updated, nil
+}
+
+// Close releases the decorated store, if it holds any resources
+func (s *PublishingStore) Close(ctx context.Context) error {
+	if closer, ok := s.Store.(store.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
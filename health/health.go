@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DEFAULT_TIMEOUT is used for probes registered without an explicit timeout
+const DEFAULT_TIMEOUT = 2 * time.Second
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Checker is implemented by anything that can report whether it is usable right now,
+// e.g. a store.Store implementation pinging its database
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets plain functions be used as probes
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type ComponentReport struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     Status            `json:"status"`
+	Components []ComponentReport `json:"components"`
+}
+
+type probe struct {
+	name    string
+	checker Checker
+	timeout time.Duration
+}
+
+// Registry holds all dependency probes that make up the readiness of the service
+type Registry struct {
+	mu           sync.RWMutex
+	probes       []probe
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a named probe, a zero timeout falls back to DEFAULT_TIMEOUT
+func (r *Registry) Register(name string, checker Checker, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probes = append(r.probes, probe{name: name, checker: checker, timeout: timeout})
+}
+
+// SetShuttingDown marks the service as draining, after this readiness always fails
+// so load balancers stop routing new traffic to this instance
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Run executes all probes concurrently and aggregates their results,
+// the report is only "up" when every component is up
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	probes := make([]probe, len(r.probes))
+	copy(probes, r.probes)
+	r.mu.RUnlock()
+
+	components := make([]ComponentReport, len(probes))
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, p probe) {
+			defer wg.Done()
+			components[i] = runProbe(ctx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: components}
+	for _, c := range components {
+		if c.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func runProbe(ctx context.Context, p probe) ComponentReport {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	// NOTE: run the check in its own goroutine so a probe that ignores its context
+	// still cant block the whole report past its timeout
+	result := make(chan error, 1)
+	go func() {
+		result <- p.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", p.timeout)
+	}
+
+	report := ComponentReport{
+		Name:    p.name,
+		Status:  StatusUp,
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		report.Status = StatusDown
+		report.Error = err.Error()
+	}
+
+	return report
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryRun(t *testing.T) {
+	ctx := context.Background()
+	ok := CheckerFunc(func(context.Context) error { return nil })
+	failing := CheckerFunc(func(context.Context) error { return errors.New("connection refused") })
+	hanging := CheckerFunc(func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	tests := map[string]struct {
+		register func(r *Registry)
+
+		wantStatus Status
+		wantErrors map[string]string
+	}{
+		"empty registry is up": {
+			register: func(r *Registry) {},
+
+			wantStatus: StatusUp,
+			wantErrors: map[string]string{},
+		},
+		"all probes up": {
+			register: func(r *Registry) {
+				r.Register("store", ok, 0)
+				r.Register("cache", ok, 0)
+			},
+
+			wantStatus: StatusUp,
+			wantErrors: map[string]string{"store": "", "cache": ""},
+		},
+		"single failing probe brings report down": {
+			register: func(r *Registry) {
+				r.Register("store", ok, 0)
+				r.Register("cache", failing, 0)
+			},
+
+			wantStatus: StatusDown,
+			wantErrors: map[string]string{"store": "", "cache": "connection refused"},
+		},
+		"probe ignoring its context times out": {
+			register: func(r *Registry) {
+				r.Register("store", hanging, 10*time.Millisecond)
+			},
+
+			wantStatus: StatusDown,
+			wantErrors: map[string]string{"store": "timed out after 10ms"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewRegistry()
+			tc.register(r)
+
+			got := r.Run(ctx)
+
+			assert.Equal(t, tc.wantStatus, got.Status)
+			gotErrors := make(map[string]string)
+			for _, c := range got.Components {
+				gotErrors[c.Name] = c.Error
+				assert.NotEmpty(t, c.Latency)
+			}
+			assert.Equal(t, tc.wantErrors, gotErrors)
+		})
+	}
+}
+
+func TestRegistryShuttingDown(t *testing.T) {
+	r := NewRegistry()
+	assert.False(t, r.ShuttingDown())
+	r.SetShuttingDown()
+	assert.True(t, r.ShuttingDown())
+}
@@ -0,0 +1,135 @@
+package httpd
+
+import (
+	"fmt"
+	"net/http"
+	"plants/alerts"
+	"plants/log"
+)
+
+// handleListAlerts returns alerts, the most recently active first, optionally filtered by "state", "ruleId" and "plantId"
+func handleListAlerts(alertStore alerts.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		query := r.URL.Query()
+		filter := alerts.AlertFilter{
+			State:   alerts.State(query.Get("state")),
+			RuleID:  query.Get("ruleId"),
+			PlantID: query.Get("plantId"),
+		}
+		if filter.State != "" && !filter.State.Valid() {
+			err := fmt.Errorf("query parameter 'state' must be one of: %s, %s, %s", alerts.StatePending, alerts.StateFiring, alerts.StateResolved)
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+			return
+		}
+
+		list, err := alertStore.ListAlerts(ctx, filter)
+		if err != nil {
+			err = fmt.Errorf("retrieve alerts: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
+
+func handleListAlertRules(alertStore alerts.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		rules, err := alertStore.ListRules(ctx)
+		if err != nil {
+			err = fmt.Errorf("retrieve all alert rules: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, rules)
+	})
+}
+
+func handleGetAlertRule(alertStore alerts.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		rule, err := alertStore.FindRule(ctx, r.PathValue("id"))
+		if err != nil {
+			err = fmt.Errorf("find alert rule by id: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, rule)
+	})
+}
+
+func handleCreateAlertRule(alertStore alerts.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		rule, problems, err := decodeValid[alerts.Rule](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		created, err := alertStore.CreateRule(ctx, rule)
+		if err != nil {
+			err = fmt.Errorf("create alert rule: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, created)
+	})
+}
+
+// handleUpdateAlertRule replaces a rule, its open alerts carry over and are evaluated against the new condition
+func handleUpdateAlertRule(alertStore alerts.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		rule, problems, err := decodeValid[alerts.Rule](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		rule.ID = r.PathValue("id")
+		updated, err := alertStore.UpdateRule(ctx, rule)
+		if err != nil {
+			err = fmt.Errorf("update alert rule: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, updated)
+	})
+}
+
+func handleDeleteAlertRule(alertStore alerts.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		if err := alertStore.DeleteRule(ctx, r.PathValue("id")); err != nil {
+			err = fmt.Errorf("delete alert rule: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,120 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"plants/alerts"
+	"plants/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertRules(t *testing.T) {
+	handler := newTestAPI(t, nil)
+
+	code, body := doAdmin(t, handler, http.MethodPost, "/alerts/rules/", `{"name":"dry","type":"threshold","metric":"soil_moisture","operator":"<"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"threshold":"threshold is required for threshold rules"}}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/alerts/rules/", `{"name":"dry","type":"threshold","metric":"soil_moisture","operator":"<","threshold":20,"for":"soon"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Contains(t, body, "duration 'soon' must be like 30m or 2h")
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/alerts/rules/", `{"name":"dry","type":"threshold","metric":"soil_moisture","operator":"<","threshold":20,"for":"30m"}`)
+	require.Equal(t, http.StatusOK, code)
+	var created alerts.Rule
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	expand := func(s string) string { return strings.ReplaceAll(s, "{rule}", created.ID) }
+	assert.JSONEq(t, expand(`{"id":"{rule}","name":"dry","type":"threshold","metric":"soil_moisture","operator":"<","threshold":20,"for":"30m0s"}`), body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/alerts/rules/"+created.ID+"/", `{"name":"thirsty","type":"careMissed","careType":"watering","days":7,"tags":["office"]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, expand(`{"id":"{rule}","name":"thirsty","type":"careMissed","careType":"watering","days":7,"tags":["office"]}`), body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/alerts/rules/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, expand(`[{"id":"{rule}","name":"thirsty","type":"careMissed","careType":"watering","days":7,"tags":["office"]}]`), body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/alerts/rules/"+created.ID+"/", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body = doAdmin(t, handler, http.MethodGet, "/alerts/rules/"+created.ID+"/", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.JSONEq(t, expand(`{"message":"find alert rule by id: alert rule with ID '{rule}' does not exist"}`), body)
+	code, _ = doAdmin(t, handler, http.MethodPut, "/alerts/rules/"+created.ID+"/", `{"name":"stunted","type":"heightUnchanged","days":30}`)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestListAlerts(t *testing.T) {
+	ctx := context.Background()
+	alertStore := alerts.NewMemoryStore(10)
+	activeAt := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	for _, alert := range []alerts.Alert{
+		{RuleID: "r1", RuleName: "dry", PlantID: "p1", PlantName: "fern", State: alerts.StateFiring, ActiveAt: activeAt, FiredAt: &activeAt},
+		{RuleID: "r1", RuleName: "dry", PlantID: "p2", PlantName: "cactus", State: alerts.StatePending, ActiveAt: activeAt.Add(time.Hour)},
+	} {
+		_, err := alertStore.SaveAlert(ctx, alert)
+		require.NoError(t, err)
+	}
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) { deps.Alerts = alertStore })
+
+	tests := map[string]struct {
+		query string
+
+		wantCode   int
+		wantPlants []string
+		wantBody   string
+	}{
+		"all alerts, the most recently active first": {
+			wantCode:   http.StatusOK,
+			wantPlants: []string{"cactus", "fern"},
+		},
+		"by state": {
+			query: "?state=firing",
+
+			wantCode:   http.StatusOK,
+			wantPlants: []string{"fern"},
+		},
+		"by plant": {
+			query: "?plantId=p2&ruleId=r1",
+
+			wantCode:   http.StatusOK,
+			wantPlants: []string{"cactus"},
+		},
+		"no matches": {
+			query: "?ruleId=r2",
+
+			wantCode:   http.StatusOK,
+			wantPlants: []string{},
+		},
+		"unknown state": {
+			query: "?state=angry",
+
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"message":"query parameter 'state' must be one of: pending, firing, resolved"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, body := doAdmin(t, handler, http.MethodGet, "/alerts/"+tc.query, "")
+
+			assert.Equal(t, tc.wantCode, code)
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, body)
+				return
+			}
+			var got []alerts.Alert
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+			gotPlants := []string{}
+			for _, alert := range got {
+				gotPlants = append(gotPlants, alert.PlantName)
+			}
+			assert.Equal(t, tc.wantPlants, gotPlants)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package httpd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"plants/auth"
+	"plants/log"
+	"plants/plants"
+	"plants/store"
+	"time"
+)
+
+// parseCareType reads the optional "type" query parameter, empty means all types
+func parseCareType(r *http.Request) (plants.CareType, error) {
+	careType := plants.CareType(r.URL.Query().Get("type"))
+	if careType != "" && !careType.Valid() {
+		return "", fmt.Errorf("query parameter 'type' must be one of: %s, %s, %s, %s", plants.CareWatering, plants.CareFertilizing, plants.CareRepotting, plants.CarePruning)
+	}
+	return careType, nil
+}
+
+// recordCare stores a care event for the plant, a watering newer than the last one also updates LastWateredAt.
+// The event is kept even if updating the plant fails, the error is returned along with it.
+func recordCare(ctx context.Context, plantStore store.Store, careStore store.CareStore, plant plants.Plant, event plants.CareEvent) (*plants.CareEvent, *plants.Plant, error) {
+	event.PlantID = plant.ID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+	if event.Actor == "" {
+		if identity, ok := auth.IdentityFromCtx(ctx); ok {
+			event.Actor = identity.Subject
+		}
+	}
+
+	created, err := careStore.CreateCareEvent(ctx, event)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create care event: %w", err)
+	}
+
+	if created.Type != plants.CareWatering {
+		return created, &plant, nil
+	}
+	// NOTE: the store compares with the current LastWateredAt itself, plant may already be outdated
+	updated, _, err := plantStore.SetLastWatered(ctx, plant.ID, created.Time)
+	if err != nil {
+		return created, nil, fmt.Errorf("update last watered: %w", err)
+	}
+	return created, updated, nil
+}
+
+// handleCreateCareEvent logs care for a plant, the actor defaults to the authenticated caller
+func handleCreateCareEvent(plantStore store.Store, careStore store.CareStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		event, problems, err := decodeValid[plants.CareEvent](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		created, _, err := recordCare(ctx, plantStore, careStore, *plant, event)
+		if created == nil {
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+		if err != nil {
+			// NOTE: the event is stored either way and overdue care is computed from events, not from the plant
+			logger.ErrorContext(ctx, err.Error(), slog.String("careEventId", created.ID))
+		}
+
+		_ = encode(w, r, http.StatusOK, created)
+	})
+}
+
+func handleListCareEvents(plantStore store.Store, careStore store.CareStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		careType, err := parseCareType(r)
+		if err != nil {
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		list, err := careStore.ListCareEvents(ctx, plant.ID, careType)
+		if err != nil {
+			err = fmt.Errorf("retrieve care events: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
+
+// handleOverdueCare lists plants whose care is late according to their care intervals, optionally of a single "type"
+func handleOverdueCare(careStore store.CareStore, speciesStore store.SpeciesStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		careType, err := parseCareType(r)
+		if err != nil {
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+			return
+		}
+
+		watering, err := speciesWatering(ctx, speciesStore)
+		if err != nil {
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+		overdue, err := careStore.OverdueCare(ctx, time.Now(), careType, watering)
+		if err != nil {
+			err = fmt.Errorf("retrieve overdue care: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, overdue)
+	})
+}
@@ -0,0 +1,180 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"plants/config"
+	"plants/plants"
+	"plants/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCareEvent(t *testing.T) {
+	lastWatered := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		plantID string
+		body    string
+
+		wantCode        int
+		wantResponse    string
+		wantLastWatered time.Time
+	}{
+		"invalid event": {
+			body: `{"type":"singing","amount":-1}`,
+
+			wantCode:        http.StatusUnprocessableEntity,
+			wantResponse:    `{"message":"validation error: invalid input with 2 error(-s)","errors":{"amount":"amount cannot be negative","type":"type must be one of: watering, fertilizing, repotting, pruning"}}`,
+			wantLastWatered: lastWatered,
+		},
+		"missing plant": {
+			plantID: "missing",
+			body:    `{"type":"watering"}`,
+
+			wantCode:        http.StatusNotFound,
+			wantResponse:    `{"message":"find plant by id: plant with ID 'missing' does not exist"}`,
+			wantLastWatered: lastWatered,
+		},
+		"watering updates the plant": {
+			body: `{"type":"watering","time":"2024-05-02T10:00:00+02:00","amount":250,"notes":"rain water"}`,
+
+			wantCode:        http.StatusOK,
+			wantResponse:    `{"type":"watering","time":"2024-05-02T08:00:00Z","amount":250,"actor":"admin","notes":"rain water"}`,
+			wantLastWatered: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+		},
+		"late watering keeps the plant": {
+			body: `{"type":"watering","time":"2024-04-30T08:00:00Z"}`,
+
+			wantCode:        http.StatusOK,
+			wantResponse:    `{"type":"watering","time":"2024-04-30T08:00:00Z","actor":"admin"}`,
+			wantLastWatered: lastWatered,
+		},
+		"other care keeps the plant": {
+			body: `{"type":"fertilizing","time":"2024-05-03T08:00:00Z","amount":5,"actor":"jane"}`,
+
+			wantCode:        http.StatusOK,
+			wantResponse:    `{"type":"fertilizing","time":"2024-05-03T08:00:00Z","amount":5,"actor":"jane"}`,
+			wantLastWatered: lastWatered,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			plantStore := store.NewMemoryStore(nil)
+			plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern", LastWateredAt: &lastWatered})
+			require.NoError(t, err)
+			plantID := tc.plantID
+			if plantID == "" {
+				plantID = plant.ID
+			}
+
+			gotCode, gotBody := doAdmin(t, newTestAPI(t, func(_ *config.Server, deps *Dependencies) { deps.PlantStore = plantStore }), http.MethodPost, "/plants/"+plantID+"/care", tc.body)
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			if gotCode == http.StatusOK {
+				// NOTE: the generated ID and plant ID are checked separately
+				var got map[string]any
+				require.NoError(t, json.Unmarshal([]byte(gotBody), &got))
+				assert.NotEmpty(t, got["id"])
+				assert.Equal(t, plant.ID, got["plantId"])
+				delete(got, "id")
+				delete(got, "plantId")
+				gotJSON, err := json.Marshal(got)
+				require.NoError(t, err)
+				gotBody = string(gotJSON)
+			}
+			assert.JSONEq(t, tc.wantResponse, gotBody)
+			gotPlant, err := plantStore.Find(ctx, plant.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantLastWatered, *gotPlant.LastWateredAt)
+		})
+	}
+}
+
+func TestCareQueries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	plantStore := store.NewMemoryStore(nil)
+	fern, err := plantStore.Create(ctx, plants.Plant{Name: "fern", CareIntervals: map[plants.CareType]int{plants.CareWatering: 2}})
+	require.NoError(t, err)
+	cactus, err := plantStore.Create(ctx, plants.Plant{Name: "cactus", CareIntervals: map[plants.CareType]int{plants.CareWatering: 14, plants.CareRepotting: 365}})
+	require.NoError(t, err)
+	for _, e := range []plants.CareEvent{
+		{PlantID: fern.ID, Type: plants.CareWatering, Time: now.AddDate(0, 0, -3)},
+		{PlantID: fern.ID, Type: plants.CarePruning, Time: now.AddDate(0, 0, -1)},
+		{PlantID: cactus.ID, Type: plants.CareWatering, Time: now.AddDate(0, 0, -1)},
+	} {
+		_, err := plantStore.CreateCareEvent(ctx, e)
+		require.NoError(t, err)
+	}
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) { deps.PlantStore = plantStore })
+
+	type item struct {
+		PlantID string          `json:"plantId"`
+		Type    plants.CareType `json:"type"`
+	}
+	tests := map[string]struct {
+		path string
+
+		wantCode  int
+		wantItems []item
+		wantError string
+	}{
+		"plant care log": {
+			path: "/plants/" + fern.ID + "/care",
+
+			wantCode:  http.StatusOK,
+			wantItems: []item{{PlantID: fern.ID, Type: plants.CarePruning}, {PlantID: fern.ID, Type: plants.CareWatering}},
+		},
+		"plant care log of a type": {
+			path: "/plants/" + fern.ID + "/care?type=watering",
+
+			wantCode:  http.StatusOK,
+			wantItems: []item{{PlantID: fern.ID, Type: plants.CareWatering}},
+		},
+		"plant care log of a missing plant": {
+			path: "/plants/missing/care",
+
+			wantCode:  http.StatusNotFound,
+			wantError: `{"message":"find plant by id: plant with ID 'missing' does not exist"}`,
+		},
+		"overdue": {
+			path: "/care/overdue",
+
+			wantCode:  http.StatusOK,
+			wantItems: []item{{PlantID: cactus.ID, Type: plants.CareRepotting}, {PlantID: fern.ID, Type: plants.CareWatering}},
+		},
+		"overdue of a type": {
+			path: "/care/overdue?type=repotting",
+
+			wantCode:  http.StatusOK,
+			wantItems: []item{{PlantID: cactus.ID, Type: plants.CareRepotting}},
+		},
+		"overdue of an unknown type": {
+			path: "/care/overdue?type=singing",
+
+			wantCode:  http.StatusBadRequest,
+			wantError: `{"message":"query parameter 'type' must be one of: watering, fertilizing, repotting, pruning"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotCode, gotBody := doAdmin(t, handler, http.MethodGet, tc.path, "")
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			if tc.wantError != "" {
+				assert.JSONEq(t, tc.wantError, gotBody)
+				return
+			}
+			var gotItems []item
+			require.NoError(t, json.Unmarshal([]byte(gotBody), &gotItems))
+			assert.Equal(t, tc.wantItems, gotItems)
+		})
+	}
+}
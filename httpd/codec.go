@@ -0,0 +1,312 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec renders responses in one format and optionally decodes request bodies.
+// All codecs share the JSON data model: values are marshalled to JSON first,
+// so json struct tags apply to every format.
+type Codec struct {
+	// Name is used in error messages, e.g. "json"
+	Name      string
+	MediaType string
+	// Aliases are other media types that select this codec, e.g. "text/xml"
+	Aliases []string
+	Encode  func(w io.Writer, v any) error
+	// Decode converts a request body into JSON, nil means the format is response only
+	Decode func(body []byte) ([]byte, error)
+}
+
+func (c Codec) matches(mediaType string) bool {
+	return c.MediaType == mediaType || slices.Contains(c.Aliases, mediaType)
+}
+
+type codecRegistry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// codecs are tried in order when a client accepts several formats equally, the first one is the default
+var codecs = &codecRegistry{codecs: []Codec{jsonCodec, xmlCodec, yamlCodec, msgpackCodec, csvCodec, sseCodec}}
+
+// RegisterCodec adds a response format, or replaces the codec with the same media type
+func RegisterCodec(codec Codec) {
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	for i, c := range codecs.codecs {
+		if c.MediaType == codec.MediaType {
+			codecs.codecs[i] = codec
+			return
+		}
+	}
+	codecs.codecs = append(codecs.codecs, codec)
+}
+
+func (r *codecRegistry) all() []Codec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.codecs)
+}
+
+func (r *codecRegistry) mediaTypes() string {
+	var types []string
+	for _, c := range r.all() {
+		types = append(types, c.MediaType)
+	}
+	return strings.Join(types, ", ")
+}
+
+// decodeTypes lists the media types accepted as request bodies
+func (r *codecRegistry) decodeTypes() string {
+	var types []string
+	for _, c := range r.all() {
+		if c.Decode != nil {
+			types = append(types, c.MediaType)
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity ranks how closely a media range matches a codec: 3 for its own type or an alias,
+// 2 for "type/*", 1 for "*/*" and 0 when it doesnt match at all
+func (mr mediaRange) specificity(c Codec) int {
+	switch {
+	case c.matches(mr.mediaType):
+		return 3
+	case mr.mediaType == "*/*":
+		return 1
+	}
+	if prefix, ok := strings.CutSuffix(mr.mediaType, "/*"); ok && strings.HasPrefix(c.MediaType, prefix+"/") {
+		return 2
+	}
+	return 0
+}
+
+// negotiate picks the codec for an Accept header, a missing header means the default codec.
+// As in RFC 9110 each codec gets the quality of the most specific range matching it, so
+// "text/csv;q=0, */*" excludes CSV. Among equal qualities the codec named more specifically wins,
+// then the registry order.
+func (r *codecRegistry) negotiate(accept string) (Codec, bool) {
+	all := r.all()
+	if strings.TrimSpace(accept) == "" {
+		return all[0], true
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	var (
+		best            Codec
+		bestQ           float64
+		bestSpecificity int
+		found           bool
+	)
+	for _, c := range all {
+		q, specificity := 0.0, 0
+		for _, mr := range ranges {
+			s := mr.specificity(c)
+			if s > specificity || (s == specificity && s > 0 && mr.q > q) {
+				q, specificity = mr.q, s
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if !found || q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity, found = c, q, specificity, true
+		}
+	}
+
+	return best, found
+}
+
+func (r *codecRegistry) forContentType(mediaType string) (Codec, bool) {
+	for _, c := range r.all() {
+		if c.matches(mediaType) {
+			return c, true
+		}
+	}
+	// NOTE: structured syntax suffixes like application/merge-patch+json are plain JSON
+	if strings.HasSuffix(mediaType, "+json") {
+		return jsonCodec, true
+	}
+
+	return Codec{}, false
+}
+
+func acceptHeader(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	return r.Header.Get("Accept")
+}
+
+// newNegotiation answers 406 before any handler runs when none of the codecs is acceptable
+func newNegotiation() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := codecs.negotiate(acceptHeader(r)); !ok {
+				err := fmt.Errorf("none of the accepted types are supported, available: %s", codecs.mediaTypes())
+				// NOTE: the client accepts nothing we have, so the error falls back to the default codec
+				r.Header.Del("Accept")
+				_ = encode(w, r, http.StatusNotAcceptable, newHttpError(err))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// encode writes v in the format negotiated from the request Accept header
+func encode[T any](w http.ResponseWriter, r *http.Request, status int, v T) error {
+	codec, ok := codecs.negotiate(acceptHeader(r))
+	if !ok {
+		codec = codecs.all()[0]
+	}
+
+	w.Header().Set("Content-Type", codec.MediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if err := codec.Encode(w, v); err != nil {
+		return fmt.Errorf("encode %s: %w", codec.Name, err)
+	}
+
+	return nil
+}
+
+// decode reads exactly one value from the request body in the format given by its Content-Type,
+// a missing Content-Type means JSON. Unknown fields are rejected so typos in field names
+// dont silently turn into zero values.
+func decode[T any](r *http.Request) (T, error) {
+	var v T
+	codec := jsonCodec
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		var ok bool
+		if err == nil {
+			codec, ok = codecs.forContentType(mediaType)
+		}
+		if !ok || codec.Decode == nil {
+			return v, &decodeError{
+				Status: http.StatusUnsupportedMediaType,
+				Err:    fmt.Errorf("unsupported content type '%s', expected one of %s", contentType, codecs.decodeTypes()),
+			}
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return v, &decodeError{
+				Status: http.StatusRequestEntityTooLarge,
+				Err:    fmt.Errorf("request body too large, limit is %d bytes", maxBytesErr.Limit),
+			}
+		}
+		return v, fmt.Errorf("read body: %w", err)
+	}
+
+	if codec.MediaType != jsonCodec.MediaType {
+		converted, err := codec.Decode(body)
+		if err != nil {
+			return v, &decodeError{Status: http.StatusUnprocessableEntity, Err: fmt.Errorf("decode %s: %w", codec.Name, err)}
+		}
+		v, err = decodeJSON[T](converted)
+		// NOTE: positions would point into the converted JSON instead of the original body, so they are dropped
+		var decodeErr *decodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.Line, decodeErr.Column = 0, 0
+		}
+		return v, err
+	}
+
+	return decodeJSON[T](body)
+}
+
+func decodeJSON[T any](body []byte) (T, error) {
+	var v T
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		decodeErr := &decodeError{Status: http.StatusUnprocessableEntity, Err: fmt.Errorf("decode json: %w", err)}
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			decodeErr.Line, decodeErr.Column = position(body, syntaxErr.Offset)
+		case errors.As(err, &typeErr):
+			decodeErr.Line, decodeErr.Column = position(body, typeErr.Offset)
+		}
+		return v, decodeErr
+	}
+
+	// NOTE: anything other than whitespace after the first value is trailing garbage or a second value
+	if rest := bytes.TrimLeft(body[dec.InputOffset():], " \t\r\n"); len(rest) > 0 {
+		line, column := position(body, int64(len(body)-len(rest)+1))
+		return v, &decodeError{
+			Status: http.StatusUnprocessableEntity,
+			Line:   line,
+			Column: column,
+			Err:    errors.New("decode json: body must contain a single JSON value"),
+		}
+	}
+
+	return v, nil
+}
+
+// position converts the offset reported by encoding/json, which is the number of bytes read
+// including the offending one, into a 1-based line and column of that byte
+func position(body []byte, offset int64) (int, int) {
+	index := min(max(offset-1, 0), int64(len(body)))
+	before := body[:index]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(index) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+type Validator interface {
+	Valid() (problems map[string]string)
+}
+
+func decodeValid[T Validator](r *http.Request) (T, map[string]string, error) {
+	v, err := decode[T](r)
+	if err != nil {
+		return v, nil, err
+	}
+
+	if problems := v.Valid(); len(problems) > 0 {
+		return v, problems, fmt.Errorf("invalid input with %d error(-s)", len(problems))
+	}
+
+	return v, nil, nil
+}
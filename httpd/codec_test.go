@@ -0,0 +1,290 @@
+package httpd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"plants/plants"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]struct {
+		accept string
+
+		want   string
+		wantOk bool
+	}{
+		"missing header is json": {
+			accept: "",
+
+			want:   "application/json",
+			wantOk: true,
+		},
+		"wildcard is json": {
+			accept: "*/*",
+
+			want:   "application/json",
+			wantOk: true,
+		},
+		"exact match": {
+			accept: "text/csv",
+
+			want:   "text/csv",
+			wantOk: true,
+		},
+		"alias": {
+			accept: "text/xml",
+
+			want:   "application/xml",
+			wantOk: true,
+		},
+		"highest quality wins": {
+			accept: "application/json;q=0.5, application/msgpack",
+
+			want:   "application/msgpack",
+			wantOk: true,
+		},
+		"unsupported types are skipped": {
+			accept: "text/html, application/yaml;q=0.9",
+
+			want:   "application/yaml",
+			wantOk: true,
+		},
+		"subtype wildcard": {
+			accept: "text/*",
+
+			want:   "text/csv",
+			wantOk: true,
+		},
+		"zero quality is not acceptable": {
+			accept: "application/json;q=0",
+
+			wantOk: false,
+		},
+		"nothing matches": {
+			accept: "image/png",
+
+			wantOk: false,
+		},
+		"zero quality excludes a type matched by a wildcard": {
+			accept: "text/csv;q=0, text/*",
+
+			want:   "text/event-stream",
+			wantOk: true,
+		},
+		"zero quality wildcard excludes the rest": {
+			accept: "application/yaml, */*;q=0",
+
+			want:   "application/yaml",
+			wantOk: true,
+		},
+		"most specific range wins among equal qualities": {
+			accept: "*/*;q=1, application/xml;q=1",
+
+			want:   "application/xml",
+			wantOk: true,
+		},
+		"most specific range sets the quality": {
+			accept: "application/json;q=0.2, */*;q=0.8",
+
+			want:   "application/xml",
+			wantOk: true,
+		},
+		"json is preferred under a wildcard": {
+			accept: "text/csv;q=0, */*",
+
+			want:   "application/json",
+			wantOk: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := codecs.negotiate(tc.accept)
+
+			assert.Equal(t, tc.wantOk, ok)
+			if tc.wantOk {
+				assert.Equal(t, tc.want, got.MediaType)
+			}
+		})
+	}
+}
+
+func TestEncodeFormats(t *testing.T) {
+	testPlants := []plants.Plant{
+		{ID: "1", Name: "foo, the fern", Height: 4},
+		{ID: "2", Name: "bar", Height: 3},
+	}
+
+	tests := map[string]struct {
+		accept string
+		value  any
+
+		want string
+	}{
+		"xml list": {
+			accept: "application/xml",
+			value:  testPlants,
+
+			want: xmlHeader + `<response><item><id>1</id><name>foo, the fern</name><height>4</height></item><item><id>2</id><name>bar</name><height>3</height></item></response>`,
+		},
+		"xml with invalid element names": {
+			accept: "application/xml",
+			value:  newValidationError(assert.AnError, map[string]string{"1st": "bad"}),
+
+			want: xmlHeader + `<response><message>assert.AnError general error for testing</message><errors><entry key="1st">bad</entry></errors></response>`,
+		},
+		"yaml list keeps field order": {
+			accept: "application/yaml",
+			value:  testPlants,
+
+			want: "- id: \"1\"\n  name: foo, the fern\n  height: 4\n- id: \"2\"\n  name: bar\n  height: 3\n",
+		},
+		"csv list": {
+			accept: "text/csv",
+			value:  testPlants,
+
+			want: "id,name,height\n1,\"foo, the fern\",4\n2,bar,3\n",
+		},
+		"csv error": {
+			accept: "text/csv",
+			value:  newHttpError(assert.AnError),
+
+			want: "message\nassert.AnError general error for testing\n",
+		},
+		"csv empty list": {
+			accept: "text/csv",
+			value:  []plants.Plant{},
+
+			want: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/test", nil)
+			r.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+
+			err := encode(w, r, http.StatusOK, tc.value)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, w.Body.String())
+			assert.Contains(t, w.Header().Get("Content-Type"), tc.accept)
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+		})
+	}
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+func TestEncodeMsgpack(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+
+	err := encode(w, r, http.StatusOK, plants.Plant{ID: "1", Name: "foo", Height: 4})
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, msgpack.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, map[string]any{"id": "1", "name": "foo", "height": int8(4)}, got)
+}
+
+func TestDecodeFormats(t *testing.T) {
+	packed, err := msgpack.Marshal(map[string]any{"name": "Bob"})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		contentType string
+		body        []byte
+
+		want       string
+		wantErr    string
+		wantStatus int
+	}{
+		"yaml": {
+			contentType: "application/yaml",
+			body:        []byte("name: Bob\n"),
+
+			want: "Bob",
+		},
+		"msgpack": {
+			contentType: "application/msgpack",
+			body:        packed,
+
+			want: "Bob",
+		},
+		"yaml unknown fields are rejected": {
+			contentType: "application/x-yaml",
+			body:        []byte("nmae: Bob\n"),
+
+			wantErr:    `decode json: json: unknown field "nmae"`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		"malformed yaml": {
+			contentType: "application/yaml",
+			body:        []byte("name: [Bob\n"),
+
+			wantErr:    "decode yaml: yaml: line 1: did not find expected ',' or ']'",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		"response only formats are rejected": {
+			contentType: "text/csv",
+			body:        []byte("name\nBob\n"),
+
+			wantErr:    "unsupported content type 'text/csv', expected one of application/json, application/yaml, application/msgpack",
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		"xml bodies are rejected": {
+			contentType: "application/xml",
+			body:        []byte("<obj><name>Bob</name></obj>"),
+
+			wantErr:    "unsupported content type 'application/xml', expected one of application/json, application/yaml, application/msgpack",
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+
+			got, err := decode[obj](r)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				assert.Equal(t, tc.wantStatus, decodeStatus(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got.Name)
+		})
+	}
+}
+
+func TestNotAcceptable(t *testing.T) {
+	handler := newNegotiation()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not run")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	res := w.Result()
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotAcceptable, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"none of the accepted types are supported, available: application/json, application/xml, application/yaml, application/msgpack, text/csv, text/event-stream"}`, string(body))
+}
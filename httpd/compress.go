@@ -0,0 +1,293 @@
+package httpd
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"plants/log"
+	"plants/websocket"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// supported content encodings, in order of preference when a client accepts several equally
+const (
+	ENCODING_ZSTD    = "zstd"
+	ENCODING_GZIP    = "gzip"
+	ENCODING_DEFLATE = "deflate"
+)
+
+var encodingPreference = []string{ENCODING_ZSTD, ENCODING_GZIP, ENCODING_DEFLATE}
+
+// compressibleTypes are media types (or their prefixes) worth compressing,
+// images, archives and the like are already compressed
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/yaml",
+	"application/javascript",
+}
+
+// NOTE: encoders are expensive to allocate, so they are reused between responses
+var encoderPools = map[string]*sync.Pool{
+	ENCODING_ZSTD: {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	}},
+	ENCODING_GZIP: {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	ENCODING_DEFLATE: {New: func() any {
+		enc, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return enc
+	}},
+}
+
+// resettableEncoder is the common part of zstd.Encoder, gzip.Writer and flate.Writer
+type resettableEncoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// negotiateEncoding picks a content coding from an Accept-Encoding header, empty means identity
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if raw, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		candidates := []string{name}
+		if name == "*" {
+			candidates = encodingPreference
+		}
+		for _, candidate := range candidates {
+			rank := indexOf(encodingPreference, candidate)
+			if rank < 0 {
+				continue
+			}
+			if q > bestQ || q == bestQ && rank < indexOf(encodingPreference, best) {
+				best, bestQ = candidate, q
+			}
+		}
+	}
+
+	return best
+}
+
+func indexOf(values []string, v string) int {
+	for i, value := range values {
+		if value == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// newCompression compresses responses of at least minSize bytes with the best encoding the client accepts
+func newCompression(minSize int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// NOTE: set even when not compressing, caches must not serve a compressed response to other clients
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			// NOTE: upgraded connections take over the raw connection, there is no response body to compress
+			if encoding == "" || r.Method == http.MethodHead || websocket.IsUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+			defer func() {
+				if err := cw.Close(); err != nil {
+					ctx := r.Context()
+					log.LoggerFromCtx(ctx).ErrorContext(ctx, fmt.Sprintf("close %s response encoder: %s", encoding, err))
+				}
+			}()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter buffers the start of a response until it knows whether compressing it is worth it,
+// the status code is forwarded only then, so outer writers (like the logger wrappedWriter) still see it
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	enc         resettableEncoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	// NOTE: informational responses are sent right away and dont count as the final header
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	cw.wroteHeader = true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	compress := len(cw.buf) >= cw.minSize &&
+		header.Get("Content-Encoding") == "" &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		compressible(header.Get("Content-Type"))
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.enc = encoderPools[cw.encoding].Get().(resettableEncoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush forces the compression decision, so streaming responses go out right away
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+	// NOTE: detach from the response before pooling, so a pooled encoder never holds on to it
+	cw.enc.Reset(io.Discard)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+	return err
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decompressBody replaces a compressed request body with a decompressing reader,
+// capped at maxBytes of decompressed data so small payloads cant expand into huge ones
+func decompressBody(w http.ResponseWriter, r *http.Request, maxBytes int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	var body io.ReadCloser
+	switch encoding {
+	case "", "identity":
+		return nil
+	case ENCODING_GZIP:
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return &decodeError{Status: http.StatusBadRequest, Err: fmt.Errorf("decompress gzip body: %w", err)}
+		}
+		body = gz
+	case ENCODING_DEFLATE:
+		body = flate.NewReader(r.Body)
+	case ENCODING_ZSTD:
+		dec, err := zstd.NewReader(r.Body, zstd.WithDecoderMaxMemory(uint64(maxBytes)), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return &decodeError{Status: http.StatusBadRequest, Err: fmt.Errorf("decompress zstd body: %w", err)}
+		}
+		body = dec.IOReadCloser()
+	default:
+		return &decodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    errors.New("unsupported content encoding '" + encoding + "', expected gzip, deflate or zstd"),
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, body, maxBytes)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+
+	return nil
+}
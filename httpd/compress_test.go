@@ -0,0 +1,296 @@
+package httpd
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]struct {
+		acceptEncoding string
+		want           string
+	}{
+		"missing header":          {acceptEncoding: "", want: ""},
+		"identity only":           {acceptEncoding: "identity", want: ""},
+		"gzip":                    {acceptEncoding: "gzip", want: ENCODING_GZIP},
+		"prefers zstd on a tie":   {acceptEncoding: "gzip, deflate, zstd", want: ENCODING_ZSTD},
+		"respects q values":       {acceptEncoding: "zstd;q=0.5, gzip", want: ENCODING_GZIP},
+		"skips refused encodings": {acceptEncoding: "zstd;q=0, deflate", want: ENCODING_DEFLATE},
+		"wildcard":                {acceptEncoding: "*", want: ENCODING_ZSTD},
+		"unknown encodings":       {acceptEncoding: "br, compress", want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, negotiateEncoding(tc.acceptEncoding))
+		})
+	}
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case ENCODING_GZIP:
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		r = gz
+	case ENCODING_DEFLATE:
+		r = flate.NewReader(bytes.NewReader(body))
+	case ENCODING_ZSTD:
+		dec, err := zstd.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		defer dec.Close()
+		r = dec
+	default:
+		return string(body)
+	}
+
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat(`{"name":"fern"}`, 100)
+
+	tests := map[string]struct {
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+
+		wantEncoding string
+		wantCode     int
+	}{
+		"gzip": {
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           large,
+
+			wantEncoding: ENCODING_GZIP,
+			wantCode:     http.StatusOK,
+		},
+		"zstd": {
+			acceptEncoding: "zstd",
+			contentType:    "application/json",
+			body:           large,
+
+			wantEncoding: ENCODING_ZSTD,
+			wantCode:     http.StatusOK,
+		},
+		"deflate": {
+			acceptEncoding: "deflate",
+			contentType:    "text/csv",
+			body:           large,
+
+			wantEncoding: ENCODING_DEFLATE,
+			wantCode:     http.StatusOK,
+		},
+		"keeps status code": {
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			status:         http.StatusCreated,
+			body:           large,
+
+			wantEncoding: ENCODING_GZIP,
+			wantCode:     http.StatusCreated,
+		},
+		"sniffs missing content type": {
+			acceptEncoding: "gzip",
+			body:           strings.Repeat("plain text ", 200),
+
+			wantEncoding: ENCODING_GZIP,
+			wantCode:     http.StatusOK,
+		},
+		"skips small bodies": {
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           `{"name":"fern"}`,
+
+			wantCode: http.StatusOK,
+		},
+		"skips already compressed types": {
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           large,
+
+			wantCode: http.StatusOK,
+		},
+		"skips event streams": {
+			acceptEncoding: "gzip",
+			contentType:    "text/event-stream",
+			body:           large,
+
+			wantCode: http.StatusOK,
+		},
+		"skips clients without support": {
+			contentType: "application/json",
+			body:        large,
+
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := newCompression(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				// NOTE: written in chunks so buffering across writes is covered
+				for _, chunk := range strings.SplitAfter(tc.body, "}") {
+					_, _ = io.WriteString(w, chunk)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantCode, rr.Code)
+			assert.Equal(t, tc.wantEncoding, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			assert.Equal(t, tc.body, decompress(t, tc.wantEncoding, rr.Body.Bytes()))
+		})
+	}
+}
+
+func TestCompressionStatusCapture(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := newMiddlewareStack(newLogger(logger), newCompression(0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, `{"name":"fern"}`)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, ENCODING_GZIP, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"name":"fern"}`, decompress(t, ENCODING_GZIP, rr.Body.Bytes()))
+	assert.Contains(t, logs.String(), `"statusCode":202`)
+}
+
+func compress(t *testing.T, encoding string, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case ENCODING_GZIP:
+		w = gzip.NewWriter(&buf)
+	case ENCODING_DEFLATE:
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case ENCODING_ZSTD:
+		w, _ = zstd.NewWriter(&buf)
+	default:
+		return []byte(body)
+	}
+	_, err := io.WriteString(w, body)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestCompressedRequestBody(t *testing.T) {
+	tests := map[string]struct {
+		contentEncoding string
+		body            []byte
+
+		wantResponse string
+		wantCode     int
+	}{
+		"gzip": {
+			contentEncoding: ENCODING_GZIP,
+			body:            compress(t, ENCODING_GZIP, `{"name":"fern"}`),
+
+			wantResponse: `{"name":"fern"}`,
+			wantCode:     http.StatusOK,
+		},
+		"deflate": {
+			contentEncoding: ENCODING_DEFLATE,
+			body:            compress(t, ENCODING_DEFLATE, `{"name":"fern"}`),
+
+			wantResponse: `{"name":"fern"}`,
+			wantCode:     http.StatusOK,
+		},
+		"zstd": {
+			contentEncoding: ENCODING_ZSTD,
+			body:            compress(t, ENCODING_ZSTD, `{"name":"fern"}`),
+
+			wantResponse: `{"name":"fern"}`,
+			wantCode:     http.StatusOK,
+		},
+		"uncompressed": {
+			body: []byte(`{"name":"fern"}`),
+
+			wantResponse: `{"name":"fern"}`,
+			wantCode:     http.StatusOK,
+		},
+		"rejects decompression bombs": {
+			contentEncoding: ENCODING_GZIP,
+			body:            compress(t, ENCODING_GZIP, `{"name":"`+strings.Repeat("a", 10_000)+`"}`),
+
+			wantResponse: `{"message":"request body too large, limit is 1024 bytes"}`,
+			wantCode:     http.StatusRequestEntityTooLarge,
+		},
+		"rejects malformed gzip": {
+			contentEncoding: ENCODING_GZIP,
+			body:            []byte(`{"name":"fern"}`),
+
+			wantResponse: `{"message":"decompress gzip body: gzip: invalid header"}`,
+			wantCode:     http.StatusBadRequest,
+		},
+		"rejects unknown encodings": {
+			contentEncoding: "br",
+			body:            []byte(`{"name":"fern"}`),
+
+			wantResponse: `{"message":"unsupported content encoding 'br', expected gzip, deflate or zstd"}`,
+			wantCode:     http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := newBodyLimit(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				v, err := decode[obj](r)
+				if err != nil {
+					_ = encode(w, r, decodeStatus(err), newHttpError(err))
+					return
+				}
+				_ = encode(w, r, http.StatusOK, v)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tc.body))
+			if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantCode, rr.Code)
+			assert.JSONEq(t, tc.wantResponse, rr.Body.String())
+		})
+	}
+}
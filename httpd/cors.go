@@ -0,0 +1,141 @@
+package httpd
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"plants/config"
+	"plants/log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// originAllowed matches an Origin header against configured origins, "https://*.example.com"
+// matches any subdomain of example.com but not example.com itself
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+
+		scheme, suffix, ok := strings.Cut(pattern, "://*.")
+		if !ok {
+			continue
+		}
+		host, ok := strings.CutPrefix(origin, scheme+"://")
+		if !ok {
+			continue
+		}
+		subdomain, ok := strings.CutSuffix(host, "."+suffix)
+		// NOTE: the Origin header is client input, so the wildcard part may only be host name labels
+		if ok && subdomain != "" && strings.Trim(subdomain, "abcdefghijklmnopqrstuvwxyz0123456789-.") == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameOrigin reports whether origin is the API itself, browsers send Origin on same-origin writes too
+func sameOrigin(r *http.Request, origin string) bool {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return strings.EqualFold(origin, scheme+"://"+r.Host)
+}
+
+// newCORS sets CORS response headers for allowed origins and rejects cross-origin requests from
+// any other origin. Without configured origins CORS is disabled and requests pass through untouched.
+func newCORS(cfg config.CORS) func(next http.Handler) http.Handler {
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if len(cfg.AllowedOrigins) == 0 || origin == "" || sameOrigin(r, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// NOTE: responses differ per origin, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+			if !originAllowed(cfg.AllowedOrigins, origin) {
+				ctx := r.Context()
+				err := fmt.Errorf("origin '%s' is not allowed", origin)
+				log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error(), slog.String("origin", origin))
+				_ = encode(w, r, http.StatusForbidden, newHttpError(err))
+				return
+			}
+
+			if anyOrigin && !cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if exposed != "" && r.Method != http.MethodOptions {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// handlePreflight answers OPTIONS for a route serving methods, newCORS has already checked the origin
+func handlePreflight(cfg config.CORS, methods []string) http.Handler {
+	allow := strings.Join(append(slices.Clone(methods), http.MethodOptions), ", ")
+	var allowedMethods []string
+	for _, method := range methods {
+		if slices.Contains(cfg.AllowedMethods, method) {
+			allowedMethods = append(allowedMethods, method)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if len(cfg.AllowedOrigins) == 0 || r.Header.Get("Origin") == "" || requestedMethod == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		ctx := r.Context()
+		if !slices.Contains(allowedMethods, requestedMethod) {
+			err := fmt.Errorf("method %s is not allowed for cross-origin requests to this route", requestedMethod)
+			log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error(), slog.String("origin", r.Header.Get("Origin")))
+			_ = encode(w, r, http.StatusForbidden, newHttpError(err))
+			return
+		}
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			header = strings.TrimSpace(header)
+			if header == "" {
+				continue
+			}
+			if !slices.ContainsFunc(cfg.AllowedHeaders, func(h string) bool { return strings.EqualFold(h, header) }) {
+				err := fmt.Errorf("header %s is not allowed for cross-origin requests", header)
+				log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error(), slog.String("origin", r.Header.Get("Origin")))
+				_ = encode(w, r, http.StatusForbidden, newHttpError(err))
+				return
+			}
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+		if len(cfg.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+		}
+		if cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
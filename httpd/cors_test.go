@@ -0,0 +1,218 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"plants/config"
+	"plants/plants"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://dashboard.example.com", "https://*.plants.dev"}
+
+	tests := map[string]struct {
+		origin string
+		want   bool
+	}{
+		"exact origin":                  {origin: "https://dashboard.example.com", want: true},
+		"origin is case insensitive":    {origin: "HTTPS://Dashboard.Example.com", want: true},
+		"different scheme":              {origin: "http://dashboard.example.com", want: false},
+		"different port":                {origin: "https://dashboard.example.com:8443", want: false},
+		"wildcard subdomain":            {origin: "https://app.plants.dev", want: true},
+		"wildcard nested subdomain":     {origin: "https://eu.app.plants.dev", want: true},
+		"wildcard excludes bare domain": {origin: "https://plants.dev", want: false},
+		"wildcard suffix lookalike":     {origin: "https://evilplants.dev", want: false},
+		"wildcard with path injection":  {origin: "https://evil.com/.plants.dev", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, originAllowed(allowed, tc.origin))
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cors := config.CORS{
+		AllowedOrigins: []string{"https://dashboard.example.com", "https://*.plants.dev"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"RateLimit-Remaining"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := map[string]struct {
+		cors    config.CORS
+		method  string
+		path    string
+		headers map[string]string
+
+		wantCode    int
+		wantHeaders map[string]string
+	}{
+		"allowed origin": {
+			cors:    cors,
+			method:  http.MethodGet,
+			path:    "/api/v1/plants/",
+			headers: map[string]string{"Origin": "https://dashboard.example.com"},
+
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://dashboard.example.com",
+				"Access-Control-Expose-Headers": "RateLimit-Remaining",
+				"Vary":                          "Origin",
+			},
+		},
+		"rejects other origins": {
+			cors:    cors,
+			method:  http.MethodGet,
+			path:    "/api/v1/plants/",
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+
+			wantCode:    http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		"any origin without credentials": {
+			cors:    config.CORS{AllowedOrigins: []string{"*"}},
+			method:  http.MethodGet,
+			path:    "/api/v1/plants/",
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		"credentials echo the origin": {
+			cors:    config.CORS{AllowedOrigins: []string{"https://*.plants.dev"}, AllowCredentials: true},
+			method:  http.MethodGet,
+			path:    "/api/v1/plants/",
+			headers: map[string]string{"Origin": "https://app.plants.dev"},
+
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.plants.dev",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		"same origin passes": {
+			cors:    cors,
+			method:  http.MethodGet,
+			path:    "/api/v1/plants/",
+			headers: map[string]string{"Origin": "http://example.com"},
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		"disabled without origins": {
+			method:  http.MethodGet,
+			path:    "/api/v1/plants/",
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		"preflight": {
+			cors:   cors,
+			method: http.MethodOptions,
+			path:   "/api/v1/plants/",
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "authorization, content-type",
+			},
+
+			wantCode: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://dashboard.example.com",
+				"Access-Control-Allow-Methods":  "GET, POST",
+				"Access-Control-Allow-Headers":  "Authorization, Content-Type",
+				"Access-Control-Max-Age":        "600",
+				"Access-Control-Expose-Headers": "",
+				"Allow":                         "GET, POST, OPTIONS",
+			},
+		},
+		"preflight for path with wildcards": {
+			cors:   cors,
+			method: http.MethodOptions,
+			path:   "/api/v1/plants/123/",
+			headers: map[string]string{
+				"Origin":                        "https://app.plants.dev",
+				"Access-Control-Request-Method": "GET",
+			},
+
+			wantCode:    http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": "GET"},
+		},
+		"preflight only allows configured methods": {
+			cors:   cors,
+			method: http.MethodOptions,
+			path:   "/api/v1/admin/log-level",
+			headers: map[string]string{
+				"Origin":                        "https://dashboard.example.com",
+				"Access-Control-Request-Method": "PUT",
+			},
+
+			wantCode:    http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		"preflight rejects other headers": {
+			cors:   cors,
+			method: http.MethodOptions,
+			path:   "/api/v1/plants/",
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Debug",
+			},
+
+			wantCode: http.StatusForbidden,
+		},
+		"preflight rejects other origins": {
+			cors:   cors,
+			method: http.MethodOptions,
+			path:   "/api/v1/plants/",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+
+			wantCode: http.StatusForbidden,
+		},
+		"options without cors": {
+			cors:   cors,
+			method: http.MethodOptions,
+			path:   "/api/v1/readyz",
+
+			wantCode:    http.StatusNoContent,
+			wantHeaders: map[string]string{"Allow": "GET, OPTIONS"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := newTestAPI(t, func(cfg *config.Server, deps *Dependencies) {
+				cfg.CORS = tc.cors
+				deps.PlantStore = &mockStore{plants: []plants.Plant{}}
+			})
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.wantCode, rr.Code)
+			for k, v := range tc.wantHeaders {
+				if k == "Vary" {
+					assert.Contains(t, rr.Header().Values(k), v)
+					continue
+				}
+				assert.Equal(t, v, rr.Header().Get(k), k)
+			}
+		})
+	}
+}
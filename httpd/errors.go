@@ -1,5 +1,10 @@
 package httpd
 
+import (
+	"errors"
+	"net/http"
+)
+
 type httpError struct {
 	Message string `json:"message"`
 }
@@ -13,11 +18,49 @@ func newHttpError(err error) httpError {
 type validationError struct {
 	Message  string            `json:"message"`
 	Problems map[string]string `json:"errors,omitempty"`
+	// Line and Column point at malformed input in the request body, when known
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
 }
 
-func newValidationError(msg string, problems map[string]string) validationError {
-	return validationError{
-		Message:  msg,
+func newValidationError(err error, problems map[string]string) validationError {
+	v := validationError{
+		Message:  err.Error(),
 		Problems: problems,
 	}
+
+	var decodeErr *decodeError
+	if errors.As(err, &decodeErr) {
+		v.Line = decodeErr.Line
+		v.Column = decodeErr.Column
+	}
+
+	return v
+}
+
+// decodeError is a request body problem, it carries the status it should be answered with
+// and, for malformed JSON, where in the body it happened
+type decodeError struct {
+	Status int
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *decodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeStatus picks the response status for an error returned by decode or decodeValid
+func decodeStatus(err error) int {
+	var decodeErr *decodeError
+	if errors.As(err, &decodeErr) {
+		return decodeErr.Status
+	}
+
+	return http.StatusUnprocessableEntity
 }
@@ -0,0 +1,124 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"plants/events"
+	"plants/log"
+	"strconv"
+	"time"
+)
+
+// EVENT_RESET tells a resuming client that events were missed, it should reload all plants
+const EVENT_RESET = "reset"
+
+// how long EventSource clients wait before reconnecting
+const sseRetry = 3 * time.Second
+
+// handlePlantEvents streams plant changes as server-sent events. Clients resume with the
+// Last-Event-ID header (or the lastEventId query parameter), clients that fall too far behind
+// are disconnected and catch up from the replay buffer when they reconnect.
+func handlePlantEvents(broker *events.Broker, heartbeat time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventId")
+		}
+		var after uint64
+		if lastEventID != "" {
+			var err error
+			if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+				err = fmt.Errorf("last event id '%s' is not a valid event id", lastEventID)
+				logger.WarnContext(ctx, err.Error())
+				_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+				return
+			}
+		}
+
+		sub, err := broker.Subscribe(after)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, events.ErrBrokerClosed) {
+				code = http.StatusServiceUnavailable
+			}
+			err = fmt.Errorf("subscribe to plant events: %w", err)
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, code, newHttpError(err))
+			return
+		}
+		defer sub.Close()
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", sseCodec.MediaType)
+		w.Header().Set("Cache-Control", "no-cache")
+		// NOTE: stops reverse proxies like nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(write func(w io.Writer) error) bool {
+			if err := write(w); err != nil {
+				logger.DebugContext(ctx, "write plant event", slog.String("error", err.Error()))
+				return false
+			}
+			if err := rc.Flush(); err != nil {
+				logger.DebugContext(ctx, "flush plant event", slog.String("error", err.Error()))
+				return false
+			}
+			return true
+		}
+		sendEvent := func(e events.Event) bool {
+			return send(func(w io.Writer) error {
+				return writeSSE(w, strconv.FormatUint(e.ID, 10), string(e.Type), e)
+			})
+		}
+
+		if !send(func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+			return err
+		}) {
+			return
+		}
+		if sub.Missed && !send(func(w io.Writer) error {
+			return writeSSE(w, "", EVENT_RESET, httpError{Message: "events were missed, reload all plants"})
+		}) {
+			return
+		}
+		for _, e := range sub.Replay {
+			if !sendEvent(e) {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub.Events():
+				if !ok {
+					if sub.Lagged() {
+						logger.WarnContext(ctx, "plant event client fell behind, disconnecting")
+					}
+					return
+				}
+				if !sendEvent(e) {
+					return
+				}
+			case <-ticker.C:
+				if !send(func(w io.Writer) error {
+					_, err := io.WriteString(w, ": heartbeat\n\n")
+					return err
+				}) {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"plants/events"
+	"plants/log"
+	"plants/plants"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readFrame reads one server-sent event, the lines up to the next blank line
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func eventFrame(t *testing.T, e events.Event) string {
+	t.Helper()
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: {\"id\":%d,\"type\":\"%s\",\"time\":\"%s\",\"plant\":{\"id\":\"%s\",\"name\":\"%s\",\"height\":%d}}",
+		e.ID, e.Type, e.ID, e.Type, e.Time.Format(time.RFC3339Nano), e.Plant.ID, e.Plant.Name, e.Plant.Height)
+}
+
+func TestPlantEvents(t *testing.T) {
+	tests := map[string]struct {
+		replaySize  int
+		published   int
+		lastEventID string
+		query       string
+
+		wantReplay []int
+		wantReset  bool
+	}{
+		"streams live events": {
+			replaySize: 10,
+			published:  2,
+		},
+		"resumes after last event id": {
+			replaySize:  10,
+			published:   3,
+			lastEventID: "1",
+
+			wantReplay: []int{2, 3},
+		},
+		"resumes from query parameter": {
+			replaySize: 10,
+			published:  3,
+			query:      "?lastEventId=2",
+
+			wantReplay: []int{3},
+		},
+		"signals missed events": {
+			replaySize:  1,
+			published:   3,
+			lastEventID: "1",
+
+			wantReplay: []int{3},
+			wantReset:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			broker := events.NewBroker(tc.replaySize, 10)
+			var published []events.Event
+			for i := range tc.published {
+				published = append(published, broker.Publish(events.TypeCreated, plants.Plant{ID: fmt.Sprint(i + 1), Name: "fern"}))
+			}
+
+			// NOTE: the real middleware wraps the writer, flushing has to reach through them
+			handler := newMiddlewareStack(newLogger(log.NoopLogger()), newCompression(0))(handlePlantEvents(broker, time.Hour))
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tc.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer func() { _ = res.Body.Close() }()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+			assert.Empty(t, res.Header.Get("Content-Encoding"))
+
+			body := bufio.NewReader(res.Body)
+			assert.Equal(t, "retry: 3000", readFrame(t, body))
+			if tc.wantReset {
+				assert.Equal(t, "event: reset\ndata: {\"message\":\"events were missed, reload all plants\"}", readFrame(t, body))
+			}
+			for _, id := range tc.wantReplay {
+				assert.Equal(t, eventFrame(t, published[id-1]), readFrame(t, body))
+			}
+
+			live := broker.Publish(events.TypeUpdated, plants.Plant{ID: "1", Name: "fern", Height: 3})
+			assert.Equal(t, eventFrame(t, live), readFrame(t, body))
+		})
+	}
+}
+
+func TestPlantEventsHeartbeatAndShutdown(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	srv := httptest.NewServer(handlePlantEvents(broker, 10*time.Millisecond))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+
+	body := bufio.NewReader(res.Body)
+	assert.Equal(t, "retry: 3000", readFrame(t, body))
+	assert.Equal(t, ": heartbeat", readFrame(t, body))
+
+	// NOTE: closing the broker ends the stream, so shutdown doesnt wait for clients to disconnect
+	broker.Close()
+	for {
+		if _, err := body.ReadString('\n'); err != nil {
+			break
+		}
+	}
+
+	res, err = http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestPlantEventsInvalidLastEventID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+
+	handlePlantEvents(events.NewBroker(10, 10), time.Hour).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"message":"last event id 'abc' is not a valid event id"}`, w.Body.String())
+}
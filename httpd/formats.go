@@ -0,0 +1,379 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+var jsonCodec = Codec{
+	Name:      "json",
+	MediaType: "application/json",
+	Encode: func(w io.Writer, v any) error {
+		return json.NewEncoder(w).Encode(v)
+	},
+	Decode: func(body []byte) ([]byte, error) { return body, nil },
+}
+
+var xmlCodec = Codec{
+	Name:      "xml",
+	MediaType: "application/xml",
+	Aliases:   []string{"text/xml"},
+	Encode: func(w io.Writer, v any) error {
+		tree, err := toTree(v)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		if err := writeXML(enc, "response", tree); err != nil {
+			return err
+		}
+		return enc.Flush()
+	},
+}
+
+var yamlCodec = Codec{
+	Name:      "yaml",
+	MediaType: "application/yaml",
+	Aliases:   []string{"application/x-yaml", "text/yaml"},
+	Encode: func(w io.Writer, v any) error {
+		tree, err := toTree(v)
+		if err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		if err := enc.Encode(yamlNode(tree)); err != nil {
+			return err
+		}
+		return enc.Close()
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		var v any
+		if err := yaml.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	},
+}
+
+var msgpackCodec = Codec{
+	Name:      "msgpack",
+	MediaType: "application/msgpack",
+	Aliases:   []string{"application/x-msgpack", "application/vnd.msgpack"},
+	Encode: func(w io.Writer, v any) error {
+		tree, err := toTree(v)
+		if err != nil {
+			return err
+		}
+		enc := msgpack.NewEncoder(w)
+		// NOTE: embedded devices read these, so numbers use the smallest encoding that fits
+		enc.UseCompactInts(true)
+		enc.UseCompactFloats(true)
+		return enc.Encode(tree)
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		var v any
+		if err := msgpack.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	},
+}
+
+// csvCodec renders lists of objects as rows with a header, a single object is a single row
+var csvCodec = Codec{
+	Name:      "csv",
+	MediaType: "text/csv",
+	Encode: func(w io.Writer, v any) error {
+		tree, err := toTree(v)
+		if err != nil {
+			return err
+		}
+		return writeCSV(w, tree)
+	},
+}
+
+// sseCodec lets EventSource clients, which only accept text/event-stream, get
+// responses like errors as a single JSON "data" message
+var sseCodec = Codec{
+	Name:      "sse",
+	MediaType: "text/event-stream",
+	Encode: func(w io.Writer, v any) error {
+		return writeSSE(w, "", "", v)
+	},
+}
+
+// jsonObject keeps the member order of a JSON object, so every format lists fields
+// in the same order as the JSON response does
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	Key   string
+	Value any
+}
+
+func (o jsonObject) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(len(o)); err != nil {
+		return err
+	}
+	for _, m := range o {
+		if err := enc.EncodeString(m.Key); err != nil {
+			return err
+		}
+		if err := enc.Encode(m.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toTree marshals v to JSON and parses it back into nil, bool, string, int64, float64, []any or jsonObject
+func toTree(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return parseTree(dec)
+}
+
+func parseTree(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			list := []any{}
+			for dec.More() {
+				item, err := parseTree(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+
+		obj := jsonObject{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{Key: keyTok.(string), Value: value})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	default:
+		return t, nil
+	}
+}
+
+func scalarString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+
+	// NOTE: nested values are embedded as JSON, e.g. a list inside a CSV cell
+	data, _ := json.Marshal(treeToAny(v))
+	return string(data)
+}
+
+// treeToAny converts a tree back into values encoding/json can marshal
+func treeToAny(v any) any {
+	switch t := v.(type) {
+	case jsonObject:
+		m := make(map[string]any, len(t))
+		for _, member := range t {
+			m[member.Key] = treeToAny(member.Value)
+		}
+		return m
+	case []any:
+		list := make([]any, len(t))
+		for i, item := range t {
+			list[i] = treeToAny(item)
+		}
+		return list
+	}
+	return v
+}
+
+func writeXML(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	// NOTE: JSON keys arent always valid element names (e.g. validation problem keys), those keep the key as an attribute
+	if !isXMLName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch t := v.(type) {
+	case jsonObject:
+		for _, m := range t {
+			if err := writeXML(enc, m.Key, m.Value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range t {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(t))); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+func isXMLName(name string) bool {
+	if name == "" || len(name) >= 3 && (name[0]|0x20) == 'x' && (name[1]|0x20) == 'm' && (name[2]|0x20) == 'l' {
+		return false
+	}
+	for i, r := range name {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && !(r >= '0' && r <= '9') && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func yamlNode(v any) *yaml.Node {
+	switch t := v.(type) {
+	case jsonObject:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, m := range t {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: m.Key}, yamlNode(m.Value))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range t {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: scalarString(t)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: scalarString(t)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: scalarString(t)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+}
+
+// writeSSE writes one server-sent event, empty id and event fields are left out
+func writeSSE(w io.Writer, id, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if id != "" {
+		fmt.Fprintf(&buf, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	// NOTE: json.Marshal output has no newlines, so it always fits on a single data line
+	fmt.Fprintf(&buf, "data: %s\n\n", data)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func writeCSV(w io.Writer, v any) error {
+	var rows []jsonObject
+	switch t := v.(type) {
+	case jsonObject:
+		rows = []jsonObject{t}
+	case []any:
+		for _, item := range t {
+			obj, ok := item.(jsonObject)
+			if !ok {
+				return errors.New("csv needs a list of objects")
+			}
+			rows = append(rows, obj)
+		}
+	default:
+		return errors.New("csv needs an object or a list of objects")
+	}
+
+	// header is the union of all keys, in the order they first appear
+	var header []string
+	columns := make(map[string]int)
+	for _, row := range rows {
+		for _, m := range row {
+			if _, ok := columns[m.Key]; !ok {
+				columns[m.Key] = len(header)
+				header = append(header, m.Key)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if len(header) > 0 {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for _, m := range row {
+			record[columns[m.Key]] = scalarString(m.Value)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
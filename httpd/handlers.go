@@ -3,18 +3,45 @@ package httpd
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"plants/health"
 	"plants/log"
+	"plants/photos"
 	"plants/plants"
 	"plants/store"
+	"plants/telemetry"
 )
 
 // TODO: This `encode` approach doesnt rly work well with error reporting
 // there probabbly is a nicer way to report json marshalling errors
 
-func handleHealth() http.Handler {
+// handleLivez only reports that the process is able to serve requests,
+// dependencies are intentionally not checked so a broken DB doesnt get the pod restarted
+func handleLivez() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_ = encode(w, r, http.StatusOK, "")
+		_ = encode(w, r, http.StatusOK, health.Report{Status: health.StatusUp, Components: []health.ComponentReport{}})
+	})
+}
+
+// handleReadyz runs all registered dependency probes and reports not-ready while shutting down
+func handleReadyz(checks *health.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		if checks.ShuttingDown() {
+			_ = encode(w, r, http.StatusServiceUnavailable, health.Report{Status: health.StatusDown, Components: []health.ComponentReport{}})
+			return
+		}
+
+		report := checks.Run(ctx)
+		if report.Status != health.StatusUp {
+			logger.WarnContext(ctx, "readiness check failed", slog.Any("components", report.Components))
+			_ = encode(w, r, http.StatusServiceUnavailable, report)
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, report)
 	})
 }
 
@@ -25,7 +52,7 @@ func handleListPlants(plantStore store.Store) http.Handler {
 		plts, err := plantStore.List(ctx)
 		if err != nil {
 			err = fmt.Errorf("retrieve all plants: %w", err)
-			logger.Error(err.Error())
+			logger.ErrorContext(ctx, err.Error())
 			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
 			return
 		}
@@ -45,10 +72,12 @@ func handleGetPlant(plantStore store.Store) http.Handler {
 		id := r.PathValue("id")
 		if id == "" {
 			err := errors.New("id is required in path parameters")
-			logger.Error(err.Error())
+			logger.ErrorContext(ctx, err.Error())
 			_ = encode(w, r, http.StatusUnprocessableEntity, newHttpError(err))
 			return
 		}
+		ctx = log.WithPlantID(ctx, id)
+		logger = log.LoggerFromCtx(ctx)
 
 		plant, err := plantStore.Find(ctx, id)
 		if err != nil {
@@ -57,7 +86,7 @@ func handleGetPlant(plantStore store.Store) http.Handler {
 				code = http.StatusNotFound
 			}
 			err = fmt.Errorf("find plant by id: %w", err)
-			logger.Error(err.Error())
+			logger.ErrorContext(ctx, err.Error())
 			_ = encode(w, r, code, newHttpError(err))
 			return
 		}
@@ -66,26 +95,164 @@ func handleGetPlant(plantStore store.Store) http.Handler {
 	})
 }
 
-func handleCreatePlant(plantStore store.Store) http.Handler {
+// handleCreatePlant creates a plant, warnings like a height above its species' mature height are sent as Warning headers
+func handleCreatePlant(plantStore store.Store, speciesStore store.SpeciesStore) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		logger := log.LoggerFromCtx(ctx)
 		newPlant, problems, err := decodeValid[plants.Plant](r)
 		if err != nil {
 			err = fmt.Errorf("validation error: %w", err)
-			logger.Error(err.Error())
-			_ = encode(w, r, http.StatusUnprocessableEntity, newValidationError(err.Error(), problems))
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
 			return
 		}
+		warnings, ok := linkSpecies(w, r, speciesStore, &newPlant)
+		if !ok {
+			return
+		}
+		// NOTE: LastWateredAt follows the care log, a new plant hasnt been watered yet
+		newPlant.LastWateredAt = nil
 
 		plant, err := plantStore.Create(ctx, newPlant)
 		if err != nil {
 			err = fmt.Errorf("create plant: %w", err)
-			logger.Error(err.Error())
+			logger.ErrorContext(ctx, err.Error())
 			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
 			return
 		}
 
+		writeWarnings(w, warnings)
 		_ = encode(w, r, http.StatusOK, plant)
 	})
 }
+
+func handleUpdatePlant(plantStore store.Store, speciesStore store.SpeciesStore, measurementStore store.MeasurementStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		logger := log.LoggerFromCtx(ctx)
+		plant, problems, err := decodeValid[plants.Plant](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+		// NOTE: the path decides which plant is updated, an ID in the body is ignored
+		plant.ID = r.PathValue("id")
+		// NOTE: once a plant is measured its height follows the readings, a height in the body is ignored
+		latest, err := measurementStore.LatestMeasurement(ctx, plant.ID)
+		if err != nil && !errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+			err = fmt.Errorf("find latest measurement: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+		if err == nil {
+			plant.Height = latest.Height()
+		}
+		// NOTE: the store keeps LastWateredAt, it follows the care log
+		warnings, ok := linkSpecies(w, r, speciesStore, &plant)
+		if !ok {
+			return
+		}
+
+		updated, err := plantStore.Update(ctx, plant)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+				code = http.StatusNotFound
+			}
+			err = fmt.Errorf("update plant: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, code, newHttpError(err))
+			return
+		}
+
+		writeWarnings(w, warnings)
+		_ = encode(w, r, http.StatusOK, updated)
+	})
+}
+
+// handleDeletePlant deletes a plant along with its photos, measurements, telemetry and location history,
+// its sensors are unmapped
+func handleDeletePlant(plantStore store.Store, library *photos.Library, locationStore store.LocationStore, measurementStore store.MeasurementStore, telemetryStore telemetry.Store, sensors telemetry.SensorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		logger := log.LoggerFromCtx(ctx)
+		if _, err := plantStore.Delete(ctx, r.PathValue("id")); err != nil {
+			code := http.StatusInternalServerError
+			if errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+				code = http.StatusNotFound
+			}
+			err = fmt.Errorf("delete plant: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, code, newHttpError(err))
+			return
+		}
+		// NOTE: the plant is gone either way, photos that couldnt be deleted are only left behind on disk
+		if err := library.DeletePlant(ctx, r.PathValue("id")); err != nil {
+			logger.WarnContext(ctx, fmt.Errorf("delete photos of deleted plant: %w", err).Error())
+		}
+		if err := measurementStore.DeleteMeasurements(ctx, r.PathValue("id")); err != nil {
+			logger.WarnContext(ctx, fmt.Errorf("delete measurements of deleted plant: %w", err).Error())
+		}
+		if err := telemetryStore.DeleteReadings(ctx, r.PathValue("id")); err != nil {
+			logger.WarnContext(ctx, fmt.Errorf("delete telemetry of deleted plant: %w", err).Error())
+		}
+		// NOTE: a sensor left mapped would keep storing readings for a plant that doesnt exist
+		if err := sensors.DeletePlantSensors(ctx, r.PathValue("id")); err != nil {
+			logger.ErrorContext(ctx, fmt.Errorf("unmap sensors of deleted plant: %w", err).Error())
+		}
+		// NOTE: a leftover placement would keep taking up room in its slot, so this is logged as an error
+		if err := locationStore.RemovePlant(ctx, r.PathValue("id")); err != nil {
+			logger.ErrorContext(ctx, fmt.Errorf("remove location of deleted plant: %w", err).Error())
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (l logLevelRequest) Valid() map[string]string {
+	problems := make(map[string]string)
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		problems["level"] = "level must be one of: debug, info, warn, error"
+	}
+
+	return problems
+}
+
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func handleGetLogLevel(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = encode(w, r, http.StatusOK, logLevelResponse{Level: level.Level().String()})
+	})
+}
+
+func handleSetLogLevel(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		req, problems, err := decodeValid[logLevelRequest](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		// NOTE: already validated, so this cannot fail
+		_ = level.UnmarshalText([]byte(req.Level))
+		logger.InfoContext(ctx, "changed log level", slog.String("level", level.Level().String()))
+
+		_ = encode(w, r, http.StatusOK, logLevelResponse{Level: level.Level().String()})
+	})
+}
@@ -2,18 +2,24 @@ package httpd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"plants/health"
 	"plants/log"
+	"plants/photos"
 	"plants/plants"
 	"plants/store"
+	"plants/telemetry"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestListPlants(t *testing.T) {
@@ -173,6 +179,13 @@ func TestCreatePlant(t *testing.T) {
 			wantResponse: `{"id":"new id","name":"foo","height":2}`,
 			wantCode:     http.StatusOK,
 		},
+		"ignores last watered time": {
+			store:       &mockStore{},
+			requestJson: `{"name":"foo","height":2,"lastWateredAt":"2024-05-01T00:00:00Z"}`,
+
+			wantResponse: `{"id":"new id","name":"foo","height":2}`,
+			wantCode:     http.StatusOK,
+		},
 		"returns validation errors": {
 			store:       &mockStore{},
 			requestJson: `{"name":"","height":-2}`,
@@ -181,6 +194,13 @@ func TestCreatePlant(t *testing.T) {
 			wantResponse: `{"message":"validation error: invalid input with 2 error(-s)","errors":{"height":"height cannot be negative","name":"name cannot be empty"}}`,
 			wantCode:     http.StatusUnprocessableEntity,
 		},
+		"returns malformed json position": {
+			store:       &mockStore{},
+			requestJson: "{\"name\":\"foo\",\n\"height\":two}",
+
+			wantResponse: `{"message":"validation error: decode json: invalid character 'w' in literal true (expecting 'r')","line":2,"column":11}`,
+			wantCode:     http.StatusUnprocessableEntity,
+		},
 		"returns error when store error": {
 			store:       &mockStore{err: testError},
 			requestJson: `{"name":"foo","height":2}`,
@@ -195,8 +215,248 @@ func TestCreatePlant(t *testing.T) {
 			r := httptest.NewRequest(http.MethodGet, "/test", strings.NewReader(tc.requestJson))
 			w := httptest.NewRecorder()
 
-			handler := handleCreatePlant(tc.store)
+			handler := handleCreatePlant(tc.store, store.NewMemorySpeciesStore())
+
+			handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer func() { _ = res.Body.Close() }()
+
+			if res.StatusCode != tc.wantCode {
+				t.Errorf("status code mismatch, expected: %v, got: %v", tc.wantCode, res.StatusCode)
+			}
+
+			gotBody, gotErr := io.ReadAll(res.Body)
+			if gotErr != nil {
+				t.Errorf("failed to read response body: %v", gotErr)
+			}
+
+			assert.JSONEq(t, tc.wantResponse, string(gotBody))
+		})
+	}
+}
+
+func TestUpdatePlant(t *testing.T) {
+	testError := errors.New("foo bar test error")
+	wateredAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		store       store.Store
+		id          string
+		requestJson string
+
+		wantResponse string
+		wantCode     int
+	}{
+		"returns updated object json": {
+			store:       &mockStore{plant: &plants.Plant{ID: "1", Name: "foo", Height: 2}},
+			id:          "1",
+			requestJson: `{"id":"other","name":"foo","height":3}`,
+
+			wantResponse: `{"id":"1","name":"foo","height":3}`,
+			wantCode:     http.StatusOK,
+		},
+		"keeps last watered time": {
+			store:       &mockStore{plant: &plants.Plant{ID: "1", Name: "foo", Height: 2, LastWateredAt: &wateredAt}},
+			id:          "1",
+			requestJson: `{"name":"foo","height":3,"lastWateredAt":"2024-06-01T00:00:00Z"}`,
+
+			wantResponse: `{"id":"1","name":"foo","height":3,"lastWateredAt":"2024-05-01T00:00:00Z"}`,
+			wantCode:     http.StatusOK,
+		},
+		"returns validation errors": {
+			store:       &mockStore{plant: &plants.Plant{ID: "1", Name: "foo", Height: 2}},
+			id:          "1",
+			requestJson: `{"name":"foo","height":-3}`,
+
+			wantResponse: `{"message":"validation error: invalid input with 1 error(-s)","errors":{"height":"height cannot be negative"}}`,
+			wantCode:     http.StatusUnprocessableEntity,
+		},
+		"returns 404 when not found": {
+			store:       &mockStore{},
+			id:          "1",
+			requestJson: `{"name":"foo","height":3}`,
+
+			wantResponse: `{"message":"update plant: item doesnt exist in store"}`,
+			wantCode:     http.StatusNotFound,
+		},
+		"returns error when store error": {
+			store:       &mockStore{err: testError},
+			id:          "1",
+			requestJson: `{"name":"foo","height":3}`,
+
+			wantResponse: `{"message":"update plant: foo bar test error"}`,
+			wantCode:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/test", strings.NewReader(tc.requestJson))
+			r.SetPathValue("id", tc.id)
+			w := httptest.NewRecorder()
+
+			handleUpdatePlant(tc.store, store.NewMemorySpeciesStore(), store.NewMemoryMeasurementStore()).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.JSONEq(t, tc.wantResponse, w.Body.String())
+		})
+	}
+}
+
+func TestDeletePlant(t *testing.T) {
+	testError := errors.New("foo bar test error")
+
+	tests := map[string]struct {
+		store store.Store
+		id    string
+
+		wantResponse string
+		wantCode     int
+	}{
+		"returns no content": {
+			store: &mockStore{plant: &plants.Plant{ID: "1", Name: "foo", Height: 2}},
+			id:    "1",
+
+			wantCode: http.StatusNoContent,
+		},
+		"returns 404 when not found": {
+			store: &mockStore{},
+			id:    "1",
+
+			wantResponse: `{"message":"delete plant: item doesnt exist in store"}`,
+			wantCode:     http.StatusNotFound,
+		},
+		"returns error when store error": {
+			store: &mockStore{err: testError},
+			id:    "1",
+
+			wantResponse: `{"message":"delete plant: foo bar test error"}`,
+			wantCode:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, "/test", nil)
+			r.SetPathValue("id", tc.id)
+			w := httptest.NewRecorder()
+
+			blobs, err := photos.NewFileStore(t.TempDir())
+			require.NoError(t, err)
+			handleDeletePlant(tc.store, photos.NewLibrary(photos.NewMemoryStore(), blobs, 64), store.NewMemoryLocationStore(), store.NewMemoryMeasurementStore(), telemetry.NewMemoryStore(0, nil), telemetry.NewMemorySensorStore()).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantResponse == "" {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+			assert.JSONEq(t, tc.wantResponse, w.Body.String())
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	testError := errors.New("foo bar test error")
+
+	tests := map[string]struct {
+		store        store.Store
+		shuttingDown bool
+
+		wantReport health.Report
+		wantCode   int
+	}{
+		"ready when store is up": {
+			store: &mockStore{},
+
+			wantReport: health.Report{
+				Status:     health.StatusUp,
+				Components: []health.ComponentReport{{Name: "store", Status: health.StatusUp}},
+			},
+			wantCode: http.StatusOK,
+		},
+		"not ready when store is down": {
+			store: &mockStore{err: testError},
+
+			wantReport: health.Report{
+				Status:     health.StatusDown,
+				Components: []health.ComponentReport{{Name: "store", Status: health.StatusDown, Error: "foo bar test error"}},
+			},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		"not ready while shutting down": {
+			store:        &mockStore{},
+			shuttingDown: true,
+
+			wantReport: health.Report{Status: health.StatusDown, Components: []health.ComponentReport{}},
+			wantCode:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			checks := health.NewRegistry()
+			checks.Register("store", tc.store, 0)
+			if tc.shuttingDown {
+				checks.SetShuttingDown()
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			w := httptest.NewRecorder()
+
+			handler := handleReadyz(checks)
+			handler.ServeHTTP(w, r)
+
+			res := w.Result()
+			defer func() { _ = res.Body.Close() }()
+
+			if res.StatusCode != tc.wantCode {
+				t.Errorf("status code mismatch, expected: %v, got: %v", tc.wantCode, res.StatusCode)
+			}
+
+			var got health.Report
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+			// latency is not deterministic, so its cleared before comparing
+			for i := range got.Components {
+				assert.NotEmpty(t, got.Components[i].Latency)
+				got.Components[i].Latency = ""
+			}
+
+			assert.Equal(t, tc.wantReport, got)
+		})
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	tests := map[string]struct {
+		requestJson string
+
+		wantResponse string
+		wantCode     int
+		wantLevel    slog.Level
+	}{
+		"changes level": {
+			requestJson: `{"level":"debug"}`,
+
+			wantResponse: `{"level":"DEBUG"}`,
+			wantCode:     http.StatusOK,
+			wantLevel:    slog.LevelDebug,
+		},
+		"rejects unknown level": {
+			requestJson: `{"level":"loud"}`,
 
+			wantResponse: `{"message":"validation error: invalid input with 1 error(-s)","errors":{"level":"level must be one of: debug, info, warn, error"}}`,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantLevel:    slog.LevelInfo,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+			r := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(tc.requestJson))
+			w := httptest.NewRecorder()
+
+			handler := handleSetLogLevel(level)
 			handler.ServeHTTP(w, r)
 			res := w.Result()
 			defer func() { _ = res.Body.Close() }()
@@ -211,6 +471,7 @@ func TestCreatePlant(t *testing.T) {
 			}
 
 			assert.JSONEq(t, tc.wantResponse, string(gotBody))
+			assert.Equal(t, tc.wantLevel, level.Level())
 		})
 	}
 }
@@ -248,3 +509,52 @@ func (s *mockStore) Create(_ context.Context, plant plants.Plant) (*plants.Plant
 	plant.ID = "new id"
 	return &plant, nil
 }
+
+func (s *mockStore) Update(_ context.Context, plant plants.Plant) (*plants.Plant, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.plant == nil {
+		return nil, store.ErrorResourceDoesNotExist{Err: errors.New("item doesnt exist in store")}
+	}
+	plant.LastWateredAt = s.plant.LastWateredAt
+	return &plant, nil
+}
+
+func (s *mockStore) Delete(_ context.Context, id string) (*plants.Plant, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.plant == nil {
+		return nil, store.ErrorResourceDoesNotExist{Err: errors.New("item doesnt exist in store")}
+	}
+	return s.plant, nil
+}
+
+func (s *mockStore) SetHeight(_ context.Context, id string, height int, _ time.Time) (*plants.Plant, bool, error) {
+	if s.err != nil {
+		return nil, false, s.err
+	}
+	if s.plant == nil {
+		return nil, false, store.ErrorResourceDoesNotExist{Err: errors.New("item doesnt exist in store")}
+	}
+	plant := *s.plant
+	plant.Height = height
+	return &plant, true, nil
+}
+
+func (s *mockStore) SetLastWatered(_ context.Context, id string, wateredAt time.Time) (*plants.Plant, bool, error) {
+	if s.err != nil {
+		return nil, false, s.err
+	}
+	if s.plant == nil {
+		return nil, false, store.ErrorResourceDoesNotExist{Err: errors.New("item doesnt exist in store")}
+	}
+	plant := *s.plant
+	plant.LastWateredAt = &wateredAt
+	return &plant, true, nil
+}
+
+func (s *mockStore) Check(_ context.Context) error {
+	return s.err
+}
@@ -0,0 +1,162 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"plants/alerts"
+	"plants/events"
+	"plants/log"
+	"plants/notify"
+	"plants/plants"
+	"plants/store"
+	"time"
+)
+
+// remindOverdueWatering is a scheduled job, it publishes a reminder event for every plant overdue for watering
+func remindOverdueWatering(plantStore store.Store, careStore store.CareStore, speciesStore store.SpeciesStore, broker *events.Broker, now func() time.Time) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		logger := log.LoggerFromCtx(ctx)
+		watering, err := speciesWatering(ctx, speciesStore)
+		if err != nil {
+			return err
+		}
+		overdue, err := careStore.OverdueCare(ctx, now(), plants.CareWatering, watering)
+		if err != nil {
+			return fmt.Errorf("retrieve overdue watering: %w", err)
+		}
+
+		var errs []error
+		reminded := 0
+		for _, o := range overdue {
+			plant, err := plantStore.Find(ctx, o.PlantID)
+			if errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+				// NOTE: deleted since the overdue list was computed
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("find plant by id: %w", err))
+				continue
+			}
+			broker.PublishReminder(*plant, o)
+			reminded++
+		}
+
+		logger.InfoContext(ctx, "published overdue watering reminders", slog.Int("count", reminded))
+		return errors.Join(errs...)
+	}
+}
+
+// overdueDigest is a scheduled job, it sends everyone who opted in a digest of their plants overdue for care
+type overdueDigest struct {
+	plantStore   store.Store
+	careStore    store.CareStore
+	speciesStore store.SpeciesStore
+	preferences  notify.PreferenceStore
+	templates    *notify.Templates
+	// notifiers per channel, channels without one are skipped
+	notifiers map[string]notify.Notifier
+	// loc is where days are counted for the digest
+	loc *time.Location
+	now func() time.Time
+}
+
+func (d overdueDigest) run(ctx context.Context) error {
+	logger := log.LoggerFromCtx(ctx)
+	now := d.now()
+	watering, err := speciesWatering(ctx, d.speciesStore)
+	if err != nil {
+		return err
+	}
+	overdue, err := d.careStore.OverdueCare(ctx, now, "", watering)
+	if err != nil {
+		return fmt.Errorf("retrieve overdue care: %w", err)
+	}
+	if len(overdue) == 0 {
+		logger.InfoContext(ctx, "no plants overdue for care, skipping the digest")
+		return nil
+	}
+	plantList, err := d.plantStore.List(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve all plants: %w", err)
+	}
+	prefsList, err := d.preferences.ListPreferences(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve notification preferences: %w", err)
+	}
+
+	var errs []error
+	sent := 0
+	for _, prefs := range prefsList {
+		if !prefs.Digest {
+			continue
+		}
+		digest := notify.BuildDigest(prefs, overdue, plantList, now, d.loc)
+		if len(digest.Items) == 0 {
+			continue
+		}
+		msg, err := d.templates.Render("digest", digest)
+		if err != nil {
+			return err
+		}
+
+		n, err := notifyUser(ctx, d.notifiers, prefs, msg)
+		sent += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	logger.InfoContext(ctx, "sent overdue care digests", slog.Int("count", sent))
+	return errors.Join(errs...)
+}
+
+// notifyUser sends msg over every channel of the user that has a notifier, it returns how many were sent
+func notifyUser(ctx context.Context, notifiers map[string]notify.Notifier, prefs notify.Preferences, msg notify.Message) (int, error) {
+	var errs []error
+	sent := 0
+	for _, channel := range prefs.Channels {
+		notifier, ok := notifiers[channel]
+		if !ok {
+			log.LoggerFromCtx(ctx).WarnContext(ctx, "notification channel is not configured", slog.String("channel", channel), slog.String(log.USER_ID_KEY, prefs.UserID))
+			continue
+		}
+		if err := notifier.Notify(ctx, prefs, msg); err != nil {
+			errs = append(errs, fmt.Errorf("notify user '%s' by %s: %w", prefs.UserID, channel, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// alertNotifier is an alert sink, it notifies everyone who opted into alerts about the plants they follow
+type alertNotifier struct {
+	preferences notify.PreferenceStore
+	templates   *notify.Templates
+	// notifiers per channel, channels without one are skipped
+	notifiers map[string]notify.Notifier
+}
+
+func (n alertNotifier) Send(ctx context.Context, alert alerts.Alert, plant plants.Plant) error {
+	prefsList, err := n.preferences.ListPreferences(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve notification preferences: %w", err)
+	}
+	msg, err := n.templates.Render("alert", alert)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, prefs := range prefsList {
+		if !prefs.Alerts || (len(prefs.Tags) > 0 && !plant.HasAnyTag(prefs.Tags)) {
+			continue
+		}
+		if _, err := notifyUser(ctx, n.notifiers, prefs, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
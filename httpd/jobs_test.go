@@ -0,0 +1,209 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"plants/alerts"
+	"plants/events"
+	"plants/notify"
+	"plants/plants"
+	"plants/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemindOverdueWatering(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	plantStore := store.NewMemoryStore(nil)
+	thirsty, err := plantStore.Create(ctx, plants.Plant{Name: "fern", CareIntervals: map[plants.CareType]int{plants.CareWatering: 2}})
+	require.NoError(t, err)
+	watered, err := plantStore.Create(ctx, plants.Plant{Name: "cactus", CareIntervals: map[plants.CareType]int{plants.CareWatering: 14}})
+	require.NoError(t, err)
+	_, err = plantStore.Create(ctx, plants.Plant{Name: "moss", CareIntervals: map[plants.CareType]int{plants.CarePruning: 30}})
+	require.NoError(t, err)
+	for _, e := range []plants.CareEvent{
+		{PlantID: thirsty.ID, Type: plants.CareWatering, Time: now.AddDate(0, 0, -3)},
+		{PlantID: watered.ID, Type: plants.CareWatering, Time: now.AddDate(0, 0, -3)},
+	} {
+		_, err := plantStore.CreateCareEvent(ctx, e)
+		require.NoError(t, err)
+	}
+
+	broker := events.NewBroker(10, 10)
+	sub, err := broker.Subscribe(0)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	err = remindOverdueWatering(plantStore, plantStore, store.NewMemorySpeciesStore(), broker, func() time.Time { return now })(ctx)
+	require.NoError(t, err)
+
+	got := <-sub.Events()
+	assert.Equal(t, events.TypeReminder, got.Type)
+	assert.Equal(t, *thirsty, got.Plant)
+	lastCaredAt, dueAt := now.AddDate(0, 0, -3), now.AddDate(0, 0, -1)
+	assert.Equal(t, &plants.OverdueCare{
+		PlantID:      thirsty.ID,
+		PlantName:    "fern",
+		Type:         plants.CareWatering,
+		IntervalDays: 2,
+		LastCaredAt:  &lastCaredAt,
+		DueAt:        &dueAt,
+	}, got.Reminder)
+	select {
+	case e := <-sub.Events():
+		t.Fatalf("only one plant is overdue for watering, got another event: %+v", e)
+	default:
+	}
+}
+
+type sentNotification struct {
+	userID  string
+	subject string
+}
+
+type recordingNotifier struct {
+	sent []sentNotification
+	err  error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, to notify.Preferences, msg notify.Message) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, sentNotification{userID: to.UserID, subject: msg.Subject})
+	return nil
+}
+
+func TestOverdueDigest(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 10, 7, 0, 0, 0, time.UTC)
+	plantStore := store.NewMemoryStore(nil)
+	for _, p := range []plants.Plant{
+		{Name: "fern", Tags: []string{"office"}, CareIntervals: map[plants.CareType]int{plants.CareWatering: 2, plants.CareFertilizing: 30}},
+		{Name: "cactus", Tags: []string{"home"}, CareIntervals: map[plants.CareType]int{plants.CareWatering: 14}},
+	} {
+		_, err := plantStore.Create(ctx, p)
+		require.NoError(t, err)
+	}
+	preferences := notify.NewMemoryPreferenceStore()
+	for _, prefs := range []notify.Preferences{
+		{UserID: "jane", Channels: []string{notify.CHANNEL_EMAIL}, Email: "jane@example.com", Digest: true},
+		{UserID: "john", Channels: []string{notify.CHANNEL_EMAIL}, Email: "john@example.com", Digest: true, Tags: []string{"home"}},
+		{UserID: "mary", Channels: []string{notify.CHANNEL_EMAIL}, Email: "mary@example.com", Digest: true, Tags: []string{"garden"}},
+		{UserID: "opted-out", Channels: []string{notify.CHANNEL_EMAIL}, Email: "no@example.com"},
+		{UserID: "sms", Channels: []string{"sms"}, Digest: true},
+	} {
+		_, err := preferences.PutPreferences(ctx, prefs)
+		require.NoError(t, err)
+	}
+	templates, err := notify.NewTemplates()
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		notifier *recordingNotifier
+
+		wantSent []sentNotification
+		wantErr  string
+	}{
+		"digests are sent to users with overdue plants": {
+			notifier: &recordingNotifier{},
+
+			wantSent: []sentNotification{
+				{userID: "jane", subject: "3 plant(-s) overdue for care"},
+				{userID: "john", subject: "1 plant(-s) overdue for care"},
+			},
+		},
+		"failures are reported per user": {
+			notifier: &recordingNotifier{err: errors.New("smtp is down")},
+
+			wantErr: "notify user 'jane' by email: smtp is down\nnotify user 'john' by email: smtp is down",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			digest := overdueDigest{
+				plantStore:   plantStore,
+				careStore:    plantStore,
+				speciesStore: store.NewMemorySpeciesStore(),
+				preferences:  preferences,
+				templates:    templates,
+				notifiers:    map[string]notify.Notifier{notify.CHANNEL_EMAIL: tc.notifier},
+				loc:          time.UTC,
+				now:          func() time.Time { return now },
+			}
+
+			err := digest.run(ctx)
+
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantSent, tc.notifier.sent)
+		})
+	}
+}
+
+func TestAlertNotifier(t *testing.T) {
+	ctx := context.Background()
+	preferences := notify.NewMemoryPreferenceStore()
+	for _, prefs := range []notify.Preferences{
+		{UserID: "jane", Channels: []string{notify.CHANNEL_EMAIL}, Email: "jane@example.com", Alerts: true},
+		{UserID: "john", Channels: []string{notify.CHANNEL_EMAIL}, Email: "john@example.com", Alerts: true, Tags: []string{"home"}},
+		{UserID: "mary", Channels: []string{notify.CHANNEL_EMAIL}, Email: "mary@example.com", Alerts: true, Tags: []string{"garden"}},
+		{UserID: "digest-only", Channels: []string{notify.CHANNEL_EMAIL}, Email: "no@example.com", Digest: true},
+	} {
+		_, err := preferences.PutPreferences(ctx, prefs)
+		require.NoError(t, err)
+	}
+	templates, err := notify.NewTemplates()
+	require.NoError(t, err)
+	firedAt := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	alert := alerts.Alert{RuleName: "dry", PlantID: "1", PlantName: "cactus", State: alerts.StateFiring, FiredAt: &firedAt}
+	plant := plants.Plant{ID: "1", Name: "cactus", Tags: []string{"home"}}
+
+	tests := map[string]struct {
+		notifier *recordingNotifier
+
+		wantSent []sentNotification
+		wantErr  string
+	}{
+		"users who opted into alerts for the plant are notified": {
+			notifier: &recordingNotifier{},
+
+			wantSent: []sentNotification{
+				{userID: "jane", subject: "[firing] dry: cactus"},
+				{userID: "john", subject: "[firing] dry: cactus"},
+			},
+		},
+		"failures are reported per user": {
+			notifier: &recordingNotifier{err: errors.New("smtp is down")},
+
+			wantErr: "notify user 'jane' by email: smtp is down\nnotify user 'john' by email: smtp is down",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			notifier := alertNotifier{
+				preferences: preferences,
+				templates:   templates,
+				notifiers:   map[string]notify.Notifier{notify.CHANNEL_EMAIL: tc.notifier},
+			}
+
+			err := notifier.Send(ctx, alert, plant)
+
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantSent, tc.notifier.sent)
+		})
+	}
+}
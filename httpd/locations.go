@@ -0,0 +1,292 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"plants/auth"
+	"plants/log"
+	"plants/plants"
+	"plants/store"
+	"strconv"
+	"time"
+)
+
+// parentProblems returns the validation problems of a location store error about the parent location,
+// ok is false for any other error
+func parentProblems(err error) (map[string]string, bool) {
+	var parentErr store.ErrorInvalidParent
+	if !errors.As(err, &parentErr) {
+		return nil, false
+	}
+	return map[string]string{"parentId": parentErr.Error()}, true
+}
+
+// handleListLocations lists all locations, or only the ones directly in the "parentId" query parameter
+func handleListLocations(locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		list, err := locationStore.ListLocations(ctx, r.URL.Query().Get("parentId"))
+		if err != nil {
+			err = fmt.Errorf("retrieve all locations: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
+
+func handleGetLocation(locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		location, err := locationStore.FindLocation(ctx, r.PathValue("id"))
+		if err != nil {
+			err = fmt.Errorf("find location by id: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, location)
+	})
+}
+
+func handleCreateLocation(locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		location, problems, err := decodeValid[plants.Location](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+		created, err := locationStore.CreateLocation(ctx, location)
+		if problems, ok := parentProblems(err); ok {
+			err = fmt.Errorf("validation error: invalid input with %d error(-s)", len(problems))
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusUnprocessableEntity, newValidationError(err, problems))
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("create location: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, created)
+	})
+}
+
+// handleUpdateLocation replaces a location, it can be moved to another parent along with everything in it
+func handleUpdateLocation(locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		location, problems, err := decodeValid[plants.Location](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+		location.ID = r.PathValue("id")
+		updated, err := locationStore.UpdateLocation(ctx, location)
+		if problems, ok := parentProblems(err); ok {
+			err = fmt.Errorf("validation error: invalid input with %d error(-s)", len(problems))
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusUnprocessableEntity, newValidationError(err, problems))
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("update location: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, updated)
+	})
+}
+
+func handleDeleteLocation(locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		if err := locationStore.DeleteLocation(ctx, r.PathValue("id")); err != nil {
+			err = fmt.Errorf("delete location: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handleListLocationPlants lists the plants in a location, with "recursive=true" also the ones in every location under it
+func handleListLocationPlants(plantStore store.Store, locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		recursive := false
+		if value := r.URL.Query().Get("recursive"); value != "" {
+			var err error
+			recursive, err = strconv.ParseBool(value)
+			if err != nil {
+				err = errors.New("query parameter 'recursive' must be true or false")
+				logger.WarnContext(ctx, err.Error())
+				_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+				return
+			}
+		}
+
+		plantIDs, err := locationStore.PlantsIn(ctx, r.PathValue("id"), recursive)
+		if err != nil {
+			err = fmt.Errorf("find plants in location: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+		plantList, err := plantStore.List(ctx)
+		if err != nil {
+			err = fmt.Errorf("retrieve all plants: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		in := make(map[string]bool, len(plantIDs))
+		for _, id := range plantIDs {
+			in[id] = true
+		}
+		found := make([]plants.Plant, 0, len(plantIDs))
+		for _, plant := range plantList {
+			if in[plant.ID] {
+				found = append(found, plant)
+			}
+		}
+
+		_ = encode(w, r, http.StatusOK, found)
+	})
+}
+
+type moveRequest struct {
+	LocationID string `json:"locationId"`
+}
+
+func (m moveRequest) Valid() map[string]string {
+	problems := make(map[string]string)
+	if m.LocationID == "" {
+		problems["locationId"] = "locationId cannot be empty"
+	}
+
+	return problems
+}
+
+// movePlant records a plant being moved by the authenticated caller and writes the response
+func movePlant(w http.ResponseWriter, r *http.Request, locationStore store.LocationStore, plant plants.Plant, locationID string) (*plants.PlantMove, bool) {
+	ctx := r.Context()
+	logger := log.LoggerFromCtx(ctx)
+	move := plants.PlantMove{PlantID: plant.ID, ToLocationID: locationID, Time: time.Now().UTC()}
+	if identity, ok := auth.IdentityFromCtx(ctx); ok {
+		move.Actor = identity.Subject
+	}
+
+	moved, err := locationStore.MovePlant(ctx, move)
+	if err != nil {
+		err = fmt.Errorf("move plant: %w", err)
+		logger.ErrorContext(ctx, err.Error())
+		_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+		return nil, false
+	}
+	return moved, true
+}
+
+// handleMovePlant places a plant in a location, slots refuse plants once they are full
+func handleMovePlant(plantStore store.Store, locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		req, problems, err := decodeValid[moveRequest](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+		_, err = locationStore.FindLocation(ctx, req.LocationID)
+		if errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+			problems := map[string]string{"locationId": err.Error()}
+			err = fmt.Errorf("validation error: invalid input with %d error(-s)", len(problems))
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusUnprocessableEntity, newValidationError(err, problems))
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("find location by id: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		moved, ok := movePlant(w, r, locationStore, *plant, req.LocationID)
+		if !ok {
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, moved)
+	})
+}
+
+// handleRemovePlantLocation takes a plant out of its location, the move is kept in its history
+func handleRemovePlantLocation(plantStore store.Store, locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		if _, ok := movePlant(w, r, locationStore, *plant, ""); !ok {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func handleListPlantMoves(plantStore store.Store, locationStore store.LocationStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		list, err := locationStore.ListMoves(ctx, plant.ID)
+		if err != nil {
+			err = fmt.Errorf("retrieve plant moves: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
@@ -0,0 +1,132 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"plants/config"
+	"plants/plants"
+	"plants/store"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocations(t *testing.T) {
+	plantStore := store.NewMemoryStore(nil)
+	var plantIDs []string
+	for _, name := range []string{"basil", "mint", "fern"} {
+		plant, err := plantStore.Create(context.Background(), plants.Plant{Name: name})
+		require.NoError(t, err)
+		plantIDs = append(plantIDs, plant.ID)
+	}
+	basil, mint, fern := plantIDs[0], plantIDs[1], plantIDs[2]
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) { deps.PlantStore = plantStore })
+	create := func(body string) string {
+		t.Helper()
+		code, body := doAdmin(t, handler, http.MethodPost, "/locations/", body)
+		require.Equal(t, http.StatusOK, code, body)
+		var location plants.Location
+		require.NoError(t, json.Unmarshal([]byte(body), &location))
+		return location.ID
+	}
+	plantNames := func(path string) []string {
+		t.Helper()
+		code, body := doAdmin(t, handler, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, code, body)
+		var list []plants.Plant
+		require.NoError(t, json.Unmarshal([]byte(body), &list))
+		names := make([]string, 0, len(list))
+		for _, plant := range list {
+			names = append(names, plant.Name)
+		}
+		return names
+	}
+
+	code, body := doAdmin(t, handler, http.MethodPost, "/locations/", `{"name":"","kind":"slot","parentId":""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 3 error(-s)","errors":{
+		"name":"name cannot be empty",
+		"parentId":"parent location is required",
+		"capacity":"capacity of a slot must be at least 1 plant"
+	}}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/locations/", `{"name":"Greenhouse B","kind":"greenhouse","parentId":"missing"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"parentId":"parent location with ID 'missing' does not exist"}}`, body)
+
+	site := create(`{"name":"Riga","kind":"site"}`)
+	greenhouseA := create(`{"name":"Greenhouse A","kind":"greenhouse","parentId":"` + site + `"}`)
+	greenhouseB := create(`{"name":"Greenhouse B","kind":"greenhouse","parentId":"` + site + `"}`)
+	bed := create(`{"name":"Bed 1","kind":"bed","parentId":"` + greenhouseB + `"}`)
+	slot := create(`{"name":"Slot 1","kind":"slot","parentId":"` + bed + `","capacity":1}`)
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/locations/", `{"name":"Bed 2","kind":"bed","parentId":"`+slot+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"parentId":"a bed cannot be placed in a slot"}}`, body)
+	code, body = doAdmin(t, handler, http.MethodPut, "/locations/"+bed+"/", `{"name":"Bed 1","kind":"slot","parentId":"`+bed+`","capacity":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"parentId":"location 'Bed 1' cannot be placed in itself or a location under it"}}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/locations/?parentId="+site, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[
+		{"id":"`+greenhouseA+`","name":"Greenhouse A","kind":"greenhouse","parentId":"`+site+`"},
+		{"id":"`+greenhouseB+`","name":"Greenhouse B","kind":"greenhouse","parentId":"`+site+`"}
+	]`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/plants/"+basil+"/location", `{"locationId":"`+slot+`"}`)
+	assert.Equal(t, http.StatusOK, code)
+	var move plants.PlantMove
+	require.NoError(t, json.Unmarshal([]byte(body), &move))
+	assert.Equal(t, plants.PlantMove{PlantID: basil, ToLocationID: slot, Time: move.Time, Actor: "admin"}, move)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/plants/"+mint+"/location", `{"locationId":"`+slot+`"}`)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"message":"move plant: slot 'Slot 1' is full, it holds 1 plant(-s)"}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/plants/"+mint+"/location", `{"locationId":"missing"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"locationId":"location with ID 'missing' does not exist"}}`, body)
+
+	code, _ = doAdmin(t, handler, http.MethodPut, "/plants/"+mint+"/location", `{"locationId":"`+bed+`"}`)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = doAdmin(t, handler, http.MethodPut, "/plants/"+fern+"/location", `{"locationId":"`+greenhouseA+`"}`)
+	require.Equal(t, http.StatusOK, code)
+
+	assert.Empty(t, plantNames("/locations/"+greenhouseB+"/plants"))
+	assert.Equal(t, []string{"basil", "mint"}, plantNames("/locations/"+greenhouseB+"/plants?recursive=true"))
+	assert.Equal(t, []string{"basil", "mint", "fern"}, plantNames("/locations/"+site+"/plants?recursive=true"))
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/locations/"+site+"/plants?recursive=yes", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.JSONEq(t, `{"message":"query parameter 'recursive' must be true or false"}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodDelete, "/locations/"+slot+"/", "")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"message":"delete location: location 'Slot 1' still holds 1 plant(-s)"}`, body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/plants/"+basil+"/location", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body = doAdmin(t, handler, http.MethodDelete, "/plants/"+basil+"/location", "")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"message":"move plant: plant with ID '`+basil+`' has no location"}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/plants/"+basil+"/moves", "")
+	assert.Equal(t, http.StatusOK, code)
+	var moves []plants.PlantMove
+	require.NoError(t, json.Unmarshal([]byte(body), &moves))
+	require.Len(t, moves, 2)
+	assert.Equal(t, [2]string{"", slot}, [2]string{moves[0].FromLocationID, moves[0].ToLocationID})
+	assert.Equal(t, [2]string{slot, ""}, [2]string{moves[1].FromLocationID, moves[1].ToLocationID})
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/locations/"+slot+"/", "")
+	assert.Equal(t, http.StatusNoContent, code)
+
+	// NOTE: a deleted plant doesnt keep a bed from being emptied
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/plants/"+mint+"/", "")
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/locations/"+bed+"/", "")
+	assert.Equal(t, http.StatusNoContent, code)
+}
@@ -2,27 +2,161 @@ package httpd
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"plants/alerts"
 	"plants/config"
+	"plants/events"
+	"plants/health"
+	"plants/log"
+	"plants/notify"
+	"plants/photos"
 	"plants/plants"
+	"plants/ratelimit"
+	"plants/scheduler"
 	"plants/store"
+	"plants/telemetry"
+	"plants/webhooks"
+	"strings"
+	"time"
 )
 
-func NewApiHandler(logger *slog.Logger, config config.Server, plantStore store.Store) http.Handler {
+// Dependencies are the stores and subsystems the API handlers are built from
+type Dependencies struct {
+	PlantStore  store.Store
+	Checks      *health.Registry
+	LogLevel    *slog.LevelVar
+	RateLimiter ratelimit.Store
+	Events      *events.Broker
+	// MeasurementStore holds the growth history, the latest reading is mirrored in the plant height
+	MeasurementStore store.MeasurementStore
+	// CareStore holds the care log, waterings are mirrored in the plant LastWateredAt
+	CareStore store.CareStore
+	// WebhookStore holds webhook subscriptions, Webhooks sends their deliveries
+	WebhookStore webhooks.Store
+	Webhooks     *webhooks.Dispatcher
+	// Preferences holds the notification settings of every user
+	Preferences notify.PreferenceStore
+	// Telemetry holds sensor readings, Sensors maps sensors to plants and Ingester queues batches for Telemetry
+	Telemetry telemetry.Store
+	Sensors   telemetry.SensorStore
+	Ingester  *telemetry.Ingester
+	// Alerts holds alert rules and the alerts they raised, they are evaluated by a scheduled job
+	Alerts alerts.Store
+	// Photos holds plant photos, they are deleted along with their plant
+	Photos *photos.Library
+	// Species is the catalog plants link to for their default care
+	Species store.SpeciesStore
+	// Locations holds the site, greenhouse, bed and slot tree and where each plant is
+	Locations store.LocationStore
+}
+
+func NewApiHandler(logger *slog.Logger, config config.Server, deps Dependencies) http.Handler {
 	mux := http.NewServeMux()
 
 	// NOTE: you can add specific middleware to each route here
-	adminOnly := newAdminOnly("supersecret")
+	adminOnly := newAdminOnly(config.AdminToken)
+	authenticated := newAuthenticated()
+	readLimit := newRateLimit(deps.RateLimiter, "read", ratelimit.Limit{Rate: config.RateLimit.ReadRate, Burst: config.RateLimit.ReadBurst})
+	bodyLimit := newBodyLimit(int64(config.MaxBodyBytes))
+	writeLimit := newRateLimit(deps.RateLimiter, "write", ratelimit.Limit{Rate: config.RateLimit.WriteRate, Burst: config.RateLimit.WriteBurst})
+	batchLimit := newBodyLimit(int64(config.Telemetry.MaxBatchBytes))
+	uploadLimit := newBodyLimit(int64(config.Photos.MaxUploadBytes))
+	negotiation := newNegotiation()
+
+	// routes collects the methods registered for each path, so every path answers CORS preflights
+	var paths []string
+	routes := make(map[string][]string)
+	handleBinary := func(pattern string, handler http.Handler) {
+		method, path, _ := strings.Cut(pattern, " ")
+		if _, ok := routes[path]; !ok {
+			paths = append(paths, path)
+		}
+		routes[path] = append(routes[path], method)
+		mux.Handle(pattern, withRoute(pattern, handler))
+	}
+	// NOTE: only routes answering with a codec negotiate, binary ones like photos set their own content type
+	handle := func(pattern string, handler http.Handler) {
+		handleBinary(pattern, negotiation(handler))
+	}
+
+	handle("GET /livez", handleLivez())
+	handle("GET /readyz", handleReadyz(deps.Checks))
+	handle("GET /plants/", readLimit(handleListPlants(deps.PlantStore)))
+	handle("POST /plants/", writeLimit(adminOnly(bodyLimit(handleCreatePlant(deps.PlantStore, deps.Species)))))
+	handle("GET /plants/events", readLimit(handlePlantEvents(deps.Events, config.Events.Heartbeat)))
+	handle("GET /plants/socket", readLimit(authenticated(handlePlantSocket(deps.PlantStore, deps.CareStore, deps.Events, config.WebSocket, config.CORS))))
+	handle("GET /plants/{id}/", readLimit(handleGetPlant(deps.PlantStore)))
+	handle("GET /plants/{id}/measurements", readLimit(handleListMeasurements(deps.PlantStore, deps.MeasurementStore)))
+	handle("POST /plants/{id}/measurements", writeLimit(adminOnly(bodyLimit(handleCreateMeasurement(deps.PlantStore, deps.MeasurementStore)))))
+	handle("GET /plants/{id}/measurements/daily", readLimit(handleDailyMeasurements(deps.PlantStore, deps.MeasurementStore)))
+	handle("GET /plants/{id}/care", readLimit(handleListCareEvents(deps.PlantStore, deps.CareStore)))
+	handle("POST /plants/{id}/care", writeLimit(authenticated(bodyLimit(handleCreateCareEvent(deps.PlantStore, deps.CareStore)))))
+	handle("GET /plants/{id}/telemetry", readLimit(handleAggregateTelemetry(deps.PlantStore, deps.Telemetry)))
+	handle("GET /plants/{id}/telemetry/latest", readLimit(handleLatestTelemetry(deps.PlantStore, deps.Telemetry)))
+	handle("GET /plants/{id}/photos", readLimit(handleListPhotos(deps.PlantStore, deps.Photos)))
+	handle("POST /plants/{id}/photos", writeLimit(authenticated(uploadLimit(handleUploadPhoto(deps.PlantStore, deps.Photos)))))
+	handleBinary("GET /plants/{id}/photos/{photoId}", readLimit(handleGetPhoto(deps.PlantStore, deps.Photos, false)))
+	handleBinary("GET /plants/{id}/photos/{photoId}/thumbnail", readLimit(handleGetPhoto(deps.PlantStore, deps.Photos, true)))
+	handle("DELETE /plants/{id}/photos/{photoId}", writeLimit(adminOnly(handleDeletePhoto(deps.Photos))))
+	handle("PUT /plants/{id}/", writeLimit(adminOnly(bodyLimit(handleUpdatePlant(deps.PlantStore, deps.Species, deps.MeasurementStore)))))
+	handle("PUT /plants/{id}/location", writeLimit(authenticated(bodyLimit(handleMovePlant(deps.PlantStore, deps.Locations)))))
+	handle("DELETE /plants/{id}/location", writeLimit(authenticated(handleRemovePlantLocation(deps.PlantStore, deps.Locations))))
+	handle("GET /plants/{id}/moves", readLimit(handleListPlantMoves(deps.PlantStore, deps.Locations)))
+	handle("DELETE /plants/{id}/", writeLimit(adminOnly(handleDeletePlant(deps.PlantStore, deps.Photos, deps.Locations, deps.MeasurementStore, deps.Telemetry, deps.Sensors))))
+
+	handle("GET /species/", readLimit(handleListSpecies(deps.Species)))
+	handle("POST /species/", writeLimit(adminOnly(bodyLimit(handleCreateSpecies(deps.Species)))))
+	handle("GET /species/{id}/", readLimit(handleGetSpecies(deps.Species)))
+	handle("PUT /species/{id}/", writeLimit(adminOnly(bodyLimit(handleUpdateSpecies(deps.Species)))))
+	handle("DELETE /species/{id}/", writeLimit(adminOnly(handleDeleteSpecies(deps.PlantStore, deps.Species))))
 
-	mux.Handle("GET /health", handleHealth())
-	mux.Handle("GET /plants/", handleListPlants(plantStore))
-	mux.Handle("POST /plants/", adminOnly(handleCreatePlant(plantStore)))
-	mux.Handle("GET /plants/{id}/", handleGetPlant(plantStore))
+	handle("GET /locations/", readLimit(handleListLocations(deps.Locations)))
+	handle("POST /locations/", writeLimit(adminOnly(bodyLimit(handleCreateLocation(deps.Locations)))))
+	handle("GET /locations/{id}/", readLimit(handleGetLocation(deps.Locations)))
+	handle("PUT /locations/{id}/", writeLimit(adminOnly(bodyLimit(handleUpdateLocation(deps.Locations)))))
+	handle("DELETE /locations/{id}/", writeLimit(adminOnly(handleDeleteLocation(deps.Locations))))
+	handle("GET /locations/{id}/plants", readLimit(handleListLocationPlants(deps.PlantStore, deps.Locations)))
+
+	handle("GET /care/overdue", readLimit(handleOverdueCare(deps.CareStore, deps.Species)))
+
+	// NOTE: ingestion skips the write limit, sensors send often and the bounded queue sheds load instead
+	handle("POST /telemetry", authenticated(batchLimit(handleIngestTelemetry(deps.Sensors, deps.Ingester))))
+	handle("GET /sensors/", readLimit(adminOnly(handleListSensors(deps.Sensors))))
+	handle("PUT /sensors/{id}", writeLimit(adminOnly(bodyLimit(handlePutSensor(deps.PlantStore, deps.Sensors)))))
+	handle("DELETE /sensors/{id}", writeLimit(adminOnly(handleDeleteSensor(deps.Sensors))))
+
+	handle("GET /alerts/", readLimit(handleListAlerts(deps.Alerts)))
+	handle("GET /alerts/rules/", readLimit(adminOnly(handleListAlertRules(deps.Alerts))))
+	handle("POST /alerts/rules/", writeLimit(adminOnly(bodyLimit(handleCreateAlertRule(deps.Alerts)))))
+	handle("GET /alerts/rules/{id}/", readLimit(adminOnly(handleGetAlertRule(deps.Alerts))))
+	handle("PUT /alerts/rules/{id}/", writeLimit(adminOnly(bodyLimit(handleUpdateAlertRule(deps.Alerts)))))
+	handle("DELETE /alerts/rules/{id}/", writeLimit(adminOnly(handleDeleteAlertRule(deps.Alerts))))
+
+	handle("GET /notifications/preferences", readLimit(authenticated(handleGetPreferences(deps.Preferences))))
+	handle("PUT /notifications/preferences", writeLimit(authenticated(bodyLimit(handlePutPreferences(deps.Preferences)))))
+	handle("DELETE /notifications/preferences", writeLimit(authenticated(handleDeletePreferences(deps.Preferences))))
+
+	handle("GET /webhooks/", readLimit(adminOnly(handleListWebhooks(deps.WebhookStore))))
+	handle("POST /webhooks/", writeLimit(adminOnly(bodyLimit(handleCreateWebhook(deps.WebhookStore)))))
+	handle("GET /webhooks/{id}/", readLimit(adminOnly(handleGetWebhook(deps.WebhookStore))))
+	handle("PUT /webhooks/{id}/", writeLimit(adminOnly(bodyLimit(handleUpdateWebhook(deps.WebhookStore)))))
+	handle("DELETE /webhooks/{id}/", writeLimit(adminOnly(handleDeleteWebhook(deps.WebhookStore))))
+	handle("GET /webhooks/{id}/deliveries", readLimit(adminOnly(handleListWebhookDeliveries(deps.WebhookStore))))
+	handle("POST /webhooks/{id}/deliveries/{deliveryId}/redeliver", writeLimit(adminOnly(handleRedeliverWebhook(deps.Webhooks))))
+
+	handle("GET /admin/log-level", adminOnly(handleGetLogLevel(deps.LogLevel)))
+	handle("PUT /admin/log-level", writeLimit(adminOnly(bodyLimit(handleSetLogLevel(deps.LogLevel)))))
+
+	for _, path := range paths {
+		pattern := http.MethodOptions + " " + path
+		mux.Handle(pattern, withRoute(pattern, handlePreflight(config.CORS, routes[path])))
+	}
 
 	root := http.NewServeMux()
 	root.Handle("/api/v1/", http.StripPrefix("/api/v1", mux))
@@ -30,85 +164,286 @@ func NewApiHandler(logger *slog.Logger, config config.Server, plantStore store.S
 	stack := newMiddlewareStack(
 		newTracing(logger),
 		newLogger(logger),
+		newCORS(config.CORS),
+		newClientIdentity(),
+		newTokenIdentity(config.AdminToken),
+		newCompression(config.CompressionMinBytes),
 	)
 	var handler http.Handler = root
 
 	return stack(handler)
 }
 
+// RunOption changes what Run wires up that config cant express
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	elector scheduler.Elector
+}
+
+// WithElector hands out the scheduler job leases, without it an in-memory elector only coordinates this process,
+// so replicas have to share an elector (e.g. backed by their database) or they all run every job
+func WithElector(elector scheduler.Elector) RunOption {
+	return func(o *runOptions) {
+		o.elector = elector
+	}
+}
+
+// Run serves the API until ctx is cancelled
 func Run(
 	ctx context.Context,
 	args []string,
 	getenv func(string) string,
 	stdin io.Reader,
 	stdout, stderr io.Writer,
+	options ...RunOption,
 ) error {
-	// NOTE: you would configure the slog.Logger appropriately here, this is just for running locally,
-	// in a human-readable(-ish) form
-	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	run := runOptions{}
+	for _, option := range options {
+		option(&run)
+	}
+	elector := run.elector
+	if elector == nil {
+		elector = scheduler.NewMemoryElector(nil)
+	}
+
+	cfg, opts, err := config.Load(args, getenv, stderr)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if opts.PrintConfig {
+		return cfg.Print(stdout)
+	}
+	// NOTE: background workers stop with ctx, so they dont outlive a Run that fails before serving
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logLevel := new(slog.LevelVar)
+	// NOTE: the level was already validated when loading config
+	_ = logLevel.UnmarshalText([]byte(cfg.Log.Level))
+	logOutput, closeLogOutput, err := log.OpenOutput(cfg.Log.Output, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	// NOTE: deferred instead of a shutdown hook so shutdown hooks can still log
+	defer func() { _ = closeLogOutput() }()
+	logger := slog.New(log.NewHandler(logOutput, cfg.Log, logLevel))
 	slog.SetDefault(logger)
+	if cfg.AdminToken == "" {
+		logger.WarnContext(ctx, "no admin token configured, admin routes are disabled", slog.String("env", config.ENV_API_ADMIN_TOKEN))
+	}
 
-	cfg := config.FromEnv(getenv)
+	// NOTE: closing the broker ends all event streams, otherwise they would hold up draining the http server
+	broker := events.NewBroker(cfg.Events.ReplaySize, cfg.Events.ClientBuffer)
+	stopBroker := context.AfterFunc(ctx, broker.Close)
+	defer stopBroker()
 
 	// NOTE: realistically this wouldnt be an in-memory array,
 	// but a DB implementation of store.Store interface
-	s := store.NewMemoryStore([]plants.Plant{})
+	memoryStore := store.NewMemoryStore([]plants.Plant{})
+	var s store.Store = events.NewPublishingStore(memoryStore, broker)
 
-	handler := NewApiHandler(logger, cfg, s)
-	httpServer := &http.Server{
-		Addr:    net.JoinHostPort(cfg.Host, cfg.Port),
-		Handler: handler,
+	checks := health.NewRegistry()
+	checks.Register("store", s, time.Second)
+
+	webhookStore := webhooks.NewMemoryStore(cfg.Webhooks.HistorySize)
+	dispatcher := webhooks.NewDispatcher(logger, webhookStore, broker, webhooks.Options{
+		Workers:        cfg.Webhooks.Workers,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+		Timeout:        cfg.Webhooks.Timeout,
+	})
+	// NOTE: stops with the broker, retries still waiting stay pending and are lost with the in-memory store
+	if err := dispatcher.Start(ctx); err != nil {
+		return fmt.Errorf("start webhooks: %w", err)
+	}
+
+	preferences := notify.NewMemoryPreferenceStore()
+	notifiers, err := newNotifiers(cfg.SMTP)
+	if err != nil {
+		return fmt.Errorf("configure notifications: %w", err)
+	}
+	templates, err := notify.NewTemplates()
+	if err != nil {
+		return fmt.Errorf("configure notifications: %w", err)
+	}
+
+	telemetryStore := telemetry.NewMemoryStore(cfg.Telemetry.Retention, nil)
+	ingester := telemetry.NewIngester(logger, telemetryStore, telemetry.Options{
+		QueueSize: cfg.Telemetry.QueueSize,
+		Workers:   cfg.Telemetry.Workers,
+	})
+	// NOTE: stops accepting batches with ctx, the shutdown hook waits for the queue to drain
+	if err := ingester.Start(ctx); err != nil {
+		return fmt.Errorf("start telemetry ingestion: %w", err)
 	}
 
-	go func() {
-		logger.Info(fmt.Sprintf("listening to requests on %s", string(httpServer.Addr)))
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error(fmt.Sprintf("error listening: %s", err))
-			return
+	blobs, err := photos.NewFileStore(cfg.Photos.Dir)
+	if err != nil {
+		return fmt.Errorf("configure photos: %w", err)
+	}
+	// NOTE: photo metadata is in memory while the images are on disk, so photos dont survive a restart either
+	library := photos.NewLibrary(photos.NewMemoryStore(), blobs, cfg.Photos.ThumbnailSize)
+
+	speciesStore := store.NewMemorySpeciesStore()
+	if cfg.Species.LoadBundled {
+		bundled, err := plants.BundledSpecies()
+		if err != nil {
+			return fmt.Errorf("load bundled species: %w", err)
+		}
+		created, updated, err := store.LoadSpecies(ctx, speciesStore, bundled)
+		if err != nil {
+			return fmt.Errorf("load bundled species: %w", err)
 		}
-	}()
+		logger.InfoContext(ctx, "loaded bundled species", slog.Int("created", created), slog.Int("updated", updated))
+	}
 
-	<-ctx.Done()
-	logger.Info("graceful shutdown")
-	if err := httpServer.Shutdown(ctx); err != nil && err != http.ErrServerClosed {
-		logger.Error(fmt.Sprintf("error shutting down: %s", err))
+	measurementStore := store.NewMemoryMeasurementStore()
+	alertStore := alerts.NewMemoryStore(cfg.Alerts.HistorySize)
+	alertSink := alerts.MultiSink{alerts.LogSink(logger)}
+	var digest func(ctx context.Context) error
+	// NOTE: without a notification channel there is nobody to notify, the digest is skipped and alerts are only logged
+	if len(notifiers) > 0 {
+		// NOTE: the time zone was already validated when loading config
+		loc, _ := time.LoadLocation(cfg.Scheduler.TimeZone)
+		digest = overdueDigest{
+			plantStore:   s,
+			careStore:    memoryStore,
+			speciesStore: speciesStore,
+			preferences:  preferences,
+			templates:    templates,
+			notifiers:    notifiers,
+			loc:          loc,
+			now:          time.Now,
+		}.run
+		alertSink = append(alertSink, alertNotifier{preferences: preferences, templates: templates, notifiers: notifiers})
 	}
+	evaluator := alerts.NewEvaluator(alertStore, alerts.Sources{
+		Plants:       s,
+		Care:         memoryStore,
+		Measurements: measurementStore,
+		Telemetry:    telemetryStore,
+	}, alertSink)
 
-	return nil
-}
+	handler := NewApiHandler(logger, cfg, Dependencies{
+		PlantStore:       s,
+		MeasurementStore: measurementStore,
+		// NOTE: care events arent plant changes, so they skip the publishing store
+		CareStore: memoryStore,
+		Checks:    checks,
+		LogLevel:  logLevel,
+		// NOTE: replace with a shared store when running multiple instances
+		RateLimiter:  ratelimit.NewMemoryStore(nil),
+		Events:       broker,
+		WebhookStore: webhookStore,
+		Webhooks:     dispatcher,
+		Preferences:  preferences,
+		Telemetry:    telemetryStore,
+		Sensors:      telemetry.NewMemorySensorStore(),
+		Ingester:     ingester,
+		Alerts:       alertStore,
+		Photos:       library,
+		Species:      speciesStore,
+		Locations:    store.NewMemoryLocationStore(),
+	})
+	srv := newServer(logger, handler, checks, cfg.ShutdownTimeout, cfg.ShutdownDelay)
+	if closer, ok := s.(store.Closer); ok {
+		srv.onShutdown("store", closer.Close)
+	}
+	srv.onShutdown("webhooks", dispatcher.Wait)
+	srv.onShutdown("telemetry", ingester.Wait)
 
-func encode[T any](w http.ResponseWriter, _ *http.Request, status int, v T) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		return fmt.Errorf("encode json: %w", err)
+	jobs, err := newScheduler(logger, cfg.Scheduler, elector, remindOverdueWatering(s, memoryStore, speciesStore, broker, time.Now), digest, evaluator.Evaluate)
+	if err != nil {
+		return fmt.Errorf("create scheduler: %w", err)
 	}
+	// NOTE: jobs stop with ctx, the hook waits for runs in progress and hands their leases over
+	if err := jobs.Start(log.WithLogger(ctx, logger)); err != nil {
+		return fmt.Errorf("start scheduler: %w", err)
+	}
+	srv.onShutdown("scheduler", jobs.Wait)
 
-	return nil
-}
+	var tlsCfg *tls.Config
+	if cfg.TLS.Enabled() {
+		var err error
+		tlsCfg, err = newTLSConfig(logger, cfg.TLS)
+		if err != nil {
+			return fmt.Errorf("configure tls: %w", err)
+		}
+	}
 
-func decode[T any](r *http.Request) (T, error) {
-	var v T
-	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		return v, fmt.Errorf("decode json: %w", err)
+	// NOTE: listening before serving means errors like "address already in use"
+	// are returned to the caller instead of only being logged from a goroutine
+	ln, err := net.Listen("tcp", cfg.Addr())
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
 	}
 
-	return v, nil
-}
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
 
-type Validator interface {
-	Valid() (problems map[string]string)
+	return srv.serve(ctx, ln)
 }
 
-func decodeValid[T Validator](r *http.Request) (T, map[string]string, error) {
-	v, err := decode[T](r)
-	if err != nil {
-		return v, nil, err
+// newNotifiers returns a notifier for every configured channel, it is empty when none are
+func newNotifiers(cfg config.SMTP) (map[string]notify.Notifier, error) {
+	notifiers := make(map[string]notify.Notifier)
+	if cfg.Host != "" {
+		sender, err := notify.NewSMTPSender(notify.SMTPOptions{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+			Attempts: cfg.Attempts,
+			Backoff:  cfg.Backoff,
+			Timeout:  cfg.Timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		notifiers[notify.CHANNEL_EMAIL] = sender
 	}
+	return notifiers, nil
+}
 
-	if problems := v.Valid(); len(problems) > 0 {
-		return v, problems, fmt.Errorf("invalid input with %d error(-s)", len(problems))
+// newScheduler adds the jobs that have a schedule, a nil run function leaves a job out
+func newScheduler(logger *slog.Logger, cfg config.Scheduler, elector scheduler.Elector, reminders, digest, alertEvaluation func(ctx context.Context) error) (*scheduler.Scheduler, error) {
+	instance := cfg.Instance
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("get hostname for scheduler instance: %w", err)
+		}
+		instance = hostname
 	}
+	// NOTE: the time zone was already validated when loading config
+	loc, _ := time.LoadLocation(cfg.TimeZone)
 
-	return v, nil, nil
+	jobs := scheduler.New(logger, scheduler.Options{
+		Elector:  elector,
+		Instance: instance,
+	})
+	for _, job := range []struct {
+		name, schedule string
+		run            func(ctx context.Context) error
+	}{
+		{"overdue-watering-reminders", cfg.OverdueReminders, reminders},
+		{"overdue-digest", cfg.OverdueDigest, digest},
+		{"alert-evaluation", cfg.AlertEvaluation, alertEvaluation},
+	} {
+		if job.schedule == "" || job.run == nil {
+			continue
+		}
+		schedule, err := scheduler.Parse(job.schedule, loc)
+		if err != nil {
+			return nil, err
+		}
+		if err := jobs.Add(scheduler.Job{Name: job.name, Schedule: schedule, Jitter: cfg.Jitter, Run: job.run}); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
 }
@@ -0,0 +1,173 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"plants/log"
+	"plants/plants"
+	"plants/store"
+	"time"
+)
+
+// parseTimeRange reads the optional "from" and "to" RFC 3339 query parameters
+func parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return bounds[0], bounds[1], fmt.Errorf("query parameter '%s' must be an RFC 3339 time like 2024-05-01T00:00:00Z", name)
+		}
+		bounds[i] = parsed
+	}
+
+	from, to := bounds[0], bounds[1]
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("query parameter 'from' must be before 'to'")
+	}
+	return from, to, nil
+}
+
+// parseTimeZone reads the optional "tz" IANA time zone query parameter, it defaults to UTC
+func parseTimeZone(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, errors.New("query parameter 'tz' must be an IANA time zone like Europe/Riga")
+	}
+	return loc, nil
+}
+
+// findPlant answers the request with 404 or 500 when the plant cant be found
+func findPlant(w http.ResponseWriter, r *http.Request, plantStore store.Store) (*plants.Plant, bool) {
+	ctx := r.Context()
+	plant, err := plantStore.Find(ctx, r.PathValue("id"))
+	if err != nil {
+		err = fmt.Errorf("find plant by id: %w", err)
+		log.LoggerFromCtx(ctx).ErrorContext(ctx, err.Error())
+		_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+		return nil, false
+	}
+	return plant, true
+}
+
+// handleCreateMeasurement records a reading, when it is the latest one the plant height is updated to match
+func handleCreateMeasurement(plantStore store.Store, measurementStore store.MeasurementStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		m, problems, err := decodeValid[plants.Measurement](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+		m.PlantID = plant.ID
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+		m.Time = m.Time.UTC()
+		if m.Source == "" {
+			m.Source = plants.SOURCE_MANUAL
+		}
+
+		created, err := measurementStore.CreateMeasurement(ctx, m)
+		if err != nil {
+			err = fmt.Errorf("create measurement: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		// NOTE: a late reading from the past is kept in the history but doesnt change the current height,
+		// the store also skips it when a later reading was recorded in the meantime
+		latest, err := measurementStore.LatestMeasurement(ctx, plant.ID)
+		if err == nil && latest.ID == created.ID {
+			_, _, err = plantStore.SetHeight(ctx, plant.ID, created.Height(), created.Time)
+		}
+		if err != nil {
+			// NOTE: the measurement is stored either way, the height catches up with the next reading
+			logger.ErrorContext(ctx, fmt.Sprintf("update plant height: %s", err), slog.String("measurementId", created.ID))
+		}
+
+		_ = encode(w, r, http.StatusOK, created)
+	})
+}
+
+func handleListMeasurements(plantStore store.Store, measurementStore store.MeasurementStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		from, to, err := parseTimeRange(r)
+		if err != nil {
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		measurements, err := measurementStore.ListMeasurements(ctx, plant.ID, from, to)
+		if err != nil {
+			err = fmt.Errorf("retrieve measurements: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, measurements)
+	})
+}
+
+// handleDailyMeasurements downsamples readings for charts, days are split in the "tz" time zone
+func handleDailyMeasurements(plantStore store.Store, measurementStore store.MeasurementStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		from, to, err := parseTimeRange(r)
+		var loc *time.Location
+		if err == nil {
+			loc, err = parseTimeZone(r)
+		}
+		if err != nil {
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		summaries, err := measurementStore.DailyMeasurements(ctx, plant.ID, from, to, loc)
+		if err != nil {
+			err = fmt.Errorf("summarize measurements: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, summaries)
+	})
+}
@@ -0,0 +1,214 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"plants/config"
+	"plants/plants"
+	"plants/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasurementQueries(t *testing.T) {
+	ctx := context.Background()
+	plantStore := store.NewMemoryStore(nil)
+	measurementStore := store.NewMemoryMeasurementStore()
+	plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern", Height: 3})
+	require.NoError(t, err)
+	replacements := []string{"{plant}", plant.ID}
+	for i, m := range []plants.Measurement{
+		{Time: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), Value: 100, Unit: plants.UNIT_MILLIMETERS, Source: "sensor-1"},
+		{Time: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC), Value: 12, Unit: plants.UNIT_CENTIMETERS, Source: plants.SOURCE_MANUAL},
+		{Time: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), Value: 14, Unit: plants.UNIT_CENTIMETERS, Source: plants.SOURCE_MANUAL},
+	} {
+		m.PlantID = plant.ID
+		created, err := measurementStore.CreateMeasurement(ctx, m)
+		require.NoError(t, err)
+		replacements = append(replacements, fmt.Sprintf("{m%d}", i), created.ID)
+	}
+	// NOTE: fills in the generated IDs, {plant} and {m0}, {m1}, ... for the seeded measurements
+	expand := strings.NewReplacer(replacements...).Replace
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.MeasurementStore = measurementStore
+	})
+
+	tests := map[string]struct {
+		path string
+
+		wantCode     int
+		wantResponse string
+	}{
+		"list all": {
+			path: "/plants/{plant}/measurements",
+
+			wantCode: http.StatusOK,
+			wantResponse: `[
+				{"id":"{m0}","plantId":"{plant}","time":"2024-05-01T08:00:00Z","value":100,"unit":"mm","source":"sensor-1"},
+				{"id":"{m1}","plantId":"{plant}","time":"2024-05-01T20:00:00Z","value":12,"unit":"cm","source":"manual"},
+				{"id":"{m2}","plantId":"{plant}","time":"2024-05-02T08:00:00Z","value":14,"unit":"cm","source":"manual"}
+			]`,
+		},
+		"list range": {
+			path: "/plants/{plant}/measurements?from=2024-05-01T12:00:00Z&to=2024-05-02T08:00:00Z",
+
+			wantCode:     http.StatusOK,
+			wantResponse: `[{"id":"{m1}","plantId":"{plant}","time":"2024-05-01T20:00:00Z","value":12,"unit":"cm","source":"manual"}]`,
+		},
+		"empty range": {
+			path: "/plants/{plant}/measurements?from=2024-06-01T00:00:00Z",
+
+			wantCode:     http.StatusOK,
+			wantResponse: `[]`,
+		},
+		"invalid time": {
+			path: "/plants/{plant}/measurements?from=yesterday",
+
+			wantCode:     http.StatusBadRequest,
+			wantResponse: `{"message":"query parameter 'from' must be an RFC 3339 time like 2024-05-01T00:00:00Z"}`,
+		},
+		"backwards range": {
+			path: "/plants/{plant}/measurements?from=2024-05-02T00:00:00Z&to=2024-05-01T00:00:00Z",
+
+			wantCode:     http.StatusBadRequest,
+			wantResponse: `{"message":"query parameter 'from' must be before 'to'"}`,
+		},
+		"missing plant": {
+			path: "/plants/missing/measurements",
+
+			wantCode:     http.StatusNotFound,
+			wantResponse: `{"message":"find plant by id: plant with ID 'missing' does not exist"}`,
+		},
+		"daily": {
+			path: "/plants/{plant}/measurements/daily",
+
+			wantCode:     http.StatusOK,
+			wantResponse: `[{"date":"2024-05-01","min":10,"max":12,"avg":11,"count":2},{"date":"2024-05-02","min":14,"max":14,"avg":14,"count":1}]`,
+		},
+		"daily in time zone": {
+			path: "/plants/{plant}/measurements/daily?tz=Asia/Tokyo",
+
+			wantCode:     http.StatusOK,
+			wantResponse: `[{"date":"2024-05-01","min":10,"max":10,"avg":10,"count":1},{"date":"2024-05-02","min":12,"max":14,"avg":13,"count":2}]`,
+		},
+		"daily in unknown time zone": {
+			path: "/plants/{plant}/measurements/daily?tz=Mars/Olympus",
+
+			wantCode:     http.StatusBadRequest,
+			wantResponse: `{"message":"query parameter 'tz' must be an IANA time zone like Europe/Riga"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotCode, gotBody := doAdmin(t, handler, http.MethodGet, expand(tc.path), "")
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			assert.JSONEq(t, expand(tc.wantResponse), gotBody)
+		})
+	}
+}
+
+func TestCreateMeasurement(t *testing.T) {
+	tests := map[string]struct {
+		plantID string
+		body    string
+
+		wantCode     int
+		wantResponse string
+		wantHeight   int
+	}{
+		"invalid reading": {
+			body: `{"value":-1,"unit":"ft"}`,
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"validation error: invalid input with 2 error(-s)","errors":{"unit":"unit must be one of: cm, mm, in","value":"value must be a non-negative number"}}`,
+			wantHeight:   3,
+		},
+		"missing plant": {
+			plantID: "missing",
+			body:    `{"value":1,"unit":"cm"}`,
+
+			wantCode:     http.StatusNotFound,
+			wantResponse: `{"message":"find plant by id: plant with ID 'missing' does not exist"}`,
+			wantHeight:   3,
+		},
+		"late reading keeps the height": {
+			body: `{"time":"2024-04-01T08:00:00Z","value":2,"unit":"cm"}`,
+
+			wantCode:     http.StatusOK,
+			wantResponse: `{"id":"{id}","plantId":"{plant}","time":"2024-04-01T08:00:00Z","value":2,"unit":"cm","source":"manual"}`,
+			wantHeight:   3,
+		},
+		"latest reading sets the height": {
+			body: `{"time":"2024-05-03T10:00:00+02:00","value":6.3,"unit":"in","source":"sensor-1"}`,
+
+			wantCode:     http.StatusOK,
+			wantResponse: `{"id":"{id}","plantId":"{plant}","time":"2024-05-03T08:00:00Z","value":6.3,"unit":"in","source":"sensor-1"}`,
+			wantHeight:   16,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			plantStore := store.NewMemoryStore(nil)
+			measurementStore := store.NewMemoryMeasurementStore()
+			plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern", Height: 3})
+			require.NoError(t, err)
+			_, err = measurementStore.CreateMeasurement(ctx, plants.Measurement{PlantID: plant.ID, Time: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), Value: 14, Unit: plants.UNIT_CENTIMETERS, Source: plants.SOURCE_MANUAL})
+			require.NoError(t, err)
+			handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+				deps.PlantStore = plantStore
+				deps.MeasurementStore = measurementStore
+			})
+			plantID := tc.plantID
+			if plantID == "" {
+				plantID = plant.ID
+			}
+
+			gotCode, gotBody := doAdmin(t, handler, http.MethodPost, "/plants/"+plantID+"/measurements", tc.body)
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			var created plants.Measurement
+			if gotCode == http.StatusOK {
+				require.NoError(t, json.Unmarshal([]byte(gotBody), &created))
+			}
+			assert.JSONEq(t, strings.NewReplacer("{plant}", plant.ID, "{id}", created.ID).Replace(tc.wantResponse), gotBody)
+			got, err := plantStore.Find(ctx, plant.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantHeight, got.Height)
+		})
+	}
+}
+
+func TestMeasuredPlant(t *testing.T) {
+	ctx := context.Background()
+	plantStore := store.NewMemoryStore(nil)
+	measurementStore := store.NewMemoryMeasurementStore()
+	plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern", Height: 3})
+	require.NoError(t, err)
+	_, err = measurementStore.CreateMeasurement(ctx, plants.Measurement{PlantID: plant.ID, Time: time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), Value: 14, Unit: plants.UNIT_CENTIMETERS, Source: plants.SOURCE_MANUAL})
+	require.NoError(t, err)
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.MeasurementStore = measurementStore
+	})
+
+	// NOTE: the latest reading is 14cm, the height in the body doesnt override it
+	code, body := doAdmin(t, handler, http.MethodPut, "/plants/"+plant.ID+"/", `{"name":"fern","height":99}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"`+plant.ID+`","name":"fern","height":14}`, body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/plants/"+plant.ID+"/", "")
+	require.Equal(t, http.StatusNoContent, code)
+	_, err = measurementStore.LatestMeasurement(ctx, plant.ID)
+	assert.ErrorAs(t, err, &store.ErrorResourceDoesNotExist{}, "measurements are deleted with their plant")
+}
@@ -1,11 +1,14 @@
 package httpd
 
 import (
-	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"plants/auth"
 	"plants/log"
+	"strings"
 	"time"
 
 	"github.com/rs/xid"
@@ -34,6 +37,11 @@ func (w *wrappedWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streaming responses
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func newLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 	slog.SetDefault(logger)
 	return func(next http.Handler) http.Handler {
@@ -46,9 +54,15 @@ func newLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 
 			next.ServeHTTP(wrapped, r)
 
-			requestLogger := log.LoggerFromCtx(r.Context())
-			requestLogger.Info(
-				fmt.Sprintf("%s %s", r.Method, r.URL.String()),
+			ctx := r.Context()
+			requestLogger := log.LoggerFromCtx(ctx)
+			// NOTE: the full url is an attribute instead of part of the message,
+			// so the redacting handler can mask sensitive query parameters.
+			// The context carries the trace and span IDs, the ContextHandler adds them from there
+			requestLogger.InfoContext(
+				ctx,
+				fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+				slog.Any("url", r.URL),
 				slog.Int("statusCode", wrapped.statusCode),
 				slog.String("duration", time.Since(start).String()),
 			)
@@ -56,34 +70,104 @@ func newLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
-func newAdminOnly(_ string) func(next http.Handler) http.Handler {
-	// NOTE: this doesnt actually do anything, its just an example of how a middleware would get its dependencies
+// newTokenIdentity puts the admin identity into the request context when the request carries the admin
+// bearer token. It runs for every request, before rate limiting, so limits are keyed on the verified identity;
+// a missing or wrong token leaves the context untouched and newAdminOnly refuses the request later
+func newTokenIdentity(token string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// TODO: some auth handler thing could go here (like a JWT check)
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token != "" && ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+				identity := auth.Identity{Subject: "admin", Method: auth.METHOD_TOKEN}
+				ctx := auth.WithIdentity(r.Context(), identity)
+				r = r.WithContext(log.WithUserID(ctx, identity.Subject))
+			}
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-// NOTE: use typed strings for context keys so they cannot collide on accident,
-// however in this case we are using constants as keys so that wouldnt be possible anyway
-type traceCtxKey string
+// refuseUnauthenticated answers 401 asking for the admin bearer token
+func refuseUnauthenticated(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := errors.New("missing or invalid admin token")
+	log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error())
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	_ = encode(w, r, http.StatusUnauthorized, newHttpError(err))
+}
+
+// newAdminOnly requires the identity newTokenIdentity sets for the admin bearer token. Without a configured
+// token nobody is the admin, so admin routes answer 403, Run warns about it on startup.
+func newAdminOnly(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				ctx := r.Context()
+				err := errors.New("admin routes are disabled, no admin token is configured")
+				log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error())
+				_ = encode(w, r, http.StatusForbidden, newHttpError(err))
+				return
+			}
+			if identity, ok := auth.IdentityFromCtx(r.Context()); !ok || identity.Method != auth.METHOD_TOKEN {
+				refuseUnauthenticated(w, r)
+				return
+			}
 
-const CONTEXT_TRACE_ID traceCtxKey = "ctx.trace"
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-func newTracing(logger *slog.Logger) func(next http.Handler) http.Handler {
+// newAuthenticated requires any identity, one established by a client certificate
+// is enough, otherwise the admin bearer token is needed like for newAdminOnly
+func newAuthenticated() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// generate request ID
-			requestID := xid.New().String()
-			ctx := context.WithValue(r.Context(), CONTEXT_TRACE_ID, requestID)
+			if _, ok := auth.IdentityFromCtx(r.Context()); !ok {
+				refuseUnauthenticated(w, r)
+				return
+			}
 
-			// add request ID to all child logs
-			scopedLogger := logger.With(slog.String("traceId", requestID))
-			ctx = context.WithValue(ctx, log.CONTEXT_LOGGER, scopedLogger)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTracing(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// generate request trace and span IDs, the log.ContextHandler adds them to all child logs
+			ctx := log.WithTrace(r.Context(), xid.New().String(), xid.New().String())
+			ctx = log.WithLogger(ctx, logger)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// withRoute tags the context logger with the matched route pattern
+func withRoute(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(log.WithRoute(r.Context(), pattern)))
+	})
+}
+
+// newBodyLimit caps how much of the request body handlers can read, decode turns
+// the resulting error into a 413 response. Compressed bodies are decompressed here,
+// with the same cap applied to both the compressed and the decompressed size.
+func newBodyLimit(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			if err := decompressBody(w, r, maxBytes); err != nil {
+				ctx := r.Context()
+				log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error())
+				_ = encode(w, r, decodeStatus(err), newHttpError(err))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
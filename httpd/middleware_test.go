@@ -0,0 +1,163 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"plants/auth"
+	"plants/config"
+	"plants/log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminOnly(t *testing.T) {
+	tests := map[string]struct {
+		token         string
+		authorization string
+
+		wantResponse string
+		wantCode     int
+	}{
+		"passes with valid token": {
+			token:         "supersecret",
+			authorization: "Bearer supersecret",
+
+			wantResponse: `{"subject":"admin"}`,
+			wantCode:     http.StatusOK,
+		},
+		"rejects missing token": {
+			token: "supersecret",
+
+			wantResponse: `{"message":"missing or invalid admin token"}`,
+			wantCode:     http.StatusUnauthorized,
+		},
+		"rejects wrong token": {
+			token:         "supersecret",
+			authorization: "Bearer guess",
+
+			wantResponse: `{"message":"missing or invalid admin token"}`,
+			wantCode:     http.StatusUnauthorized,
+		},
+		"rejects non bearer scheme": {
+			token:         "supersecret",
+			authorization: "Basic supersecret",
+
+			wantResponse: `{"message":"missing or invalid admin token"}`,
+			wantCode:     http.StatusUnauthorized,
+		},
+		"rejects everyone without configured token": {
+			authorization: "Bearer ",
+
+			wantResponse: `{"message":"admin routes are disabled, no admin token is configured"}`,
+			wantCode:     http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler := newMiddlewareStack(newTokenIdentity(tc.token), newAdminOnly(tc.token))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, _ := auth.IdentityFromCtx(r.Context())
+				_ = encode(w, r, http.StatusOK, map[string]string{"subject": identity.Subject})
+			}))
+			handler.ServeHTTP(w, r)
+
+			res := w.Result()
+			defer func() { _ = res.Body.Close() }()
+
+			if res.StatusCode != tc.wantCode {
+				t.Errorf("status code mismatch, expected: %v, got: %v", tc.wantCode, res.StatusCode)
+			}
+
+			gotBody, gotErr := io.ReadAll(res.Body)
+			if gotErr != nil {
+				t.Errorf("failed to read response body: %v", gotErr)
+			}
+
+			assert.JSONEq(t, tc.wantResponse, string(gotBody))
+		})
+	}
+}
+
+func TestNoAdminToken(t *testing.T) {
+	handler := newTestAPI(t, func(cfg *config.Server, deps *Dependencies) {
+		cfg.AdminToken = ""
+	})
+
+	tests := map[string]struct {
+		method string
+		path   string
+		body   string
+
+		wantResponse string
+		wantCode     int
+	}{
+		"admin route": {
+			method: http.MethodPost,
+			path:   "/api/v1/webhooks/",
+			body:   `{"url":"http://10.0.0.1/hook"}`,
+
+			wantResponse: `{"message":"admin routes are disabled, no admin token is configured"}`,
+			wantCode:     http.StatusForbidden,
+		},
+		"authenticated route": {
+			method: http.MethodGet,
+			path:   "/api/v1/notifications/preferences",
+
+			wantResponse: `{"message":"missing or invalid admin token"}`,
+			wantCode:     http.StatusUnauthorized,
+		},
+		"public route": {
+			method: http.MethodGet,
+			path:   "/api/v1/plants/",
+
+			wantResponse: `[]`,
+			wantCode:     http.StatusOK,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Authorization", "Bearer ")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.JSONEq(t, tc.wantResponse, w.Body.String())
+		})
+	}
+}
+
+func TestAccessLogTrace(t *testing.T) {
+	var buf bytes.Buffer
+	// NOTE: newLogger replaces the global logger, put the previous one back
+	defer slog.SetDefault(slog.Default())
+	logger := slog.New(log.NewHandler(&buf, config.Log{Format: config.LOG_FORMAT_JSON}, nil))
+	var traceID string
+	handler := newMiddlewareStack(newTracing(logger), newLogger(logger))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID, _, _ = log.TraceFromCtx(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "GET /test", line["msg"])
+	assert.NotEmpty(t, traceID)
+	assert.Equal(t, traceID, line[log.TRACE_ID_KEY])
+	assert.NotEmpty(t, line[log.SPAN_ID_KEY])
+}
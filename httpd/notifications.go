@@ -0,0 +1,101 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"plants/auth"
+	"plants/log"
+	"plants/notify"
+	"slices"
+)
+
+// callerIdentity answers the request with 401 when nobody is authenticated, routes are expected
+// to be wrapped in authenticated so this only guards against wiring mistakes
+func callerIdentity(w http.ResponseWriter, r *http.Request) (auth.Identity, bool) {
+	ctx := r.Context()
+	identity, ok := auth.IdentityFromCtx(ctx)
+	if !ok {
+		err := errors.New("notification preferences require an authenticated client")
+		log.LoggerFromCtx(ctx).WarnContext(ctx, err.Error())
+		_ = encode(w, r, http.StatusUnauthorized, newHttpError(err))
+	}
+	return identity, ok
+}
+
+// handleGetPreferences returns the notification preferences of the caller
+func handleGetPreferences(preferences notify.PreferenceStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		identity, ok := callerIdentity(w, r)
+		if !ok {
+			return
+		}
+
+		prefs, err := preferences.FindPreferences(ctx, identity.Subject)
+		if err != nil {
+			err = fmt.Errorf("find notification preferences: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, prefs)
+	})
+}
+
+// handlePutPreferences creates or replaces the notification preferences of the caller
+func handlePutPreferences(preferences notify.PreferenceStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		identity, ok := callerIdentity(w, r)
+		if !ok {
+			return
+		}
+
+		prefs, problems, err := decodeValid[notify.Preferences](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		prefs.UserID = identity.Subject
+		if prefs.Channels == nil {
+			prefs.Channels = slices.Clone(notify.DefaultChannels)
+		}
+		saved, err := preferences.PutPreferences(ctx, prefs)
+		if err != nil {
+			err = fmt.Errorf("save notification preferences: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, saved)
+	})
+}
+
+// handleDeletePreferences turns all notifications of the caller off
+func handleDeletePreferences(preferences notify.PreferenceStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		identity, ok := callerIdentity(w, r)
+		if !ok {
+			return
+		}
+
+		if err := preferences.DeletePreferences(ctx, identity.Subject); err != nil {
+			err = fmt.Errorf("delete notification preferences: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
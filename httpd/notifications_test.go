@@ -0,0 +1,46 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	handler := newTestAPI(t, nil)
+
+	r := httptest.NewRequest(http.MethodPut, "/api/v1/notifications/preferences", strings.NewReader(`{"email":"jane@example.com"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	code, body := doAdmin(t, handler, http.MethodGet, "/notifications/preferences", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.JSONEq(t, `{"message":"find notification preferences: notification preferences of user 'admin' do not exist"}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/notifications/preferences", `{"channels":["pigeon"],"digest":true,"tags":[""]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 2 error(-s)","errors":{"channels":"channels must be any of: email","tags":"tags cannot be empty"}}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/notifications/preferences", `{"digest":true}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"email":"email must be a plain address like jane@example.com"}}`, body)
+
+	// NOTE: the user is always the caller, whatever the body says
+	code, body = doAdmin(t, handler, http.MethodPut, "/notifications/preferences", `{"userId":"someone","email":"admin@example.com","digest":true,"tags":["office"]}`)
+	assert.Equal(t, http.StatusOK, code)
+	want := `{"userId":"admin","channels":["email"],"email":"admin@example.com","digest":true,"alerts":false,"tags":["office"]}`
+	assert.JSONEq(t, want, body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/notifications/preferences", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, want, body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/notifications/preferences", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = doAdmin(t, handler, http.MethodGet, "/notifications/preferences", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
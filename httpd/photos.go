@@ -0,0 +1,197 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"plants/log"
+	"plants/photos"
+	"plants/store"
+	"unicode/utf8"
+)
+
+// maxCaptionLength keeps photo captions to a sentence or two
+const maxCaptionLength = 500
+
+// photoCacheControl lets clients and proxies keep photos for a day, a photo never changes once uploaded
+const photoCacheControl = "public, max-age=86400"
+
+type photoUpload struct {
+	data    []byte
+	caption string
+}
+
+// readPhotoUpload reads a multipart/form-data body with the image in a "photo" field and an optional "caption"
+func readPhotoUpload(r *http.Request) (photoUpload, map[string]string, error) {
+	var upload photoUpload
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return upload, nil, &decodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("unsupported content type '%s', expected multipart/form-data", r.Header.Get("Content-Type")),
+		}
+	}
+
+	problems := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return upload, nil, uploadError(err)
+		}
+		switch part.FormName() {
+		case "photo":
+			upload.data, err = io.ReadAll(part)
+		case "caption":
+			var caption []byte
+			caption, err = io.ReadAll(io.LimitReader(part, 4*maxCaptionLength+1))
+			upload.caption = string(caption)
+		default:
+			// NOTE: unknown fields are rejected like unknown JSON fields, so typos dont silently drop a caption
+			problems[part.FormName()] = "unknown field"
+		}
+		_ = part.Close()
+		if err != nil {
+			return upload, nil, uploadError(err)
+		}
+	}
+
+	if len(upload.data) == 0 {
+		problems["photo"] = "photo is required"
+	}
+	if !utf8.ValidString(upload.caption) || utf8.RuneCountInString(upload.caption) > maxCaptionLength {
+		problems["caption"] = fmt.Sprintf("caption must be valid text of at most %d characters", maxCaptionLength)
+	}
+	if len(problems) > 0 {
+		return upload, problems, fmt.Errorf("invalid input with %d error(-s)", len(problems))
+	}
+	return upload, nil, nil
+}
+
+func uploadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &decodeError{
+			Status: http.StatusRequestEntityTooLarge,
+			Err:    fmt.Errorf("request body too large, limit is %d bytes", maxBytesErr.Limit),
+		}
+	}
+	return &decodeError{Status: http.StatusBadRequest, Err: fmt.Errorf("read multipart body: %w", err)}
+}
+
+func handleListPhotos(plantStore store.Store, library *photos.Library) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		list, err := library.List(ctx, plant.ID)
+		if err != nil {
+			err = fmt.Errorf("retrieve photos: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
+
+// handleUploadPhoto stores an image with a thumbnail, the format is sniffed from the content and not taken from the request
+func handleUploadPhoto(plantStore store.Store, library *photos.Library) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		upload, problems, err := readPhotoUpload(r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		photo, err := library.Add(ctx, plant.ID, upload.caption, upload.data)
+		if err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, photos.ErrUnsupportedType):
+				code = http.StatusUnsupportedMediaType
+			case errors.Is(err, photos.ErrInvalidImage):
+				code = http.StatusUnprocessableEntity
+			}
+			err = fmt.Errorf("add photo: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, code, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, photo)
+	})
+}
+
+// handleGetPhoto serves the original image or its thumbnail, with range requests and conditional requests
+func handleGetPhoto(plantStore store.Store, library *photos.Library, thumbnail bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+		photo, err := library.Find(ctx, plant.ID, r.PathValue("photoId"))
+		if err != nil {
+			err = fmt.Errorf("find photo by id: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		blob, err := library.Open(ctx, *photo, thumbnail)
+		if err != nil {
+			err = fmt.Errorf("open photo: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+		defer func() { _ = blob.Close() }()
+
+		etag := `"` + photo.ID + `"`
+		if thumbnail {
+			etag = `"` + photo.ID + `-thumbnail"`
+		}
+		w.Header().Set("Content-Type", photo.ContentType)
+		w.Header().Set("Cache-Control", photoCacheControl)
+		w.Header().Set("ETag", etag)
+		// NOTE: ServeContent answers Range, If-None-Match and If-Modified-Since on its own
+		http.ServeContent(w, r, "", photo.UploadedAt, blob)
+	})
+}
+
+func handleDeletePhoto(library *photos.Library) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		logger := log.LoggerFromCtx(ctx)
+		if err := library.Delete(ctx, r.PathValue("id"), r.PathValue("photoId")); err != nil {
+			err = fmt.Errorf("delete photo: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,303 @@
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"plants/config"
+	"plants/photos"
+	"plants/plants"
+	"plants/store"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadPhoto posts a multipart form with the given fields to the photos of a plant and returns the response
+func uploadPhoto(t *testing.T, handler http.Handler, plantID string, fields map[string][]byte) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		part, err := form.CreateFormFile(name, name+".bin")
+		require.NoError(t, err)
+		_, err = part.Write(value)
+		require.NoError(t, err)
+	}
+	require.NoError(t, form.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/plants/"+plantID+"/photos", &body)
+	r.Header.Set("Authorization", "Bearer supersecret")
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func TestUploadPhoto(t *testing.T) {
+	tests := map[string]struct {
+		fields         map[string][]byte
+		maxUploadBytes int
+
+		wantCode     int
+		wantResponse string
+	}{
+		"photo with caption": {
+			fields: map[string][]byte{"photo": testPNG(t, 64, 48), "caption": []byte("new leaf")},
+
+			wantCode: http.StatusOK,
+		},
+		"missing photo and unknown field": {
+			fields: map[string][]byte{"caption": []byte("new leaf"), "picture": testPNG(t, 4, 4)},
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"validation error: invalid input with 2 error(-s)","errors":{"photo":"photo is required","picture":"unknown field"}}`,
+		},
+		"content is sniffed, not trusted": {
+			fields: map[string][]byte{"photo": []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")},
+
+			wantCode:     http.StatusUnsupportedMediaType,
+			wantResponse: `{"message":"add photo: photo must be a JPEG or PNG image"}`,
+		},
+		"broken image": {
+			fields: map[string][]byte{"photo": testPNG(t, 64, 48)[:40]},
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"add photo: photo is not a valid image: unexpected EOF"}`,
+		},
+		"too large": {
+			fields:         map[string][]byte{"photo": bytes.Repeat([]byte{0}, 2048)},
+			maxUploadBytes: 1024,
+
+			wantCode:     http.StatusRequestEntityTooLarge,
+			wantResponse: `{"message":"validation error: request body too large, limit is 1024 bytes"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			plantStore := store.NewMemoryStore(nil)
+			plant, err := plantStore.Create(context.Background(), plants.Plant{Name: "fern"})
+			require.NoError(t, err)
+			handler := newTestAPI(t, func(cfg *config.Server, deps *Dependencies) {
+				if tc.maxUploadBytes != 0 {
+					cfg.Photos.MaxUploadBytes = tc.maxUploadBytes
+				}
+				deps.PlantStore = plantStore
+			})
+
+			res := uploadPhoto(t, handler, plant.ID, tc.fields)
+			defer func() { _ = res.Body.Close() }()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantCode, res.StatusCode)
+			if tc.wantResponse != "" {
+				assert.JSONEq(t, tc.wantResponse, string(body))
+				return
+			}
+			var got photos.Photo
+			require.NoError(t, json.Unmarshal(body, &got))
+			assert.Equal(t, plant.ID, got.PlantID)
+			assert.Equal(t, "image/png", got.ContentType)
+			assert.Equal(t, [2]int{64, 48}, [2]int{got.Width, got.Height})
+			assert.Equal(t, "new leaf", got.Caption)
+		})
+	}
+
+	t.Run("requires a multipart body", func(t *testing.T) {
+		plantStore := store.NewMemoryStore(nil)
+		plant, err := plantStore.Create(context.Background(), plants.Plant{Name: "fern"})
+		require.NoError(t, err)
+		handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) { deps.PlantStore = plantStore })
+
+		code, body := doAdmin(t, handler, http.MethodPost, "/plants/"+plant.ID+"/photos", `{"photo":"..."}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, code)
+		assert.JSONEq(t, `{"message":"validation error: unsupported content type '', expected multipart/form-data"}`, body)
+	})
+}
+
+func TestServePhoto(t *testing.T) {
+	plantStore := store.NewMemoryStore(nil)
+	plant, err := plantStore.Create(context.Background(), plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) { deps.PlantStore = plantStore })
+	original := testPNG(t, 64, 48)
+	res := uploadPhoto(t, handler, plant.ID, map[string][]byte{"photo": original})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var photo photos.Photo
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&photo))
+	_ = res.Body.Close()
+	list := "/api/v1/plants/" + plant.ID + "/photos"
+	path := list + "/" + photo.ID
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := map[string]struct {
+		path    string
+		headers map[string]string
+
+		wantCode    int
+		wantBody    []byte
+		wantHeaders map[string]string
+	}{
+		// NOTE: images arent compressed again
+		"original": {
+			path:    path,
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+
+			wantCode: http.StatusOK,
+			wantBody: original,
+			wantHeaders: map[string]string{
+				"Content-Type":     "image/png",
+				"Content-Encoding": "",
+				"Cache-Control":    "public, max-age=86400",
+				"Accept-Ranges":    "bytes",
+				"ETag":             `"` + photo.ID + `"`,
+			},
+		},
+		// NOTE: image routes set their own content type, so image accept headers arent answered with 406
+		"accepts any image": {
+			path:    path,
+			headers: map[string]string{"Accept": "image/*"},
+
+			wantCode: http.StatusOK,
+			wantBody: original,
+		},
+		"accepts png": {
+			path:    path,
+			headers: map[string]string{"Accept": "image/png"},
+
+			wantCode: http.StatusOK,
+			wantBody: original,
+		},
+		"accepts browser image header": {
+			path:    path,
+			headers: map[string]string{"Accept": "image/avif,image/webp,*/*;q=0.8"},
+
+			wantCode: http.StatusOK,
+			wantBody: original,
+		},
+		"thumbnail accepts any image": {
+			path:    path + "/thumbnail",
+			headers: map[string]string{"Accept": "image/*"},
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"Content-Type": "image/png"},
+		},
+		"range": {
+			path:    path,
+			headers: map[string]string{"Range": "bytes=0-7"},
+
+			wantCode:    http.StatusPartialContent,
+			wantBody:    original[:8],
+			wantHeaders: map[string]string{"Content-Range": "bytes 0-7/" + strconv.Itoa(len(original))},
+		},
+		"not modified": {
+			path:    path,
+			headers: map[string]string{"If-None-Match": `"` + photo.ID + `"`},
+
+			wantCode: http.StatusNotModified,
+		},
+		"list is still negotiated": {
+			path:    list,
+			headers: map[string]string{"Accept": "image/*"},
+
+			wantCode: http.StatusNotAcceptable,
+		},
+		"missing photo": {
+			path: list + "/missing",
+
+			wantCode: http.StatusNotFound,
+			wantBody: []byte(`{"message":"find photo by id: photo with ID 'missing' does not exist"}` + "\n"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := get(tc.path, tc.headers)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantBody != nil {
+				assert.Equal(t, tc.wantBody, w.Body.Bytes())
+			}
+			for k, v := range tc.wantHeaders {
+				assert.Equal(t, v, w.Header().Get(k), k)
+			}
+		})
+	}
+
+	t.Run("thumbnail", func(t *testing.T) {
+		w := get(path+"/thumbnail", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		cfg, err := png.DecodeConfig(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{32, 24}, [2]int{cfg.Width, cfg.Height})
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := get(list, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var got []photos.Photo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, []photos.Photo{photo}, got)
+	})
+}
+
+func TestDeletePhotos(t *testing.T) {
+	plantStore := store.NewMemoryStore(nil)
+	plant, err := plantStore.Create(context.Background(), plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	blobs, err := photos.NewFileStore(dir)
+	require.NoError(t, err)
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.Photos = photos.NewLibrary(photos.NewMemoryStore(), blobs, 32)
+	})
+	var uploaded []photos.Photo
+	for range 2 {
+		res := uploadPhoto(t, handler, plant.ID, map[string][]byte{"photo": testPNG(t, 8, 8)})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var photo photos.Photo
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&photo))
+		_ = res.Body.Close()
+		uploaded = append(uploaded, photo)
+	}
+
+	code, _ := doAdmin(t, handler, http.MethodDelete, "/plants/"+plant.ID+"/photos/"+uploaded[0].ID, "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body := doAdmin(t, handler, http.MethodDelete, "/plants/"+plant.ID+"/photos/"+uploaded[0].ID, "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.JSONEq(t, `{"message":"delete photo: photo with ID '`+uploaded[0].ID+`' does not exist"}`, body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/plants/"+plant.ID+"/", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the blobs of the plant are deleted with it")
+}
@@ -0,0 +1,69 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"plants/auth"
+	"plants/log"
+	"plants/ratelimit"
+	"strconv"
+	"time"
+)
+
+// newRateLimit limits each client per route group, group names keep buckets of
+// different groups apart so heavy reading doesnt use up the write budget
+func newRateLimit(limiter ratelimit.Store, group string, limit ratelimit.Limit) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Unlimited() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := log.LoggerFromCtx(ctx)
+
+			result, err := limiter.Take(ctx, group+":"+clientKey(r), limit)
+			if err != nil {
+				// NOTE: fail open, a broken limiter store shouldnt take the whole API down
+				logger.ErrorContext(ctx, fmt.Sprintf("rate limiter: %s", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+			if !result.Allowed {
+				err := errors.New("rate limit exceeded")
+				logger.WarnContext(ctx, err.Error())
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				_ = encode(w, r, http.StatusTooManyRequests, newHttpError(err))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey prefers the verified identity (a client certificate or the admin token, both established
+// before any limiter runs) and falls back to the client IP. Unverified headers are never used,
+// a client could send a new value with every request and get a fresh bucket each time.
+func clientKey(r *http.Request) string {
+	if identity, ok := auth.IdentityFromCtx(r.Context()); ok {
+		return "sub:" + identity.Subject
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,149 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"plants/auth"
+	"plants/config"
+	"plants/ratelimit"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("limiter store unreachable")
+}
+
+func TestRateLimit(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+
+	tests := map[string]struct {
+		limiter  ratelimit.Store
+		limit    ratelimit.Limit
+		requests int
+
+		wantCode    int
+		wantHeaders map[string]string
+	}{
+		"allows requests within burst": {
+			limiter:  ratelimit.NewMemoryStore(nil),
+			limit:    limit,
+			requests: 2,
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "Retry-After": ""},
+		},
+		"rejects requests over burst": {
+			limiter:  ratelimit.NewMemoryStore(nil),
+			limit:    limit,
+			requests: 3,
+
+			wantCode:    http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "Retry-After": "1"},
+		},
+		"unlimited group skips limiter": {
+			limiter:  failingLimiter{},
+			limit:    ratelimit.Limit{},
+			requests: 5,
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": ""},
+		},
+		"limiter errors fail open": {
+			limiter:  failingLimiter{},
+			limit:    limit,
+			requests: 5,
+
+			wantCode:    http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": ""},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := newRateLimit(tc.limiter, "write", tc.limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tc.requests; i++ {
+				w = httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/plants/", nil))
+			}
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			for header, want := range tc.wantHeaders {
+				assert.Equal(t, want, w.Header().Get(header), header)
+			}
+		})
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := map[string]struct {
+		request func() *http.Request
+
+		want string
+	}{
+		"client ip": {
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = "10.0.0.7:51234"
+				return r
+			},
+
+			want: "ip:10.0.0.7",
+		},
+		"unverified headers are ignored": {
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = "10.0.0.7:51234"
+				r.Header.Set("X-API-Key", "random-value")
+				r.Header.Set("Authorization", "Bearer guess")
+				return r
+			},
+
+			want: "ip:10.0.0.7",
+		},
+		"verified identity wins": {
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				return r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{Subject: "sensor-1"}))
+			},
+
+			want: "sub:sensor-1",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, clientKey(tc.request()))
+		})
+	}
+}
+
+func TestRateLimitKeyedOnIdentity(t *testing.T) {
+	handler := newTestAPI(t, func(cfg *config.Server, _ *Dependencies) {
+		cfg.RateLimit.WriteRate = 0.001
+		cfg.RateLimit.WriteBurst = 1
+	})
+	post := func(remoteAddr, token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/plants/", strings.NewReader(`{"name":"fern"}`))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post("10.0.0.1:1000", "supersecret"))
+	assert.Equal(t, http.StatusTooManyRequests, post("10.0.0.2:1000", "supersecret"), "the admin has one bucket wherever it calls from")
+	assert.Equal(t, http.StatusUnauthorized, post("10.0.0.3:1000", "guess"))
+	assert.Equal(t, http.StatusTooManyRequests, post("10.0.0.3:1000", "guess2"), "guessing tokens is limited per client ip")
+}
@@ -0,0 +1,107 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"plants/health"
+	"sync"
+	"time"
+)
+
+// ShutdownHook is called after the http server has drained, stores and background workers
+// use it to flush and release their resources
+type ShutdownHook func(ctx context.Context) error
+
+type server struct {
+	httpServer   *http.Server
+	logger       *slog.Logger
+	checks       *health.Registry
+	drainTimeout time.Duration
+	// shutdownDelay keeps the listener open after readyz starts failing
+	shutdownDelay time.Duration
+
+	mu    sync.Mutex
+	hooks []namedHook
+}
+
+type namedHook struct {
+	name string
+	hook ShutdownHook
+}
+
+func newServer(logger *slog.Logger, handler http.Handler, checks *health.Registry, drainTimeout, shutdownDelay time.Duration) *server {
+	return &server{
+		httpServer:    &http.Server{Handler: handler},
+		logger:        logger,
+		checks:        checks,
+		drainTimeout:  drainTimeout,
+		shutdownDelay: shutdownDelay,
+	}
+}
+
+// onShutdown registers a hook, hooks run in reverse registration order
+// so dependencies registered first are released last
+func (s *server) onShutdown(name string, hook ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, namedHook{name: name, hook: hook})
+}
+
+// serve blocks until either the listener fails or ctx is cancelled, in the latter case readyz fails
+// for shutdownDelay while requests are still served, then in-flight requests get up to drainTimeout to finish
+func (s *server) serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		s.logger.Info(fmt.Sprintf("listening to requests on %s", ln.Addr().String()))
+		serveErr <- s.httpServer.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("serve http: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	return s.shutdown()
+}
+
+func (s *server) shutdown() error {
+	s.logger.Info("graceful shutdown")
+	s.checks.SetShuttingDown()
+	// NOTE: load balancers only stop routing here after their next failed readyz probe,
+	// closing the listener right away would refuse the requests they still send
+	if s.shutdownDelay > 0 {
+		s.logger.Info("waiting for load balancers to notice", slog.Duration("delay", s.shutdownDelay))
+		time.Sleep(s.shutdownDelay)
+	}
+
+	// NOTE: the parent context is already cancelled at this point, so draining needs a fresh one
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancelDrain()
+
+	var errs []error
+	if err := s.httpServer.Shutdown(drainCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drain http server: %w", err))
+	}
+
+	// NOTE: a slow drain can use up its whole timeout, hooks still get a full one to flush
+	hookCtx, cancelHooks := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancelHooks()
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].hook(hookCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", hooks[i].name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -2,10 +2,16 @@ package httpd
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"plants/config"
+	"plants/health"
+	"plants/log"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -13,15 +19,140 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// TODO: this test is sort of pointless and flaky
-// the idea is to test if cancelling the parent context stops the Run function
-// and this graceful shutdown isnt treated as an error by the application (because it isnt)
 func TestGracefulShutdownHttpDaemon(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	err := Run(ctx, []string{}, func(string) string { return "" }, os.Stdin, io.Discard, io.Discard)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	checks := health.NewRegistry()
+	srv := newServer(log.NoopLogger(), handler, checks, time.Second, 0)
+	hookCalled := false
+	srv.onShutdown("test", func(context.Context) error {
+		hookCalled = true
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() { served <- srv.serve(ctx, ln) }()
+
+	// start an in-flight request, then cancel the context while its still being handled
+	responded := make(chan int, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responded <- 0
+			return
+		}
+		_ = res.Body.Close()
+		responded <- res.StatusCode
+	}()
+	<-started
+	cancel()
+
+	// the in-flight request must be drained instead of being cut off
+	assert.Eventually(t, checks.ShuttingDown, time.Second, time.Millisecond)
+	close(release)
+	assert.Equal(t, http.StatusOK, <-responded)
+	assert.NoError(t, <-served)
+	assert.True(t, hookCalled)
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	srv := newServer(log.NoopLogger(), handler, health.NewRegistry(), 10*time.Millisecond, 0)
+	hookErr := errors.New("flush failed")
+	var hookCtxErr error
+	srv.onShutdown("store", func(ctx context.Context) error {
+		hookCtxErr = ctx.Err()
+		return hookErr
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() { served <- srv.serve(ctx, ln) }()
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	err = <-served
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, hookErr)
+	assert.NoError(t, hookCtxErr, "hooks get their own timeout after the drain used up its one")
+}
+
+func TestShutdownDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	checks := health.NewRegistry()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := newServer(log.NoopLogger(), handler, checks, time.Second, 200*time.Millisecond)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() { served <- srv.serve(ctx, ln) }()
+	cancel()
+
+	// NOTE: readyz already fails, but requests routed before load balancers noticed are still served
+	assert.Eventually(t, checks.ShuttingDown, time.Second, time.Millisecond)
+	res, err := http.Get("http://" + ln.Addr().String())
+	if assert.NoError(t, err) {
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+	assert.NoError(t, <-served)
+}
+
+func TestRunReturnsListenError(t *testing.T) {
+	// occupy a port so Run cannot bind to it
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	assert.NoError(t, err)
+	// NOTE: the default photos dir is relative, it would end up in the source tree
+	env := map[string]string{config.ENV_API_HOST: host, config.ENV_API_PORT: port, config.ENV_API_PHOTOS_DIR: t.TempDir()}
+
+	goroutines := runtime.NumGoroutine()
+	// NOTE: the context is never cancelled, so Run returning at all means the error was propagated
+	err = Run(context.Background(), []string{}, func(k string) string { return env[k] }, os.Stdin, io.Discard, io.Discard)
+	assert.ErrorContains(t, err, "listen")
+	// NOTE: polled by hand, assert.Eventually runs its condition in goroutines of its own
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines, "webhooks, telemetry and the scheduler stop when Run fails")
 }
 
 func TestEncode(t *testing.T) {
@@ -73,3 +204,135 @@ func TestDecodeArray(t *testing.T) {
 	assert.Equal(t, requestObjects[1].Name, "Bobbe")
 	assert.Equal(t, requestObjects[2].Name, "Foo")
 }
+
+func TestDecodeStrict(t *testing.T) {
+	tests := map[string]struct {
+		body        string
+		contentType string
+		maxBytes    int64
+
+		wantErr    string
+		wantStatus int
+		wantLine   int
+		wantColumn int
+	}{
+		"accepts json content type with charset": {
+			body:        `{"name": "Bob"}`,
+			contentType: "application/json; charset=utf-8",
+		},
+		"accepts json suffix content type": {
+			body:        `{"name": "Bob"}`,
+			contentType: "application/merge-patch+json",
+		},
+		"rejects other content types": {
+			body:        `name=Bob`,
+			contentType: "application/x-www-form-urlencoded",
+
+			wantErr:    "unsupported content type 'application/x-www-form-urlencoded', expected one of application/json, application/yaml, application/msgpack",
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		"rejects unknown fields": {
+			body: `{"name": "Bob", "nmae": "Bob"}`,
+
+			wantErr:    `decode json: json: unknown field "nmae"`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		"rejects multiple values": {
+			body: `{"name": "Bob"} {"name": "Alice"}`,
+
+			wantErr:    "decode json: body must contain a single JSON value",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantLine:   1,
+			wantColumn: 17,
+		},
+		"rejects trailing garbage": {
+			body: "{\"name\": \"Bob\"}\n]",
+
+			wantErr:    "decode json: body must contain a single JSON value",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantLine:   2,
+			wantColumn: 1,
+		},
+		"reports syntax error position": {
+			body: "{\n  \"name\": Bob\n}",
+
+			wantErr:    "decode json: invalid character 'B' looking for beginning of value",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantLine:   2,
+			wantColumn: 11,
+		},
+		"reports type error position": {
+			body: "{\"name\": 42}",
+
+			wantErr:    "decode json: json: cannot unmarshal number into Go struct field obj.name of type string",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantLine:   1,
+			wantColumn: 11,
+		},
+		"rejects too large body": {
+			body:     `{"name": "Bob"}`,
+			maxBytes: 4,
+
+			wantErr:    "request body too large, limit is 4 bytes",
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.maxBytes > 0 {
+				r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, tc.maxBytes)
+			}
+
+			_, err := decode[obj](r)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantStatus, decodeStatus(err))
+			got := newValidationError(err, nil)
+			assert.Equal(t, tc.wantLine, got.Line, "line")
+			assert.Equal(t, tc.wantColumn, got.Column, "column")
+		})
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(`{"name": "Bob"}`)
+	f.Add(`[{"name": "Bob"}]`)
+	f.Add("{\n\"name\": }")
+	f.Add(`{"name": "Bob"} x`)
+	f.Add(`{"unknown": 1}`)
+	f.Add(``)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		r := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+		r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 1024)
+
+		v, err := decode[obj](r)
+		if err != nil {
+			status := decodeStatus(err)
+			if status != http.StatusUnprocessableEntity && status != http.StatusRequestEntityTooLarge {
+				t.Errorf("unexpected status %d for %q: %v", status, body, err)
+			}
+			got := newValidationError(err, nil)
+			if got.Line < 0 || got.Column < 0 || got.Line > strings.Count(body, "\n")+1 {
+				t.Errorf("position out of range for %q: line %d column %d", body, got.Line, got.Column)
+			}
+			return
+		}
+
+		// anything accepted has to survive a round trip unchanged
+		w := httptest.NewRecorder()
+		assert.NoError(t, encode(w, r, http.StatusOK, v))
+		again, err := decode[obj](httptest.NewRequest(http.MethodPost, "/test", w.Body))
+		assert.NoError(t, err)
+		assert.Equal(t, v, again)
+	})
+}
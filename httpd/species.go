@@ -0,0 +1,204 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"plants/log"
+	"plants/plants"
+	"plants/store"
+	"slices"
+	"strings"
+)
+
+// linkSpecies checks the species a plant links to exists, the plant keeps following the species watering interval
+// unless it sets its own, see speciesWatering. It returns warnings about the plant, ok is false when the response was already written.
+func linkSpecies(w http.ResponseWriter, r *http.Request, speciesStore store.SpeciesStore, plant *plants.Plant) (map[string]string, bool) {
+	if plant.SpeciesID == "" {
+		return nil, true
+	}
+	ctx := r.Context()
+	logger := log.LoggerFromCtx(ctx)
+	species, err := speciesStore.FindSpecies(ctx, plant.SpeciesID)
+	if errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+		problems := map[string]string{"speciesId": err.Error()}
+		err = fmt.Errorf("validation error: invalid input with %d error(-s)", len(problems))
+		logger.WarnContext(ctx, err.Error())
+		_ = encode(w, r, http.StatusUnprocessableEntity, newValidationError(err, problems))
+		return nil, false
+	}
+	if err != nil {
+		err = fmt.Errorf("find species by id: %w", err)
+		logger.ErrorContext(ctx, err.Error())
+		_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+		return nil, false
+	}
+
+	return plant.SpeciesWarnings(*species), true
+}
+
+// speciesWatering returns the watering interval per species ID, for computing overdue care of plants without their own
+func speciesWatering(ctx context.Context, speciesStore store.SpeciesStore) (map[string]int, error) {
+	list, err := speciesStore.ListSpecies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve all species: %w", err)
+	}
+	intervals := make(map[string]int, len(list))
+	for _, s := range list {
+		intervals[s.ID] = s.WateringInterval
+	}
+	return intervals, nil
+}
+
+// writeWarnings adds a Warning header per warning, so clients learn about them without the response body changing
+func writeWarnings(w http.ResponseWriter, warnings map[string]string) {
+	fields := make([]string, 0, len(warnings))
+	for field := range warnings {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	for _, field := range fields {
+		// NOTE: 299 is the "miscellaneous persistent warning" code, the agent is unknown so it is "-"
+		w.Header().Add("Warning", "299 - "+quotedString(warnings[field]))
+	}
+}
+
+// quotedString quotes s as an HTTP quoted-string, only backslashes and double quotes are escaped.
+// Control characters cant be sent in a header at all, they are replaced with spaces.
+func quotedString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r == 0x7F:
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func handleListSpecies(speciesStore store.SpeciesStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		list, err := speciesStore.ListSpecies(ctx)
+		if err != nil {
+			err = fmt.Errorf("retrieve all species: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
+
+func handleGetSpecies(speciesStore store.SpeciesStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		species, err := speciesStore.FindSpecies(ctx, r.PathValue("id"))
+		if err != nil {
+			err = fmt.Errorf("find species by id: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, species)
+	})
+}
+
+func handleCreateSpecies(speciesStore store.SpeciesStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		species, problems, err := decodeValid[plants.Species](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		created, err := speciesStore.CreateSpecies(ctx, species)
+		if err != nil {
+			err = fmt.Errorf("create species: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, created)
+	})
+}
+
+// handleUpdateSpecies replaces a species, plants already linked to it keep the care intervals they were given
+func handleUpdateSpecies(speciesStore store.SpeciesStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		species, problems, err := decodeValid[plants.Species](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		species.ID = r.PathValue("id")
+		updated, err := speciesStore.UpdateSpecies(ctx, species)
+		if err != nil {
+			err = fmt.Errorf("update species: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, updated)
+	})
+}
+
+// handleDeleteSpecies refuses to delete species plants still link to, so no plant is left pointing at nothing
+func handleDeleteSpecies(plantStore store.Store, speciesStore store.SpeciesStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		id := r.PathValue("id")
+		plantList, err := plantStore.List(ctx)
+		if err != nil {
+			err = fmt.Errorf("retrieve all plants: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+		linked := 0
+		for _, plant := range plantList {
+			if plant.SpeciesID == id {
+				linked++
+			}
+		}
+		if linked > 0 {
+			err := fmt.Errorf("delete species: species is linked to %d plant(-s)", linked)
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusConflict, newHttpError(err))
+			return
+		}
+
+		if err := speciesStore.DeleteSpecies(ctx, id); err != nil {
+			err = fmt.Errorf("delete species: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,143 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plants/plants"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const monstera = `{"scientificName":"Monstera deliciosa","commonNames":["Swiss cheese plant"],"family":"Araceae","genus":"Monstera","wateringInterval":7,"light":"bright","matureHeight":{"min":100,"max":300}}`
+
+func TestSpecies(t *testing.T) {
+	handler := newTestAPI(t, nil)
+
+	code, body := doAdmin(t, handler, http.MethodPost, "/species/", `{"scientificName":"deliciosa","family":" ","genus":"Monstera","light":"dark","matureHeight":{"min":300,"max":100}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 5 error(-s)","errors":{
+		"scientificName":"scientific name must start with the genus",
+		"family":"family cannot be empty",
+		"wateringInterval":"watering interval must be at least 1 day",
+		"light":"light must be one of: low, medium, bright, direct",
+		"matureHeight":"mature height must be a range of centimeters with min no larger than max"
+	}}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/species/", monstera)
+	require.Equal(t, http.StatusOK, code)
+	var created plants.Species
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	expand := func(s string) string { return strings.ReplaceAll(s, "{species}", created.ID) }
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/species/", strings.Replace(monstera, "Swiss", "Mexican", 1))
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"message":"create species: species 'Monstera deliciosa' already exists"}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/species/"+created.ID+"/", strings.Replace(monstera, `"wateringInterval":7`, `"wateringInterval":9`, 1))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, expand(`{"id":"{species}","scientificName":"Monstera deliciosa","commonNames":["Swiss cheese plant"],"family":"Araceae","genus":"Monstera","wateringInterval":9,"light":"bright","matureHeight":{"min":100,"max":300}}`), body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/species/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, expand(`[{"id":"{species}","scientificName":"Monstera deliciosa","commonNames":["Swiss cheese plant"],"family":"Araceae","genus":"Monstera","wateringInterval":9,"light":"bright","matureHeight":{"min":100,"max":300}}]`), body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/species/missing/", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.JSONEq(t, `{"message":"find species by id: species with ID 'missing' does not exist"}`, body)
+}
+
+func TestPlantSpecies(t *testing.T) {
+	handler := newTestAPI(t, nil)
+	code, body := doAdmin(t, handler, http.MethodPost, "/species/", monstera)
+	require.Equal(t, http.StatusOK, code)
+	var species plants.Species
+	require.NoError(t, json.Unmarshal([]byte(body), &species))
+	expand := func(s string) string { return strings.ReplaceAll(s, "{species}", species.ID) }
+	post := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/plants/", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer supersecret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post(`{"name":"monty","height":120,"speciesId":"missing"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"speciesId":"species with ID 'missing' does not exist"}}`, w.Body.String())
+
+	w = post(expand(`{"name":"monty","height":120,"speciesId":"{species}"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Values("Warning"))
+	var plant plants.Plant
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plant))
+	assert.Empty(t, plant.CareIntervals, "the species interval isnt copied into the plant")
+
+	w = post(expand(`{"name":"giant","height":350,"speciesId":"{species}","careIntervals":{"watering":3}}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{`299 - "height 350cm is above the mature height of Monstera deliciosa, 100-300cm"`}, w.Header().Values("Warning"))
+	plant = plants.Plant{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plant))
+	assert.Equal(t, map[plants.CareType]int{plants.CareWatering: 3}, plant.CareIntervals, "a plant's own interval wins")
+
+	overdueIntervals := func() map[string]int {
+		t.Helper()
+		code, body := doAdmin(t, handler, http.MethodGet, "/care/overdue?type=watering", "")
+		require.Equal(t, http.StatusOK, code)
+		var overdue []plants.OverdueCare
+		require.NoError(t, json.Unmarshal([]byte(body), &overdue))
+		intervals := make(map[string]int)
+		for _, o := range overdue {
+			intervals[o.PlantName] = o.IntervalDays
+		}
+		return intervals
+	}
+	assert.Equal(t, map[string]int{"monty": 7, "giant": 3}, overdueIntervals())
+	// NOTE: the species interval is looked up when overdue care is computed, so updates apply to linked plants
+	code, _ = doAdmin(t, handler, http.MethodPut, "/species/"+species.ID+"/", strings.Replace(monstera, `"wateringInterval":7`, `"wateringInterval":9`, 1))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]int{"monty": 9, "giant": 3}, overdueIntervals())
+
+	code, body = doAdmin(t, handler, http.MethodDelete, "/species/"+species.ID+"/", "")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"message":"delete species: species is linked to 2 plant(-s)"}`, body)
+}
+
+func TestQuotedString(t *testing.T) {
+	tests := map[string]struct {
+		s string
+
+		want string
+	}{
+		"plain": {
+			s: "height 350cm is above the mature height",
+
+			want: `"height 350cm is above the mature height"`,
+		},
+		"quotes and backslashes": {
+			s: `Monstera "deliciosa" \ Araceae`,
+
+			want: `"Monstera \"deliciosa\" \\ Araceae"`,
+		},
+		"non-ascii is kept as is": {
+			s: "Opuntia ficus-indica, 30–50cm",
+
+			want: `"Opuntia ficus-indica, 30–50cm"`,
+		},
+		"control characters": {
+			s: "line\r\nbreak",
+
+			want: `"line  break"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, quotedString(tc.s))
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"plants/log"
+	"plants/store"
+	"plants/telemetry"
+	"time"
+)
+
+// minTelemetryStep keeps aggregate windows from being finer than sensors report
+const minTelemetryStep = time.Minute
+
+type ingestResult struct {
+	Accepted int `json:"accepted"`
+}
+
+// handleIngestTelemetry accepts a batch of sensor readings and stores it in the background,
+// the whole batch is rejected when a line is invalid or comes from a sensor that isnt mapped to a plant
+func handleIngestTelemetry(sensors telemetry.SensorStore, ingester *telemetry.Ingester) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != telemetry.FORMAT_NDJSON && mediaType != telemetry.FORMAT_LINE_PROTOCOL {
+			err := fmt.Errorf("unsupported content type '%s', expected %s or %s", contentType, telemetry.FORMAT_NDJSON, telemetry.FORMAT_LINE_PROTOCOL)
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusUnsupportedMediaType, newHttpError(err))
+			return
+		}
+
+		readings, problems, err := telemetry.Parse(mediaType, r.Body, time.Now())
+		if err != nil {
+			code := http.StatusUnprocessableEntity
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				code = http.StatusRequestEntityTooLarge
+				err = fmt.Errorf("request body too large, limit is %d bytes", maxBytesErr.Limit)
+			}
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, code, newHttpError(err))
+			return
+		}
+
+		// NOTE: batches usually come from a handful of sensors, so each one is looked up once
+		plantIDs := make(map[string]string)
+		for i, reading := range readings {
+			plantID, ok := plantIDs[reading.SensorID]
+			if !ok {
+				sensor, err := sensors.FindSensor(ctx, reading.SensorID)
+				if err != nil && !errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+					err = fmt.Errorf("find sensor: %w", err)
+					logger.ErrorContext(ctx, err.Error())
+					_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+					return
+				}
+				if sensor != nil {
+					plantID = sensor.PlantID
+				}
+				plantIDs[reading.SensorID] = plantID
+			}
+			if plantID == "" {
+				problems["sensor "+reading.SensorID] = "sensor is not mapped to a plant"
+				continue
+			}
+			readings[i].PlantID = plantID
+		}
+		if len(problems) > 0 {
+			err := fmt.Errorf("validation error: invalid input with %d error(-s)", len(problems))
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusUnprocessableEntity, newValidationError(err, problems))
+			return
+		}
+
+		if len(readings) > 0 {
+			if err := ingester.Submit(readings); err != nil {
+				// NOTE: sensors retry on their own, asking them to back off is all we can do when overloaded
+				w.Header().Set("Retry-After", "1")
+				err = fmt.Errorf("ingest telemetry: %w", err)
+				logger.WarnContext(ctx, err.Error(), slog.Int("readings", len(readings)))
+				_ = encode(w, r, http.StatusServiceUnavailable, newHttpError(err))
+				return
+			}
+		}
+
+		_ = encode(w, r, http.StatusAccepted, ingestResult{Accepted: len(readings)})
+	})
+}
+
+// parseTelemetryQuery reads the required "metric" and the optional "step" query parameters, step defaults to an hour
+func parseTelemetryQuery(r *http.Request) (string, time.Duration, error) {
+	metric := r.URL.Query().Get("metric")
+	if !telemetry.ValidMetric(metric) {
+		return "", 0, fmt.Errorf("query parameter 'metric' must be one of: %s, %s, %s", telemetry.METRIC_SOIL_MOISTURE, telemetry.METRIC_TEMPERATURE, telemetry.METRIC_LIGHT)
+	}
+
+	step := time.Hour
+	if raw := r.URL.Query().Get("step"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < minTelemetryStep {
+			return "", 0, errors.New("query parameter 'step' must be a duration of at least 1m like 15m or 1h")
+		}
+		step = parsed
+	}
+	return metric, step, nil
+}
+
+// handleLatestTelemetry returns the most recent reading of every metric of a plant
+func handleLatestTelemetry(plantStore store.Store, telemetryStore telemetry.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		readings, err := telemetryStore.LatestReadings(ctx, plant.ID)
+		if err != nil {
+			err = fmt.Errorf("retrieve latest telemetry: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, readings)
+	})
+}
+
+// handleAggregateTelemetry summarizes one metric of a plant into windows of "step" within the "from" and "to" range
+func handleAggregateTelemetry(plantStore store.Store, telemetryStore telemetry.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.WithPlantID(r.Context(), r.PathValue("id"))
+		r = r.WithContext(ctx)
+		logger := log.LoggerFromCtx(ctx)
+		metric, step, err := parseTelemetryQuery(r)
+		var from, to time.Time
+		if err == nil {
+			from, to, err = parseTimeRange(r)
+		}
+		if err != nil {
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusBadRequest, newHttpError(err))
+			return
+		}
+
+		plant, ok := findPlant(w, r, plantStore)
+		if !ok {
+			return
+		}
+
+		aggregates, err := telemetryStore.AggregateReadings(ctx, plant.ID, metric, from, to, step)
+		if err != nil {
+			err = fmt.Errorf("aggregate telemetry: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, aggregates)
+	})
+}
+
+func handleListSensors(sensors telemetry.SensorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		list, err := sensors.ListSensors(ctx)
+		if err != nil {
+			err = fmt.Errorf("retrieve sensors: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, list)
+	})
+}
+
+// handlePutSensor maps a sensor to a plant, readings already stored stay with the plant they were sent for
+func handlePutSensor(plantStore store.Store, sensors telemetry.SensorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		sensor, problems, err := decodeValid[telemetry.Sensor](r)
+		if err == nil {
+			if _, findErr := plantStore.Find(ctx, sensor.PlantID); findErr != nil {
+				if !errors.As(findErr, &store.ErrorResourceDoesNotExist{}) {
+					err = fmt.Errorf("find plant by id: %w", findErr)
+					logger.ErrorContext(ctx, err.Error())
+					_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+					return
+				}
+				problems = map[string]string{"plantId": findErr.Error()}
+				err = fmt.Errorf("invalid input with %d error(-s)", len(problems))
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		sensor.ID = r.PathValue("id")
+		saved, err := sensors.PutSensor(ctx, sensor)
+		if err != nil {
+			err = fmt.Errorf("save sensor: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, saved)
+	})
+}
+
+func handleDeleteSensor(sensors telemetry.SensorStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		if err := sensors.DeleteSensor(ctx, r.PathValue("id")); err != nil {
+			err = fmt.Errorf("delete sensor: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
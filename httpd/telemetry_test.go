@@ -0,0 +1,285 @@
+package httpd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"plants/config"
+	"plants/log"
+	"plants/plants"
+	"plants/store"
+	"plants/telemetry"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ingest posts a telemetry batch as the admin
+func ingest(t *testing.T, handler http.Handler, contentType, body string) (int, http.Header, string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/telemetry", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer supersecret")
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	res := w.Result()
+	defer func() { _ = res.Body.Close() }()
+	got, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, res.Header, string(got)
+}
+
+func TestIngestTelemetry(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        string
+
+		wantCode     int
+		wantResponse string
+		wantReadings int
+	}{
+		"ndjson": {
+			contentType: "application/x-ndjson",
+			body: `{"sensorId":"s1","metric":"soil_moisture","value":23.5,"time":"2024-05-01T08:00:00Z"}
+{"sensorId":"s1","metric":"temperature","value":21,"time":"2024-05-01T08:00:00Z"}`,
+
+			wantCode:     http.StatusAccepted,
+			wantResponse: `{"accepted":2}`,
+			wantReadings: 2,
+		},
+		"line protocol": {
+			contentType: "text/plain; charset=utf-8",
+			body:        `climate,sensor=s1 soil_moisture=23.5,light=1200i 1714550400000000000`,
+
+			wantCode:     http.StatusAccepted,
+			wantResponse: `{"accepted":2}`,
+			wantReadings: 2,
+		},
+		"invalid lines reject the batch": {
+			contentType: "text/plain",
+			body: `soil_moisture,sensor=s1 value=23.5
+soil_moisture,sensor=s1 value=wet
+soil_moisture,sensor=s9 value=20`,
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"validation error: invalid input with 2 error(-s)","errors":{"line 2":"field 'value' must be a number","sensor s9":"sensor is not mapped to a plant"}}`,
+		},
+		"unsupported format": {
+			contentType: "application/json",
+			body:        `[]`,
+
+			wantCode:     http.StatusUnsupportedMediaType,
+			wantResponse: `{"message":"unsupported content type 'application/json', expected application/x-ndjson or text/plain"}`,
+		},
+		"batch too large": {
+			contentType: "text/plain",
+			body:        strings.Repeat("soil_moisture,sensor=s1 value=23.5\n", 10),
+
+			wantCode:     http.StatusRequestEntityTooLarge,
+			wantResponse: `{"message":"request body too large, limit is 256 bytes"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			plantStore := store.NewMemoryStore(nil)
+			plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern"})
+			require.NoError(t, err)
+			sensors := telemetry.NewMemorySensorStore()
+			_, err = sensors.PutSensor(ctx, telemetry.Sensor{ID: "s1", PlantID: plant.ID})
+			require.NoError(t, err)
+			telemetryStore := telemetry.NewMemoryStore(0, nil)
+			ingester := telemetry.NewIngester(log.NoopLogger(), telemetryStore, telemetry.Options{QueueSize: 10, Workers: 1})
+			require.NoError(t, ingester.Start(ctx))
+			handler := newTestAPI(t, func(cfg *config.Server, deps *Dependencies) {
+				cfg.Telemetry.MaxBatchBytes = 256
+				deps.PlantStore = plantStore
+				deps.Telemetry = telemetryStore
+				deps.Sensors = sensors
+				deps.Ingester = ingester
+			})
+
+			gotCode, _, gotBody := ingest(t, handler, tc.contentType, tc.body)
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			assert.JSONEq(t, tc.wantResponse, gotBody)
+			cancel()
+			require.NoError(t, ingester.Wait(context.Background()))
+			latest, err := telemetryStore.LatestReadings(context.Background(), plant.ID)
+			require.NoError(t, err)
+			assert.Len(t, latest, tc.wantReadings)
+		})
+	}
+}
+
+func TestIngestTelemetryOverloaded(t *testing.T) {
+	ctx := context.Background()
+	plantStore := store.NewMemoryStore(nil)
+	plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	sensors := telemetry.NewMemorySensorStore()
+	_, err = sensors.PutSensor(ctx, telemetry.Sensor{ID: "s1", PlantID: plant.ID})
+	require.NoError(t, err)
+	// NOTE: the ingester is not started, so the first batch stays queued
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.Sensors = sensors
+		deps.Ingester = telemetry.NewIngester(log.NoopLogger(), telemetry.NewMemoryStore(0, nil), telemetry.Options{QueueSize: 1, Workers: 1})
+	})
+	body := `soil_moisture,sensor=s1 value=23.5`
+
+	gotCode, _, _ := ingest(t, handler, "text/plain", body)
+	assert.Equal(t, http.StatusAccepted, gotCode)
+
+	gotCode, gotHeader, gotBody := ingest(t, handler, "text/plain", body)
+	assert.Equal(t, http.StatusServiceUnavailable, gotCode)
+	assert.Equal(t, "1", gotHeader.Get("Retry-After"))
+	assert.JSONEq(t, `{"message":"ingest telemetry: telemetry ingestion queue is full"}`, gotBody)
+}
+
+func TestTelemetryQueries(t *testing.T) {
+	ctx := context.Background()
+	plantStore := store.NewMemoryStore(nil)
+	plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	telemetryStore := telemetry.NewMemoryStore(0, nil)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	require.NoError(t, telemetryStore.AppendReadings(ctx, []telemetry.Reading{
+		{SensorID: "s1", PlantID: plant.ID, Metric: telemetry.METRIC_SOIL_MOISTURE, Time: start, Value: 40},
+		{SensorID: "s1", PlantID: plant.ID, Metric: telemetry.METRIC_SOIL_MOISTURE, Time: start.Add(20 * time.Minute), Value: 30},
+		{SensorID: "s1", PlantID: plant.ID, Metric: telemetry.METRIC_SOIL_MOISTURE, Time: start.Add(80 * time.Minute), Value: 20},
+		{SensorID: "s1", PlantID: plant.ID, Metric: telemetry.METRIC_LIGHT, Time: start, Value: 1200},
+	}))
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.Telemetry = telemetryStore
+	})
+
+	tests := map[string]struct {
+		path string
+
+		wantCode     int
+		wantResponse string
+	}{
+		"latest": {
+			path: "/plants/{plant}/telemetry/latest",
+
+			wantCode: http.StatusOK,
+			wantResponse: `[
+				{"sensorId":"s1","plantId":"{plant}","metric":"light","time":"2024-05-01T08:00:00Z","value":1200},
+				{"sensorId":"s1","plantId":"{plant}","metric":"soil_moisture","time":"2024-05-01T09:20:00Z","value":20}
+			]`,
+		},
+		"hourly": {
+			path: "/plants/{plant}/telemetry?metric=soil_moisture",
+
+			wantCode: http.StatusOK,
+			wantResponse: `[
+				{"start":"2024-05-01T08:00:00Z","min":30,"max":40,"avg":35,"count":2},
+				{"start":"2024-05-01T09:00:00Z","min":20,"max":20,"avg":20,"count":1}
+			]`,
+		},
+		"within range": {
+			path: "/plants/{plant}/telemetry?metric=soil_moisture&step=15m&from=2024-05-01T08:10:00Z&to=2024-05-01T09:00:00Z",
+
+			wantCode:     http.StatusOK,
+			wantResponse: `[{"start":"2024-05-01T08:15:00Z","min":30,"max":30,"avg":30,"count":1}]`,
+		},
+		"missing metric": {
+			path: "/plants/{plant}/telemetry",
+
+			wantCode:     http.StatusBadRequest,
+			wantResponse: `{"message":"query parameter 'metric' must be one of: soil_moisture, temperature, light"}`,
+		},
+		"step too small": {
+			path: "/plants/{plant}/telemetry?metric=light&step=1s",
+
+			wantCode:     http.StatusBadRequest,
+			wantResponse: `{"message":"query parameter 'step' must be a duration of at least 1m like 15m or 1h"}`,
+		},
+		"missing plant": {
+			path: "/plants/missing/telemetry/latest",
+
+			wantCode:     http.StatusNotFound,
+			wantResponse: `{"message":"find plant by id: plant with ID 'missing' does not exist"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotCode, gotBody := doAdmin(t, handler, http.MethodGet, strings.ReplaceAll(tc.path, "{plant}", plant.ID), "")
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			assert.JSONEq(t, strings.ReplaceAll(tc.wantResponse, "{plant}", plant.ID), gotBody)
+		})
+	}
+}
+
+func TestSensors(t *testing.T) {
+	ctx := context.Background()
+	plantStore := store.NewMemoryStore(nil)
+	plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	sensors := telemetry.NewMemorySensorStore()
+	_, err = sensors.PutSensor(ctx, telemetry.Sensor{ID: "s1", PlantID: plant.ID})
+	require.NoError(t, err)
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.Sensors = sensors
+	})
+	expand := func(s string) string { return strings.ReplaceAll(s, "{plant}", plant.ID) }
+
+	code, body := doAdmin(t, handler, http.MethodPut, "/sensors/s2", `{"plantId":"missing"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"plantId":"plant with ID 'missing' does not exist"}}`, body)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/sensors/s2", expand(`{"plantId":"{plant}","name":"bench 2"}`))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, expand(`{"id":"s2","plantId":"{plant}","name":"bench 2"}`), body)
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/sensors/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, expand(`[{"id":"s1","plantId":"{plant}"},{"id":"s2","plantId":"{plant}","name":"bench 2"}]`), body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/sensors/s1", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, body = doAdmin(t, handler, http.MethodDelete, "/sensors/s1", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.JSONEq(t, `{"message":"delete sensor: sensor with ID 's1' does not exist"}`, body)
+}
+
+func TestDeletePlantTelemetry(t *testing.T) {
+	ctx := context.Background()
+	plantStore := store.NewMemoryStore(nil)
+	plant, err := plantStore.Create(ctx, plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	sensors := telemetry.NewMemorySensorStore()
+	_, err = sensors.PutSensor(ctx, telemetry.Sensor{ID: "s1", PlantID: plant.ID})
+	require.NoError(t, err)
+	telemetryStore := telemetry.NewMemoryStore(0, nil)
+	require.NoError(t, telemetryStore.AppendReadings(ctx, []telemetry.Reading{
+		{SensorID: "s1", PlantID: plant.ID, Metric: telemetry.METRIC_SOIL_MOISTURE, Time: time.Now(), Value: 40},
+	}))
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.Telemetry = telemetryStore
+		deps.Sensors = sensors
+	})
+
+	code, _ := doAdmin(t, handler, http.MethodDelete, "/plants/"+plant.ID+"/", "")
+	require.Equal(t, http.StatusNoContent, code)
+
+	latest, err := telemetryStore.LatestReadings(ctx, plant.ID)
+	require.NoError(t, err)
+	assert.Empty(t, latest)
+	// NOTE: the sensor was unmapped along with the plant, so it cant store readings for it anymore
+	gotCode, _, gotBody := ingest(t, handler, "text/plain", `soil_moisture,sensor=s1 value=23.5`)
+	assert.Equal(t, http.StatusUnprocessableEntity, gotCode)
+	assert.JSONEq(t, `{"message":"validation error: invalid input with 1 error(-s)","errors":{"sensor s1":"sensor is not mapped to a plant"}}`, gotBody)
+}
@@ -0,0 +1,187 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"plants/auth"
+	"plants/config"
+	"plants/log"
+	"sync"
+	"time"
+)
+
+// how often certificate files are checked for changes, at most once per handshake
+const certReloadInterval = 10 * time.Second
+
+// secureCipherSuites only apply to TLS 1.2, TLS 1.3 suites are not configurable and all are fine
+var secureCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+func newTLSConfig(logger *slog.Logger, cfg config.TLS) (*tls.Config, error) {
+	minVersion, err := parseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(logger, cfg.CertFile, cfg.KeyFile, certReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   secureCipherSuites,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	switch cfg.ClientAuth {
+	case config.TLS_CLIENT_AUTH_NONE, "":
+		return tlsCfg, nil
+	case config.TLS_CLIENT_AUTH_OPTIONAL:
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.TLS_CLIENT_AUTH_REQUIRE:
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown tls client auth mode '%s'", cfg.ClientAuth)
+	}
+
+	if cfg.ClientCAFile == "" {
+		return nil, errors.New("tls client auth requires a client CA file")
+	}
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in client CA file '%s'", cfg.ClientCAFile)
+	}
+	tlsCfg.ClientCAs = pool
+
+	return tlsCfg, nil
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "1.2", "":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls min version '%s'", v)
+	}
+}
+
+// certReloader serves the current certificate and picks up renewed files without a restart,
+// e.g. when cert-manager rotates a mounted secret
+type certReloader struct {
+	logger   *slog.Logger
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func newCertReloader(logger *slog.Logger, certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{
+		logger:   logger,
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("stat certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("stat key: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	r.lastCheck = time.Now()
+
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+
+	return !certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)
+}
+
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < r.interval {
+		return r.cert, nil
+	}
+	r.lastCheck = time.Now()
+
+	if r.changed() {
+		// NOTE: a half-written or broken pair keeps the previous certificate in use
+		if err := r.reload(); err != nil {
+			r.logger.Error(fmt.Sprintf("reload tls certificate: %s", err))
+		} else {
+			r.logger.Info("reloaded tls certificate")
+		}
+	}
+
+	return r.cert, nil
+}
+
+// newClientIdentity puts the verified client certificate identity into the request context,
+// unverified or missing certificates leave the context untouched
+func newClientIdentity() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+				identity := auth.FromCertificate(r.TLS.VerifiedChains[0][0])
+				ctx := auth.WithIdentity(r.Context(), identity)
+				r = r.WithContext(log.WithUserID(ctx, identity.Subject))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
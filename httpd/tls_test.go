@@ -0,0 +1,298 @@
+package httpd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"plants/auth"
+	"plants/config"
+	"plants/health"
+	"plants/log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a throwaway certificate authority, generated per test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns PEM encoded certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{commonName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) clientCert(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, commonName, 3, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// serveTLS starts a server with the given tls config, the handler echoes the client identity subject
+func serveTLS(t *testing.T, cfg config.TLS) string {
+	t.Helper()
+	tlsCfg, err := newTLSConfig(log.NoopLogger(), cfg)
+	require.NoError(t, err)
+
+	handler := newClientIdentity()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromCtx(r.Context())
+		_, _ = io.WriteString(w, identity.Subject)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := newServer(log.NoopLogger(), handler, health.NewRegistry(), time.Second, 0)
+	served := make(chan error, 1)
+	go func() { served <- srv.serve(ctx, tls.NewListener(ln, tlsCfg)) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+	})
+
+	return "https://" + ln.Addr().String()
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	baseCfg := config.TLS{
+		CertFile:     writeFile(t, dir, "server.crt", certPEM),
+		KeyFile:      writeFile(t, dir, "server.key", keyPEM),
+		ClientCAFile: writeFile(t, dir, "ca.crt", ca.pem),
+		MinVersion:   "1.2",
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := map[string]struct {
+		clientAuth  string
+		clientCerts []tls.Certificate
+		maxVersion  uint16
+		minVersion  string
+
+		wantSubject string
+		wantErr     bool
+	}{
+		"plain tls without client cert": {
+			clientAuth: config.TLS_CLIENT_AUTH_NONE,
+
+			wantSubject: "",
+		},
+		"optional client cert missing": {
+			clientAuth: config.TLS_CLIENT_AUTH_OPTIONAL,
+
+			wantSubject: "",
+		},
+		"optional client cert present": {
+			clientAuth:  config.TLS_CLIENT_AUTH_OPTIONAL,
+			clientCerts: []tls.Certificate{ca.clientCert(t, "sensor-1")},
+
+			wantSubject: "sensor-1",
+		},
+		"required client cert present": {
+			clientAuth:  config.TLS_CLIENT_AUTH_REQUIRE,
+			clientCerts: []tls.Certificate{ca.clientCert(t, "sensor-2")},
+
+			wantSubject: "sensor-2",
+		},
+		"required client cert missing": {
+			clientAuth: config.TLS_CLIENT_AUTH_REQUIRE,
+
+			wantErr: true,
+		},
+		"client cert from unknown CA": {
+			clientAuth:  config.TLS_CLIENT_AUTH_REQUIRE,
+			clientCerts: []tls.Certificate{otherCA.clientCert(t, "intruder")},
+
+			wantErr: true,
+		},
+		"client below min version": {
+			clientAuth: config.TLS_CLIENT_AUTH_NONE,
+			minVersion: "1.3",
+			maxVersion: tls.VersionTLS12,
+
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := baseCfg
+			cfg.ClientAuth = tc.clientAuth
+			if tc.minVersion != "" {
+				cfg.MinVersion = tc.minVersion
+			}
+			url := serveTLS(t, cfg)
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: tc.clientCerts,
+				MaxVersion:   tc.maxVersion,
+			}}}
+			res, err := client.Get(url)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer func() { _ = res.Body.Close() }()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantSubject, string(body))
+		})
+	}
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, dir, "server.crt", certPEM)
+	keyFile := writeFile(t, dir, "server.key", keyPEM)
+
+	tests := map[string]struct {
+		cfg config.TLS
+
+		wantErr string
+	}{
+		"missing certificate file": {
+			cfg: config.TLS{CertFile: filepath.Join(dir, "nope.crt"), KeyFile: keyFile},
+
+			wantErr: "stat certificate",
+		},
+		"unknown min version": {
+			cfg: config.TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"},
+
+			wantErr: "unsupported tls min version '1.0'",
+		},
+		"client auth without CA": {
+			cfg: config.TLS{CertFile: certFile, KeyFile: keyFile, ClientAuth: config.TLS_CLIENT_AUTH_REQUIRE},
+
+			wantErr: "tls client auth requires a client CA file",
+		},
+		"unknown client auth mode": {
+			cfg: config.TLS{CertFile: certFile, KeyFile: keyFile, ClientAuth: "sometimes"},
+
+			wantErr: "unknown tls client auth mode 'sometimes'",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newTLSConfig(log.NoopLogger(), tc.cfg)
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "localhost", 10, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, dir, "server.crt", certPEM)
+	keyFile := writeFile(t, dir, "server.key", keyPEM)
+
+	// NOTE: zero interval means the files are checked on every handshake
+	reloader, err := newCertReloader(log.NoopLogger(), certFile, keyFile, 0)
+	require.NoError(t, err)
+
+	serial := func() int64 {
+		cert, err := reloader.GetCertificate(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return leaf.SerialNumber.Int64()
+	}
+	assert.Equal(t, int64(10), serial())
+
+	// rotate the certificate, explicitly bumping the mod time so the test doesnt depend on fs timestamp resolution
+	certPEM, keyPEM = ca.issue(t, "localhost", 11, x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "server.crt", certPEM)
+	writeFile(t, dir, "server.key", keyPEM)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	assert.Equal(t, int64(11), serial())
+
+	// a broken rotation keeps serving the last good certificate
+	writeFile(t, dir, "server.key", []byte("garbage"))
+	future = future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	assert.Equal(t, int64(11), serial())
+}
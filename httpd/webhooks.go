@@ -0,0 +1,192 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"plants/log"
+	"plants/store"
+	"plants/webhooks"
+	"time"
+)
+
+// withoutSecret hides the signing secret, it is only shown once when a subscription is created
+func withoutSecret(sub webhooks.Subscription) webhooks.Subscription {
+	sub.Secret = ""
+	return sub
+}
+
+// storeErrorStatus is 404 for missing resources, 409 for conflicting ones and 500 for anything else
+func storeErrorStatus(err error) int {
+	if errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+		return http.StatusNotFound
+	}
+	if errors.As(err, &store.ErrorResourceConflict{}) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func handleListWebhooks(webhookStore webhooks.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		subs, err := webhookStore.ListSubscriptions(ctx)
+		if err != nil {
+			err = fmt.Errorf("retrieve all webhooks: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		res := make([]webhooks.Subscription, 0, len(subs))
+		for _, sub := range subs {
+			res = append(res, withoutSecret(sub))
+		}
+
+		_ = encode(w, r, http.StatusOK, res)
+	})
+}
+
+func handleGetWebhook(webhookStore webhooks.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		sub, err := webhookStore.FindSubscription(ctx, r.PathValue("id"))
+		if err != nil {
+			err = fmt.Errorf("find webhook by id: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, withoutSecret(*sub))
+	})
+}
+
+func handleCreateWebhook(webhookStore webhooks.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		sub, problems, err := decodeValid[webhooks.Subscription](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		if sub.Secret == "" {
+			if sub.Secret, err = webhooks.NewSecret(); err != nil {
+				logger.ErrorContext(ctx, err.Error())
+				_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+				return
+			}
+		}
+		sub.CreatedAt = time.Now().UTC()
+
+		created, err := webhookStore.CreateSubscription(ctx, sub)
+		if err != nil {
+			err = fmt.Errorf("create webhook: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusInternalServerError, newHttpError(err))
+			return
+		}
+
+		// NOTE: the only response that includes the secret, receivers need it to verify signatures
+		_ = encode(w, r, http.StatusOK, created)
+	})
+}
+
+func handleUpdateWebhook(webhookStore webhooks.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		sub, problems, err := decodeValid[webhooks.Subscription](r)
+		if err != nil {
+			err = fmt.Errorf("validation error: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, decodeStatus(err), newValidationError(err, problems))
+			return
+		}
+
+		existing, err := webhookStore.FindSubscription(ctx, r.PathValue("id"))
+		if err == nil {
+			// NOTE: an empty secret keeps the current one, so updating filters doesnt require rotating it
+			if sub.Secret == "" {
+				sub.Secret = existing.Secret
+			}
+			sub.ID = existing.ID
+			sub.CreatedAt = existing.CreatedAt
+			existing, err = webhookStore.UpdateSubscription(ctx, sub)
+		}
+		if err != nil {
+			err = fmt.Errorf("update webhook: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusOK, withoutSecret(*existing))
+	})
+}
+
+func handleDeleteWebhook(webhookStore webhooks.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		if err := webhookStore.DeleteSubscription(ctx, r.PathValue("id")); err != nil {
+			err = fmt.Errorf("delete webhook: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func handleListWebhookDeliveries(webhookStore webhooks.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		deliveries, err := webhookStore.ListDeliveries(ctx, r.PathValue("id"))
+		if err != nil {
+			err = fmt.Errorf("retrieve webhook deliveries: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, storeErrorStatus(err), newHttpError(err))
+			return
+		}
+
+		if len(deliveries) == 0 {
+			deliveries = make([]webhooks.Delivery, 0)
+		}
+
+		_ = encode(w, r, http.StatusOK, deliveries)
+	})
+}
+
+// handleRedeliverWebhook queues a finished (succeeded or dead) delivery again, it answers
+// before the delivery is sent and the outcome shows up in the delivery history
+func handleRedeliverWebhook(dispatcher *webhooks.Dispatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		delivery, err := dispatcher.Redeliver(ctx, r.PathValue("id"), r.PathValue("deliveryId"))
+		if err != nil {
+			code := storeErrorStatus(err)
+			switch {
+			case errors.Is(err, webhooks.ErrDeliveryPending):
+				code = http.StatusConflict
+			case errors.Is(err, webhooks.ErrDispatcherStopped):
+				code = http.StatusServiceUnavailable
+			}
+			err = fmt.Errorf("redeliver webhook: %w", err)
+			logger.ErrorContext(ctx, err.Error())
+			_ = encode(w, r, code, newHttpError(err))
+			return
+		}
+
+		_ = encode(w, r, http.StatusAccepted, delivery)
+	})
+}
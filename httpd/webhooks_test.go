@@ -0,0 +1,136 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"plants/config"
+	"plants/events"
+	"plants/log"
+	"plants/store"
+	"plants/webhooks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	tests := map[string]struct {
+		body string
+
+		wantCode     int
+		wantResponse string
+	}{
+		"invalid url": {
+			body: `{"url":"ftp://example.com"}`,
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"validation error: invalid input with 1 error(-s)","errors":{"url":"url must be an absolute http or https url"}}`,
+		},
+		"unknown event type": {
+			body: `{"url":"https://example.com/hook","events":["watered"]}`,
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"validation error: invalid input with 1 error(-s)","errors":{"events":"events must be any of: created, updated, deleted, reminder"}}`,
+		},
+		"short secret": {
+			body: `{"url":"https://example.com/hook","secret":"hunter2"}`,
+
+			wantCode:     http.StatusUnprocessableEntity,
+			wantResponse: `{"message":"validation error: invalid input with 1 error(-s)","errors":{"secret":"secret must be at least 16 characters"}}`,
+		},
+	}
+
+	handler := newTestAPI(t, nil)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotCode, gotBody := doAdmin(t, handler, http.MethodPost, "/webhooks/", tc.body)
+
+			assert.Equal(t, tc.wantCode, gotCode)
+			assert.JSONEq(t, tc.wantResponse, gotBody)
+		})
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	webhookStore := webhooks.NewMemoryStore(10)
+	dispatcher := webhooks.NewDispatcher(log.NoopLogger(), webhookStore, broker, webhooks.Options{
+		Workers:        1,
+		MaxAttempts:    1,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Timeout:        time.Second,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, dispatcher.Start(ctx))
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, dispatcher.Wait(context.Background()))
+	})
+	handler := newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = events.NewPublishingStore(store.NewMemoryStore(nil), broker)
+		deps.Events = broker
+		deps.WebhookStore = webhookStore
+		deps.Webhooks = dispatcher
+	})
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	t.Cleanup(receiver.Close)
+
+	code, body := doAdmin(t, handler, http.MethodPost, "/webhooks/", `{"url":"`+receiver.URL+`","events":["created"]}`)
+	require.Equal(t, http.StatusOK, code, body)
+	var created webhooks.Subscription
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	assert.Len(t, created.Secret, 64, "a secret is generated and returned once")
+
+	code, body = doAdmin(t, handler, http.MethodGet, "/webhooks/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, body, created.Secret)
+
+	code, body = doAdmin(t, handler, http.MethodPut, "/webhooks/"+created.ID+"/", `{"url":"`+receiver.URL+`","events":["created","deleted"]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, body, created.Secret)
+	updated, err := webhookStore.FindSubscription(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.Secret, updated.Secret, "updates without a secret keep the current one")
+	assert.Equal(t, []events.Type{events.TypeCreated, events.TypeDeleted}, updated.Events)
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/plants/", `{"name":"basil"}`)
+	require.Equal(t, http.StatusOK, code, body)
+
+	r, payload := <-received, <-bodies
+	require.NoError(t, webhooks.Verify(created.Secret, r.Header, payload, time.Now(), time.Minute))
+	var deliveries []webhooks.Delivery
+	require.Eventually(t, func() bool {
+		code, body = doAdmin(t, handler, http.MethodGet, "/webhooks/"+created.ID+"/deliveries", "")
+		require.NoError(t, json.Unmarshal([]byte(body), &deliveries))
+		return len(deliveries) == 1 && deliveries[0].Status == webhooks.StatusSucceeded
+	}, 2*time.Second, time.Millisecond)
+	assert.Equal(t, "basil", deliveries[0].Event.Plant.Name)
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/webhooks/"+created.ID+"/deliveries/"+deliveries[0].ID+"/redeliver", "")
+	assert.Equal(t, http.StatusAccepted, code, body)
+	r = <-received
+	assert.Equal(t, deliveries[0].ID, r.Header.Get(webhooks.HEADER_DELIVERY))
+
+	code, body = doAdmin(t, handler, http.MethodPost, "/webhooks/"+created.ID+"/deliveries/missing/redeliver", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.JSONEq(t, `{"message":"redeliver webhook: webhook delivery with ID 'missing' does not exist"}`, body)
+
+	code, _ = doAdmin(t, handler, http.MethodDelete, "/webhooks/"+created.ID+"/", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = doAdmin(t, handler, http.MethodGet, "/webhooks/"+created.ID+"/", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = doAdmin(t, handler, http.MethodGet, "/webhooks/"+created.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,251 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"plants/auth"
+	"plants/config"
+	"plants/events"
+	"plants/log"
+	"plants/plants"
+	"plants/store"
+	"plants/websocket"
+	"slices"
+	"time"
+)
+
+// message types of the plant socket protocol, every message is a JSON object with a "type"
+const (
+	// client to server
+	WS_SUBSCRIBE    = "subscribe"
+	WS_UNSUBSCRIBE  = "unsubscribe"
+	WS_LOG_WATERING = "logWatering"
+	// server to client
+	WS_ACK   = "ack"
+	WS_ERROR = "error"
+	WS_EVENT = "event"
+)
+
+// how long a client gets to answer the close frame
+const wsCloseTimeout = 5 * time.Second
+
+// how long a client gets to read a message before the connection is considered stuck
+const wsWriteTimeout = 10 * time.Second
+
+// wsRequest is a client command, ID is optional and echoed in the reply so clients can match them
+type wsRequest struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id,omitempty"`
+	PlantIDs []string `json:"plantIds,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	PlantID  string   `json:"plantId,omitempty"`
+}
+
+type wsResponse struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Plant   *plants.Plant `json:"plant,omitempty"`
+	Event   *events.Event `json:"event,omitempty"`
+}
+
+// wsFilter selects the events a connection receives, nothing is sent until the client subscribes
+type wsFilter struct {
+	plantIDs []string
+	tags     []string
+}
+
+func (f *wsFilter) matches(p plants.Plant) bool {
+	return slices.Contains(f.plantIDs, p.ID) || p.HasAnyTag(f.tags)
+}
+
+func addUnique(list []string, values []string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func removeAll(list []string, values []string) []string {
+	return slices.DeleteFunc(list, func(v string) bool { return slices.Contains(values, v) })
+}
+
+type wsMessage struct {
+	messageType websocket.MessageType
+	data        []byte
+}
+
+// handlePlantSocket is a bidirectional alternative to handlePlantEvents: clients subscribe to plant IDs
+// or tags, get matching change events and can send commands. Connections are closed with
+// "going away" when the broker shuts down. Browsers can only connect from the same host or an origin in cors.AllowedOrigins.
+func handlePlantSocket(plantStore store.Store, careStore store.CareStore, broker *events.Broker, cfg config.WebSocket, cors config.CORS) http.Handler {
+	checkOrigin := func(r *http.Request) bool {
+		return websocket.SameOrigin(r) || originAllowed(cors.AllowedOrigins, r.Header.Get("Origin"))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := log.LoggerFromCtx(ctx)
+		identity, ok := auth.IdentityFromCtx(ctx)
+		if !ok {
+			err := errors.New("plant socket requires an authenticated client")
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusUnauthorized, newHttpError(err))
+			return
+		}
+
+		sub, err := broker.Subscribe(0)
+		if err != nil {
+			err = fmt.Errorf("subscribe to plant events: %w", err)
+			logger.WarnContext(ctx, err.Error())
+			_ = encode(w, r, http.StatusServiceUnavailable, newHttpError(err))
+			return
+		}
+		defer sub.Close()
+
+		conn, err := websocket.Upgrade(w, r, websocket.Options{
+			MaxMessageBytes: int64(cfg.MaxMessageBytes),
+			// NOTE: clients answer every ping, so silence for two intervals means the connection is gone
+			ReadTimeout:  2 * cfg.PingInterval,
+			WriteTimeout: wsWriteTimeout,
+			CheckOrigin:  checkOrigin,
+		})
+		if err != nil {
+			var handshakeErr *websocket.HandshakeError
+			if errors.As(err, &handshakeErr) {
+				logger.WarnContext(ctx, err.Error())
+				_ = encode(w, r, handshakeErr.Status, newHttpError(err))
+				return
+			}
+			logger.ErrorContext(ctx, fmt.Sprintf("upgrade plant socket: %s", err))
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		logger.InfoContext(ctx, "plant socket connected", slog.String("subject", identity.Subject))
+
+		done := make(chan struct{})
+		defer close(done)
+		messages := make(chan wsMessage)
+		readErr := make(chan error, 1)
+		go func() {
+			for {
+				messageType, data, err := conn.ReadMessage()
+				if err != nil {
+					readErr <- err
+					return
+				}
+				select {
+				case messages <- wsMessage{messageType: messageType, data: data}:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		send := func(res wsResponse) bool {
+			data, err := json.Marshal(res)
+			if err == nil {
+				err = conn.WriteMessage(websocket.TextMessage, data)
+			}
+			if err != nil {
+				logger.DebugContext(ctx, "write plant socket message", slog.String("error", err.Error()))
+				return false
+			}
+			return true
+		}
+		closeGracefully := func(code int, reason string) {
+			_ = conn.WriteClose(code, reason)
+			select {
+			case <-readErr:
+			case <-time.After(wsCloseTimeout):
+			}
+		}
+
+		var filter wsFilter
+		ticker := time.NewTicker(cfg.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg := <-messages:
+				if !send(handleSocketMessage(r, plantStore, careStore, &filter, msg)) {
+					return
+				}
+			case err := <-readErr:
+				logger.InfoContext(ctx, "plant socket disconnected", slog.String("reason", err.Error()))
+				return
+			case e, ok := <-sub.Events():
+				if !ok {
+					if sub.Lagged() {
+						logger.WarnContext(ctx, "plant socket client fell behind, disconnecting")
+						closeGracefully(websocket.CLOSE_TRY_AGAIN_LATER, "too slow to keep up with events")
+						return
+					}
+					closeGracefully(websocket.CLOSE_GOING_AWAY, "server shutting down")
+					return
+				}
+				if filter.matches(e.Plant) && !send(wsResponse{Type: WS_EVENT, Event: &e}) {
+					return
+				}
+			case <-ticker.C:
+				if err := conn.Ping(nil); err != nil {
+					logger.DebugContext(ctx, "ping plant socket", slog.String("error", err.Error()))
+					return
+				}
+			}
+		}
+	})
+}
+
+// handleSocketMessage runs a single client command and returns the reply
+func handleSocketMessage(r *http.Request, plantStore store.Store, careStore store.CareStore, filter *wsFilter, msg wsMessage) wsResponse {
+	if msg.messageType != websocket.TextMessage {
+		return wsResponse{Type: WS_ERROR, Message: "only text messages with JSON commands are supported"}
+	}
+	req, err := decodeJSON[wsRequest](msg.data)
+	if err != nil {
+		return wsResponse{Type: WS_ERROR, Message: err.Error()}
+	}
+
+	switch req.Type {
+	case WS_SUBSCRIBE, WS_UNSUBSCRIBE:
+		if len(req.PlantIDs) == 0 && len(req.Tags) == 0 {
+			return wsResponse{Type: WS_ERROR, ID: req.ID, Message: "plantIds or tags are required"}
+		}
+		if req.Type == WS_SUBSCRIBE {
+			filter.plantIDs = addUnique(filter.plantIDs, req.PlantIDs)
+			filter.tags = addUnique(filter.tags, req.Tags)
+		} else {
+			filter.plantIDs = removeAll(filter.plantIDs, req.PlantIDs)
+			filter.tags = removeAll(filter.tags, req.Tags)
+		}
+		return wsResponse{Type: WS_ACK, ID: req.ID}
+	case WS_LOG_WATERING:
+		if req.PlantID == "" {
+			return wsResponse{Type: WS_ERROR, ID: req.ID, Message: "plantId is required"}
+		}
+		ctx := log.WithPlantID(r.Context(), req.PlantID)
+		logger := log.LoggerFromCtx(ctx)
+
+		plant, err := plantStore.Find(ctx, req.PlantID)
+		if err == nil {
+			_, plant, err = recordCare(ctx, plantStore, careStore, *plant, plants.CareEvent{Type: plants.CareWatering})
+		}
+		if err != nil {
+			err = fmt.Errorf("log watering: %w", err)
+			if !errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+				logger.ErrorContext(ctx, err.Error())
+			}
+			return wsResponse{Type: WS_ERROR, ID: req.ID, Message: err.Error()}
+		}
+
+		logger.InfoContext(ctx, "logged watering")
+		return wsResponse{Type: WS_ACK, ID: req.ID, Plant: plant}
+	}
+
+	return wsResponse{Type: WS_ERROR, ID: req.ID, Message: fmt.Sprintf("unknown message type '%s'", req.Type)}
+}
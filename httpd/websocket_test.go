@@ -0,0 +1,248 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"plants/config"
+	"plants/events"
+	"plants/plants"
+	"plants/store"
+	"plants/websocket"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketURL serves handler and returns the url of its plant socket
+func socketURL(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/plants/socket"
+}
+
+// dialSocket connects to the plant socket as the admin
+func dialSocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, url, http.Header{"Authorization": {"Bearer supersecret"}}, nil, websocket.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func roundTrip(t *testing.T, conn *websocket.Conn, request string) wsResponse {
+	t.Helper()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(request)))
+	return readResponse(t, conn)
+}
+
+func readResponse(t *testing.T, conn *websocket.Conn) wsResponse {
+	t.Helper()
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var res wsResponse
+	require.NoError(t, json.Unmarshal(data, &res))
+	return res
+}
+
+func TestPlantSocketHandshake(t *testing.T) {
+	socket := socketURL(t, newTestAPI(t, nil))
+	u, err := url.Parse(socket)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		header http.Header
+
+		wantCode int
+	}{
+		"requires authentication": {
+			wantCode: http.StatusUnauthorized,
+		},
+		// NOTE: without configured cors origins the cors middleware lets every origin through, the socket doesnt
+		"rejects foreign origin": {
+			header: http.Header{"Authorization": {"Bearer supersecret"}, "Origin": {"https://evil.example.org"}},
+
+			wantCode: http.StatusForbidden,
+		},
+		"accepts pages served by the api": {
+			header: http.Header{"Authorization": {"Bearer supersecret"}, "Origin": {"http://" + u.Host}},
+
+			wantCode: http.StatusSwitchingProtocols,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn, res, err := websocket.Dial(context.Background(), socket, tc.header, nil, websocket.Options{})
+			if err == nil {
+				_ = conn.Close()
+			}
+
+			require.NotNil(t, res, err)
+			assert.Equal(t, tc.wantCode, res.StatusCode)
+		})
+	}
+}
+
+func TestPlantSocketCommands(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	memoryStore := store.NewMemoryStore(nil)
+	plantStore := events.NewPublishingStore(memoryStore, broker)
+	plant, err := plantStore.Create(context.Background(), plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	conn := dialSocket(t, socketURL(t, newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.CareStore = memoryStore
+		deps.Events = broker
+	})))
+
+	tests := map[string]struct {
+		request string
+
+		want wsResponse
+	}{
+		"subscribe": {
+			request: `{"type":"subscribe","id":"1","tags":["herbs"]}`,
+
+			want: wsResponse{Type: WS_ACK, ID: "1"},
+		},
+		"subscribe without filters": {
+			request: `{"type":"subscribe","id":"2"}`,
+
+			want: wsResponse{Type: WS_ERROR, ID: "2", Message: "plantIds or tags are required"},
+		},
+		"unsubscribe": {
+			request: `{"type":"unsubscribe","plantIds":["1"]}`,
+
+			want: wsResponse{Type: WS_ACK},
+		},
+		"log watering of missing plant": {
+			request: `{"type":"logWatering","id":"3","plantId":"missing"}`,
+
+			want: wsResponse{Type: WS_ERROR, ID: "3", Message: "log watering: plant with ID 'missing' does not exist"},
+		},
+		"log watering without plant": {
+			request: `{"type":"logWatering"}`,
+
+			want: wsResponse{Type: WS_ERROR, Message: "plantId is required"},
+		},
+		"unknown type": {
+			request: `{"type":"dance","id":"4"}`,
+
+			want: wsResponse{Type: WS_ERROR, ID: "4", Message: "unknown message type 'dance'"},
+		},
+		"unknown field": {
+			request: `{"type":"subscribe","plant":"1"}`,
+
+			want: wsResponse{Type: WS_ERROR, Message: `decode json: json: unknown field "plant"`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, roundTrip(t, conn, tc.request))
+		})
+	}
+
+	t.Run("log watering", func(t *testing.T) {
+		got := roundTrip(t, conn, `{"type":"logWatering","id":"5","plantId":"`+plant.ID+`"}`)
+		assert.Equal(t, WS_ACK, got.Type)
+		require.NotNil(t, got.Plant)
+		assert.NotNil(t, got.Plant.LastWateredAt)
+
+		stored, err := plantStore.Find(context.Background(), plant.ID)
+		require.NoError(t, err)
+		assert.Equal(t, got.Plant.LastWateredAt, stored.LastWateredAt)
+	})
+}
+
+func TestPlantSocketSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	broker := events.NewBroker(10, 10)
+	memoryStore := store.NewMemoryStore(nil)
+	plantStore := events.NewPublishingStore(memoryStore, broker)
+	basil, err := plantStore.Create(ctx, plants.Plant{Name: "basil", Tags: []string{"herbs"}})
+	require.NoError(t, err)
+	fern, err := plantStore.Create(ctx, plants.Plant{Name: "fern"})
+	require.NoError(t, err)
+	conn := dialSocket(t, socketURL(t, newTestAPI(t, func(_ *config.Server, deps *Dependencies) {
+		deps.PlantStore = plantStore
+		deps.CareStore = memoryStore
+		deps.Events = broker
+	})))
+
+	assert.Equal(t, WS_ACK, roundTrip(t, conn, `{"type":"subscribe","tags":["herbs"]}`).Type)
+	assert.Equal(t, WS_ACK, roundTrip(t, conn, `{"type":"subscribe","plantIds":["`+fern.ID+`"]}`).Type)
+
+	_, err = plantStore.Create(ctx, plants.Plant{Name: "cactus"})
+	require.NoError(t, err)
+	_, err = plantStore.Create(ctx, plants.Plant{Name: "mint", Tags: []string{"herbs", "balcony"}})
+	require.NoError(t, err)
+	_, err = plantStore.Delete(ctx, fern.ID)
+	require.NoError(t, err)
+	// NOTE: watering through the socket publishes an update like any other store change
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"logWatering","plantId":"`+basil.ID+`"}`)))
+
+	// NOTE: the ack and the events race each other, only the order of events is guaranteed
+	var got []string
+	for range 4 {
+		res := readResponse(t, conn)
+		if res.Type == WS_ACK {
+			continue
+		}
+		require.Equal(t, WS_EVENT, res.Type)
+		got = append(got, string(res.Event.Type)+" "+res.Event.Plant.Name)
+	}
+	assert.Equal(t, []string{"created mint", "deleted fern", "updated basil"}, got)
+}
+
+func TestPlantSocketClose(t *testing.T) {
+	tests := map[string]struct {
+		act func(broker *events.Broker, conn *websocket.Conn) error
+
+		wantCode int
+	}{
+		"going away on shutdown": {
+			act: func(broker *events.Broker, _ *websocket.Conn) error {
+				broker.Close()
+				return nil
+			},
+
+			wantCode: websocket.CLOSE_GOING_AWAY,
+		},
+		"message too big": {
+			act: func(_ *events.Broker, conn *websocket.Conn) error {
+				return conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","tags":["`+strings.Repeat("a", 300)+`"]}`))
+			},
+
+			wantCode: websocket.CLOSE_TOO_BIG,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			broker := events.NewBroker(10, 10)
+			conn := dialSocket(t, socketURL(t, newTestAPI(t, func(cfg *config.Server, deps *Dependencies) {
+				cfg.WebSocket.MaxMessageBytes = 256
+				deps.Events = broker
+			})))
+			// NOTE: a round trip makes sure the server is past the handshake
+			assert.Equal(t, WS_ACK, roundTrip(t, conn, `{"type":"subscribe","tags":["herbs"]}`).Type)
+
+			require.NoError(t, tc.act(broker, conn))
+
+			_, _, err := conn.ReadMessage()
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, tc.wantCode, closeErr.Code)
+		})
+	}
+}
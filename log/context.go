@@ -0,0 +1,61 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// attribute keys holding the request trace and the current span IDs
+const TRACE_ID_KEY = "traceId"
+const SPAN_ID_KEY = "spanId"
+
+type traceCtxKey string
+
+const CONTEXT_TRACE traceCtxKey = "ctx.trace"
+
+type trace struct {
+	traceID string
+	spanID  string
+}
+
+// WithTrace stores trace and span IDs in the context, the ContextHandler adds them to every record
+// logged with that context
+func WithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, CONTEXT_TRACE, trace{traceID: traceID, spanID: spanID})
+}
+
+func TraceFromCtx(ctx context.Context) (traceID, spanID string, ok bool) {
+	t, ok := ctx.Value(CONTEXT_TRACE).(trace)
+	return t.traceID, t.spanID, ok
+}
+
+// ContextHandler pulls trace and span IDs out of the context, so they only show up
+// when logging through the *Context methods, e.g. logger.ErrorContext(ctx, ...)
+type ContextHandler struct {
+	next slog.Handler
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if traceID, spanID, ok := TraceFromCtx(ctx); ok {
+		r = r.Clone()
+		r.AddAttrs(slog.String(TRACE_ID_KEY, traceID), slog.String(SPAN_ID_KEY, spanID))
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name)}
+}
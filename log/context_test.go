@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextEnrichment(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewTextHandler(&buf, nil)))
+
+	tests := map[string]struct {
+		ctx func() context.Context
+
+		want    []string
+		wantNot []string
+	}{
+		"trace and span ids from context": {
+			ctx: func() context.Context {
+				return WithTrace(WithLogger(context.Background(), logger), "trace-1", "span-1")
+			},
+
+			want: []string{"traceId=trace-1", "spanId=span-1"},
+		},
+		"attributes attached while passing through layers": {
+			ctx: func() context.Context {
+				ctx := WithLogger(context.Background(), logger)
+				ctx = WithRoute(ctx, "GET /plants/{id}/")
+				ctx = WithUserID(ctx, "admin")
+				return WithPlantID(ctx, "42")
+			},
+
+			want:    []string{`route="GET /plants/{id}/"`, "userId=admin", "plantId=42"},
+			wantNot: []string{"traceId"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf.Reset()
+			ctx := tc.ctx()
+
+			LoggerFromCtx(ctx).InfoContext(ctx, "hello")
+
+			for _, want := range tc.want {
+				assert.Contains(t, buf.String(), want)
+			}
+			for _, wantNot := range tc.wantNot {
+				assert.NotContains(t, buf.String(), wantNot)
+			}
+		})
+	}
+}
+
+func TestLoggerFromCtxFallback(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	logger := LoggerFromCtx(context.Background())
+	assert.Equal(t, slog.Default(), logger)
+	// NOTE: the fallback notice is debug level, so it never shows up at the default info level
+	assert.Empty(t, buf.String())
+}
@@ -2,23 +2,109 @@ package log
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"plants/config"
+	"sync"
 )
 
 type loggerCtxKey string
 
 const CONTEXT_LOGGER loggerCtxKey = "ctx.logger"
 
+// attribute keys used to enrich the context logger as a request moves through the layers
+const USER_ID_KEY = "userId"
+const ROUTE_KEY = "route"
+const PLANT_ID_KEY = "plantId"
+
+var fallbackNotice sync.Once
+
+// LoggerFromCtx returns the request scoped logger, outside of requests (tests, background jobs)
+// it falls back to the global logger and says so once, at debug level
 func LoggerFromCtx(ctx context.Context) *slog.Logger {
 	requestLogger, ok := ctx.Value(CONTEXT_LOGGER).(*slog.Logger)
 	if !ok {
-		slog.Default().Warn("fallback to global logger")
+		fallbackNotice.Do(func() {
+			slog.Default().Debug("no logger in context, falling back to global logger")
+		})
 		requestLogger = slog.Default()
 	}
 	return requestLogger
 }
 
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, CONTEXT_LOGGER, logger)
+}
+
+// With adds attributes to the context logger, every later LoggerFromCtx call includes them
+func With(ctx context.Context, attrs ...any) context.Context {
+	return WithLogger(ctx, LoggerFromCtx(ctx).With(attrs...))
+}
+
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return With(ctx, slog.String(USER_ID_KEY, userID))
+}
+
+func WithRoute(ctx context.Context, pattern string) context.Context {
+	return With(ctx, slog.String(ROUTE_KEY, pattern))
+}
+
+func WithPlantID(ctx context.Context, plantID string) context.Context {
+	return With(ctx, slog.String(PLANT_ID_KEY, plantID))
+}
+
 func NoopLogger() *slog.Logger {
-	return slog.New(slog.NewJSONHandler(io.Discard, nil))
+	return slog.New(NewHandler(io.Discard, config.Log{Format: config.LOG_FORMAT_JSON}, nil))
+}
+
+// NewHandler builds the handler used by the whole service, level is usually a *slog.LevelVar
+// so it can be changed at runtime, nil means slog.LevelInfo
+func NewHandler(w io.Writer, cfg config.Log, level slog.Leveler) slog.Handler {
+	return newHandler(w, cfg, &slog.HandlerOptions{
+		Level:     level,
+		AddSource: cfg.AddSource,
+	})
+}
+
+func newHandler(w io.Writer, cfg config.Log, opts *slog.HandlerOptions) slog.Handler {
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if cfg.Format == config.LOG_FORMAT_JSON {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	if cfg.Redact {
+		handler = NewRedactingHandler(handler, DefaultRedactOptions)
+	}
+
+	// NOTE: sampling goes before redaction, so dropped records dont pay for it
+	if cfg.SampleFirst > 0 {
+		handler = NewSamplingHandler(handler, SampleOptions{
+			Interval:   cfg.SampleInterval,
+			First:      cfg.SampleFirst,
+			Thereafter: cfg.SampleThereafter,
+		})
+	}
+
+	return NewContextHandler(handler)
+}
+
+// OpenOutput resolves a config.Log output destination, the returned close func
+// has to be called on shutdown and is a noop for stdout/stderr
+func OpenOutput(dest string, stdout, stderr io.Writer) (io.Writer, func() error, error) {
+	noop := func() error { return nil }
+	switch dest {
+	case config.LOG_OUTPUT_STDERR:
+		return stderr, noop, nil
+	case config.LOG_OUTPUT_STDOUT:
+		return stdout, noop, nil
+	}
+
+	f, err := os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, noop, fmt.Errorf("open log output: %w", err)
+	}
+
+	return f, f.Close, nil
 }
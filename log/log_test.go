@@ -0,0 +1,63 @@
+package log
+
+import (
+	"bytes"
+	"log/slog"
+	"plants/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHandler(t *testing.T) {
+	tests := map[string]struct {
+		cfg   config.Log
+		level slog.Level
+
+		want    string
+		wantNot string
+	}{
+		"text format": {
+			cfg:   config.Log{Format: config.LOG_FORMAT_TEXT},
+			level: slog.LevelInfo,
+
+			want:    "level=INFO msg=hello",
+			wantNot: "debug message",
+		},
+		"json format": {
+			cfg:   config.Log{Format: config.LOG_FORMAT_JSON},
+			level: slog.LevelInfo,
+
+			want: `"msg":"hello"`,
+		},
+		"debug level": {
+			cfg:   config.Log{Format: config.LOG_FORMAT_TEXT},
+			level: slog.LevelDebug,
+
+			want: "debug message",
+		},
+		"source location": {
+			cfg:   config.Log{Format: config.LOG_FORMAT_TEXT, AddSource: true},
+			level: slog.LevelInfo,
+
+			want: "log_test.go",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			level := new(slog.LevelVar)
+			level.Set(tc.level)
+			logger := slog.New(NewHandler(&buf, tc.cfg, level))
+
+			logger.Debug("debug message")
+			logger.Info("hello")
+
+			assert.Contains(t, buf.String(), tc.want)
+			if tc.wantNot != "" {
+				assert.NotContains(t, buf.String(), tc.wantNot)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// REDACTED replaces masked values in log output
+const REDACTED = "[REDACTED]"
+
+type RedactOptions struct {
+	// Keys are attribute keys masked at any nesting level, matched case-insensitively
+	Keys []string
+	// QueryParams are masked inside *url.URL attribute values
+	QueryParams []string
+	// Headers are masked inside http.Header attribute values
+	Headers []string
+}
+
+var DefaultRedactOptions = RedactOptions{
+	Keys:        []string{"password", "secret", "token", "authorization", "cookie", "apiKey", "api_key", "notes"},
+	QueryParams: []string{"token", "access_token", "api_key", "apikey", "key", "password"},
+	Headers:     []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "Proxy-Authorization"},
+}
+
+// Secret is a string that always logs as REDACTED, no matter the attribute key
+type Secret string
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(REDACTED)
+}
+
+// RedactingHandler masks sensitive attributes before they reach the wrapped handler.
+// Domain types take part by implementing slog.LogValuer, their resolved values
+// are redacted by key like any other attribute.
+type RedactingHandler struct {
+	next        slog.Handler
+	keys        map[string]struct{}
+	queryParams map[string]struct{}
+	headers     map[string]struct{}
+}
+
+func NewRedactingHandler(next slog.Handler, opts RedactOptions) *RedactingHandler {
+	return &RedactingHandler{
+		next:        next,
+		keys:        lowerSet(opts.Keys),
+		queryParams: lowerSet(opts.QueryParams),
+		headers:     lowerSet(opts.Headers),
+	}
+}
+
+func lowerSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[strings.ToLower(v)] = struct{}{}
+	}
+	return set
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+
+	return &RedactingHandler{next: h.next.WithAttrs(redacted), keys: h.keys, queryParams: h.queryParams, headers: h.headers}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), keys: h.keys, queryParams: h.queryParams, headers: h.headers}
+}
+
+func (h *RedactingHandler) redact(a slog.Attr) slog.Attr {
+	// NOTE: resolve first, so LogValuer implementations on domain types get inspected too
+	a.Value = a.Value.Resolve()
+	if _, ok := h.keys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, REDACTED)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case *url.URL:
+			return slog.String(a.Key, h.redactURL(v))
+		case url.URL:
+			return slog.String(a.Key, h.redactURL(&v))
+		case http.Header:
+			return slog.Attr{Key: a.Key, Value: h.redactHeader(v)}
+		}
+	}
+
+	return a
+}
+
+// redactURL masks query parameter values while keeping their order, so logs stay readable
+func (h *RedactingHandler) redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if _, ok := h.queryParams[strings.ToLower(key)]; ok {
+			pairs[i] = rawKey + "=" + REDACTED
+		}
+	}
+
+	masked := *u
+	masked.RawQuery = strings.Join(pairs, "&")
+	return masked.String()
+}
+
+func (h *RedactingHandler) redactHeader(header http.Header) slog.Value {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	// NOTE: sorted so the output doesnt depend on map iteration order
+	slices.Sort(names)
+
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		if _, ok := h.headers[strings.ToLower(name)]; ok {
+			attrs = append(attrs, slog.String(name, REDACTED))
+			continue
+		}
+		attrs = append(attrs, slog.String(name, strings.Join(header[name], ", ")))
+	}
+
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"bytes"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"plants/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// customer is a domain type that decides itself what gets logged
+type customer struct {
+	Name  string
+	Email string
+	Notes string
+}
+
+func (c customer) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", c.Name),
+		slog.String("notes", c.Notes),
+	)
+}
+
+func TestRedactingHandlerGolden(t *testing.T) {
+	var buf bytes.Buffer
+	// NOTE: time is dropped so the output is stable between runs
+	handler := newHandler(&buf, config.Log{Format: config.LOG_FORMAT_JSON, Redact: true}, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger := slog.New(handler)
+
+	u, err := url.Parse("/api/v1/plants/?token=abc123&page=2&API_KEY=xyz")
+	require.NoError(t, err)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer abc123")
+	header.Set("Accept", "application/json")
+	header.Set("X-Api-Key", "xyz")
+
+	logger.Info("GET /api/v1/plants/", slog.Any("url", u), slog.Any("headers", header))
+	logger.Info("login", slog.String("user", "bob"), slog.String("Password", "hunter2"))
+	logger.With(slog.String("token", "abc123")).Info("scoped attrs are redacted too")
+	logger.WithGroup("request").Info("grouped", slog.Group("auth", slog.String("apiKey", "xyz"), slog.String("method", "key")))
+	logger.Info("domain type", slog.Any("customer", customer{Name: "bob", Email: "bob@example.com", Notes: "allergic to ferns"}))
+	logger.Info("secret type", slog.Any("dbUrl", Secret("postgres://user:pass@db")))
+
+	golden := filepath.Join("testdata", "redact.golden")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+
+	assert.Equal(t, string(want), buf.String())
+}
+
+func TestRedactingHandlerDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newHandler(&buf, config.Log{Format: config.LOG_FORMAT_TEXT}, &slog.HandlerOptions{}))
+
+	logger.Info("login", slog.String("password", "hunter2"))
+
+	assert.Contains(t, buf.String(), "hunter2")
+}
@@ -0,0 +1,226 @@
+package log
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SampleOptions struct {
+	// Interval is the window identical messages are counted in
+	Interval time.Duration
+	// First identical messages per interval are always kept
+	First int
+	// Thereafter every Nth identical message is kept, 0 drops all of them
+	Thereafter int
+	// Now is the clock, nil means time.Now
+	Now func() time.Time
+}
+
+// SamplingHandler protects the log pipeline from floods of identical records,
+// e.g. an Error line per request during a burst of 404s. Records are identical when
+// their level and message match, values quoted like 'abc' are left out, e.g. the IDs
+// in "plant with ID '…' does not exist".
+type SamplingHandler struct {
+	next    slog.Handler
+	opts    SampleOptions
+	state   *sampleState
+	traceID string
+}
+
+type sampleKey struct {
+	level   slog.Level
+	message string
+}
+
+// sampleState is shared between a handler and all its WithAttrs/WithGroup children
+type sampleState struct {
+	// root is the handler summaries go to, without the attrs of whichever child logged last
+	root        slog.Handler
+	mu          sync.Mutex
+	windowStart time.Time
+	// window is incremented on every flush so stale summary timers can be ignored
+	window     int
+	counts     map[sampleKey]int
+	dropped    map[sampleKey]int
+	seenTraces map[string]struct{}
+}
+
+func NewSamplingHandler(next slog.Handler, opts SampleOptions) *SamplingHandler {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &SamplingHandler{
+		next: next,
+		opts: opts,
+		state: &sampleState{
+			root:        next,
+			windowStart: opts.Now(),
+			counts:      make(map[sampleKey]int),
+			dropped:     make(map[sampleKey]int),
+			seenTraces:  make(map[string]struct{}),
+		},
+	}
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.keep(ctx, r) {
+		return nil
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *SamplingHandler) keep(ctx context.Context, r slog.Record) bool {
+	now := h.opts.Now()
+	h.state.mu.Lock()
+	if now.Sub(h.state.windowStart) >= h.opts.Interval {
+		summary := h.rollover(now)
+		h.state.mu.Unlock()
+		// NOTE: the summary isnt about the record that happened to end the window, so it gets none of its context
+		h.emitSummary(context.Background(), summary)
+		h.state.mu.Lock()
+	}
+	defer h.state.mu.Unlock()
+
+	if r.Level >= slog.LevelError {
+		if traceID := h.recordTraceID(r); traceID != "" {
+			if _, seen := h.state.seenTraces[traceID]; !seen {
+				h.state.seenTraces[traceID] = struct{}{}
+				return true
+			}
+		}
+	}
+
+	key := sampleKey{level: r.Level, message: withoutQuoted(r.Message)}
+	h.state.counts[key]++
+	n := h.state.counts[key]
+	if n <= h.opts.First {
+		return true
+	}
+	if h.opts.Thereafter > 0 && (n-h.opts.First)%h.opts.Thereafter == 0 {
+		return true
+	}
+
+	if len(h.state.dropped) == 0 {
+		// NOTE: without this a quiet period after a flood would never report what was dropped
+		window := h.state.window
+		time.AfterFunc(h.opts.Interval, func() { h.flushWindow(window) })
+	}
+	h.state.dropped[key]++
+
+	return false
+}
+
+// withoutQuoted replaces values quoted like 'abc' with '…', so messages that only differ in IDs match
+func withoutQuoted(message string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(message, '\'')
+		if start < 0 {
+			break
+		}
+		length := strings.IndexByte(message[start+1:], '\'')
+		if length < 0 {
+			break
+		}
+		b.WriteString(message[:start])
+		b.WriteString("'…'")
+		message = message[start+length+2:]
+	}
+	b.WriteString(message)
+	return b.String()
+}
+
+func (h *SamplingHandler) recordTraceID(r slog.Record) string {
+	traceID := h.traceID
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == TRACE_ID_KEY {
+			traceID = a.Value.String()
+			return false
+		}
+		return true
+	})
+
+	return traceID
+}
+
+// rollover starts a new window and returns the dropped counts of the previous one,
+// it must be called with the state lock held
+func (h *SamplingHandler) rollover(now time.Time) map[sampleKey]int {
+	summary := h.state.dropped
+	h.state.windowStart = now
+	h.state.window++
+	h.state.counts = make(map[sampleKey]int)
+	h.state.dropped = make(map[sampleKey]int)
+	h.state.seenTraces = make(map[string]struct{})
+
+	return summary
+}
+
+func (h *SamplingHandler) emitSummary(ctx context.Context, summary map[sampleKey]int) {
+	keys := make([]sampleKey, 0, len(summary))
+	for key := range summary {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b sampleKey) int {
+		return cmp.Or(cmp.Compare(a.level, b.level), cmp.Compare(a.message, b.message))
+	})
+
+	for _, key := range keys {
+		dropped := summary[key]
+		r := slog.NewRecord(h.opts.Now(), slog.LevelWarn, "dropped sampled log records", 0)
+		r.AddAttrs(
+			slog.String("sampledMessage", key.message),
+			slog.String("sampledLevel", key.level.String()),
+			slog.Int("dropped", dropped),
+			slog.String("interval", h.opts.Interval.String()),
+		)
+		_ = h.state.root.Handle(ctx, r)
+	}
+}
+
+func (h *SamplingHandler) flushWindow(window int) {
+	h.state.mu.Lock()
+	if h.state.window != window {
+		h.state.mu.Unlock()
+		return
+	}
+	summary := h.rollover(h.opts.Now())
+	h.state.mu.Unlock()
+
+	h.emitSummary(context.Background(), summary)
+}
+
+// Flush ends the current window and emits its summary of dropped records right away
+func (h *SamplingHandler) Flush(ctx context.Context) {
+	h.state.mu.Lock()
+	summary := h.rollover(h.opts.Now())
+	h.state.mu.Unlock()
+
+	h.emitSummary(ctx, summary)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := &SamplingHandler{next: h.next.WithAttrs(attrs), opts: h.opts, state: h.state, traceID: h.traceID}
+	for _, a := range attrs {
+		if a.Key == TRACE_ID_KEY {
+			child.traceID = a.Value.String()
+		}
+	}
+
+	return child
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{next: h.next.WithGroup(name), opts: h.opts, state: h.state, traceID: h.traceID}
+}
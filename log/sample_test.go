@@ -0,0 +1,202 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock only moves when told to, so sampling windows are deterministic
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+type logLine struct {
+	Level          string `json:"level"`
+	Msg            string `json:"msg"`
+	TraceID        string `json:"traceId"`
+	SampledMessage string `json:"sampledMessage"`
+	Dropped        int    `json:"dropped"`
+}
+
+func readLines(t *testing.T, buf *bytes.Buffer) []logLine {
+	t.Helper()
+	var lines []logLine
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line logLine
+		require.NoError(t, json.Unmarshal([]byte(raw), &line))
+		lines = append(lines, line)
+	}
+	buf.Reset()
+	return lines
+}
+
+func newTestSampler(buf *bytes.Buffer, clock *fakeClock) *SamplingHandler {
+	return NewSamplingHandler(slog.NewJSONHandler(buf, nil), SampleOptions{
+		// NOTE: long interval so the real-time summary timer never fires during tests
+		Interval:   time.Hour,
+		First:      3,
+		Thereafter: 5,
+		Now:        clock.Now,
+	})
+}
+
+func TestSamplingHandler(t *testing.T) {
+	tests := map[string]struct {
+		log func(logger *slog.Logger)
+
+		wantKept int
+	}{
+		"keeps first n identical records": {
+			log: func(logger *slog.Logger) {
+				for i := 0; i < 3; i++ {
+					logger.Info("request")
+				}
+			},
+
+			wantKept: 3,
+		},
+		"samples one in m after first n": {
+			log: func(logger *slog.Logger) {
+				// 3 first + every 5th of the remaining 20
+				for i := 0; i < 23; i++ {
+					logger.Info("request")
+				}
+			},
+
+			wantKept: 3 + 4,
+		},
+		"different messages are counted separately": {
+			log: func(logger *slog.Logger) {
+				for i := 0; i < 4; i++ {
+					logger.Info("request")
+					logger.Info("other")
+				}
+			},
+
+			wantKept: 6,
+		},
+		"different levels are counted separately": {
+			log: func(logger *slog.Logger) {
+				for i := 0; i < 4; i++ {
+					logger.Info("request")
+					logger.Warn("request")
+				}
+			},
+
+			wantKept: 6,
+		},
+		"messages differing in quoted values are counted together": {
+			log: func(logger *slog.Logger) {
+				for _, id := range []string{"1", "2", "3", "4", "5"} {
+					logger.Warn("plant with ID '" + id + "' does not exist")
+				}
+			},
+
+			wantKept: 3,
+		},
+		"errors with new trace ids are always kept": {
+			log: func(logger *slog.Logger) {
+				for _, traceID := range []string{"a", "b", "c", "d", "e", "f"} {
+					logger.With(slog.String(TRACE_ID_KEY, traceID)).Error("plant not found")
+				}
+			},
+
+			wantKept: 6,
+		},
+		"errors with repeated trace id are sampled": {
+			log: func(logger *slog.Logger) {
+				for i := 0; i < 6; i++ {
+					logger.Error("plant not found", slog.String(TRACE_ID_KEY, "same"))
+				}
+			},
+
+			// first one is kept as a new trace, the rest go through regular sampling
+			wantKept: 1 + 3,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			logger := slog.New(newTestSampler(&buf, clock))
+
+			tc.log(logger)
+
+			assert.Len(t, readLines(t, &buf), tc.wantKept)
+		})
+	}
+}
+
+func TestSamplingHandlerSummary(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	handler := newTestSampler(&buf, clock)
+	logger := slog.New(handler)
+
+	for i := 0; i < 10; i++ {
+		logger.Info("request")
+	}
+	// 3 first + the 5th after them, 6 dropped
+	assert.Len(t, readLines(t, &buf), 4)
+
+	// the next window starts with a summary of the previous one and fresh counters
+	clock.Advance(time.Hour)
+	logger.Info("request")
+	lines := readLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "dropped sampled log records", lines[0].Msg)
+	assert.Equal(t, "request", lines[0].SampledMessage)
+	assert.Equal(t, 6, lines[0].Dropped)
+	assert.Equal(t, "request", lines[1].Msg)
+
+	// flushing without drops emits nothing
+	handler.Flush(context.Background())
+	assert.Empty(t, readLines(t, &buf))
+
+	for i := 0; i < 5; i++ {
+		logger.Info("request")
+	}
+	handler.Flush(context.Background())
+	lines = readLines(t, &buf)
+	require.Len(t, lines, 4)
+	assert.Equal(t, 2, lines[3].Dropped)
+}
+
+func TestSamplingHandlerSummaryContext(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	logger := slog.New(NewContextHandler(newTestSampler(&buf, clock)))
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		logger.With(slog.String(PLANT_ID_KEY, id)).Warn("plant with ID '" + id + "' does not exist")
+	}
+	assert.Len(t, readLines(t, &buf), 3)
+
+	// NOTE: the request ending the window has its own trace and plant, the summary belongs to neither
+	clock.Advance(time.Hour)
+	ctx := WithTrace(context.Background(), "trace", "span")
+	logger.With(slog.String(PLANT_ID_KEY, "f")).WarnContext(ctx, "other")
+	var summary map[string]any
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(buf.String(), "\n", 2)[0]), &summary))
+	assert.Equal(t, "dropped sampled log records", summary["msg"])
+	assert.Equal(t, "plant with ID '…' does not exist", summary["sampledMessage"])
+	assert.EqualValues(t, 2, summary["dropped"])
+	assert.NotContains(t, summary, TRACE_ID_KEY)
+	assert.NotContains(t, summary, PLANT_ID_KEY)
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"plants/httpd"
+	// NOTE: embedded so time zone queries work in minimal containers without a zoneinfo database
+	_ "time/tzdata"
 )
 
 // run - starts the http daemon with a cancellable context
@@ -22,6 +26,7 @@ func run(
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, os.Kill)
 	defer cancel()
 
+	// NOTE: a single instance, the default in-memory elector is enough to keep scheduled jobs from overlapping
 	if err := httpd.Run(ctx, args, getenv, stdin, stdout, stderr); err != nil {
 		return err
 	}
@@ -32,7 +37,12 @@ func run(
 func main() {
 	ctx := context.Background()
 	// NOTE: in main() we use all the os defaults
-	if err := run(ctx, os.Args, os.Getenv, os.Stdin, os.Stdout, os.Stderr); err != nil {
+	err := run(ctx, os.Args, os.Getenv, os.Stdin, os.Stdout, os.Stderr)
+	// NOTE: -h already printed the usage, asking for help isnt a failure
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
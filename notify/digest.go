@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"plants/plants"
+	"time"
+)
+
+// Digest is the data of the morning digest template
+type Digest struct {
+	UserID string
+	// Date is the day of the digest, e.g. "Monday, 6 May 2024"
+	Date  string
+	Items []DigestItem
+}
+
+type DigestItem struct {
+	PlantName string
+	Type      plants.CareType
+	// DaysOverdue is how many whole days ago care was due, Never is set when it was never logged
+	DaysOverdue int
+	Never       bool
+}
+
+// BuildDigest picks the overdue care the user wants to hear about, the digest has no items
+// when there is nothing to send. Days are counted in loc.
+func BuildDigest(prefs Preferences, overdue []plants.OverdueCare, plantList []plants.Plant, now time.Time, loc *time.Location) Digest {
+	now = now.In(loc)
+	digest := Digest{UserID: prefs.UserID, Date: now.Format("Monday, 2 January 2006")}
+	byID := make(map[string]plants.Plant, len(plantList))
+	for _, p := range plantList {
+		byID[p.ID] = p
+	}
+
+	for _, o := range overdue {
+		plant, ok := byID[o.PlantID]
+		if !ok || (len(prefs.Tags) > 0 && !plant.HasAnyTag(prefs.Tags)) {
+			continue
+		}
+		item := DigestItem{PlantName: o.PlantName, Type: o.Type, Never: o.DueAt == nil}
+		if o.DueAt != nil {
+			item.DaysOverdue = daysBetween(o.DueAt.In(loc), now)
+		}
+		digest.Items = append(digest.Items, item)
+	}
+	return digest
+}
+
+// daysBetween counts calendar days, so care due late yesterday is 1 day overdue this morning
+func daysBetween(from, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"plants/alerts"
+	"plants/plants"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDigest(t *testing.T) {
+	riga, err := time.LoadLocation("Europe/Riga")
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 6, 5, 0, 0, 0, time.UTC)
+	lateYesterday := time.Date(2024, 5, 5, 22, 0, 0, 0, time.UTC)
+	lastWeek := time.Date(2024, 4, 29, 8, 0, 0, 0, time.UTC)
+	plantList := []plants.Plant{
+		{ID: "1", Name: "fern", Tags: []string{"office"}},
+		{ID: "2", Name: "cactus", Tags: []string{"home"}},
+	}
+	overdue := []plants.OverdueCare{
+		{PlantID: "1", PlantName: "fern", Type: plants.CareFertilizing},
+		{PlantID: "2", PlantName: "cactus", Type: plants.CareWatering, DueAt: &lastWeek},
+		{PlantID: "1", PlantName: "fern", Type: plants.CareWatering, DueAt: &lateYesterday},
+		// NOTE: deleted since
+		{PlantID: "3", PlantName: "ivy", Type: plants.CareWatering, DueAt: &lastWeek},
+	}
+
+	tests := map[string]struct {
+		prefs Preferences
+		loc   *time.Location
+
+		want Digest
+	}{
+		"all plants": {
+			prefs: Preferences{UserID: "jane"},
+			loc:   time.UTC,
+
+			want: Digest{UserID: "jane", Date: "Monday, 6 May 2024", Items: []DigestItem{
+				{PlantName: "fern", Type: plants.CareFertilizing, Never: true},
+				{PlantName: "cactus", Type: plants.CareWatering, DaysOverdue: 7},
+				{PlantName: "fern", Type: plants.CareWatering, DaysOverdue: 1},
+			}},
+		},
+		"days are counted in the time zone": {
+			prefs: Preferences{UserID: "jane", Tags: []string{"office"}},
+			loc:   riga,
+
+			want: Digest{UserID: "jane", Date: "Monday, 6 May 2024", Items: []DigestItem{
+				{PlantName: "fern", Type: plants.CareFertilizing, Never: true},
+				{PlantName: "fern", Type: plants.CareWatering, DaysOverdue: 0},
+			}},
+		},
+		"nothing to send": {
+			prefs: Preferences{UserID: "jane", Tags: []string{"garden"}},
+			loc:   time.UTC,
+
+			want: Digest{UserID: "jane", Date: "Monday, 6 May 2024"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := BuildDigest(tc.prefs, overdue, plantList, now, tc.loc)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	templates, err := NewTemplates()
+	require.NoError(t, err)
+
+	got, err := templates.Render("digest", Digest{UserID: "jane", Date: "Monday, 6 May 2024", Items: []DigestItem{
+		{PlantName: "<b>fern</b>", Type: plants.CareWatering, DaysOverdue: 2},
+		{PlantName: "cactus", Type: plants.CareRepotting, Never: true},
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2 plant(-s) overdue for care", got.Subject)
+	assert.Equal(t, `Good morning,
+
+these plants are overdue for care on Monday, 6 May 2024:
+
+- <b>fern</b>: watering 2 day(-s) overdue
+- cactus: repotting was never logged
+
+You get this digest because it is enabled in your notification preferences.
+`, got.Text)
+	assert.Contains(t, got.HTML, "<li><strong>&lt;b&gt;fern&lt;/b&gt;</strong>: watering 2 day(-s) overdue</li>")
+	assert.Contains(t, got.HTML, "<li><strong>cactus</strong>: repotting was never logged</li>")
+
+	_, err = templates.Render("missing", nil)
+	assert.EqualError(t, err, "unknown message template 'missing'")
+}
+
+func TestRenderAlert(t *testing.T) {
+	templates, err := NewTemplates()
+	require.NoError(t, err)
+	firedAt := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	resolvedAt := firedAt.Add(90 * time.Minute)
+	value := 15.5
+	alert := alerts.Alert{RuleName: "dry", PlantName: "<b>fern</b>", State: alerts.StateFiring, Value: &value, FiredAt: &firedAt}
+
+	got, err := templates.Render("alert", alert)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[firing] dry: <b>fern</b>", got.Subject)
+	assert.Equal(t, `Hello,
+
+the alert "dry" for <b>fern</b> is firing since 2024-05-10 08:00 UTC, the last value was 15.5.
+
+You get this notification because alerts are enabled in your notification preferences.
+`, got.Text)
+	assert.Contains(t, got.HTML, "<p>the alert <strong>dry</strong> for <strong>&lt;b&gt;fern&lt;/b&gt;</strong> is firing since 2024-05-10 08:00 UTC, the last value was 15.5.</p>")
+
+	alert.State, alert.Value, alert.ResolvedAt = alerts.StateResolved, nil, &resolvedAt
+	got, err = templates.Render("alert", alert)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[resolved] dry: <b>fern</b>", got.Subject)
+	assert.Contains(t, got.Text, `the alert "dry" for <b>fern</b> is resolved since 2024-05-10 09:30 UTC.`)
+}
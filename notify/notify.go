@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+)
+
+// notification channels, every channel is delivered by its own Notifier
+const CHANNEL_EMAIL = "email"
+
+var channels = []string{CHANNEL_EMAIL}
+
+// DefaultChannels are used when preferences dont list any
+var DefaultChannels = []string{CHANNEL_EMAIL}
+
+// ErrNoAddress is returned by a Notifier when the preferences dont have an address for its channel
+var ErrNoAddress = errors.New("recipient has no address for this channel")
+
+// Message is a rendered notification, channels that cant show HTML use Text
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers messages over a single channel, it picks the address it needs out of the
+// recipient's preferences, so a new channel only needs a new field there
+type Notifier interface {
+	Notify(ctx context.Context, to Preferences, msg Message) error
+}
+
+// Preferences are the notification settings of a single user
+type Preferences struct {
+	// UserID is the subject of the authenticated user
+	UserID string `json:"userId"`
+	// Channels are the channels notifications are sent over, CHANNEL_* values, nil means DefaultChannels
+	Channels []string `json:"channels"`
+	Email    string   `json:"email,omitempty"`
+	// Digest opts into the morning digest of overdue plants
+	Digest bool `json:"digest"`
+	// Alerts opts into notifications when alert rules fire and resolve
+	Alerts bool `json:"alerts"`
+	// Tags limits notifications to plants with any of these tags, empty means all plants
+	Tags []string `json:"tags,omitempty"`
+}
+
+func (p Preferences) Valid() map[string]string {
+	problems := make(map[string]string)
+	if p.Channels == nil {
+		p.Channels = DefaultChannels
+	}
+	for _, channel := range p.Channels {
+		if !slices.Contains(channels, channel) {
+			problems["channels"] = fmt.Sprintf("channels must be any of: %s", CHANNEL_EMAIL)
+		}
+	}
+
+	if slices.Contains(p.Channels, CHANNEL_EMAIL) || p.Email != "" {
+		if addr, err := mail.ParseAddress(p.Email); err != nil || addr.Name != "" {
+			problems["email"] = "email must be a plain address like jane@example.com"
+		}
+	}
+
+	if slices.Contains(p.Tags, "") {
+		problems["tags"] = "tags cannot be empty"
+	}
+
+	return problems
+}
@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"plants/log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SMTPOptions struct {
+	Host string
+	Port string
+	// Username and Password enable PLAIN authentication, which net/smtp only allows over TLS or to localhost
+	Username string
+	Password string
+	// From is the sender, e.g. "Plants <plants@example.com>"
+	From string
+	// Attempts is how many times a message is sent before giving up, only temporary (4xx) failures are retried
+	Attempts int
+	// Backoff is the wait before the first retry, it doubles with every retry
+	Backoff time.Duration
+	// Timeout caps a single attempt
+	Timeout time.Duration
+	// TLSConfig is used when the server offers STARTTLS, nil verifies the server against the system roots
+	TLSConfig *tls.Config
+}
+
+// SMTPSender is the Notifier of CHANNEL_EMAIL
+type SMTPSender struct {
+	opts SMTPOptions
+	from *mail.Address
+	now  func() time.Time
+}
+
+func NewSMTPSender(opts SMTPOptions) (*SMTPSender, error) {
+	from, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("parse smtp from address: %w", err)
+	}
+	if opts.Attempts < 1 {
+		opts.Attempts = 1
+	}
+	return &SMTPSender{opts: opts, from: from, now: time.Now}, nil
+}
+
+func (s *SMTPSender) Notify(ctx context.Context, to Preferences, msg Message) error {
+	if to.Email == "" {
+		return ErrNoAddress
+	}
+	body, err := s.build(to.Email, msg)
+	if err != nil {
+		return fmt.Errorf("build email: %w", err)
+	}
+
+	logger := log.LoggerFromCtx(ctx)
+	backoff := s.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err = s.send(ctx, to.Email, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.opts.Attempts || permanent(err) {
+			return fmt.Errorf("send email after %d attempt(-s): %w", attempt, err)
+		}
+
+		logger.WarnContext(ctx, fmt.Sprintf("send email: %s", err), slog.Int("attempt", attempt), slog.Duration("retryIn", backoff))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("send email: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// permanent reports whether the server rejected the message for good, like an unknown mailbox
+func permanent(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
+
+func (s *SMTPSender) send(ctx context.Context, to string, body []byte) error {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.opts.Host, s.opts.Port))
+	if err != nil {
+		return err
+	}
+	// NOTE: net/smtp doesnt take a context, so an expired deadline is what unblocks a stuck server
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		tlsConfig := &tls.Config{ServerName: s.opts.Host}
+		if s.opts.TLSConfig != nil {
+			tlsConfig = s.opts.TLSConfig.Clone()
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if s.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return fmt.Errorf("authenticate: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// build writes a multipart/alternative email, so clients without HTML support show the text part
+func (s *SMTPSender) build(to string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+	headers := []struct{ key, value string }{
+		{"From", s.from.String()},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", s.now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.New().String(), s.opts.Host)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%s", parts.Boundary())},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h.key, h.value)
+	}
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
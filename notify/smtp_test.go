@@ -0,0 +1,237 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"plants/log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP is an in-process SMTP server that speaks just enough of the protocol for net/smtp
+type fakeSMTP struct {
+	host, port string
+
+	mu       sync.Mutex
+	mails    []fakeMail
+	attempts int
+	// replies answers DATA commands in order before mails are accepted, like "451 try again later"
+	replies []string
+}
+
+func newFakeSMTP(t *testing.T, replies ...string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+
+	s := &fakeSMTP{host: host, port: port, replies: replies}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 fake ESMTP")
+	var current fakeMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 fake")
+		case "MAIL":
+			current = fakeMail{from: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			current.to = append(current.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			s.mu.Lock()
+			s.attempts++
+			var reply string
+			if len(s.replies) > 0 {
+				reply, s.replies = s.replies[0], s.replies[1:]
+			}
+			s.mu.Unlock()
+			if reply != "" {
+				_ = tp.PrintfLine("%s", reply)
+				continue
+			}
+
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, current)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			_ = tp.PrintfLine("250 ok")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) received() ([]fakeMail, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mails, s.attempts
+}
+
+func newTestSender(t *testing.T, server *fakeSMTP) *SMTPSender {
+	t.Helper()
+	sender, err := NewSMTPSender(SMTPOptions{
+		Host:     server.host,
+		Port:     server.port,
+		From:     "Plants <plants@example.com>",
+		Attempts: 3,
+		Backoff:  time.Millisecond,
+		Timeout:  5 * time.Second,
+	})
+	require.NoError(t, err)
+	return sender
+}
+
+func TestSMTPSenderDelivers(t *testing.T) {
+	slog.SetDefault(log.NoopLogger())
+	server := newFakeSMTP(t)
+	sender := newTestSender(t, server)
+
+	err := sender.Notify(context.Background(), Preferences{UserID: "jane", Email: "jane@example.com"}, Message{
+		Subject: "Zalā lapa",
+		Text:    "plain text\n.\nwith a lone dot",
+		HTML:    "<p>html</p>",
+	})
+	require.NoError(t, err)
+
+	mails, _ := server.received()
+	require.Len(t, mails, 1)
+	assert.Equal(t, "plants@example.com", mails[0].from)
+	assert.Equal(t, []string{"jane@example.com"}, mails[0].to)
+
+	msg, err := mail.ReadMessage(strings.NewReader(mails[0].data))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Zalā lapa", subject)
+	assert.Equal(t, `"Plants" <plants@example.com>`, msg.Header.Get("From"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		// NOTE: the multipart reader decodes quoted-printable
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		bodies = append(bodies, part.Header.Get("Content-Type")+": "+strings.ReplaceAll(string(body), "\r\n", "\n"))
+	}
+	assert.Equal(t, []string{
+		"text/plain; charset=utf-8: plain text\n.\nwith a lone dot",
+		"text/html; charset=utf-8: <p>html</p>",
+	}, bodies)
+}
+
+func TestSMTPSenderRetries(t *testing.T) {
+	tests := map[string]struct {
+		replies []string
+
+		wantErr      string
+		wantMails    int
+		wantAttempts int
+	}{
+		"temporary failures are retried": {
+			replies: []string{"451 try again later", "421 too busy"},
+
+			wantMails:    1,
+			wantAttempts: 3,
+		},
+		"gives up after all attempts": {
+			replies: []string{"451 try again later", "451 try again later", "451 try again later"},
+
+			wantErr:      `send email after 3 attempt(-s): 451 "try again later"`,
+			wantAttempts: 3,
+		},
+		"permanent failures are not retried": {
+			replies: []string{"550 no such mailbox"},
+
+			wantErr:      `send email after 1 attempt(-s): 550 "no such mailbox"`,
+			wantAttempts: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			slog.SetDefault(log.NoopLogger())
+			server := newFakeSMTP(t, tc.replies...)
+			sender := newTestSender(t, server)
+
+			err := sender.Notify(context.Background(), Preferences{Email: "jane@example.com"}, Message{Subject: "hi", Text: "hi"})
+
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			mails, attempts := server.received()
+			assert.Len(t, mails, tc.wantMails)
+			assert.Equal(t, tc.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestSMTPSenderWithoutAddress(t *testing.T) {
+	server := newFakeSMTP(t)
+	sender := newTestSender(t, server)
+
+	err := sender.Notify(context.Background(), Preferences{UserID: "jane"}, Message{Subject: "hi"})
+
+	assert.ErrorIs(t, err, ErrNoAddress)
+	_, attempts := server.received()
+	assert.Zero(t, attempts)
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"plants/store"
+	"slices"
+	"sync"
+)
+
+// PreferenceStore keeps notification preferences per user, missing ones are reported
+// with store.ErrorResourceDoesNotExist like in the plant store
+type PreferenceStore interface {
+	// ListPreferences returns the preferences of all users, ordered by user ID
+	ListPreferences(ctx context.Context) ([]Preferences, error)
+	FindPreferences(ctx context.Context, userID string) (*Preferences, error)
+	// PutPreferences creates or replaces the preferences of prefs.UserID
+	PutPreferences(ctx context.Context, prefs Preferences) (*Preferences, error)
+	DeletePreferences(ctx context.Context, userID string) error
+}
+
+type MemoryPreferenceStore struct {
+	mu    sync.RWMutex
+	items map[string]Preferences
+}
+
+func NewMemoryPreferenceStore() *MemoryPreferenceStore {
+	return &MemoryPreferenceStore{items: make(map[string]Preferences)}
+}
+
+func preferencesNotFound(userID string) error {
+	return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("notification preferences of user '%s' do not exist", userID)}
+}
+
+func (s *MemoryPreferenceStore) ListPreferences(ctx context.Context) ([]Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Preferences, 0, len(s.items))
+	for _, prefs := range s.items {
+		list = append(list, prefs)
+	}
+	slices.SortFunc(list, func(a, b Preferences) int { return cmp.Compare(a.UserID, b.UserID) })
+	return list, nil
+}
+
+func (s *MemoryPreferenceStore) FindPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefs, ok := s.items[userID]
+	if !ok {
+		return nil, preferencesNotFound(userID)
+	}
+	return &prefs, nil
+}
+
+func (s *MemoryPreferenceStore) PutPreferences(ctx context.Context, prefs Preferences) (*Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[prefs.UserID] = prefs
+	return &prefs, nil
+}
+
+func (s *MemoryPreferenceStore) DeletePreferences(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[userID]; !ok {
+		return preferencesNotFound(userID)
+	}
+	delete(s.items, userID)
+	return nil
+}
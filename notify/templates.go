@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+// every message kind has a text template defining a "subject" block and an html template,
+// e.g. digest.txt.tmpl and digest.html.tmpl
+//
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Templates renders messages out of the embedded templates
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func NewTemplates() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	names, err := fs.Glob(templateFS, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		kind := strings.TrimSuffix(strings.TrimPrefix(name, "templates/"), ".txt.tmpl")
+		// NOTE: parsed separately per kind, so the "subject" blocks dont clash
+		if t.text[kind], err = texttemplate.ParseFS(templateFS, name); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		if t.text[kind].Lookup("subject") == nil {
+			return nil, fmt.Errorf("template %s has no subject block", name)
+		}
+		if t.html[kind], err = htmltemplate.ParseFS(templateFS, "templates/"+kind+".html.tmpl"); err != nil {
+			return nil, fmt.Errorf("parse %s html: %w", kind, err)
+		}
+	}
+	return t, nil
+}
+
+// Render builds a message of the given kind, e.g. "digest"
+func (t *Templates) Render(kind string, data any) (Message, error) {
+	text, ok := t.text[kind]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown message template '%s'", kind)
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("render %s subject: %w", kind, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("render %s text: %w", kind, err)
+	}
+	if err := t.html[kind].Execute(&html, data); err != nil {
+		return Message{}, fmt.Errorf("render %s html: %w", kind, err)
+	}
+	return Message{Subject: strings.TrimSpace(subject.String()), Text: body.String(), HTML: html.String()}, nil
+}
@@ -0,0 +1,105 @@
+package photos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"plants/store"
+)
+
+// BlobStore keeps binary objects under slash separated keys like "plant/photo",
+// missing ones are reported with store.ErrorResourceDoesNotExist
+type BlobStore interface {
+	// Put stores everything read from r under key, replacing what was there, and returns how many bytes it wrote
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns the blob for reading, the caller closes it
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes a blob, deleting a missing one isnt an error so cleanups can be retried
+	Delete(ctx context.Context, key string) error
+}
+
+// FileStore is a BlobStore on the local filesystem, every key is a file under its root directory
+type FileStore struct {
+	root string
+}
+
+// NewFileStore creates the root directory when it doesnt exist yet
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("create blob directory: %w", err)
+	}
+	return &FileStore{root: root}, nil
+}
+
+// path maps a key to a file, keys that would escape the root directory are rejected
+func (s *FileStore) path(key string) (string, error) {
+	local := filepath.FromSlash(key)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("invalid blob key '%s'", key)
+	}
+	return filepath.Join(s.root, local), nil
+}
+
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return 0, fmt.Errorf("create blob directory: %w", err)
+	}
+
+	// NOTE: written to a temporary file and renamed, so readers never see a partial blob
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("create blob: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		_ = tmp.Close()
+		return 0, fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("write blob: %w", err)
+	}
+	return n, nil
+}
+
+func (s *FileStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, store.ErrorResourceDoesNotExist{Err: fmt.Errorf("blob '%s' does not exist", key)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open blob: %w", err)
+	}
+	return f, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	// NOTE: removes the directory once its last blob is gone, it fails harmlessly while others are left
+	if dir := filepath.Dir(path); dir != filepath.Clean(s.root) {
+		_ = os.Remove(dir)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package photos
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"plants/store"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	blobs, err := NewFileStore(filepath.Join(root, "photos"))
+	require.NoError(t, err)
+
+	n, err := blobs.Put(ctx, "plant/photo", strings.NewReader("first"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	_, err = blobs.Put(ctx, "plant/photo", strings.NewReader("second"))
+	require.NoError(t, err)
+
+	f, err := blobs.Open(ctx, "plant/photo")
+	require.NoError(t, err)
+	got, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "second", string(got))
+
+	require.NoError(t, blobs.Delete(ctx, "plant/photo"))
+	require.NoError(t, blobs.Delete(ctx, "plant/photo"), "deleting a missing blob isnt an error")
+	_, err = blobs.Open(ctx, "plant/photo")
+	assert.ErrorAs(t, err, &store.ErrorResourceDoesNotExist{})
+	_, err = os.Stat(filepath.Join(root, "photos", "plant"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "empty plant directories are removed")
+
+	for _, key := range []string{"../escape", "/etc/passwd", "plant/../../escape", ""} {
+		_, err := blobs.Put(ctx, key, strings.NewReader("x"))
+		assert.EqualError(t, err, "invalid blob key '"+key+"'")
+	}
+	_, err = os.Stat(filepath.Join(root, "escape"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,128 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxPixels caps the decoded size of an upload, a small compressed file can otherwise decode into gigabytes
+const maxPixels = 50_000_000
+
+// thumbnailQuality is the JPEG quality of thumbnails of JPEG photos
+const thumbnailQuality = 80
+
+// Library keeps photo metadata in a Store and the images with their thumbnails in a BlobStore
+type Library struct {
+	store Store
+	blobs BlobStore
+	// thumbnailSize is the longest side of thumbnails in pixels
+	thumbnailSize int
+	now           func() time.Time
+}
+
+func NewLibrary(store Store, blobs BlobStore, thumbnailSize int) *Library {
+	return &Library{store: store, blobs: blobs, thumbnailSize: thumbnailSize, now: time.Now}
+}
+
+// Add stores an uploaded image with a thumbnail. The format is sniffed from the content,
+// anything but JPEG and PNG is rejected with ErrUnsupportedType.
+func (l *Library) Add(ctx context.Context, plantID, caption string, data []byte) (*Photo, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != CONTENT_TYPE_JPEG && contentType != CONTENT_TYPE_PNG {
+		return nil, ErrUnsupportedType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is larger than %d megapixels", ErrInvalidImage, cfg.Width, cfg.Height, maxPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	var thumb bytes.Buffer
+	if contentType == CONTENT_TYPE_JPEG {
+		err = jpeg.Encode(&thumb, thumbnail(img, l.thumbnailSize), &jpeg.Options{Quality: thumbnailQuality})
+	} else {
+		err = png.Encode(&thumb, thumbnail(img, l.thumbnailSize))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %w", err)
+	}
+
+	photo := Photo{
+		ID:          uuid.New().String(),
+		PlantID:     plantID,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Caption:     caption,
+		UploadedAt:  l.now().UTC(),
+	}
+	if _, err := l.blobs.Put(ctx, originalKey(photo), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if _, err := l.blobs.Put(ctx, thumbnailKey(photo), &thumb); err != nil {
+		return nil, errors.Join(err, l.deleteBlobs(ctx, photo))
+	}
+	created, err := l.store.CreatePhoto(ctx, photo)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("create photo: %w", err), l.deleteBlobs(ctx, photo))
+	}
+	return created, nil
+}
+
+func (l *Library) List(ctx context.Context, plantID string) ([]Photo, error) {
+	return l.store.ListPhotos(ctx, plantID)
+}
+
+func (l *Library) Find(ctx context.Context, plantID, id string) (*Photo, error) {
+	return l.store.FindPhoto(ctx, plantID, id)
+}
+
+// Open returns the original image of a photo, or its thumbnail, the caller closes it
+func (l *Library) Open(ctx context.Context, photo Photo, thumbnail bool) (io.ReadSeekCloser, error) {
+	if thumbnail {
+		return l.blobs.Open(ctx, thumbnailKey(photo))
+	}
+	return l.blobs.Open(ctx, originalKey(photo))
+}
+
+// Delete removes a photo, its blobs are deleted after it so a failure leaves files behind rather than broken photos
+func (l *Library) Delete(ctx context.Context, plantID, id string) error {
+	deleted, err := l.store.DeletePhoto(ctx, plantID, id)
+	if err != nil {
+		return err
+	}
+	return l.deleteBlobs(ctx, *deleted)
+}
+
+// DeletePlant removes every photo of a plant, it is called once the plant itself is deleted
+func (l *Library) DeletePlant(ctx context.Context, plantID string) error {
+	deleted, err := l.store.DeletePlantPhotos(ctx, plantID)
+	if err != nil {
+		return fmt.Errorf("delete plant photos: %w", err)
+	}
+	var errs []error
+	for _, photo := range deleted {
+		errs = append(errs, l.deleteBlobs(ctx, photo))
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Library) deleteBlobs(ctx context.Context, photo Photo) error {
+	return errors.Join(l.blobs.Delete(ctx, originalKey(photo)), l.blobs.Delete(ctx, thumbnailKey(photo)))
+}
@@ -0,0 +1,166 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{G: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil))
+	return buf.Bytes()
+}
+
+// hugePNG claims to be 10000x10000 pixels in its header, which is all DecodeConfig reads
+func hugePNG(t *testing.T) []byte {
+	t.Helper()
+	data := encodePNG(t, 1, 1)
+	// NOTE: the IHDR chunk follows the 8 byte signature, its width and height start after the length and type
+	binary.BigEndian.PutUint32(data[16:], 10000)
+	binary.BigEndian.PutUint32(data[20:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestLibraryAdd(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+
+		wantType      string
+		wantSize      [2]int
+		wantThumbnail [2]int
+		wantErr       error
+	}{
+		"landscape png": {
+			data: encodePNG(t, 400, 100),
+
+			wantType:      CONTENT_TYPE_PNG,
+			wantSize:      [2]int{400, 100},
+			wantThumbnail: [2]int{64, 16},
+		},
+		"portrait jpeg": {
+			data: encodeJPEG(t, 90, 300),
+
+			wantType:      CONTENT_TYPE_JPEG,
+			wantSize:      [2]int{90, 300},
+			wantThumbnail: [2]int{19, 64},
+		},
+		"small images arent scaled up": {
+			data: encodePNG(t, 10, 20),
+
+			wantType:      CONTENT_TYPE_PNG,
+			wantSize:      [2]int{10, 20},
+			wantThumbnail: [2]int{10, 20},
+		},
+		"not an image": {
+			data: []byte("<html><body>hello</body></html>"),
+
+			wantErr: ErrUnsupportedType,
+		},
+		"gif": {
+			data: []byte("GIF89a\x01\x00\x01\x00"),
+
+			wantErr: ErrUnsupportedType,
+		},
+		"truncated png": {
+			data: encodePNG(t, 50, 50)[:60],
+
+			wantErr: ErrInvalidImage,
+		},
+		"too many pixels": {
+			data: hugePNG(t),
+
+			wantErr: ErrInvalidImage,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			blobs, err := NewFileStore(t.TempDir())
+			require.NoError(t, err)
+			library := NewLibrary(NewMemoryStore(), blobs, 64)
+
+			photo, err := library.Add(ctx, "plant", "leaf spots", tc.data)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantType, photo.ContentType)
+			assert.Equal(t, tc.wantSize, [2]int{photo.Width, photo.Height})
+			assert.Equal(t, int64(len(tc.data)), photo.Size)
+			assert.Equal(t, "leaf spots", photo.Caption)
+
+			original, err := library.Open(ctx, *photo, false)
+			require.NoError(t, err)
+			defer func() { _ = original.Close() }()
+			got, err := io.ReadAll(original)
+			require.NoError(t, err)
+			assert.Equal(t, tc.data, got)
+
+			thumb, err := library.Open(ctx, *photo, true)
+			require.NoError(t, err)
+			defer func() { _ = thumb.Close() }()
+			cfg, format, err := image.DecodeConfig(thumb)
+			require.NoError(t, err)
+			assert.Equal(t, "image/"+format, tc.wantType)
+			assert.Equal(t, tc.wantThumbnail, [2]int{cfg.Width, cfg.Height})
+		})
+	}
+}
+
+func TestLibraryDelete(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	library := NewLibrary(NewMemoryStore(), blobs, 64)
+	var added []Photo
+	for _, plantID := range []string{"fern", "fern", "cactus"} {
+		photo, err := library.Add(ctx, plantID, "", encodePNG(t, 8, 8))
+		require.NoError(t, err)
+		added = append(added, *photo)
+	}
+
+	require.NoError(t, library.Delete(ctx, "fern", added[0].ID))
+	_, err = library.Open(ctx, added[0], false)
+	assert.Error(t, err)
+	assert.Error(t, library.Delete(ctx, "cactus", added[1].ID), "photos are only found under their own plant")
+
+	require.NoError(t, library.DeletePlant(ctx, "fern"))
+	list, err := library.List(ctx, "fern")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	_, err = library.Open(ctx, added[1], true)
+	assert.Error(t, err)
+
+	list, err = library.List(ctx, "cactus")
+	require.NoError(t, err)
+	assert.Equal(t, []Photo{added[2]}, list)
+}
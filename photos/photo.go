@@ -0,0 +1,35 @@
+package photos
+
+import (
+	"errors"
+	"time"
+)
+
+// supported photo formats, detected from the content rather than the declared type
+const (
+	CONTENT_TYPE_JPEG = "image/jpeg"
+	CONTENT_TYPE_PNG  = "image/png"
+)
+
+// ErrUnsupportedType is returned for uploads that arent JPEG or PNG images
+var ErrUnsupportedType = errors.New("photo must be a JPEG or PNG image")
+
+// ErrInvalidImage is returned for uploads that look like an image but cant be decoded, or are too large to decode
+var ErrInvalidImage = errors.New("photo is not a valid image")
+
+// Photo describes an uploaded image, the image itself and its thumbnail are kept in a BlobStore
+type Photo struct {
+	ID          string `json:"id"`
+	PlantID     string `json:"plantId"`
+	ContentType string `json:"contentType"`
+	// Size is the original image size in bytes
+	Size       int64     `json:"size"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Caption    string    `json:"caption,omitempty"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// blob keys, photos are grouped per plant so they are easy to find when cleaning up
+func originalKey(p Photo) string  { return p.PlantID + "/" + p.ID }
+func thumbnailKey(p Photo) string { return p.PlantID + "/" + p.ID + ".thumb" }
@@ -0,0 +1,83 @@
+package photos
+
+import (
+	"context"
+	"fmt"
+	"plants/store"
+	"slices"
+	"sync"
+)
+
+// Store keeps photo metadata, missing photos are reported with store.ErrorResourceDoesNotExist
+type Store interface {
+	// ListPhotos returns the photos of a plant, oldest first
+	ListPhotos(ctx context.Context, plantID string) ([]Photo, error)
+	FindPhoto(ctx context.Context, plantID, id string) (*Photo, error)
+	// CreatePhoto stores a photo under the ID it already has, its blobs are written before it is created
+	CreatePhoto(ctx context.Context, photo Photo) (*Photo, error)
+	DeletePhoto(ctx context.Context, plantID, id string) (*Photo, error)
+	// DeletePlantPhotos removes every photo of a plant and returns them, so their blobs can be deleted too
+	DeletePlantPhotos(ctx context.Context, plantID string) ([]Photo, error)
+}
+
+type MemoryStore struct {
+	mu sync.RWMutex
+	// photos per plant, in upload order
+	photos map[string][]Photo
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{photos: make(map[string][]Photo)}
+}
+
+func photoNotFound(id string) error {
+	return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("photo with ID '%s' does not exist", id)}
+}
+
+func (s *MemoryStore) ListPhotos(ctx context.Context, plantID string) ([]Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := slices.Clone(s.photos[plantID])
+	if list == nil {
+		list = []Photo{}
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) FindPhoto(ctx context.Context, plantID, id string) (*Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := slices.IndexFunc(s.photos[plantID], func(p Photo) bool { return p.ID == id })
+	if i < 0 {
+		return nil, photoNotFound(id)
+	}
+	photo := s.photos[plantID][i]
+	return &photo, nil
+}
+
+func (s *MemoryStore) CreatePhoto(ctx context.Context, photo Photo) (*Photo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.photos[photo.PlantID] = append(s.photos[photo.PlantID], photo)
+	return &photo, nil
+}
+
+func (s *MemoryStore) DeletePhoto(ctx context.Context, plantID, id string) (*Photo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.photos[plantID], func(p Photo) bool { return p.ID == id })
+	if i < 0 {
+		return nil, photoNotFound(id)
+	}
+	deleted := s.photos[plantID][i]
+	s.photos[plantID] = slices.Delete(s.photos[plantID], i, i+1)
+	return &deleted, nil
+}
+
+func (s *MemoryStore) DeletePlantPhotos(ctx context.Context, plantID string) ([]Photo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := s.photos[plantID]
+	delete(s.photos, plantID)
+	return deleted, nil
+}
@@ -0,0 +1,85 @@
+package plants
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+type CareType string
+
+const (
+	CareWatering    CareType = "watering"
+	CareFertilizing CareType = "fertilizing"
+	CareRepotting   CareType = "repotting"
+	CarePruning     CareType = "pruning"
+)
+
+var careTypes = []CareType{CareWatering, CareFertilizing, CareRepotting, CarePruning}
+
+// Valid reports whether t is one of the known care types
+func (t CareType) Valid() bool {
+	return slices.Contains(careTypes, t)
+}
+
+func careTypesProblem() string {
+	return fmt.Sprintf("type must be one of: %s, %s, %s, %s", CareWatering, CareFertilizing, CareRepotting, CarePruning)
+}
+
+// CareEvent records a single time a plant was looked after
+type CareEvent struct {
+	ID      string   `json:"id"`
+	PlantID string   `json:"plantId"`
+	Type    CareType `json:"type"`
+	// Time is when the care happened, it defaults to when the event was recorded
+	Time time.Time `json:"time"`
+	// Amount is how much was given, by convention milliliters of water or grams of fertilizer
+	Amount float64 `json:"amount,omitempty"`
+	// Actor is who did it, it defaults to the authenticated caller
+	Actor string `json:"actor"`
+	Notes string `json:"notes,omitempty"`
+}
+
+func (e CareEvent) Valid() map[string]string {
+	problems := make(map[string]string)
+	if !e.Type.Valid() {
+		problems["type"] = careTypesProblem()
+	}
+
+	if e.Amount < 0 {
+		problems["amount"] = "amount cannot be negative"
+	}
+
+	return problems
+}
+
+// OverdueCare is a plant that should have been cared for already
+type OverdueCare struct {
+	PlantID   string   `json:"plantId"`
+	PlantName string   `json:"plantName"`
+	Type      CareType `json:"type"`
+	// IntervalDays is the plant's interval for Type
+	IntervalDays int `json:"intervalDays"`
+	// LastCaredAt and DueAt are nil when the plant was never cared for this way, it is overdue right away
+	LastCaredAt *time.Time `json:"lastCaredAt,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+}
+
+// CareSchedule returns the plant's care intervals, the watering interval falls back to the one of its species
+// when the plant doesnt set its own. speciesWatering holds the watering interval per species ID.
+func (p Plant) CareSchedule(speciesWatering map[string]int) map[CareType]int {
+	if _, ok := p.CareIntervals[CareWatering]; ok {
+		return p.CareIntervals
+	}
+	days, ok := speciesWatering[p.SpeciesID]
+	if !ok {
+		return p.CareIntervals
+	}
+	schedule := maps.Clone(p.CareIntervals)
+	if schedule == nil {
+		schedule = make(map[CareType]int)
+	}
+	schedule[CareWatering] = days
+	return schedule
+}
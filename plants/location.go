@@ -0,0 +1,88 @@
+package plants
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LocationKind is the level of a location in the hierarchy, from sites down to slots
+type LocationKind string
+
+const (
+	LocationSite       LocationKind = "site"
+	LocationGreenhouse LocationKind = "greenhouse"
+	LocationBed        LocationKind = "bed"
+	LocationSlot       LocationKind = "slot"
+)
+
+// NOTE: ordered from the top of the hierarchy down
+var locationKinds = []LocationKind{LocationSite, LocationGreenhouse, LocationBed, LocationSlot}
+
+// Valid reports whether k is one of the known location kinds
+func (k LocationKind) Valid() bool {
+	return slices.Contains(locationKinds, k)
+}
+
+// Contains reports whether a location of kind k can hold a location of kind child directly,
+// levels can be skipped, e.g. a bed can be outdoors on a site
+func (k LocationKind) Contains(child LocationKind) bool {
+	return slices.Index(locationKinds, k) < slices.Index(locationKinds, child)
+}
+
+// Location is a place plants are kept in, locations form a tree with sites at the top
+type Location struct {
+	ID   string       `json:"id"`
+	Name string       `json:"name"`
+	Kind LocationKind `json:"kind"`
+	// ParentID is the location this one is in, every location but a site has one
+	ParentID string `json:"parentId,omitempty"`
+	// Capacity is how many plants a slot can hold, other kinds have no limit
+	Capacity int `json:"capacity,omitempty"`
+}
+
+func (l Location) Valid() map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(l.Name) == "" {
+		problems["name"] = "name cannot be empty"
+	}
+
+	if !l.Kind.Valid() {
+		problems["kind"] = fmt.Sprintf("kind must be one of: %s, %s, %s, %s", LocationSite, LocationGreenhouse, LocationBed, LocationSlot)
+	}
+
+	if l.Kind == LocationSite && l.ParentID != "" {
+		problems["parentId"] = "a site cannot have a parent location"
+	} else if l.Kind != LocationSite && l.ParentID == "" {
+		problems["parentId"] = "parent location is required"
+	}
+
+	if l.Kind == LocationSlot && l.Capacity < 1 {
+		problems["capacity"] = "capacity of a slot must be at least 1 plant"
+	} else if l.Kind != LocationSlot && l.Capacity != 0 {
+		problems["capacity"] = "only slots have a capacity"
+	}
+
+	return problems
+}
+
+// ParentProblem describes why parent cannot hold the location, it is empty if it can
+func (l Location) ParentProblem(parent Location) string {
+	if !parent.Kind.Contains(l.Kind) {
+		return fmt.Sprintf("a %s cannot be placed in a %s", l.Kind, parent.Kind)
+	}
+	return ""
+}
+
+// PlantMove records a plant being placed in, moved between or taken out of locations
+type PlantMove struct {
+	PlantID string `json:"plantId"`
+	// FromLocationID is empty when the plant had no location before
+	FromLocationID string `json:"fromLocationId,omitempty"`
+	// ToLocationID is empty when the plant was taken out of its location
+	ToLocationID string    `json:"toLocationId,omitempty"`
+	Time         time.Time `json:"time"`
+	// Actor is who moved the plant, it defaults to the authenticated caller
+	Actor string `json:"actor"`
+}
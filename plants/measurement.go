@@ -0,0 +1,96 @@
+package plants
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// measurement units, readings in other units are converted to centimeters for Plant.Height and summaries
+const (
+	UNIT_CENTIMETERS = "cm"
+	UNIT_MILLIMETERS = "mm"
+	UNIT_INCHES      = "in"
+)
+
+// SOURCE_MANUAL marks readings entered by hand, any other source is the ID of the sensor that took it
+const SOURCE_MANUAL = "manual"
+
+// Measurement is a single height reading of a plant
+type Measurement struct {
+	ID      string `json:"id"`
+	PlantID string `json:"plantId"`
+	// Time is when the plant was measured, it defaults to when the reading was recorded
+	Time   time.Time `json:"time"`
+	Value  float64   `json:"value"`
+	Unit   string    `json:"unit"`
+	Source string    `json:"source"`
+}
+
+func (m Measurement) Valid() map[string]string {
+	problems := make(map[string]string)
+	if m.Value < 0 || math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+		problems["value"] = "value must be a non-negative number"
+	}
+
+	if m.Unit != UNIT_CENTIMETERS && m.Unit != UNIT_MILLIMETERS && m.Unit != UNIT_INCHES {
+		problems["unit"] = "unit must be one of: " + strings.Join([]string{UNIT_CENTIMETERS, UNIT_MILLIMETERS, UNIT_INCHES}, ", ")
+	}
+
+	if m.Source != "" && strings.TrimSpace(m.Source) == "" {
+		problems["source"] = "source cannot be blank"
+	}
+
+	return problems
+}
+
+// Centimeters converts the reading, the unit is assumed to be valid
+func (m Measurement) Centimeters() float64 {
+	switch m.Unit {
+	case UNIT_MILLIMETERS:
+		return m.Value / 10
+	case UNIT_INCHES:
+		return m.Value * 2.54
+	}
+	return m.Value
+}
+
+// Height is the reading rounded the way Plant.Height stores it
+func (m Measurement) Height() int {
+	return int(math.Round(m.Centimeters()))
+}
+
+// DailySummary downsamples the readings of one calendar day, values are in centimeters
+type DailySummary struct {
+	// Date is the day in the time zone the summary was made for, e.g. "2024-05-01"
+	Date  string  `json:"date"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+}
+
+// SummarizeDaily groups measurements sorted by time into calendar days of loc
+func SummarizeDaily(measurements []Measurement, loc *time.Location) []DailySummary {
+	summaries := []DailySummary{}
+	var sum float64
+	for _, m := range measurements {
+		date := m.Time.In(loc).Format(time.DateOnly)
+		value := m.Centimeters()
+		last := len(summaries) - 1
+		if last < 0 || summaries[last].Date != date {
+			summaries = append(summaries, DailySummary{Date: date, Min: value, Max: value})
+			last++
+			sum = 0
+		}
+
+		s := &summaries[last]
+		s.Min = min(s.Min, value)
+		s.Max = max(s.Max, value)
+		s.Count++
+		sum += value
+		s.Avg = sum / float64(s.Count)
+	}
+
+	return summaries
+}
@@ -1,9 +1,29 @@
 package plants
 
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
 type Plant struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Height int    `json:"height"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Height is in centimeters, recording a measurement sets it to the latest reading
+	Height int `json:"height"`
+	// Tags group plants for filtering and subscriptions, e.g. "herbs" or "balcony"
+	Tags          []string   `json:"tags,omitempty"`
+	LastWateredAt *time.Time `json:"lastWateredAt,omitempty"`
+	// CareIntervals is how many days can pass between care events of each type before the plant is overdue
+	CareIntervals map[CareType]int `json:"careIntervals,omitempty"`
+	// SpeciesID links the plant to the species catalog, the watering interval defaults to the species' one
+	SpeciesID string `json:"speciesId,omitempty"`
+}
+
+// HasAnyTag reports whether the plant has at least one of tags
+func (p Plant) HasAnyTag(tags []string) bool {
+	return slices.ContainsFunc(p.Tags, func(tag string) bool { return slices.Contains(tags, tag) })
 }
 
 func (p Plant) Valid() map[string]string {
@@ -16,5 +36,36 @@ func (p Plant) Valid() map[string]string {
 		problems["height"] = "height cannot be negative"
 	}
 
+	if slices.Contains(p.Tags, "") {
+		problems["tags"] = "tags cannot be empty"
+	}
+
+	for careType, days := range p.CareIntervals {
+		if !careType.Valid() {
+			problems["careIntervals"] = careTypesProblem()
+		} else if days < 1 {
+			problems["careIntervals"] = "care intervals must be at least 1 day"
+		}
+	}
+
 	return problems
 }
+
+// SpeciesWarnings reports likely mistakes that dont make the plant invalid, e.g. a height above the species' mature height
+func (p Plant) SpeciesWarnings(species Species) map[string]string {
+	warnings := make(map[string]string)
+	if p.Height > species.MatureHeight.Max {
+		warnings["height"] = fmt.Sprintf("height %dcm is above the mature height of %s, %d-%dcm", p.Height, species.ScientificName, species.MatureHeight.Min, species.MatureHeight.Max)
+	}
+
+	return warnings
+}
+
+// LogValue controls which fields end up in logs, new fields are not logged unless added here
+func (p Plant) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", p.ID),
+		slog.String("name", p.Name),
+		slog.Int("height", p.Height),
+	)
+}
@@ -0,0 +1,95 @@
+package plants
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Light is how much light a species needs
+type Light string
+
+const (
+	LightLow    Light = "low"
+	LightMedium Light = "medium"
+	// LightBright is bright but indirect light, LightDirect is direct sun for at least part of the day
+	LightBright Light = "bright"
+	LightDirect Light = "direct"
+)
+
+var lights = []Light{LightLow, LightMedium, LightBright, LightDirect}
+
+// Valid reports whether l is one of the known light needs
+func (l Light) Valid() bool {
+	return slices.Contains(lights, l)
+}
+
+// HeightRange is a range of heights in centimeters
+type HeightRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Species is a catalog entry plants can link to, it provides their default care
+type Species struct {
+	ID string `json:"id"`
+	// ScientificName is the binomial name, e.g. "Monstera deliciosa", it is unique in the catalog
+	ScientificName string   `json:"scientificName"`
+	CommonNames    []string `json:"commonNames,omitempty"`
+	Family         string   `json:"family"`
+	Genus          string   `json:"genus"`
+	// WateringInterval is how many days can pass between waterings, plants of the species default to it
+	WateringInterval int   `json:"wateringInterval"`
+	Light            Light `json:"light"`
+	// MatureHeight is how tall grown plants usually get
+	MatureHeight HeightRange `json:"matureHeight"`
+}
+
+func (s Species) Valid() map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(s.ScientificName) == "" {
+		problems["scientificName"] = "scientific name cannot be empty"
+	} else if genus, _, _ := strings.Cut(s.ScientificName, " "); genus != s.Genus {
+		problems["scientificName"] = "scientific name must start with the genus"
+	}
+
+	if slices.ContainsFunc(s.CommonNames, func(name string) bool { return strings.TrimSpace(name) == "" }) {
+		problems["commonNames"] = "common names cannot be empty"
+	}
+
+	if strings.TrimSpace(s.Family) == "" {
+		problems["family"] = "family cannot be empty"
+	}
+
+	if strings.TrimSpace(s.Genus) == "" {
+		problems["genus"] = "genus cannot be empty"
+	}
+
+	if s.WateringInterval < 1 {
+		problems["wateringInterval"] = "watering interval must be at least 1 day"
+	}
+
+	if !s.Light.Valid() {
+		problems["light"] = fmt.Sprintf("light must be one of: %s, %s, %s, %s", LightLow, LightMedium, LightBright, LightDirect)
+	}
+
+	if s.MatureHeight.Min < 0 || s.MatureHeight.Max < 1 || s.MatureHeight.Min > s.MatureHeight.Max {
+		problems["matureHeight"] = "mature height must be a range of centimeters with min no larger than max"
+	}
+
+	return problems
+}
+
+//go:embed species.json
+var bundledSpecies []byte
+
+// BundledSpecies returns the species catalog shipped with the API, common house plants and herbs
+func BundledSpecies() ([]Species, error) {
+	var species []Species
+	if err := json.Unmarshal(bundledSpecies, &species); err != nil {
+		return nil, fmt.Errorf("decode bundled species: %w", err)
+	}
+	return species, nil
+}
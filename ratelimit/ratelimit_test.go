@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		// offsets of each take from start, all for the same key
+		takes []time.Duration
+
+		want Result
+	}{
+		"first take is allowed": {
+			takes: []time.Duration{0},
+
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second},
+		},
+		"burst is exhausted": {
+			takes: []time.Duration{0, 0, 0, 0},
+
+			want: Result{Allowed: false, Limit: 3, Remaining: 0, Reset: 3 * time.Second, RetryAfter: time.Second},
+		},
+		"tokens refill over time": {
+			takes: []time.Duration{0, 0, 0, 1500 * time.Millisecond},
+
+			want: Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 2500 * time.Millisecond},
+		},
+		"refill is capped at burst": {
+			takes: []time.Duration{0, time.Hour},
+
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := start
+			s := NewMemoryStore(func() time.Time { return now })
+
+			var got Result
+			for _, offset := range tc.takes {
+				now = start.Add(offset)
+				var err error
+				got, err = s.Take(ctx, "client", limit)
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(nil)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	first, _ := s.Take(ctx, "a", limit)
+	second, _ := s.Take(ctx, "a", limit)
+	other, _ := s.Take(ctx, "b", limit)
+
+	assert.True(t, first.Allowed)
+	assert.False(t, second.Allowed)
+	assert.True(t, other.Allowed)
+}
+
+func TestMemoryStoreSweepsIdleBuckets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(func() time.Time { return now })
+	limit := Limit{Rate: 1, Burst: 1}
+
+	_, _ = s.Take(ctx, "idle", limit)
+	now = now.Add(2 * sweepInterval)
+	_, _ = s.Take(ctx, "active", limit)
+
+	assert.Len(t, s.buckets, 1)
+	assert.Contains(t, s.buckets, "active")
+}
@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs next
+type Schedule interface {
+	// Next returns the first run strictly after t, or the zero time if there is none
+	Next(t time.Time) time.Time
+}
+
+// how far ahead Next looks before giving up on schedules that never match, like "0 0 30 2 *"
+const cronSearchYears = 5
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// NOTE: 7 is accepted as sunday too and folded into 0
+	{name: "day of week", min: 0, max: 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule keeps a bit per allowed value of each field
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// restricted day fields are ORed like in classic cron: "0 0 1 * 1" runs on the 1st and on every monday
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+// everySchedule runs at a fixed interval after the previous run
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Parse reads a standard 5 field cron expression "minute hour day-of-month month day-of-week",
+// evaluated in loc. Fields accept "*", values, ranges "1-5", steps "*/15" or "0-30/10" and comma lists.
+// The descriptors @yearly, @monthly, @weekly, @daily, @hourly and "@every <duration>" work too.
+func Parse(expr string, loc *time.Location) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if interval, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("cron expression '%s' must have a positive duration like @every 5m", expr)
+		}
+		return everySchedule(d), nil
+	}
+	if descriptor, ok := cronDescriptors[expr]; ok {
+		expr = descriptor
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression '%s' must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+	var masks [5]uint64
+	for i, part := range parts {
+		mask, err := parseField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression '%s': %w", expr, err)
+		}
+		masks[i] = mask
+	}
+	if masks[4]&(1<<7) != 0 {
+		masks[4] = masks[4]&^(1<<7) | 1
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &cronSchedule{
+		minute: masks[0],
+		hour:   masks[1],
+		dom:    masks[2],
+		month:  masks[3],
+		dow:    masks[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+		loc:    loc,
+	}, nil
+}
+
+func parseField(part string, f field) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(part, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("%s step '%s' must be a positive number", f.name, stepPart)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = parseValue(lowPart, f); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = parseValue(highPart, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// NOTE: "5/15" means every 15 starting at 5
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("%s range '%s' must go from low to high", f.name, rangePart)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s '%s' must be a number from %d to %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func has(mask uint64, v int) bool {
+	return mask&(1<<v) != 0
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case !has(c.hour, t.Hour()):
+			// NOTE: adding instead of time.Date, so hours repeated by DST are not skipped or looped on,
+			// and the local minute instead of Truncate, which would break zones with half hour offsets
+			t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute * time.Duration(nextSet(c.minute, t.Minute())-t.Minute()))
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// nextSet returns the first set bit after v, or 60 to roll over to the next hour
+func nextSet(mask uint64, v int) int {
+	rest := mask >> (v + 1) << (v + 1)
+	if rest == 0 {
+		return 60
+	}
+	return bits.TrailingZeros64(rest)
+}
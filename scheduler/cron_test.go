@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNext(t *testing.T) {
+	riga, err := time.LoadLocation("Europe/Riga")
+	require.NoError(t, err)
+	// NOTE: a wednesday
+	after := time.Date(2024, 5, 1, 10, 17, 30, 0, time.UTC)
+
+	tests := map[string]struct {
+		expr  string
+		loc   *time.Location
+		after time.Time
+
+		want time.Time
+	}{
+		"every 15 minutes": {
+			expr: "*/15 * * * *",
+
+			want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		},
+		"step from a start value": {
+			expr: "5/20 * * * *",
+
+			want: time.Date(2024, 5, 1, 10, 25, 0, 0, time.UTC),
+		},
+		"hourly": {
+			expr: "@hourly",
+
+			want: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC),
+		},
+		"daily": {
+			expr: "@daily",
+
+			want: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+		"weekdays": {
+			expr: "30 8 * * 1-5",
+
+			want: time.Date(2024, 5, 2, 8, 30, 0, 0, time.UTC),
+		},
+		"lists": {
+			expr: "0 9,18 * * *",
+
+			want: time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC),
+		},
+		"sunday as 7": {
+			expr: "0 9 * * 7",
+
+			want: time.Date(2024, 5, 5, 9, 0, 0, 0, time.UTC),
+		},
+		"day of month or day of week": {
+			expr: "0 0 1 * 1",
+
+			want: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		},
+		"leap day": {
+			expr: "0 0 29 2 *",
+
+			want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		"in a time zone": {
+			expr: "0 8 * * *",
+			loc:  riga,
+
+			want: time.Date(2024, 5, 2, 5, 0, 0, 0, time.UTC),
+		},
+		"skips times that dont exist because of DST": {
+			expr:  "30 3 * * *",
+			loc:   riga,
+			after: time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC),
+
+			want: time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC),
+		},
+		"every interval": {
+			expr: "@every 90m",
+
+			want: after.Add(90 * time.Minute),
+		},
+		"never": {
+			expr: "0 0 30 2 *",
+
+			want: time.Time{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			from := tc.after
+			if from.IsZero() {
+				from = after
+			}
+			schedule, err := Parse(tc.expr, tc.loc)
+			require.NoError(t, err)
+
+			got := schedule.Next(from)
+
+			assert.True(t, tc.want.Equal(got), "want %s, got %s", tc.want, got)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]struct {
+		expr string
+
+		wantErr string
+	}{
+		"too few fields": {
+			expr: "* * *",
+
+			wantErr: "cron expression '* * *' must have 5 fields: minute hour day-of-month month day-of-week",
+		},
+		"out of range": {
+			expr: "60 * * * *",
+
+			wantErr: "cron expression '60 * * * *': minute '60' must be a number from 0 to 59",
+		},
+		"not a number": {
+			expr: "* noon * * *",
+
+			wantErr: "cron expression '* noon * * *': hour 'noon' must be a number from 0 to 23",
+		},
+		"zero step": {
+			expr: "*/0 * * * *",
+
+			wantErr: "cron expression '*/0 * * * *': minute step '0' must be a positive number",
+		},
+		"backwards range": {
+			expr: "* * * 5-1 *",
+
+			wantErr: "cron expression '* * * 5-1 *': month range '5-1' must go from low to high",
+		},
+		"bad interval": {
+			expr: "@every -1m",
+
+			wantErr: "cron expression '@every -1m' must have a positive duration like @every 5m",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.expr, time.UTC)
+
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
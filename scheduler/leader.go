@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Elector hands out leases, so only one instance runs a job when several replicas share a database.
+// A database implementation would upsert a row per key, taking it only when it is expired or already held by holder.
+type Elector interface {
+	// Acquire takes the lease on key for holder until ttl passes, or renews it when holder already has it.
+	// It reports false when another holder has a lease that hasnt expired.
+	Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error)
+	// Release gives the lease up early, it does nothing unless holder has it
+	Release(ctx context.Context, key, holder string) error
+}
+
+type lease struct {
+	holder  string
+	expires time.Time
+}
+
+// MemoryElector only coordinates schedulers within a single process
+type MemoryElector struct {
+	mu     sync.Mutex
+	clock  Clock
+	leases map[string]lease
+}
+
+// NewMemoryElector creates an in-process elector, nil clock means the real time
+func NewMemoryElector(clock Clock) *MemoryElector {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &MemoryElector{clock: clock, leases: make(map[string]lease)}
+}
+
+func (e *MemoryElector) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.clock.Now()
+	current, ok := e.leases[key]
+	if ok && current.holder != holder && now.Before(current.expires) {
+		return false, nil
+	}
+	e.leases[key] = lease{holder: holder, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (e *MemoryElector) Release(ctx context.Context, key, holder string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if current, ok := e.leases[key]; ok && current.holder == holder {
+		delete(e.leases, key)
+	}
+	return nil
+}
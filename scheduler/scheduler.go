@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+var ErrSchedulerStarted = errors.New("scheduler is already started")
+
+// how long a lease outlives the next run, so the leader renews it before anyone else can take it
+const leaseGrace = 30 * time.Second
+
+// Job is a task that runs on a schedule, a run never overlaps with the previous run of the same job
+type Job struct {
+	// Name identifies the job in logs and is the lease key, so it has to be the same on every replica
+	Name     string
+	Schedule Schedule
+	// Jitter delays every run by a random duration up to Jitter, so replicas and jobs dont hit the database at once
+	Jitter time.Duration
+	Run    func(ctx context.Context) error
+}
+
+type Options struct {
+	// Clock is the time source, nil means the real time
+	Clock Clock
+	// Elector makes sure only one replica runs each job, nil means every run happens on this instance
+	Elector Elector
+	// Instance identifies this replica to the Elector, e.g. the hostname
+	Instance string
+}
+
+// Scheduler runs jobs in the background until the context passed to Start is cancelled
+type Scheduler struct {
+	logger *slog.Logger
+	opts   Options
+	jobs   []Job
+	// jitter returns a random duration in [0, max), tests replace it to be deterministic
+	jitter func(max time.Duration) time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stopped sync.WaitGroup
+}
+
+func New(logger *slog.Logger, opts Options) *Scheduler {
+	if opts.Clock == nil {
+		opts.Clock = realClock{}
+	}
+
+	return &Scheduler{
+		logger: logger,
+		opts:   opts,
+		jitter: func(max time.Duration) time.Duration {
+			if max <= 0 {
+				return 0
+			}
+			return rand.N(max)
+		},
+	}
+}
+
+// Add registers a job, jobs have to be added before Start
+func (s *Scheduler) Add(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return ErrSchedulerStarted
+	}
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return errors.New("job needs a name, a schedule and a run function")
+	}
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			return fmt.Errorf("job '%s' is already added", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// Start runs every job on its schedule until ctx is cancelled, runs in progress get ctx cancelled too
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return ErrSchedulerStarted
+	}
+	s.started = true
+
+	for _, job := range s.jobs {
+		s.stopped.Add(1)
+		go func() {
+			defer s.stopped.Done()
+			s.loop(ctx, job)
+		}()
+	}
+	return nil
+}
+
+// Wait blocks until all jobs have stopped and gives up their leases, so another replica can take over
+// right away. It matches the shutdown hook signature.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.stopped.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return fmt.Errorf("wait for scheduled jobs: %w", ctx.Err())
+	}
+
+	if s.opts.Elector == nil {
+		return nil
+	}
+	var errs []error
+	for _, job := range s.jobs {
+		if err := s.opts.Elector.Release(ctx, job.Name, s.opts.Instance); err != nil {
+			errs = append(errs, fmt.Errorf("release job '%s': %w", job.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	logger := s.logger.With(slog.String("job", job.Name))
+	for {
+		now := s.opts.Clock.Now()
+		next := job.Schedule.Next(now)
+		if next.IsZero() {
+			logger.ErrorContext(ctx, "job schedule has no upcoming runs, stopping it")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.opts.Clock.After(next.Sub(now) + s.jitter(job.Jitter)):
+		}
+
+		// NOTE: the lease lasts a bit past the next run, so the leader keeps the job while it is alive
+		// and another replica takes over within two runs of the leader going away
+		s.run(ctx, logger, job, job.Schedule.Next(next).Add(job.Jitter+leaseGrace))
+
+		if missed := job.Schedule.Next(next); !missed.IsZero() && missed.Before(s.opts.Clock.Now()) {
+			// NOTE: runs dont overlap, the ones that should have started during a long run are skipped
+			logger.WarnContext(ctx, "job ran past its next run, skipping to the upcoming one")
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, logger *slog.Logger, job Job, leaseUntil time.Time) {
+	if s.opts.Elector != nil {
+		leader, err := s.opts.Elector.Acquire(ctx, job.Name, s.opts.Instance, leaseUntil.Sub(s.opts.Clock.Now()))
+		if err != nil {
+			logger.ErrorContext(ctx, fmt.Sprintf("acquire job lease: %s", err))
+			return
+		}
+		if !leader {
+			logger.DebugContext(ctx, "job is run by another instance")
+			return
+		}
+	}
+
+	start := s.opts.Clock.Now()
+	logger.InfoContext(ctx, "job started")
+	err := safeRun(ctx, job)
+	duration := slog.Duration("duration", s.opts.Clock.Now().Sub(start))
+	if err != nil {
+		logger.ErrorContext(ctx, fmt.Sprintf("job failed: %s", err), duration)
+		return
+	}
+	logger.InfoContext(ctx, "job finished", duration)
+}
+
+// safeRun turns a panicking job into an error, so one broken job doesnt take the process down
+func safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 5, 1, 10, 17, 0, 0, time.UTC)
+
+// NOTE: not log.NoopLogger, the log package imports config which imports this package
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func mustParse(t *testing.T, expr string) Schedule {
+	t.Helper()
+	schedule, err := Parse(expr, time.UTC)
+	require.NoError(t, err)
+	return schedule
+}
+
+// startScheduler runs s until the test ends
+func startScheduler(t *testing.T, s *Scheduler) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, s.Start(ctx))
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, s.Wait(context.Background()))
+	})
+	return cancel
+}
+
+// received drains the runs recorded so far
+func received[T any](ch <-chan T) []T {
+	var got []T
+	for {
+		select {
+		case v := <-ch:
+			got = append(got, v)
+		default:
+			return got
+		}
+	}
+}
+
+func TestSchedulerRunsJobs(t *testing.T) {
+	clock := NewFakeClock(start)
+	s := New(discard, Options{Clock: clock})
+	runs := make(chan time.Time, 10)
+	calls := 0
+	require.NoError(t, s.Add(Job{
+		Name:     "quarterly",
+		Schedule: mustParse(t, "*/15 * * * *"),
+		Run: func(ctx context.Context) error {
+			calls++
+			runs <- clock.Now()
+			// NOTE: a broken job keeps its schedule
+			if calls == 1 {
+				panic("boom")
+			}
+			return errors.New("failed")
+		},
+	}))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(12 * time.Minute)
+	assert.Empty(t, received(runs))
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(13*time.Minute), <-runs)
+
+	clock.BlockUntil(1)
+	clock.Advance(15 * time.Minute)
+	assert.Equal(t, start.Add(28*time.Minute), <-runs)
+
+	assert.ErrorIs(t, s.Add(Job{Name: "late", Schedule: mustParse(t, "@hourly"), Run: func(ctx context.Context) error { return nil }}), ErrSchedulerStarted)
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	clock := NewFakeClock(start)
+	s := New(discard, Options{Clock: clock})
+	s.jitter = func(max time.Duration) time.Duration { return max / 2 }
+	runs := make(chan time.Time, 10)
+	require.NoError(t, s.Add(Job{
+		Name:     "hourly",
+		Schedule: mustParse(t, "@hourly"),
+		Jitter:   10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			runs <- clock.Now()
+			return nil
+		},
+	}))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(47 * time.Minute)
+	assert.Empty(t, received(runs))
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, time.Date(2024, 5, 1, 11, 5, 0, 0, time.UTC), <-runs)
+}
+
+func TestSchedulerNoOverlap(t *testing.T) {
+	clock := NewFakeClock(start)
+	s := New(discard, Options{Clock: clock})
+	runs := make(chan time.Time, 10)
+	release := make(chan struct{})
+	require.NoError(t, s.Add(Job{
+		Name:     "slow",
+		Schedule: mustParse(t, "* * * * *"),
+		Run: func(ctx context.Context) error {
+			runs <- clock.Now()
+			<-release
+			return nil
+		},
+	}))
+	startScheduler(t, s)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), <-runs)
+
+	// NOTE: the runs due while the first one is busy are skipped
+	clock.Advance(5 * time.Minute)
+	release <- struct{}{}
+	clock.BlockUntil(1)
+	assert.Empty(t, received(runs))
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(7*time.Minute), <-runs)
+	release <- struct{}{}
+}
+
+func TestSchedulerLeaderElection(t *testing.T) {
+	// NOTE: on the schedule, so every advance lands exactly on a run
+	clock := NewFakeClock(time.Date(2024, 5, 1, 10, 20, 0, 0, time.UTC))
+	elector := NewMemoryElector(clock)
+	runs := make(chan string, 10)
+	cancels := make(map[string]context.CancelFunc)
+	schedulers := make(map[string]*Scheduler)
+	for _, instance := range []string{"a", "b"} {
+		s := New(discard, Options{Clock: clock, Elector: elector, Instance: instance})
+		require.NoError(t, s.Add(Job{
+			Name:     "reminders",
+			Schedule: mustParse(t, "*/10 * * * *"),
+			Run: func(ctx context.Context) error {
+				runs <- instance
+				return nil
+			},
+		}))
+		schedulers[instance] = s
+		cancels[instance] = startScheduler(t, s)
+	}
+
+	var leader string
+	for range 3 {
+		clock.BlockUntil(2)
+		clock.Advance(10 * time.Minute)
+		// NOTE: both instances wake up, waiting for both to go back to sleep means the run is over
+		clock.BlockUntil(2)
+		got := received(runs)
+		require.Len(t, got, 1)
+		if leader == "" {
+			leader = got[0]
+		}
+		assert.Equal(t, leader, got[0])
+	}
+
+	// NOTE: the leader releases its lease on shutdown, so the other instance takes over with the next run
+	cancels[leader]()
+	require.NoError(t, schedulers[leader].Wait(context.Background()))
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Minute)
+	follower := "a"
+	if leader == "a" {
+		follower = "b"
+	}
+	assert.Equal(t, follower, <-runs)
+}
+
+func TestMemoryElector(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(start)
+	e := NewMemoryElector(clock)
+
+	ok, err := e.Acquire(ctx, "job", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _ = e.Acquire(ctx, "job", "b", time.Minute)
+	assert.False(t, ok, "held by a")
+	ok, _ = e.Acquire(ctx, "other", "b", time.Minute)
+	assert.True(t, ok, "leases are per key")
+
+	clock.Advance(30 * time.Second)
+	ok, _ = e.Acquire(ctx, "job", "a", time.Minute)
+	assert.True(t, ok, "a renews")
+	clock.Advance(45 * time.Second)
+	ok, _ = e.Acquire(ctx, "job", "b", time.Minute)
+	assert.False(t, ok, "renewed lease is still valid")
+
+	clock.Advance(15 * time.Second)
+	ok, _ = e.Acquire(ctx, "job", "b", time.Minute)
+	assert.True(t, ok, "expired lease is taken over")
+
+	require.NoError(t, e.Release(ctx, "job", "a"))
+	ok, _ = e.Acquire(ctx, "job", "a", time.Minute)
+	assert.False(t, ok, "release by a non-holder does nothing")
+	require.NoError(t, e.Release(ctx, "job", "b"))
+	ok, _ = e.Acquire(ctx, "job", "a", time.Minute)
+	assert.True(t, ok)
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"plants/plants"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CareStore keeps the care log of plants. It lives next to the plants, because overdue care
+// depends on the intervals of each plant as well as on its history.
+type CareStore interface {
+	// CreateCareEvent records an event, it returns ErrorResourceDoesNotExist if the plant doesnt exist
+	CreateCareEvent(ctx context.Context, event plants.CareEvent) (*plants.CareEvent, error)
+	// ListCareEvents returns the events of a plant, newest first, an empty careType lists all of them
+	ListCareEvents(ctx context.Context, plantID string, careType plants.CareType) ([]plants.CareEvent, error)
+	// OverdueCare returns the plants whose last event of a type is older than their interval for it at now,
+	// an empty careType checks all of them. Care that never happened comes first, the rest by how long it is overdue.
+	// Plants without their own watering interval use the one of their species from speciesWatering, see Plant.CareSchedule.
+	OverdueCare(ctx context.Context, now time.Time, careType plants.CareType, speciesWatering map[string]int) ([]plants.OverdueCare, error)
+}
+
+func compareCareTime(e plants.CareEvent, t time.Time) int {
+	return e.Time.Compare(t)
+}
+
+func (s *MemoryStore) CreateCareEvent(ctx context.Context, event plants.CareEvent) (*plants.CareEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.ContainsFunc(s.items, func(p plants.Plant) bool { return p.ID == event.PlantID }) {
+		return nil, ErrorResourceDoesNotExist{Err: fmt.Errorf("plant with ID '%s' does not exist", event.PlantID)}
+	}
+
+	// NOTE: lazily, so the zero value MemoryStore keeps working
+	if s.care == nil {
+		s.care = make(map[string][]plants.CareEvent)
+		s.lastCare = make(map[string]map[plants.CareType]time.Time)
+	}
+	event.ID = uuid.New().String()
+	events := s.care[event.PlantID]
+	// NOTE: events can be logged after the fact, so they are inserted after any with the same time
+	i, found := slices.BinarySearchFunc(events, event.Time, compareCareTime)
+	for found && i < len(events) && events[i].Time.Equal(event.Time) {
+		i++
+	}
+	s.care[event.PlantID] = slices.Insert(events, i, event)
+
+	last, ok := s.lastCare[event.PlantID]
+	if !ok {
+		last = make(map[plants.CareType]time.Time)
+		s.lastCare[event.PlantID] = last
+	}
+	if event.Time.After(last[event.Type]) {
+		last[event.Type] = event.Time
+	}
+	return &event, nil
+}
+
+func (s *MemoryStore) ListCareEvents(ctx context.Context, plantID string, careType plants.CareType) ([]plants.CareEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := s.care[plantID]
+	list := make([]plants.CareEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		if careType == "" || events[i].Type == careType {
+			list = append(list, events[i])
+		}
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) OverdueCare(ctx context.Context, now time.Time, careType plants.CareType, speciesWatering map[string]int) ([]plants.OverdueCare, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// NOTE: only the latest time per type is looked at, so this doesnt grow with the length of the care log
+	overdue := []plants.OverdueCare{}
+	for _, p := range s.items {
+		for t, days := range p.CareSchedule(speciesWatering) {
+			if careType != "" && t != careType {
+				continue
+			}
+			item := plants.OverdueCare{PlantID: p.ID, PlantName: p.Name, Type: t, IntervalDays: days}
+			if last, ok := s.lastCare[p.ID][t]; ok {
+				due := last.AddDate(0, 0, days)
+				if !now.After(due) {
+					continue
+				}
+				item.LastCaredAt, item.DueAt = &last, &due
+			}
+			overdue = append(overdue, item)
+		}
+	}
+
+	slices.SortFunc(overdue, func(a, b plants.OverdueCare) int {
+		switch {
+		case a.DueAt == nil && b.DueAt != nil:
+			return -1
+		case a.DueAt != nil && b.DueAt == nil:
+			return 1
+		case a.DueAt != nil && !a.DueAt.Equal(*b.DueAt):
+			return a.DueAt.Compare(*b.DueAt)
+		}
+		return cmp.Or(cmp.Compare(a.PlantID, b.PlantID), cmp.Compare(a.Type, b.Type))
+	})
+	return overdue, nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"plants/plants"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreCareEvents(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore([]plants.Plant{{ID: "1", Name: "fern"}})
+	for _, e := range []plants.CareEvent{
+		{PlantID: "1", Type: plants.CareWatering, Time: day.Add(2 * time.Hour), Amount: 200},
+		{PlantID: "1", Type: plants.CarePruning, Time: day.Add(time.Hour)},
+		{PlantID: "1", Type: plants.CareWatering, Time: day, Amount: 100},
+	} {
+		_, err := s.CreateCareEvent(ctx, e)
+		require.NoError(t, err)
+	}
+
+	_, err := s.CreateCareEvent(ctx, plants.CareEvent{PlantID: "2", Type: plants.CareWatering, Time: day})
+	assert.ErrorAs(t, err, &ErrorResourceDoesNotExist{})
+
+	all, err := s.ListCareEvents(ctx, "1", "")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, []time.Time{day.Add(2 * time.Hour), day.Add(time.Hour), day}, []time.Time{all[0].Time, all[1].Time, all[2].Time})
+
+	watering, err := s.ListCareEvents(ctx, "1", plants.CareWatering)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{200, 100}, []float64{watering[0].Amount, watering[1].Amount})
+
+	_, err = s.Delete(ctx, "1")
+	require.NoError(t, err)
+	all, err = s.ListCareEvents(ctx, "1", "")
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestMemoryStoreOverdueCare(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore([]plants.Plant{
+		{ID: "1", Name: "fern", CareIntervals: map[plants.CareType]int{plants.CareWatering: 2, plants.CareFertilizing: 14}},
+		{ID: "2", Name: "cactus", CareIntervals: map[plants.CareType]int{plants.CareWatering: 10}},
+		{ID: "3", Name: "ivy", CareIntervals: map[plants.CareType]int{plants.CareWatering: 7}},
+		{ID: "4", Name: "moss"},
+		{ID: "5", Name: "monstera", SpeciesID: "monstera"},
+		// NOTE: its own interval wins over the species one
+		{ID: "6", Name: "basil", SpeciesID: "monstera", CareIntervals: map[plants.CareType]int{plants.CareWatering: 14}},
+	})
+	for _, e := range []plants.CareEvent{
+		{PlantID: "1", Type: plants.CareWatering, Time: day.AddDate(0, 0, -5)},
+		// NOTE: logged late, the newer event still counts
+		{PlantID: "1", Type: plants.CareWatering, Time: day.AddDate(0, 0, -3)},
+		{PlantID: "1", Type: plants.CareWatering, Time: day.AddDate(0, 0, -4)},
+		{PlantID: "2", Type: plants.CareWatering, Time: day.AddDate(0, 0, -1)},
+		{PlantID: "3", Type: plants.CareWatering, Time: day.AddDate(0, 0, -9)},
+		{PlantID: "4", Type: plants.CareWatering, Time: day.AddDate(0, 0, -100)},
+		{PlantID: "5", Type: plants.CareWatering, Time: day.AddDate(0, 0, -6)},
+		{PlantID: "6", Type: plants.CareWatering, Time: day.AddDate(0, 0, -6)},
+	} {
+		_, err := s.CreateCareEvent(ctx, e)
+		require.NoError(t, err)
+	}
+
+	type overdue struct {
+		plantID  string
+		careType plants.CareType
+		dueAt    time.Time
+	}
+	tests := map[string]struct {
+		careType plants.CareType
+
+		want []overdue
+	}{
+		"all types": {
+			want: []overdue{
+				{plantID: "1", careType: plants.CareFertilizing},
+				{plantID: "3", careType: plants.CareWatering, dueAt: day.AddDate(0, 0, -2)},
+				{plantID: "1", careType: plants.CareWatering, dueAt: day.AddDate(0, 0, -1)},
+				{plantID: "5", careType: plants.CareWatering, dueAt: day.AddDate(0, 0, -1)},
+			},
+		},
+		"one type": {
+			careType: plants.CareWatering,
+
+			want: []overdue{
+				{plantID: "3", careType: plants.CareWatering, dueAt: day.AddDate(0, 0, -2)},
+				{plantID: "1", careType: plants.CareWatering, dueAt: day.AddDate(0, 0, -1)},
+				{plantID: "5", careType: plants.CareWatering, dueAt: day.AddDate(0, 0, -1)},
+			},
+		},
+		"type nobody schedules": {
+			careType: plants.CareRepotting,
+
+			want: []overdue{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.OverdueCare(ctx, day, tc.careType, map[string]int{"monstera": 5})
+			require.NoError(t, err)
+			gotOverdue := []overdue{}
+			for _, o := range got {
+				item := overdue{plantID: o.PlantID, careType: o.Type}
+				if o.DueAt != nil {
+					item.dueAt = *o.DueAt
+				}
+				gotOverdue = append(gotOverdue, item)
+			}
+			assert.Equal(t, tc.want, gotOverdue)
+		})
+	}
+}
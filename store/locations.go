@@ -0,0 +1,274 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"plants/plants"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// LocationStore keeps the location tree and which location each plant is in. Changes that would break the tree,
+// e.g. deleting a greenhouse that still has beds or overfilling a slot, are reported with ErrorResourceConflict
+type LocationStore interface {
+	// ListLocations returns locations ordered by name, only the ones directly in parentID when it isnt empty
+	ListLocations(ctx context.Context, parentID string) ([]plants.Location, error)
+	FindLocation(ctx context.Context, id string) (*plants.Location, error)
+	CreateLocation(ctx context.Context, location plants.Location) (*plants.Location, error)
+	UpdateLocation(ctx context.Context, location plants.Location) (*plants.Location, error)
+	// DeleteLocation removes an empty location, one with locations or plants in it is a conflict
+	DeleteLocation(ctx context.Context, id string) error
+	// MovePlant places the plant in move.ToLocationID, or takes it out of its location when that is empty,
+	// and records the move with FromLocationID filled in
+	MovePlant(ctx context.Context, move plants.PlantMove) (*plants.PlantMove, error)
+	// ListMoves returns the move history of a plant, oldest first
+	ListMoves(ctx context.Context, plantID string) ([]plants.PlantMove, error)
+	// PlantsIn returns the IDs of plants in the location, with recursive also the ones in every location under it
+	PlantsIn(ctx context.Context, locationID string, recursive bool) ([]string, error)
+	// RemovePlant forgets the location and move history of a deleted plant
+	RemovePlant(ctx context.Context, plantID string) error
+}
+
+func NewMemoryLocationStore() *MemoryLocationStore {
+	return &MemoryLocationStore{
+		items:      make(map[string]plants.Location),
+		placements: make(map[string]string),
+		moves:      make(map[string][]plants.PlantMove),
+	}
+}
+
+type MemoryLocationStore struct {
+	mu sync.RWMutex
+	// items per location ID
+	items map[string]plants.Location
+	// placements is the location ID per plant ID, plants without a location arent in it
+	placements map[string]string
+	// moves per plant ID, oldest first
+	moves map[string][]plants.PlantMove
+}
+
+// ErrorInvalidParent wraps the ErrorResourceDoesNotExist or ErrorResourceConflict returned when a location
+// cant be placed in its parent, so callers can tell it apart from problems with the location itself
+type ErrorInvalidParent struct {
+	Err error
+}
+
+func (e ErrorInvalidParent) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrorInvalidParent) Unwrap() error {
+	return e.Err
+}
+
+func locationNotFound(id string) error {
+	return ErrorResourceDoesNotExist{Err: fmt.Errorf("location with ID '%s' does not exist", id)}
+}
+
+// checkParent makes sure the parent of location exists and can hold it, the caller holds the lock.
+// Problems are returned as ErrorInvalidParent.
+func (s *MemoryLocationStore) checkParent(location plants.Location) error {
+	if location.ParentID == "" {
+		return nil
+	}
+	parent, ok := s.items[location.ParentID]
+	if !ok {
+		return ErrorInvalidParent{Err: ErrorResourceDoesNotExist{Err: fmt.Errorf("parent location with ID '%s' does not exist", location.ParentID)}}
+	}
+	if problem := location.ParentProblem(parent); problem != "" {
+		return ErrorInvalidParent{Err: ErrorResourceConflict{Err: errors.New(problem)}}
+	}
+	return nil
+}
+
+// within reports whether the location with ID ancestorID is id itself or one of the locations it is in,
+// the caller holds the lock
+func (s *MemoryLocationStore) within(id, ancestorID string) bool {
+	// NOTE: seen guards against a tree that already has a cycle, so this always ends
+	seen := make(map[string]bool)
+	for id != "" && !seen[id] {
+		if id == ancestorID {
+			return true
+		}
+		seen[id] = true
+		id = s.items[id].ParentID
+	}
+	return false
+}
+
+// occupancy counts the plants placed directly in a location, the caller holds the lock
+func (s *MemoryLocationStore) occupancy(id string) int {
+	count := 0
+	for _, locationID := range s.placements {
+		if locationID == id {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *MemoryLocationStore) ListLocations(ctx context.Context, parentID string) ([]plants.Location, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]plants.Location, 0)
+	for _, location := range s.items {
+		if parentID == "" || location.ParentID == parentID {
+			list = append(list, location)
+		}
+	}
+	slices.SortFunc(list, func(a, b plants.Location) int { return cmp.Compare(a.Name, b.Name) })
+	return list, nil
+}
+
+func (s *MemoryLocationStore) FindLocation(ctx context.Context, id string) (*plants.Location, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	location, ok := s.items[id]
+	if !ok {
+		return nil, locationNotFound(id)
+	}
+	return &location, nil
+}
+
+func (s *MemoryLocationStore) CreateLocation(ctx context.Context, location plants.Location) (*plants.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkParent(location); err != nil {
+		return nil, err
+	}
+	location.ID = uuid.New().String()
+	s.items[location.ID] = location
+	return &location, nil
+}
+
+func (s *MemoryLocationStore) UpdateLocation(ctx context.Context, location plants.Location) (*plants.Location, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[location.ID]; !ok {
+		return nil, locationNotFound(location.ID)
+	}
+	// NOTE: checked before the parent, whose stored kind would be the old kind of the location itself
+	if location.ParentID != "" && s.within(location.ParentID, location.ID) {
+		return nil, ErrorInvalidParent{Err: ErrorResourceConflict{Err: fmt.Errorf("location '%s' cannot be placed in itself or a location under it", location.Name)}}
+	}
+	if err := s.checkParent(location); err != nil {
+		return nil, err
+	}
+	for _, child := range s.items {
+		if child.ParentID != location.ID {
+			continue
+		}
+		if problem := child.ParentProblem(location); problem != "" {
+			return nil, ErrorResourceConflict{Err: fmt.Errorf("location '%s' is in it and %s", child.Name, problem)}
+		}
+	}
+	if count := s.occupancy(location.ID); location.Kind == plants.LocationSlot && count > location.Capacity {
+		return nil, ErrorResourceConflict{Err: fmt.Errorf("slot '%s' holds %d plant(-s), more than a capacity of %d", location.Name, count, location.Capacity)}
+	}
+	s.items[location.ID] = location
+	return &location, nil
+}
+
+func (s *MemoryLocationStore) DeleteLocation(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	location, ok := s.items[id]
+	if !ok {
+		return locationNotFound(id)
+	}
+	children := 0
+	for _, child := range s.items {
+		if child.ParentID == id {
+			children++
+		}
+	}
+	if children > 0 {
+		return ErrorResourceConflict{Err: fmt.Errorf("location '%s' still contains %d location(-s)", location.Name, children)}
+	}
+	if count := s.occupancy(id); count > 0 {
+		return ErrorResourceConflict{Err: fmt.Errorf("location '%s' still holds %d plant(-s)", location.Name, count)}
+	}
+	delete(s.items, id)
+	return nil
+}
+
+func (s *MemoryLocationStore) MovePlant(ctx context.Context, move plants.PlantMove) (*plants.PlantMove, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	move.FromLocationID = s.placements[move.PlantID]
+	if move.FromLocationID == move.ToLocationID {
+		if move.ToLocationID == "" {
+			return nil, ErrorResourceConflict{Err: fmt.Errorf("plant with ID '%s' has no location", move.PlantID)}
+		}
+		return nil, ErrorResourceConflict{Err: fmt.Errorf("plant with ID '%s' is already in location with ID '%s'", move.PlantID, move.ToLocationID)}
+	}
+
+	if move.ToLocationID == "" {
+		delete(s.placements, move.PlantID)
+	} else {
+		to, ok := s.items[move.ToLocationID]
+		if !ok {
+			return nil, locationNotFound(move.ToLocationID)
+		}
+		if count := s.occupancy(to.ID); to.Kind == plants.LocationSlot && count >= to.Capacity {
+			return nil, ErrorResourceConflict{Err: fmt.Errorf("slot '%s' is full, it holds %d plant(-s)", to.Name, count)}
+		}
+		s.placements[move.PlantID] = to.ID
+	}
+	s.moves[move.PlantID] = append(s.moves[move.PlantID], move)
+	return &move, nil
+}
+
+func (s *MemoryLocationStore) ListMoves(ctx context.Context, plantID string) ([]plants.PlantMove, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]plants.PlantMove, len(s.moves[plantID]))
+	copy(list, s.moves[plantID])
+	return list, nil
+}
+
+func (s *MemoryLocationStore) PlantsIn(ctx context.Context, locationID string, recursive bool) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.items[locationID]; !ok {
+		return nil, locationNotFound(locationID)
+	}
+
+	under := map[string]bool{locationID: true}
+	if recursive {
+		// NOTE: walks the tree breadth first from locationID, children are looked up by scanning all locations
+		// which is fine for the few hundred locations a grower has
+		queue := []string{locationID}
+		for len(queue) > 0 {
+			parentID := queue[0]
+			queue = queue[1:]
+			for id, location := range s.items {
+				if location.ParentID == parentID && !under[id] {
+					under[id] = true
+					queue = append(queue, id)
+				}
+			}
+		}
+	}
+
+	plantIDs := make([]string, 0)
+	for plantID, placedIn := range s.placements {
+		if under[placedIn] {
+			plantIDs = append(plantIDs, plantID)
+		}
+	}
+	slices.Sort(plantIDs)
+	return plantIDs, nil
+}
+
+func (s *MemoryLocationStore) RemovePlant(ctx context.Context, plantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.placements, plantID)
+	delete(s.moves, plantID)
+	return nil
+}
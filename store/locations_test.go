@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"plants/plants"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLocationStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryLocationStore()
+	create := func(name string, kind plants.LocationKind, parentID string, capacity int) plants.Location {
+		t.Helper()
+		location, err := s.CreateLocation(ctx, plants.Location{Name: name, Kind: kind, ParentID: parentID, Capacity: capacity})
+		require.NoError(t, err)
+		return *location
+	}
+	move := func(plantID, locationID string) error {
+		_, err := s.MovePlant(ctx, plants.PlantMove{PlantID: plantID, ToLocationID: locationID, Time: time.Now()})
+		return err
+	}
+	site := create("Riga", plants.LocationSite, "", 0)
+	greenhouseA := create("Greenhouse A", plants.LocationGreenhouse, site.ID, 0)
+	greenhouseB := create("Greenhouse B", plants.LocationGreenhouse, site.ID, 0)
+	bed := create("Bed 1", plants.LocationBed, greenhouseB.ID, 0)
+	slot := create("Slot 1", plants.LocationSlot, bed.ID, 1)
+
+	_, err := s.CreateLocation(ctx, plants.Location{Name: "Greenhouse C", Kind: plants.LocationGreenhouse, ParentID: bed.ID})
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "a greenhouse cannot be placed in a bed")
+
+	require.NoError(t, move("basil", slot.ID))
+	err = move("mint", slot.ID)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "slot 'Slot 1' is full, it holds 1 plant(-s)")
+	require.NoError(t, move("mint", bed.ID))
+	require.NoError(t, move("fern", greenhouseA.ID))
+
+	plantIDs, err := s.PlantsIn(ctx, greenhouseB.ID, false)
+	require.NoError(t, err)
+	assert.Empty(t, plantIDs)
+	plantIDs, err = s.PlantsIn(ctx, greenhouseB.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"basil", "mint"}, plantIDs)
+	plantIDs, err = s.PlantsIn(ctx, site.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"basil", "fern", "mint"}, plantIDs)
+
+	// NOTE: moving a bed to another greenhouse takes its slots and plants along
+	bed.ParentID = greenhouseA.ID
+	_, err = s.UpdateLocation(ctx, bed)
+	require.NoError(t, err)
+	plantIDs, err = s.PlantsIn(ctx, greenhouseA.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"basil", "fern", "mint"}, plantIDs)
+
+	bed.Kind = plants.LocationSlot
+	bed.Capacity = 5
+	_, err = s.UpdateLocation(ctx, bed)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "location 'Slot 1' is in it and a slot cannot be placed in a slot")
+
+	// NOTE: a slot fits in the bed as it is stored, but the bed itself is the one turning into that slot
+	bed.ParentID = bed.ID
+	_, err = s.UpdateLocation(ctx, bed)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "location 'Bed 1' cannot be placed in itself or a location under it")
+	greenhouseA.ParentID = slot.ID
+	_, err = s.UpdateLocation(ctx, greenhouseA)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "location 'Greenhouse A' cannot be placed in itself or a location under it")
+	bed.Kind, bed.Capacity, bed.ParentID = plants.LocationBed, 0, greenhouseA.ID
+
+	err = s.DeleteLocation(ctx, greenhouseA.ID)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "location 'Greenhouse A' still contains 1 location(-s)")
+	err = s.DeleteLocation(ctx, slot.ID)
+	assert.EqualError(t, err, "location 'Slot 1' still holds 1 plant(-s)")
+
+	require.NoError(t, move("basil", ""))
+	err = move("basil", "")
+	assert.EqualError(t, err, "plant with ID 'basil' has no location")
+	require.NoError(t, s.DeleteLocation(ctx, slot.ID))
+
+	moves, err := s.ListMoves(ctx, "basil")
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	assert.Equal(t, [2]string{"", slot.ID}, [2]string{moves[0].FromLocationID, moves[0].ToLocationID})
+	assert.Equal(t, [2]string{slot.ID, ""}, [2]string{moves[1].FromLocationID, moves[1].ToLocationID})
+
+	require.NoError(t, s.RemovePlant(ctx, "mint"))
+	moves, err = s.ListMoves(ctx, "mint")
+	require.NoError(t, err)
+	assert.Empty(t, moves)
+	plantIDs, err = s.PlantsIn(ctx, bed.ID, false)
+	require.NoError(t, err)
+	assert.Empty(t, plantIDs)
+}
+
+func TestMemoryLocationStoreCapacity(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryLocationStore()
+	site, err := s.CreateLocation(ctx, plants.Location{Name: "Riga", Kind: plants.LocationSite})
+	require.NoError(t, err)
+	slot, err := s.CreateLocation(ctx, plants.Location{Name: "Slot 1", Kind: plants.LocationSlot, ParentID: site.ID, Capacity: 2})
+	require.NoError(t, err)
+	for _, plantID := range []string{"basil", "mint"} {
+		_, err := s.MovePlant(ctx, plants.PlantMove{PlantID: plantID, ToLocationID: slot.ID})
+		require.NoError(t, err)
+	}
+
+	slot.Capacity = 1
+	_, err = s.UpdateLocation(ctx, *slot)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "slot 'Slot 1' holds 2 plant(-s), more than a capacity of 1")
+}
+
+func TestMemoryLocationStorePlantsInCycle(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryLocationStore()
+	// NOTE: updates reject cycles, this one is set up directly to make sure the walk still ends
+	s.items["a"] = plants.Location{ID: "a", Name: "A", Kind: plants.LocationBed, ParentID: "b"}
+	s.items["b"] = plants.Location{ID: "b", Name: "B", Kind: plants.LocationBed, ParentID: "a"}
+	s.placements["basil"] = "b"
+
+	plantIDs, err := s.PlantsIn(ctx, "a", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"basil"}, plantIDs)
+}
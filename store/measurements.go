@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"plants/plants"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MeasurementStore keeps the growth history of plants, ranges include from and exclude to,
+// a zero time leaves that side of the range open
+type MeasurementStore interface {
+	CreateMeasurement(ctx context.Context, m plants.Measurement) (*plants.Measurement, error)
+	// ListMeasurements returns the readings of a plant within the range, oldest first
+	ListMeasurements(ctx context.Context, plantID string, from, to time.Time) ([]plants.Measurement, error)
+	// LatestMeasurement returns the most recent reading by Time, it returns ErrorResourceDoesNotExist if there is none
+	LatestMeasurement(ctx context.Context, plantID string) (*plants.Measurement, error)
+	// DailyMeasurements downsamples the readings within the range to calendar days of loc
+	DailyMeasurements(ctx context.Context, plantID string, from, to time.Time, loc *time.Location) ([]plants.DailySummary, error)
+	// DeleteMeasurements removes the whole history of a plant, e.g. when the plant is deleted
+	DeleteMeasurements(ctx context.Context, plantID string) error
+}
+
+func NewMemoryMeasurementStore() *MemoryMeasurementStore {
+	return &MemoryMeasurementStore{
+		items: make(map[string][]plants.Measurement),
+	}
+}
+
+type MemoryMeasurementStore struct {
+	mu sync.RWMutex
+	// items per plant ID, sorted by time
+	items map[string][]plants.Measurement
+}
+
+func compareTime(m plants.Measurement, t time.Time) int {
+	return m.Time.Compare(t)
+}
+
+func (s *MemoryMeasurementStore) CreateMeasurement(ctx context.Context, m plants.Measurement) (*plants.Measurement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.ID = uuid.New().String()
+	items := s.items[m.PlantID]
+	// NOTE: readings can arrive out of order (e.g. a sensor catching up), so they are inserted after any with the same time
+	i, found := slices.BinarySearchFunc(items, m.Time, compareTime)
+	for found && i < len(items) && items[i].Time.Equal(m.Time) {
+		i++
+	}
+	s.items[m.PlantID] = slices.Insert(items, i, m)
+	return &m, nil
+}
+
+func (s *MemoryMeasurementStore) ListMeasurements(ctx context.Context, plantID string, from, to time.Time) ([]plants.Measurement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := s.items[plantID]
+	start, end := 0, len(items)
+	if !from.IsZero() {
+		start, _ = slices.BinarySearchFunc(items, from, compareTime)
+	}
+	if !to.IsZero() {
+		end, _ = slices.BinarySearchFunc(items, to, compareTime)
+	}
+	if start >= end {
+		return []plants.Measurement{}, nil
+	}
+	return slices.Clone(items[start:end]), nil
+}
+
+func (s *MemoryMeasurementStore) LatestMeasurement(ctx context.Context, plantID string) (*plants.Measurement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := s.items[plantID]
+	if len(items) == 0 {
+		return nil, ErrorResourceDoesNotExist{Err: fmt.Errorf("plant with ID '%s' has no measurements", plantID)}
+	}
+	latest := items[len(items)-1]
+	return &latest, nil
+}
+
+func (s *MemoryMeasurementStore) DeleteMeasurements(ctx context.Context, plantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, plantID)
+	return nil
+}
+
+func (s *MemoryMeasurementStore) DailyMeasurements(ctx context.Context, plantID string, from, to time.Time, loc *time.Location) ([]plants.DailySummary, error) {
+	items, err := s.ListMeasurements(ctx, plantID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return plants.SummarizeDaily(items, loc), nil
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"plants/plants"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func values(measurements []plants.Measurement) []float64 {
+	values := []float64{}
+	for _, m := range measurements {
+		values = append(values, m.Value)
+	}
+	return values
+}
+
+func TestMemoryMeasurementStoreList(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryMeasurementStore()
+	// NOTE: inserted out of order on purpose, the store keeps them sorted by time
+	for _, m := range []plants.Measurement{
+		{PlantID: "1", Time: day.Add(2 * time.Hour), Value: 12, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: "1", Time: day, Value: 10, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: "1", Time: day.Add(4 * time.Hour), Value: 14, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: "1", Time: day.Add(2 * time.Hour), Value: 13, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: "2", Time: day.Add(time.Hour), Value: 99, Unit: plants.UNIT_CENTIMETERS},
+	} {
+		_, err := s.CreateMeasurement(ctx, m)
+		require.NoError(t, err)
+	}
+
+	tests := map[string]struct {
+		plantID  string
+		from, to time.Time
+
+		want []float64
+	}{
+		"everything": {
+			plantID: "1",
+
+			want: []float64{10, 12, 13, 14},
+		},
+		"from is inclusive": {
+			plantID: "1",
+			from:    day.Add(2 * time.Hour),
+
+			want: []float64{12, 13, 14},
+		},
+		"to is exclusive": {
+			plantID: "1",
+			to:      day.Add(4 * time.Hour),
+
+			want: []float64{10, 12, 13},
+		},
+		"empty range": {
+			plantID: "1",
+			from:    day.Add(time.Hour),
+			to:      day.Add(time.Hour),
+
+			want: []float64{},
+		},
+		"other plant": {
+			plantID: "3",
+
+			want: []float64{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.ListMeasurements(ctx, tc.plantID, tc.from, tc.to)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, values(got))
+		})
+	}
+
+	latest, err := s.LatestMeasurement(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, 14.0, latest.Value)
+	_, err = s.LatestMeasurement(ctx, "3")
+	assert.ErrorAs(t, err, &ErrorResourceDoesNotExist{})
+}
+
+func TestMemoryMeasurementStoreDaily(t *testing.T) {
+	ctx := context.Background()
+	riga, err := time.LoadLocation("Europe/Riga")
+	require.NoError(t, err)
+	s := NewMemoryMeasurementStore()
+	for _, m := range []plants.Measurement{
+		{PlantID: "1", Time: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), Value: 10, Unit: plants.UNIT_CENTIMETERS},
+		{PlantID: "1", Time: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC), Value: 120, Unit: plants.UNIT_MILLIMETERS},
+		{PlantID: "1", Time: time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC), Value: 5, Unit: plants.UNIT_INCHES},
+	} {
+		_, err := s.CreateMeasurement(ctx, m)
+		require.NoError(t, err)
+	}
+
+	tests := map[string]struct {
+		loc *time.Location
+
+		want []plants.DailySummary
+	}{
+		"utc": {
+			loc: time.UTC,
+
+			want: []plants.DailySummary{{Date: "2024-05-01", Min: 10, Max: 12.7, Avg: 11.566666666666668, Count: 3}},
+		},
+		"late readings move to the next day further east": {
+			loc: riga,
+
+			want: []plants.DailySummary{
+				{Date: "2024-05-01", Min: 10, Max: 12, Avg: 11, Count: 2},
+				{Date: "2024-05-02", Min: 12.7, Max: 12.7, Avg: 12.7, Count: 1},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.DailyMeasurements(ctx, "1", time.Time{}, time.Time{}, tc.loc)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
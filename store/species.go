@@ -0,0 +1,147 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"plants/plants"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// SpeciesStore keeps the species catalog, scientific names are unique regardless of case
+// and duplicates are reported with ErrorResourceConflict
+type SpeciesStore interface {
+	// ListSpecies returns the whole catalog, ordered by scientific name
+	ListSpecies(ctx context.Context) ([]plants.Species, error)
+	FindSpecies(ctx context.Context, id string) (*plants.Species, error)
+	// FindSpeciesByName looks a species up by its scientific name, ignoring case
+	FindSpeciesByName(ctx context.Context, scientificName string) (*plants.Species, error)
+	CreateSpecies(ctx context.Context, species plants.Species) (*plants.Species, error)
+	UpdateSpecies(ctx context.Context, species plants.Species) (*plants.Species, error)
+	DeleteSpecies(ctx context.Context, id string) error
+}
+
+func NewMemorySpeciesStore() *MemorySpeciesStore {
+	return &MemorySpeciesStore{
+		items: make(map[string]plants.Species),
+	}
+}
+
+type MemorySpeciesStore struct {
+	mu sync.RWMutex
+	// items per species ID
+	items map[string]plants.Species
+}
+
+func speciesNotFound(id string) error {
+	return ErrorResourceDoesNotExist{Err: fmt.Errorf("species with ID '%s' does not exist", id)}
+}
+
+// checkUnique rejects a scientific name another species already has, the caller holds the lock
+func (s *MemorySpeciesStore) checkUnique(species plants.Species) error {
+	for _, existing := range s.items {
+		if existing.ID != species.ID && strings.EqualFold(existing.ScientificName, species.ScientificName) {
+			return ErrorResourceConflict{Err: fmt.Errorf("species '%s' already exists", existing.ScientificName)}
+		}
+	}
+	return nil
+}
+
+func (s *MemorySpeciesStore) ListSpecies(ctx context.Context) ([]plants.Species, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]plants.Species, 0, len(s.items))
+	for _, species := range s.items {
+		list = append(list, species)
+	}
+	slices.SortFunc(list, func(a, b plants.Species) int { return cmp.Compare(a.ScientificName, b.ScientificName) })
+	return list, nil
+}
+
+func (s *MemorySpeciesStore) FindSpecies(ctx context.Context, id string) (*plants.Species, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	species, ok := s.items[id]
+	if !ok {
+		return nil, speciesNotFound(id)
+	}
+	return &species, nil
+}
+
+func (s *MemorySpeciesStore) FindSpeciesByName(ctx context.Context, scientificName string) (*plants.Species, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, species := range s.items {
+		if strings.EqualFold(species.ScientificName, scientificName) {
+			return &species, nil
+		}
+	}
+	return nil, ErrorResourceDoesNotExist{Err: fmt.Errorf("species '%s' does not exist", scientificName)}
+}
+
+func (s *MemorySpeciesStore) CreateSpecies(ctx context.Context, species plants.Species) (*plants.Species, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	species.ID = uuid.New().String()
+	if err := s.checkUnique(species); err != nil {
+		return nil, err
+	}
+	s.items[species.ID] = species
+	return &species, nil
+}
+
+func (s *MemorySpeciesStore) UpdateSpecies(ctx context.Context, species plants.Species) (*plants.Species, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[species.ID]; !ok {
+		return nil, speciesNotFound(species.ID)
+	}
+	if err := s.checkUnique(species); err != nil {
+		return nil, err
+	}
+	s.items[species.ID] = species
+	return &species, nil
+}
+
+func (s *MemorySpeciesStore) DeleteSpecies(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return speciesNotFound(id)
+	}
+	delete(s.items, id)
+	return nil
+}
+
+// LoadSpecies adds species to the catalog in bulk, ones already there by scientific name are updated in place
+// so loading the same dataset again is harmless. It returns how many species were created and updated.
+func LoadSpecies(ctx context.Context, speciesStore SpeciesStore, species []plants.Species) (int, int, error) {
+	created, updated := 0, 0
+	for i, sp := range species {
+		if problems := sp.Valid(); len(problems) > 0 {
+			return created, updated, fmt.Errorf("species %d '%s' is invalid: %v", i, sp.ScientificName, problems)
+		}
+		existing, err := speciesStore.FindSpeciesByName(ctx, sp.ScientificName)
+		if err != nil && !errors.As(err, &ErrorResourceDoesNotExist{}) {
+			return created, updated, fmt.Errorf("find species '%s': %w", sp.ScientificName, err)
+		}
+		if existing != nil {
+			sp.ID = existing.ID
+			if _, err := speciesStore.UpdateSpecies(ctx, sp); err != nil {
+				return created, updated, fmt.Errorf("update species '%s': %w", sp.ScientificName, err)
+			}
+			updated++
+			continue
+		}
+		if _, err := speciesStore.CreateSpecies(ctx, sp); err != nil {
+			return created, updated, fmt.Errorf("create species '%s': %w", sp.ScientificName, err)
+		}
+		created++
+	}
+	return created, updated, nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"plants/plants"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSpecies(t *testing.T) {
+	ctx := context.Background()
+	bundled, err := plants.BundledSpecies()
+	require.NoError(t, err)
+	s := NewMemorySpeciesStore()
+
+	created, updated, err := LoadSpecies(ctx, s, bundled)
+	require.NoError(t, err)
+	assert.Equal(t, len(bundled), created)
+	assert.Zero(t, updated)
+
+	// NOTE: loading again matches species by name regardless of case, so nothing is duplicated
+	changed := bundled[0]
+	changed.ScientificName = "MONSTERA DELICIOSA"
+	changed.Genus = "MONSTERA"
+	changed.WateringInterval = 10
+	before, err := s.FindSpeciesByName(ctx, "Monstera deliciosa")
+	require.NoError(t, err)
+	created, updated, err = LoadSpecies(ctx, s, []plants.Species{changed, bundled[1]})
+	require.NoError(t, err)
+	assert.Zero(t, created)
+	assert.Equal(t, 2, updated)
+	after, err := s.FindSpecies(ctx, before.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 10, after.WateringInterval)
+
+	list, err := s.ListSpecies(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, len(bundled))
+
+	_, _, err = LoadSpecies(ctx, s, []plants.Species{{ScientificName: "Nonsense"}})
+	assert.ErrorContains(t, err, "species 0 'Nonsense' is invalid")
+}
+
+func TestMemorySpeciesStoreUnique(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySpeciesStore()
+	basil := plants.Species{ScientificName: "Ocimum basilicum", Family: "Lamiaceae", Genus: "Ocimum", WateringInterval: 2, Light: plants.LightDirect, MatureHeight: plants.HeightRange{Min: 30, Max: 60}}
+	created, err := s.CreateSpecies(ctx, basil)
+	require.NoError(t, err)
+
+	_, err = s.CreateSpecies(ctx, basil)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+	assert.EqualError(t, err, "species 'Ocimum basilicum' already exists")
+
+	mint := basil
+	mint.ScientificName = "Mentha spicata"
+	mint.Genus = "Mentha"
+	mintCreated, err := s.CreateSpecies(ctx, mint)
+	require.NoError(t, err)
+	mintCreated.ScientificName = "ocimum BASILICUM"
+	_, err = s.UpdateSpecies(ctx, *mintCreated)
+	assert.ErrorAs(t, err, &ErrorResourceConflict{})
+
+	// NOTE: a species keeps its own name when updated
+	created.WateringInterval = 3
+	_, err = s.UpdateSpecies(ctx, *created)
+	assert.NoError(t, err)
+}
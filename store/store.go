@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 
 	"log/slog"
 	"plants/log"
@@ -15,6 +18,25 @@ type Store interface {
 	Find(ctx context.Context, id string) (*plants.Plant, error)
 	List(ctx context.Context) ([]plants.Plant, error)
 	Create(ctx context.Context, plant plants.Plant) (*plants.Plant, error)
+	// Update replaces the plant with the same ID, it returns ErrorResourceDoesNotExist if there is none.
+	// LastWateredAt is kept, it follows the care log through SetLastWatered.
+	Update(ctx context.Context, plant plants.Plant) (*plants.Plant, error)
+	// Delete removes a plant and returns it, it returns ErrorResourceDoesNotExist if there is none
+	Delete(ctx context.Context, id string) (*plants.Plant, error)
+	// SetHeight changes only the height of a plant to a reading taken at measuredAt, unless a reading taken
+	// later already set it. updated reports whether the height was set.
+	SetHeight(ctx context.Context, id string, height int, measuredAt time.Time) (plant *plants.Plant, updated bool, err error)
+	// SetLastWatered changes only LastWateredAt of a plant, unless it is already at or after wateredAt.
+	// updated reports whether it was set.
+	SetLastWatered(ctx context.Context, id string, wateredAt time.Time) (plant *plants.Plant, updated bool, err error)
+	// Check reports whether the store is reachable, it is used as a readiness probe
+	Check(ctx context.Context) error
+}
+
+// Closer is implemented by stores that hold resources (connections, files) which have to be
+// released on shutdown, after the http server has stopped serving requests
+type Closer interface {
+	Close(ctx context.Context) error
 }
 
 func NewMemoryStore(items []plants.Plant) *MemoryStore {
@@ -24,7 +46,14 @@ func NewMemoryStore(items []plants.Plant) *MemoryStore {
 }
 
 type MemoryStore struct {
+	mu    sync.RWMutex
 	items []plants.Plant
+	// care events per plant ID, sorted by time
+	care map[string][]plants.CareEvent
+	// lastCare indexes the latest care event time per plant ID and type for overdue queries
+	lastCare map[string]map[plants.CareType]time.Time
+	// heightAt is when the reading behind the height of a plant was taken, per plant ID
+	heightAt map[string]time.Time
 }
 
 type ErrorResourceDoesNotExist struct {
@@ -35,12 +64,23 @@ func (e ErrorResourceDoesNotExist) Error() string {
 	return e.Err.Error()
 }
 
+// ErrorResourceConflict is returned when a change would break a uniqueness rule, e.g. a duplicate name
+type ErrorResourceConflict struct {
+	Err error
+}
+
+func (e ErrorResourceConflict) Error() string {
+	return e.Err.Error()
+}
+
 func (s *MemoryStore) Find(ctx context.Context, id string) (*plants.Plant, error) {
 	// fancy slices index generic function
 	// index := slices.IndexFunc(s.items, func(p plants.Plant) bool { return p.ID == id })
 
 	logger := log.LoggerFromCtx(ctx)
-	logger.Debug("some kind of debug message from store package", slog.Int("additionalField", 42))
+	logger.DebugContext(ctx, "some kind of debug message from store package", slog.Int("additionalField", 42))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for _, p := range s.items {
 		if p.ID == id {
 			return &p, nil
@@ -53,11 +93,86 @@ func (s *MemoryStore) Find(ctx context.Context, id string) (*plants.Plant, error
 }
 
 func (s *MemoryStore) List(ctx context.Context) ([]plants.Plant, error) {
-	return s.items, nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// NOTE: a copy, so callers can keep using it while the store is modified
+	return slices.Clone(s.items), nil
 }
 
 func (s *MemoryStore) Create(ctx context.Context, plant plants.Plant) (*plants.Plant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	plant.ID = uuid.New().String()
 	s.items = append(s.items, plant)
 	return &plant, nil
 }
+
+func (s *MemoryStore) Update(ctx context.Context, plant plants.Plant) (*plants.Plant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.items, func(p plants.Plant) bool { return p.ID == plant.ID })
+	if i < 0 {
+		return nil, ErrorResourceDoesNotExist{Err: fmt.Errorf("plant with ID '%s' does not exist", plant.ID)}
+	}
+	plant.LastWateredAt = s.items[i].LastWateredAt
+	s.items[i] = plant
+	return &plant, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) (*plants.Plant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.items, func(p plants.Plant) bool { return p.ID == id })
+	if i < 0 {
+		return nil, ErrorResourceDoesNotExist{Err: fmt.Errorf("plant with ID '%s' does not exist", id)}
+	}
+	deleted := s.items[i]
+	s.items = slices.Delete(s.items, i, i+1)
+	delete(s.care, id)
+	delete(s.lastCare, id)
+	delete(s.heightAt, id)
+	return &deleted, nil
+}
+
+func (s *MemoryStore) SetHeight(ctx context.Context, id string, height int, measuredAt time.Time) (*plants.Plant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.items, func(p plants.Plant) bool { return p.ID == id })
+	if i < 0 {
+		return nil, false, ErrorResourceDoesNotExist{Err: fmt.Errorf("plant with ID '%s' does not exist", id)}
+	}
+	if last, ok := s.heightAt[id]; ok && measuredAt.Before(last) {
+		plant := s.items[i]
+		return &plant, false, nil
+	}
+
+	if s.heightAt == nil {
+		s.heightAt = make(map[string]time.Time)
+	}
+	s.heightAt[id] = measuredAt
+	s.items[i].Height = height
+	plant := s.items[i]
+	return &plant, true, nil
+}
+
+func (s *MemoryStore) SetLastWatered(ctx context.Context, id string, wateredAt time.Time) (*plants.Plant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.items, func(p plants.Plant) bool { return p.ID == id })
+	if i < 0 {
+		return nil, false, ErrorResourceDoesNotExist{Err: fmt.Errorf("plant with ID '%s' does not exist", id)}
+	}
+	if last := s.items[i].LastWateredAt; last != nil && !wateredAt.After(*last) {
+		plant := s.items[i]
+		return &plant, false, nil
+	}
+
+	s.items[i].LastWateredAt = &wateredAt
+	plant := s.items[i]
+	return &plant, true, nil
+}
+
+func (s *MemoryStore) Check(ctx context.Context) error {
+	// NOTE: in memory store is always reachable, a DB implementation would ping its connection here
+	return nil
+}
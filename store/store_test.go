@@ -7,8 +7,10 @@ import (
 	"plants/plants"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMemoryStoreList(t *testing.T) {
@@ -144,3 +146,137 @@ func TestMemoryStoreCreate(t *testing.T) {
 		})
 	}
 }
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	slog.SetDefault(log.NoopLogger())
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	later := day.AddDate(0, 0, 1)
+
+	tests := map[string]struct {
+		store *MemoryStore
+		plant plants.Plant
+
+		want    []plants.Plant
+		wantErr bool
+	}{
+		"updates an item": {
+			store: &MemoryStore{items: []plants.Plant{{ID: "1", Name: "foo", Height: 4}, {ID: "2", Name: "bar", Height: 3}}},
+			plant: plants.Plant{ID: "2", Name: "bar", Height: 5},
+
+			want: []plants.Plant{{ID: "1", Name: "foo", Height: 4}, {ID: "2", Name: "bar", Height: 5}},
+		},
+		"keeps last watered time": {
+			store: &MemoryStore{items: []plants.Plant{{ID: "1", Name: "foo", LastWateredAt: &day}}},
+			plant: plants.Plant{ID: "1", Name: "bar", LastWateredAt: &later},
+
+			want: []plants.Plant{{ID: "1", Name: "bar", LastWateredAt: &day}},
+		},
+		"returns error if item not found": {
+			store: &MemoryStore{items: []plants.Plant{{ID: "1", Name: "foo", Height: 4}}},
+			plant: plants.Plant{ID: "2", Name: "bar", Height: 5},
+
+			want:    []plants.Plant{{ID: "1", Name: "foo", Height: 4}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.store.Update(ctx, tc.plant)
+			if tc.wantErr {
+				assert.ErrorAs(t, err, &ErrorResourceDoesNotExist{})
+			} else {
+				assert.NoError(t, err)
+			}
+
+			got, _ := tc.store.List(ctx)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMemoryStoreDelete(t *testing.T) {
+	slog.SetDefault(log.NoopLogger())
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		store *MemoryStore
+		id    string
+
+		wantDeleted *plants.Plant
+		want        []plants.Plant
+		wantErr     bool
+	}{
+		"deletes an item": {
+			store: &MemoryStore{items: []plants.Plant{{ID: "1", Name: "foo", Height: 4}, {ID: "2", Name: "bar", Height: 3}}},
+			id:    "1",
+
+			wantDeleted: &plants.Plant{ID: "1", Name: "foo", Height: 4},
+			want:        []plants.Plant{{ID: "2", Name: "bar", Height: 3}},
+		},
+		"returns error if item not found": {
+			store: &MemoryStore{items: []plants.Plant{{ID: "1", Name: "foo", Height: 4}}},
+			id:    "2",
+
+			want:    []plants.Plant{{ID: "1", Name: "foo", Height: 4}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			deleted, err := tc.store.Delete(ctx, tc.id)
+			if tc.wantErr {
+				assert.ErrorAs(t, err, &ErrorResourceDoesNotExist{})
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantDeleted, deleted)
+
+			got, _ := tc.store.List(ctx)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMemoryStoreSetHeight(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore([]plants.Plant{{ID: "1", Name: "fern", Height: 3}})
+
+	plant, updated, err := s.SetHeight(ctx, "1", 12, day.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, plants.Plant{ID: "1", Name: "fern", Height: 12}, *plant)
+
+	// NOTE: a reading taken before the one that set the height doesnt replace it
+	plant, updated, err = s.SetHeight(ctx, "1", 10, day)
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, 12, plant.Height)
+
+	_, _, err = s.SetHeight(ctx, "2", 10, day)
+	assert.ErrorAs(t, err, &ErrorResourceDoesNotExist{})
+}
+
+func TestMemoryStoreSetLastWatered(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore([]plants.Plant{{ID: "1", Name: "fern", Height: 3}})
+
+	plant, updated, err := s.SetLastWatered(ctx, "1", day.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, day.Add(time.Hour), *plant.LastWateredAt)
+	assert.Equal(t, 3, plant.Height, "other fields are left as they are")
+
+	// NOTE: logging an older watering doesnt move LastWateredAt back
+	plant, updated, err = s.SetLastWatered(ctx, "1", day)
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, day.Add(time.Hour), *plant.LastWateredAt)
+
+	_, _, err = s.SetLastWatered(ctx, "2", day)
+	assert.ErrorAs(t, err, &ErrorResourceDoesNotExist{})
+}
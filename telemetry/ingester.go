@@ -0,0 +1,108 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+var (
+	ErrQueueFull       = errors.New("telemetry ingestion queue is full")
+	ErrIngesterStopped = errors.New("telemetry ingester is not running")
+)
+
+type Options struct {
+	// QueueSize is how many batches can wait to be written, more are rejected with ErrQueueFull
+	QueueSize int
+	// Workers is how many batches are written concurrently
+	Workers int
+}
+
+// Ingester writes batches of readings to the store in the background, so a slow store
+// sheds load instead of piling up requests
+type Ingester struct {
+	logger *slog.Logger
+	store  Store
+	opts   Options
+
+	// mu guards closed, Submit holds it for reading so the queue is never sent to after it is closed
+	mu      sync.RWMutex
+	closed  bool
+	batches chan []Reading
+	stopped sync.WaitGroup
+}
+
+func NewIngester(logger *slog.Logger, store Store, opts Options) *Ingester {
+	return &Ingester{
+		logger:  logger,
+		store:   store,
+		opts:    opts,
+		batches: make(chan []Reading, opts.QueueSize),
+	}
+}
+
+// Start writes batches until ctx is cancelled, batches already queued by then are still written
+func (in *Ingester) Start(ctx context.Context) error {
+	for range in.opts.Workers {
+		in.stopped.Add(1)
+		go func() {
+			defer in.stopped.Done()
+			for batch := range in.batches {
+				in.write(batch)
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		in.closed = true
+		close(in.batches)
+	}()
+
+	return nil
+}
+
+// Submit queues a batch without waiting, it returns ErrQueueFull when the workers are behind
+func (in *Ingester) Submit(readings []Reading) error {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.closed {
+		return ErrIngesterStopped
+	}
+
+	select {
+	case in.batches <- readings:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Wait blocks until the queue is drained after Start's ctx was cancelled, it matches the shutdown hook signature
+func (in *Ingester) Wait(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		in.stopped.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for telemetry ingestion: %w", ctx.Err())
+	}
+}
+
+func (in *Ingester) write(batch []Reading) {
+	// NOTE: not bound to the ingester lifetime, so the queue can still be drained during shutdown
+	ctx := context.Background()
+	if err := in.store.AppendReadings(ctx, batch); err != nil {
+		// NOTE: sensors have already been answered, a lost batch is only logged
+		in.logger.ErrorContext(ctx, fmt.Sprintf("append telemetry readings: %s", err), slog.Int("readings", len(batch)))
+	}
+}
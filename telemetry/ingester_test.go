@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"context"
+	"plants/log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingStore holds every append until it is released
+type blockingStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (s blockingStore) AppendReadings(ctx context.Context, readings []Reading) error {
+	<-s.release
+	return s.MemoryStore.AppendReadings(ctx, readings)
+}
+
+func TestIngester(t *testing.T) {
+	s := blockingStore{MemoryStore: NewMemoryStore(0, nil), release: make(chan struct{})}
+	in := NewIngester(log.NoopLogger(), s, Options{QueueSize: 2, Workers: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, in.Start(ctx))
+	batch := func(value float64) []Reading {
+		return []Reading{{PlantID: "1", Metric: METRIC_LIGHT, Time: time.Unix(int64(value), 0), Value: value}}
+	}
+
+	// NOTE: the worker picks up the first batch and blocks, two more fit in the queue
+	require.NoError(t, in.Submit(batch(1)))
+	require.Eventually(t, func() bool { return len(in.batches) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, in.Submit(batch(2)))
+	require.NoError(t, in.Submit(batch(3)))
+	assert.ErrorIs(t, in.Submit(batch(4)), ErrQueueFull)
+
+	// NOTE: stopping still writes what was queued
+	cancel()
+	require.Eventually(t, func() bool { return in.Submit(batch(5)) == ErrIngesterStopped }, time.Second, time.Millisecond)
+	close(s.release)
+	require.NoError(t, in.Wait(context.Background()))
+
+	got, err := s.ListReadings(context.Background(), "1", METRIC_LIGHT, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	var values []float64
+	for _, r := range got {
+		values = append(values, r.Value)
+	}
+	assert.Equal(t, []float64{1, 2, 3}, values)
+}
@@ -0,0 +1,177 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// batch formats, selected by the request Content-Type
+const (
+	// FORMAT_NDJSON is one JSON reading per line, e.g. {"sensorId":"s1","metric":"light","value":1200}
+	FORMAT_NDJSON = "application/x-ndjson"
+	// FORMAT_LINE_PROTOCOL is the InfluxDB line protocol, e.g. soil_moisture,sensor=s1 value=23.5 1714550400000000000
+	FORMAT_LINE_PROTOCOL = "text/plain"
+)
+
+// maxLineBytes caps a single line of a batch
+const maxLineBytes = 64 << 10
+
+// maxProblems is how many invalid lines are reported before parsing gives up on a batch
+const maxProblems = 20
+
+// Parse reads a batch in one of the FORMAT_* values, readings without a time are stamped with now.
+// Invalid lines are reported as problems keyed by "line N", the returned error is only for failing to read the batch.
+func Parse(format string, body io.Reader, now time.Time) ([]Reading, map[string]string, error) {
+	var parseLine func(line []byte, now time.Time) ([]Reading, error)
+	switch format {
+	case FORMAT_NDJSON:
+		parseLine = parseNDJSONLine
+	case FORMAT_LINE_PROTOCOL:
+		parseLine = parseLineProtocolLine
+	default:
+		return nil, nil, fmt.Errorf("unsupported telemetry format '%s', expected %s or %s", format, FORMAT_NDJSON, FORMAT_LINE_PROTOCOL)
+	}
+
+	readings := []Reading{}
+	problems := make(map[string]string)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+	for n := 1; scanner.Scan() && len(problems) < maxProblems; n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		// NOTE: blank lines and comments are allowed so batches can be written by hand
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		parsed, err := parseLine(line, now)
+		if err == nil {
+			err = validate(parsed)
+		}
+		if err != nil {
+			problems[fmt.Sprintf("line %d", n)] = err.Error()
+			continue
+		}
+		readings = append(readings, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, nil, fmt.Errorf("telemetry lines cannot be longer than %d bytes", maxLineBytes)
+		}
+		return nil, nil, fmt.Errorf("read telemetry: %w", err)
+	}
+
+	return readings, problems, nil
+}
+
+// validate joins the problems of every reading, sorted by field so the message is stable
+func validate(readings []Reading) error {
+	var messages []string
+	for _, r := range readings {
+		problems := r.Valid()
+		fields := make([]string, 0, len(problems))
+		for field := range problems {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		for _, field := range fields {
+			messages = append(messages, problems[field])
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, ", "))
+	}
+	return nil
+}
+
+// ndjsonReading tells missing values apart from zeroes
+type ndjsonReading struct {
+	SensorID string     `json:"sensorId"`
+	Metric   string     `json:"metric"`
+	Time     *time.Time `json:"time"`
+	Value    *float64   `json:"value"`
+}
+
+func parseNDJSONLine(line []byte, now time.Time) ([]Reading, error) {
+	var raw ndjsonReading
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	if dec.More() {
+		return nil, errors.New("decode json: line must contain a single JSON value")
+	}
+	if raw.Value == nil {
+		return nil, errors.New("value is missing")
+	}
+
+	r := Reading{SensorID: raw.SensorID, Metric: raw.Metric, Time: now, Value: *raw.Value}
+	if raw.Time != nil {
+		r.Time = *raw.Time
+	}
+	return []Reading{r}, nil
+}
+
+// parseLineProtocolLine supports the subset of line protocol sensors send: the "sensor" tag is the sensor ID,
+// a field named "value" is a reading of the measurement's metric and any other field is a reading of the metric
+// it is named after. Values are floats or integers, the optional timestamp is in nanoseconds.
+// NOTE: escaped spaces, commas and quoted string fields arent supported, no sensor sends those
+func parseLineProtocolLine(line []byte, now time.Time) ([]Reading, error) {
+	parts := strings.Fields(string(line))
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, errors.New("line must be 'measurement,sensor=<id> field=<value>[,...] [timestamp]'")
+	}
+
+	measurement, tagSet, _ := strings.Cut(parts[0], ",")
+	var sensorID string
+	if tagSet != "" {
+		for _, tag := range strings.Split(tagSet, ",") {
+			key, value, ok := strings.Cut(tag, "=")
+			if !ok || key == "" || value == "" {
+				return nil, fmt.Errorf("tag '%s' must be key=value", tag)
+			}
+			if key == "sensor" {
+				sensorID = value
+			}
+		}
+	}
+	if sensorID == "" {
+		return nil, errors.New("tag 'sensor' is missing")
+	}
+
+	t := now
+	if len(parts) == 3 {
+		ns, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp '%s' must be nanoseconds since the Unix epoch", parts[2])
+		}
+		t = time.Unix(0, ns)
+	}
+
+	var readings []Reading
+	for _, field := range strings.Split(parts[1], ",") {
+		key, raw, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("field '%s' must be key=value", field)
+		}
+		// NOTE: integers are suffixed with i (signed) or u (unsigned), they are stored as floats all the same
+		value, err := strconv.ParseFloat(strings.TrimRight(raw, "iu"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s' must be a number", key)
+		}
+		metric := key
+		if key == "value" {
+			metric = measurement
+		}
+		readings = append(readings, Reading{SensorID: sensorID, Metric: metric, Time: t, Value: value})
+	}
+	return readings, nil
+}
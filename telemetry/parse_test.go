@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		format string
+		body   string
+
+		wantReadings []Reading
+		wantProblems map[string]string
+		wantErr      string
+	}{
+		"ndjson": {
+			format: FORMAT_NDJSON,
+			body: `{"sensorId":"s1","metric":"soil_moisture","value":23.5,"time":"2024-05-01T10:00:00+02:00"}
+
+{"sensorId":"s2","metric":"light","value":0}`,
+
+			wantReadings: []Reading{
+				{SensorID: "s1", Metric: METRIC_SOIL_MOISTURE, Time: at.In(time.FixedZone("", 2*60*60)), Value: 23.5},
+				{SensorID: "s2", Metric: METRIC_LIGHT, Time: now, Value: 0},
+			},
+			wantProblems: map[string]string{},
+		},
+		"invalid ndjson lines": {
+			format: FORMAT_NDJSON,
+			body: `{"sensorId":"s1","metric":"soil_moisture","value":123}
+{"sensorId":"s1","metric":"humidity","value":1,"unit":"%"}
+{"sensorId":"s1","metric":"light"}
+{"sensorId":"","metric":"wind","value":1}
+not json`,
+
+			wantReadings: []Reading{},
+			wantProblems: map[string]string{
+				"line 1": "soil moisture must be between 0 and 100 percent",
+				"line 2": `decode json: json: unknown field "unit"`,
+				"line 3": "value is missing",
+				"line 4": "metric must be one of: soil_moisture, temperature, light, sensor ID cannot be empty",
+				"line 5": "decode json: invalid character 'o' in literal null (expecting 'u')",
+			},
+		},
+		"line protocol": {
+			format: FORMAT_LINE_PROTOCOL,
+			body: `# greenhouse bench 2
+soil_moisture,sensor=s1,bench=2 value=23.5 1714550400000000000
+climate,sensor=s2 temperature=21.5,light=1200i`,
+
+			wantReadings: []Reading{
+				{SensorID: "s1", Metric: METRIC_SOIL_MOISTURE, Time: at.Local(), Value: 23.5},
+				{SensorID: "s2", Metric: METRIC_TEMPERATURE, Time: now, Value: 21.5},
+				{SensorID: "s2", Metric: METRIC_LIGHT, Time: now, Value: 1200},
+			},
+			wantProblems: map[string]string{},
+		},
+		"invalid line protocol lines": {
+			format: FORMAT_LINE_PROTOCOL,
+			body: `soil_moisture value=1
+soil_moisture,sensor=s1 value=wet
+soil_moisture,sensor=s1 value=1 yesterday
+soil_moisture,sensor=s1
+climate,sensor=s1 temperature=20,light=-1`,
+
+			wantReadings: []Reading{},
+			wantProblems: map[string]string{
+				"line 1": "tag 'sensor' is missing",
+				"line 2": "field 'value' must be a number",
+				"line 3": "timestamp 'yesterday' must be nanoseconds since the Unix epoch",
+				"line 4": "line must be 'measurement,sensor=<id> field=<value>[,...] [timestamp]'",
+				"line 5": "light cannot be negative",
+			},
+		},
+		"line too long": {
+			format: FORMAT_NDJSON,
+			body:   strings.Repeat("x", maxLineBytes+1),
+
+			wantErr: "telemetry lines cannot be longer than 65536 bytes",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotReadings, gotProblems, err := Parse(tc.format, strings.NewReader(tc.body), now)
+
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantProblems, gotProblems)
+			require.Len(t, gotReadings, len(tc.wantReadings))
+			for i, want := range tc.wantReadings {
+				assert.True(t, want.Time.Equal(gotReadings[i].Time), "reading %d time %s", i, gotReadings[i].Time)
+				gotReadings[i].Time = want.Time
+			}
+			assert.Equal(t, tc.wantReadings, gotReadings)
+		})
+	}
+}
+
+func TestParseGivesUpOnGarbage(t *testing.T) {
+	body := strings.Repeat("garbage\n", 1000)
+
+	_, problems, err := Parse(FORMAT_LINE_PROTOCOL, strings.NewReader(body), time.Now())
+
+	require.NoError(t, err)
+	assert.Len(t, problems, maxProblems)
+}
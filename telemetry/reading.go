@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// metrics sensors report, each has a fixed unit
+const (
+	// METRIC_SOIL_MOISTURE is volumetric water content in percent
+	METRIC_SOIL_MOISTURE = "soil_moisture"
+	// METRIC_TEMPERATURE is degrees Celsius
+	METRIC_TEMPERATURE = "temperature"
+	// METRIC_LIGHT is illuminance in lux
+	METRIC_LIGHT = "light"
+)
+
+var metrics = []string{METRIC_SOIL_MOISTURE, METRIC_TEMPERATURE, METRIC_LIGHT}
+
+// ValidMetric reports whether metric is one of the known METRIC_* values
+func ValidMetric(metric string) bool {
+	return slices.Contains(metrics, metric)
+}
+
+func metricsProblem() string {
+	return "metric must be one of: " + strings.Join(metrics, ", ")
+}
+
+// Reading is a single value reported by a sensor
+type Reading struct {
+	SensorID string `json:"sensorId"`
+	// PlantID is filled in from the sensor mapping when the reading is ingested
+	PlantID string    `json:"plantId,omitempty"`
+	Metric  string    `json:"metric"`
+	Time    time.Time `json:"time"`
+	Value   float64   `json:"value"`
+}
+
+func (r Reading) Valid() map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(r.SensorID) == "" {
+		problems["sensorId"] = "sensor ID cannot be empty"
+	}
+
+	if !ValidMetric(r.Metric) {
+		problems["metric"] = metricsProblem()
+	}
+
+	switch {
+	case math.IsNaN(r.Value) || math.IsInf(r.Value, 0):
+		problems["value"] = "value must be a number"
+	case r.Metric == METRIC_SOIL_MOISTURE && (r.Value < 0 || r.Value > 100):
+		problems["value"] = "soil moisture must be between 0 and 100 percent"
+	case r.Metric == METRIC_LIGHT && r.Value < 0:
+		problems["value"] = "light cannot be negative"
+	}
+
+	return problems
+}
+
+// Aggregate summarizes the readings of one metric within a window
+type Aggregate struct {
+	// Start is the beginning of the window, windows are aligned to multiples of their length since the Unix epoch
+	Start time.Time `json:"start"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	Count int       `json:"count"`
+}
+
+// Summarize groups readings sorted by time into windows of step
+func Summarize(readings []Reading, step time.Duration) []Aggregate {
+	aggregates := []Aggregate{}
+	var sum float64
+	for _, r := range readings {
+		start := r.Time.Truncate(step)
+		last := len(aggregates) - 1
+		if last < 0 || !aggregates[last].Start.Equal(start) {
+			aggregates = append(aggregates, Aggregate{Start: start, Min: r.Value, Max: r.Value})
+			sum = 0
+			last++
+		}
+		a := &aggregates[last]
+		a.Min = min(a.Min, r.Value)
+		a.Max = max(a.Max, r.Value)
+		a.Count++
+		sum += r.Value
+		a.Avg = sum / float64(a.Count)
+	}
+	return aggregates
+}
+
+// Sensor maps a sensor to the plant it is placed in
+type Sensor struct {
+	ID      string `json:"id"`
+	PlantID string `json:"plantId"`
+	// Name is an optional label, e.g. where the sensor is mounted
+	Name string `json:"name,omitempty"`
+}
+
+func (s Sensor) Valid() map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(s.PlantID) == "" {
+		problems["plantId"] = "plant ID cannot be empty"
+	}
+
+	return problems
+}
@@ -0,0 +1,215 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"plants/store"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Store is a time-series store of sensor readings, ranges include from and exclude to,
+// a zero time leaves that side of the range open
+type Store interface {
+	// AppendReadings stores a batch, readings can be out of order
+	AppendReadings(ctx context.Context, readings []Reading) error
+	// LatestReadings returns the most recent reading of every metric of a plant, ordered by metric
+	LatestReadings(ctx context.Context, plantID string) ([]Reading, error)
+	// ListReadings returns the readings of one metric of a plant within the range, oldest first
+	ListReadings(ctx context.Context, plantID, metric string, from, to time.Time) ([]Reading, error)
+	// AggregateReadings summarizes the readings of one metric within the range into windows of step
+	AggregateReadings(ctx context.Context, plantID, metric string, from, to time.Time, step time.Duration) ([]Aggregate, error)
+	// DeleteReadings drops every reading of a plant, e.g. when the plant is deleted
+	DeleteReadings(ctx context.Context, plantID string) error
+}
+
+type series struct {
+	plantID string
+	metric  string
+}
+
+// NOTE: realistically this would be a time-series DB with its own retention policy
+type MemoryStore struct {
+	mu sync.RWMutex
+	// items per plant and metric, sorted by time
+	items     map[series][]Reading
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewMemoryStore drops readings older than retention whenever a batch is appended, 0 keeps them all.
+// A nil now means time.Now.
+func NewMemoryStore(retention time.Duration, now func() time.Time) *MemoryStore {
+	if now == nil {
+		now = time.Now
+	}
+	return &MemoryStore{items: make(map[series][]Reading), retention: retention, now: now}
+}
+
+func compareTime(r Reading, t time.Time) int {
+	return r.Time.Compare(t)
+}
+
+func (s *MemoryStore) AppendReadings(ctx context.Context, readings []Reading) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range readings {
+		key := series{plantID: r.PlantID, metric: r.Metric}
+		items := s.items[key]
+		// NOTE: sensors send in order, so appending is the common case and skips the search
+		if len(items) == 0 || !r.Time.Before(items[len(items)-1].Time) {
+			s.items[key] = append(items, r)
+			continue
+		}
+		i, found := slices.BinarySearchFunc(items, r.Time, compareTime)
+		for found && i < len(items) && items[i].Time.Equal(r.Time) {
+			i++
+		}
+		s.items[key] = slices.Insert(items, i, r)
+	}
+	s.prune()
+	return nil
+}
+
+// prune drops the readings past retention from every series, including the ones no sensor writes to anymore.
+// It must be called with the lock held.
+func (s *MemoryStore) prune() {
+	if s.retention <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-s.retention)
+	for key, items := range s.items {
+		i, _ := slices.BinarySearchFunc(items, cutoff, compareTime)
+		switch {
+		case i == len(items):
+			delete(s.items, key)
+		case i > 0:
+			s.items[key] = slices.Clone(items[i:])
+		}
+	}
+}
+
+func (s *MemoryStore) DeleteReadings(ctx context.Context, plantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.items {
+		if key.plantID == plantID {
+			delete(s.items, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) LatestReadings(ctx context.Context, plantID string) ([]Reading, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	latest := []Reading{}
+	for key, items := range s.items {
+		if key.plantID == plantID && len(items) > 0 {
+			latest = append(latest, items[len(items)-1])
+		}
+	}
+	slices.SortFunc(latest, func(a, b Reading) int { return cmp.Compare(a.Metric, b.Metric) })
+	return latest, nil
+}
+
+func (s *MemoryStore) ListReadings(ctx context.Context, plantID, metric string, from, to time.Time) ([]Reading, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := s.items[series{plantID: plantID, metric: metric}]
+	start, end := 0, len(items)
+	if !from.IsZero() {
+		start, _ = slices.BinarySearchFunc(items, from, compareTime)
+	}
+	if !to.IsZero() {
+		end, _ = slices.BinarySearchFunc(items, to, compareTime)
+	}
+	if start >= end {
+		return []Reading{}, nil
+	}
+	return slices.Clone(items[start:end]), nil
+}
+
+func (s *MemoryStore) AggregateReadings(ctx context.Context, plantID, metric string, from, to time.Time, step time.Duration) ([]Aggregate, error) {
+	items, err := s.ListReadings(ctx, plantID, metric, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return Summarize(items, step), nil
+}
+
+// SensorStore maps sensors to plants, missing sensors are reported with store.ErrorResourceDoesNotExist
+type SensorStore interface {
+	// ListSensors returns all sensors, ordered by ID
+	ListSensors(ctx context.Context) ([]Sensor, error)
+	FindSensor(ctx context.Context, id string) (*Sensor, error)
+	// PutSensor creates or replaces the mapping of sensor.ID
+	PutSensor(ctx context.Context, sensor Sensor) (*Sensor, error)
+	DeleteSensor(ctx context.Context, id string) error
+	// DeletePlantSensors unmaps every sensor of a plant, their next batch is rejected until they are mapped again
+	DeletePlantSensors(ctx context.Context, plantID string) error
+}
+
+type MemorySensorStore struct {
+	mu    sync.RWMutex
+	items map[string]Sensor
+}
+
+func NewMemorySensorStore() *MemorySensorStore {
+	return &MemorySensorStore{items: make(map[string]Sensor)}
+}
+
+func sensorNotFound(id string) error {
+	return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("sensor with ID '%s' does not exist", id)}
+}
+
+func (s *MemorySensorStore) ListSensors(ctx context.Context) ([]Sensor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Sensor, 0, len(s.items))
+	for _, sensor := range s.items {
+		list = append(list, sensor)
+	}
+	slices.SortFunc(list, func(a, b Sensor) int { return cmp.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+func (s *MemorySensorStore) FindSensor(ctx context.Context, id string) (*Sensor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sensor, ok := s.items[id]
+	if !ok {
+		return nil, sensorNotFound(id)
+	}
+	return &sensor, nil
+}
+
+func (s *MemorySensorStore) PutSensor(ctx context.Context, sensor Sensor) (*Sensor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[sensor.ID] = sensor
+	return &sensor, nil
+}
+
+func (s *MemorySensorStore) DeleteSensor(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return sensorNotFound(id)
+	}
+	delete(s.items, id)
+	return nil
+}
+
+func (s *MemorySensorStore) DeletePlantSensors(ctx context.Context, plantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sensor := range s.items {
+		if sensor.PlantID == plantID {
+			delete(s.items, id)
+		}
+	}
+	return nil
+}
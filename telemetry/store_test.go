@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(0, nil)
+	// NOTE: the second batch is late on purpose, the store keeps readings sorted by time
+	for _, batch := range [][]Reading{
+		{
+			{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: start, Value: 40},
+			{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: start.Add(50 * time.Minute), Value: 30},
+			{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: start.Add(70 * time.Minute), Value: 20},
+			{PlantID: "1", Metric: METRIC_TEMPERATURE, Time: start, Value: 21},
+			{PlantID: "2", Metric: METRIC_SOIL_MOISTURE, Time: start.Add(2 * time.Hour), Value: 99},
+		},
+		{
+			{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: start.Add(10 * time.Minute), Value: 35},
+		},
+	} {
+		require.NoError(t, s.AppendReadings(ctx, batch))
+	}
+
+	tests := map[string]struct {
+		from, to time.Time
+		step     time.Duration
+
+		want []Aggregate
+	}{
+		"hourly": {
+			step: time.Hour,
+
+			want: []Aggregate{
+				{Start: start, Min: 30, Max: 40, Avg: 35, Count: 3},
+				{Start: start.Add(time.Hour), Min: 20, Max: 20, Avg: 20, Count: 1},
+			},
+		},
+		"within range": {
+			from: start.Add(10 * time.Minute),
+			to:   start.Add(70 * time.Minute),
+			step: 30 * time.Minute,
+
+			want: []Aggregate{
+				{Start: start, Min: 35, Max: 35, Avg: 35, Count: 1},
+				{Start: start.Add(30 * time.Minute), Min: 30, Max: 30, Avg: 30, Count: 1},
+			},
+		},
+		"empty range": {
+			from: start.Add(3 * time.Hour),
+			step: time.Hour,
+
+			want: []Aggregate{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := s.AggregateReadings(ctx, "1", METRIC_SOIL_MOISTURE, tc.from, tc.to, tc.step)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	latest, err := s.LatestReadings(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []Reading{
+		{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: start.Add(70 * time.Minute), Value: 20},
+		{PlantID: "1", Metric: METRIC_TEMPERATURE, Time: start, Value: 21},
+	}, latest)
+	latest, err = s.LatestReadings(ctx, "3")
+	require.NoError(t, err)
+	assert.Empty(t, latest)
+}
+
+func TestMemoryStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 10, 8, 0, 0, 0, time.UTC)
+	s := NewMemoryStore(24*time.Hour, func() time.Time { return now })
+	require.NoError(t, s.AppendReadings(ctx, []Reading{
+		{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: now.Add(-48 * time.Hour), Value: 40},
+		{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: now.Add(-time.Hour), Value: 30},
+		{PlantID: "1", Metric: METRIC_LIGHT, Time: now.Add(-25 * time.Hour), Value: 1200},
+		{PlantID: "2", Metric: METRIC_SOIL_MOISTURE, Time: now, Value: 99},
+	}))
+
+	latest, err := s.LatestReadings(ctx, "1")
+	require.NoError(t, err)
+	// NOTE: the light series only had an expired reading, so it is gone as a whole
+	assert.Equal(t, []Reading{{PlantID: "1", Metric: METRIC_SOIL_MOISTURE, Time: now.Add(-time.Hour), Value: 30}}, latest)
+	readings, err := s.ListReadings(ctx, "1", METRIC_SOIL_MOISTURE, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, readings, 1)
+
+	require.NoError(t, s.DeleteReadings(ctx, "1"))
+	latest, err = s.LatestReadings(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, latest)
+	latest, err = s.LatestReadings(ctx, "2")
+	require.NoError(t, err)
+	assert.Len(t, latest, 1, "other plants keep their readings")
+}
+
+func TestMemorySensorStoreDeletePlantSensors(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySensorStore()
+	for _, sensor := range []Sensor{{ID: "s1", PlantID: "1"}, {ID: "s2", PlantID: "1"}, {ID: "s3", PlantID: "2"}} {
+		_, err := s.PutSensor(ctx, sensor)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.DeletePlantSensors(ctx, "1"))
+
+	list, err := s.ListSensors(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Sensor{{ID: "s3", PlantID: "2"}}, list)
+}
@@ -0,0 +1,334 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"plants/events"
+	"plants/store"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDispatcherStopped = errors.New("webhook dispatcher is not running")
+	ErrDeliveryPending   = errors.New("webhook delivery is still pending")
+)
+
+// how many attempts can wait for a free worker
+const queueSize = 100
+
+// how much of a response body is read, so keep-alive connections can be reused
+const maxResponseBytes = 64 << 10
+
+type Options struct {
+	// Workers is how many deliveries are sent concurrently
+	Workers int
+	// MaxAttempts is how many times a delivery is sent before it is dead-lettered
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, it doubles with every retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout caps a single attempt, including reading the response
+	Timeout time.Duration
+	// Client sends the requests, nil means http.DefaultClient
+	Client *http.Client
+}
+
+type job struct {
+	subscriptionID string
+	deliveryID     string
+	// attempt counts from 1 and starts over when a delivery is redelivered
+	attempt int
+}
+
+// Dispatcher turns broker events into deliveries and sends them in the background
+type Dispatcher struct {
+	logger *slog.Logger
+	store  Store
+	broker *events.Broker
+	opts   Options
+	now    func() time.Time
+
+	jobs    chan job
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func NewDispatcher(logger *slog.Logger, store Store, broker *events.Broker, opts Options) *Dispatcher {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &Dispatcher{
+		logger: logger,
+		store:  store,
+		broker: broker,
+		opts:   opts,
+		now:    time.Now,
+		jobs:   make(chan job, queueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start consumes events and sends deliveries until ctx is cancelled or the broker is closed.
+// Attempts in flight are allowed to finish, retries that are still waiting stay pending.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	// NOTE: subscribed before returning, so no event published after Start is missed
+	sub, err := d.broker.Subscribe(0)
+	if err != nil {
+		return fmt.Errorf("subscribe to plant events: %w", err)
+	}
+
+	workers, cancelWorkers := context.WithCancel(context.Background())
+	var working sync.WaitGroup
+	for range d.opts.Workers {
+		working.Add(1)
+		go func() {
+			defer working.Done()
+			d.work(workers)
+		}()
+	}
+
+	d.stopped.Add(1)
+	go func() {
+		defer d.stopped.Done()
+		d.consume(ctx, sub)
+		close(d.done)
+		cancelWorkers()
+		working.Wait()
+	}()
+
+	return nil
+}
+
+// Wait blocks until the dispatcher has stopped, it matches the shutdown hook signature
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		d.stopped.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for webhook deliveries: %w", ctx.Err())
+	}
+}
+
+// consume creates deliveries for every event, resubscribing when it falls behind the broker
+func (d *Dispatcher) consume(ctx context.Context, sub *events.Subscription) {
+	var lastID uint64
+	for {
+		select {
+		case <-ctx.Done():
+			sub.Close()
+			return
+		case e, ok := <-sub.Events():
+			if ok {
+				d.dispatch(ctx, e)
+				lastID = e.ID
+				continue
+			}
+		}
+
+		// NOTE: the subscription ended, either the broker was closed or the dispatcher fell behind
+		if !sub.Lagged() {
+			return
+		}
+		d.logger.Warn("webhook dispatcher fell behind plant events, resubscribing")
+		next, err := d.broker.Subscribe(lastID)
+		if err != nil {
+			return
+		}
+		if next.Missed {
+			d.logger.Warn("webhook dispatcher missed plant events, they will not be delivered")
+		}
+		for _, e := range next.Replay {
+			d.dispatch(ctx, e)
+			lastID = e.ID
+		}
+		sub = next
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, e events.Event) {
+	subs, err := d.store.ListSubscriptions(ctx)
+	if err != nil {
+		d.logger.ErrorContext(ctx, fmt.Sprintf("list webhook subscriptions: %s", err), slog.Uint64("eventId", e.ID))
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.Matches(e) {
+			continue
+		}
+		delivery, err := d.store.CreateDelivery(ctx, Delivery{
+			SubscriptionID: sub.ID,
+			Status:         StatusPending,
+			Event:          e,
+			CreatedAt:      d.now().UTC(),
+		})
+		if err != nil {
+			d.logger.ErrorContext(ctx, fmt.Sprintf("create webhook delivery: %s", err), slog.Any("subscription", sub))
+			continue
+		}
+		d.enqueue(ctx, job{subscriptionID: sub.ID, deliveryID: delivery.ID, attempt: 1})
+	}
+}
+
+// enqueue waits for room in the queue, it gives up once the dispatcher stops
+func (d *Dispatcher) enqueue(ctx context.Context, j job) bool {
+	select {
+	case d.jobs <- j:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-d.done:
+		return false
+	}
+}
+
+// Redeliver sends a finished delivery again, with a fresh set of attempts
+func (d *Dispatcher) Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*Delivery, error) {
+	select {
+	case <-d.done:
+		return nil, ErrDispatcherStopped
+	default:
+	}
+
+	delivery, err := d.store.FindDelivery(ctx, subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == StatusPending {
+		return nil, ErrDeliveryPending
+	}
+
+	delivery.Status = StatusPending
+	delivery.NextAttemptAt = nil
+	delivery, err = d.store.UpdateDelivery(ctx, *delivery)
+	if err != nil {
+		return nil, err
+	}
+	if !d.enqueue(ctx, job{subscriptionID: subscriptionID, deliveryID: deliveryID, attempt: 1}) {
+		return nil, ErrDispatcherStopped
+	}
+
+	return delivery, nil
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-d.jobs:
+			d.attempt(j)
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome, failures are retried after a backoff
+func (d *Dispatcher) attempt(j job) {
+	// NOTE: not bound to the dispatcher lifetime, so an attempt in flight during shutdown can still finish
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+	logger := d.logger.With(slog.String("subscriptionId", j.subscriptionID), slog.String("deliveryId", j.deliveryID))
+
+	sub, err := d.store.FindSubscription(ctx, j.subscriptionID)
+	var delivery *Delivery
+	if err == nil {
+		delivery, err = d.store.FindDelivery(ctx, j.subscriptionID, j.deliveryID)
+	}
+	if err != nil {
+		// NOTE: deleted subscriptions take their pending deliveries with them
+		if !errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+			logger.ErrorContext(ctx, fmt.Sprintf("load webhook delivery: %s", err))
+		}
+		return
+	}
+
+	start := d.now()
+	statusCode, err := d.send(ctx, *sub, *delivery)
+	attempt := Attempt{Time: start.UTC(), StatusCode: statusCode, DurationMs: d.now().Sub(start).Milliseconds()}
+	delivery.NextAttemptAt = nil
+
+	var retryIn time.Duration
+	switch {
+	case err == nil:
+		delivery.Status = StatusSucceeded
+	case j.attempt >= d.opts.MaxAttempts:
+		attempt.Error = err.Error()
+		delivery.Status = StatusDead
+		logger.WarnContext(ctx, fmt.Sprintf("webhook delivery failed for good after %d attempts: %s", j.attempt, err))
+	default:
+		attempt.Error = err.Error()
+		retryIn = d.backoff(j.attempt)
+		next := start.Add(retryIn).UTC()
+		delivery.NextAttemptAt = &next
+		logger.InfoContext(ctx, fmt.Sprintf("webhook delivery failed, retrying in %s: %s", retryIn, err))
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+
+	if _, err := d.store.UpdateDelivery(ctx, *delivery); err != nil {
+		if !errors.As(err, &store.ErrorResourceDoesNotExist{}) {
+			logger.ErrorContext(ctx, fmt.Sprintf("save webhook delivery: %s", err))
+		}
+		return
+	}
+
+	if delivery.Status == StatusPending {
+		retry := job{subscriptionID: j.subscriptionID, deliveryID: j.deliveryID, attempt: j.attempt + 1}
+		time.AfterFunc(retryIn, func() { d.enqueue(context.Background(), retry) })
+	}
+}
+
+// backoff is the wait after the given failed attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.InitialBackoff
+	for i := 1; i < attempt && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+// send posts the event, any response other than 2xx is a failure
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, delivery Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, fmt.Errorf("encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := d.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "plants-webhooks")
+	req.Header.Set(HEADER_EVENT, string(delivery.Event.Type))
+	req.Header.Set(HEADER_DELIVERY, delivery.ID)
+	req.Header.Set(HEADER_TIMESTAMP, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HEADER_SIGNATURE, Sign(sub.Secret, now, body))
+
+	res, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBytes))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"plants/events"
+	"plants/log"
+	"plants/plants"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver is a webhook endpoint answering with the given status codes in order, the last one repeats
+type receiver struct {
+	url      string
+	requests chan *http.Request
+	bodies   chan []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) receiver {
+	t.Helper()
+	r := receiver{requests: make(chan *http.Request, 100), bodies: make(chan []byte, 100)}
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.requests <- req
+		r.bodies <- body
+		call := int(calls.Add(1)) - 1
+		w.WriteHeader(statuses[min(call, len(statuses)-1)])
+	}))
+	t.Cleanup(srv.Close)
+	r.url = srv.URL
+	return r
+}
+
+type dispatcherTest struct {
+	store      *MemoryStore
+	broker     *events.Broker
+	dispatcher *Dispatcher
+}
+
+func newDispatcherTest(t *testing.T, maxAttempts int) dispatcherTest {
+	t.Helper()
+	broker := events.NewBroker(10, 10)
+	s := NewMemoryStore(10)
+	d := NewDispatcher(log.NoopLogger(), s, broker, Options{
+		Workers:        2,
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        time.Second,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, d.Start(ctx))
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, d.Wait(context.Background()))
+	})
+	return dispatcherTest{store: s, broker: broker, dispatcher: d}
+}
+
+func (dt dispatcherTest) subscribe(t *testing.T, sub Subscription) Subscription {
+	t.Helper()
+	created, err := dt.store.CreateSubscription(context.Background(), sub)
+	require.NoError(t, err)
+	return *created
+}
+
+// waitForStatus polls until the only delivery of the subscription has the status
+func (dt dispatcherTest) waitForStatus(t *testing.T, subscriptionID string, status Status) Delivery {
+	t.Helper()
+	var delivery Delivery
+	require.Eventually(t, func() bool {
+		deliveries, err := dt.store.ListDeliveries(context.Background(), subscriptionID)
+		if err != nil || len(deliveries) != 1 {
+			return false
+		}
+		delivery = deliveries[0]
+		return delivery.Status == status
+	}, 2*time.Second, time.Millisecond)
+	return delivery
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	dt := newDispatcherTest(t, 3)
+	herbs := newReceiver(t, http.StatusNoContent)
+	sub := dt.subscribe(t, Subscription{URL: herbs.url, Events: []events.Type{events.TypeCreated}, Tags: []string{"herbs"}, Secret: testSecret})
+	others := newReceiver(t, http.StatusOK)
+	other := dt.subscribe(t, Subscription{URL: others.url, PlantIDs: []string{"someone-else"}, Secret: testSecret})
+
+	dt.broker.Publish(events.TypeUpdated, plants.Plant{ID: "1", Name: "basil", Tags: []string{"herbs"}})
+	published := dt.broker.Publish(events.TypeCreated, plants.Plant{ID: "1", Name: "basil", Tags: []string{"herbs"}})
+
+	delivery := dt.waitForStatus(t, sub.ID, StatusSucceeded)
+	assert.Equal(t, published, delivery.Event)
+	require.Len(t, delivery.Attempts, 1)
+	assert.Equal(t, http.StatusNoContent, delivery.Attempts[0].StatusCode)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	req, body := <-herbs.requests, <-herbs.bodies
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "created", req.Header.Get(HEADER_EVENT))
+	assert.Equal(t, delivery.ID, req.Header.Get(HEADER_DELIVERY))
+	require.NoError(t, Verify(testSecret, req.Header, body, time.Now(), time.Minute))
+	var got events.Event
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, published.ID, got.ID)
+
+	deliveries, err := dt.store.ListDeliveries(context.Background(), other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	tests := map[string]struct {
+		statuses []int
+
+		wantStatus    Status
+		wantAttempts  []int
+		wantLastError string
+	}{
+		"succeeds after retries": {
+			statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK},
+
+			wantStatus:   StatusSucceeded,
+			wantAttempts: []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK},
+		},
+		"dead after max attempts": {
+			statuses: []int{http.StatusBadGateway},
+
+			wantStatus:    StatusDead,
+			wantAttempts:  []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			wantLastError: "receiver responded with status 502",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dt := newDispatcherTest(t, 3)
+			sub := dt.subscribe(t, Subscription{URL: newReceiver(t, tc.statuses...).url, Secret: testSecret})
+
+			dt.broker.Publish(events.TypeDeleted, plants.Plant{ID: "1", Name: "fern"})
+
+			delivery := dt.waitForStatus(t, sub.ID, tc.wantStatus)
+			var got []int
+			for _, attempt := range delivery.Attempts {
+				got = append(got, attempt.StatusCode)
+			}
+			assert.Equal(t, tc.wantAttempts, got)
+			assert.Equal(t, tc.wantLastError, delivery.Attempts[len(delivery.Attempts)-1].Error)
+		})
+	}
+}
+
+func TestDispatcherRedeliver(t *testing.T) {
+	dt := newDispatcherTest(t, 1)
+	sub := dt.subscribe(t, Subscription{URL: newReceiver(t, http.StatusServiceUnavailable, http.StatusOK).url, Secret: testSecret})
+	dt.broker.Publish(events.TypeCreated, plants.Plant{ID: "1", Name: "fern"})
+	dead := dt.waitForStatus(t, sub.ID, StatusDead)
+
+	redelivered, err := dt.dispatcher.Redeliver(context.Background(), sub.ID, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, redelivered.Status)
+
+	delivery := dt.waitForStatus(t, sub.ID, StatusSucceeded)
+	require.Len(t, delivery.Attempts, 2)
+	assert.Equal(t, http.StatusOK, delivery.Attempts[1].StatusCode)
+
+	_, err = dt.dispatcher.Redeliver(context.Background(), sub.ID, "missing")
+	assert.EqualError(t, err, "webhook delivery with ID 'missing' does not exist")
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(log.NoopLogger(), nil, nil, Options{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	var got []time.Duration
+	for attempt := 1; attempt <= 6; attempt++ {
+		got = append(got, d.backoff(attempt))
+	}
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}, got)
+}
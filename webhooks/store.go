@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"plants/store"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Store keeps subscriptions and their delivery history, missing resources are reported
+// with store.ErrorResourceDoesNotExist like in the plant store
+type Store interface {
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	FindSubscription(ctx context.Context, id string) (*Subscription, error)
+	CreateSubscription(ctx context.Context, sub Subscription) (*Subscription, error)
+	UpdateSubscription(ctx context.Context, sub Subscription) (*Subscription, error)
+	// DeleteSubscription removes the subscription together with its deliveries
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// ListDeliveries returns the history of a subscription, newest first
+	ListDeliveries(ctx context.Context, subscriptionID string) ([]Delivery, error)
+	FindDelivery(ctx context.Context, subscriptionID, id string) (*Delivery, error)
+	CreateDelivery(ctx context.Context, delivery Delivery) (*Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery Delivery) (*Delivery, error)
+}
+
+type MemoryStore struct {
+	mu            sync.RWMutex
+	subscriptions []Subscription
+	// deliveries per subscription ID, oldest first
+	deliveries  map[string][]Delivery
+	historySize int
+}
+
+// NewMemoryStore keeps up to historySize deliveries per subscription, the oldest ones are dropped first
+func NewMemoryStore(historySize int) *MemoryStore {
+	return &MemoryStore{
+		deliveries:  make(map[string][]Delivery),
+		historySize: historySize,
+	}
+}
+
+func subscriptionNotFound(id string) error {
+	return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("webhook subscription with ID '%s' does not exist", id)}
+}
+
+func deliveryNotFound(id string) error {
+	return store.ErrorResourceDoesNotExist{Err: fmt.Errorf("webhook delivery with ID '%s' does not exist", id)}
+}
+
+func (s *MemoryStore) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.subscriptions), nil
+}
+
+func (s *MemoryStore) FindSubscription(ctx context.Context, id string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := slices.IndexFunc(s.subscriptions, func(sub Subscription) bool { return sub.ID == id })
+	if i < 0 {
+		return nil, subscriptionNotFound(id)
+	}
+	sub := s.subscriptions[i]
+	return &sub, nil
+}
+
+func (s *MemoryStore) CreateSubscription(ctx context.Context, sub Subscription) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.ID = uuid.New().String()
+	s.subscriptions = append(s.subscriptions, sub)
+	return &sub, nil
+}
+
+func (s *MemoryStore) UpdateSubscription(ctx context.Context, sub Subscription) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.subscriptions, func(existing Subscription) bool { return existing.ID == sub.ID })
+	if i < 0 {
+		return nil, subscriptionNotFound(sub.ID)
+	}
+	s.subscriptions[i] = sub
+	return &sub, nil
+}
+
+func (s *MemoryStore) DeleteSubscription(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.subscriptions, func(sub Subscription) bool { return sub.ID == id })
+	if i < 0 {
+		return subscriptionNotFound(id)
+	}
+	s.subscriptions = slices.Delete(s.subscriptions, i, i+1)
+	delete(s.deliveries, id)
+	return nil
+}
+
+func (s *MemoryStore) ListDeliveries(ctx context.Context, subscriptionID string) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !slices.ContainsFunc(s.subscriptions, func(sub Subscription) bool { return sub.ID == subscriptionID }) {
+		return nil, subscriptionNotFound(subscriptionID)
+	}
+	deliveries := slices.Clone(s.deliveries[subscriptionID])
+	slices.Reverse(deliveries)
+	return deliveries, nil
+}
+
+func (s *MemoryStore) FindDelivery(ctx context.Context, subscriptionID, id string) (*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	deliveries := s.deliveries[subscriptionID]
+	i := slices.IndexFunc(deliveries, func(d Delivery) bool { return d.ID == id })
+	if i < 0 {
+		return nil, deliveryNotFound(id)
+	}
+	delivery := deliveries[i]
+	delivery.Attempts = slices.Clone(delivery.Attempts)
+	return &delivery, nil
+}
+
+func (s *MemoryStore) CreateDelivery(ctx context.Context, delivery Delivery) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.ContainsFunc(s.subscriptions, func(sub Subscription) bool { return sub.ID == delivery.SubscriptionID }) {
+		return nil, subscriptionNotFound(delivery.SubscriptionID)
+	}
+	delivery.ID = uuid.New().String()
+	deliveries := append(s.deliveries[delivery.SubscriptionID], delivery)
+	if s.historySize > 0 && len(deliveries) > s.historySize {
+		deliveries = slices.Delete(deliveries, 0, len(deliveries)-s.historySize)
+	}
+	s.deliveries[delivery.SubscriptionID] = deliveries
+	return &delivery, nil
+}
+
+func (s *MemoryStore) UpdateDelivery(ctx context.Context, delivery Delivery) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deliveries := s.deliveries[delivery.SubscriptionID]
+	i := slices.IndexFunc(deliveries, func(d Delivery) bool { return d.ID == delivery.ID })
+	if i < 0 {
+		return nil, deliveryNotFound(delivery.ID)
+	}
+	// NOTE: a copy of attempts, so the caller appending to them doesnt change the stored delivery
+	deliveries[i] = delivery
+	deliveries[i].Attempts = slices.Clone(delivery.Attempts)
+	return &delivery, nil
+}
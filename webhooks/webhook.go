@@ -0,0 +1,161 @@
+// Package webhooks delivers plant events to subscriber URLs as signed HTTP POST requests,
+// retrying failed deliveries with exponential backoff until they are dead-lettered.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"plants/events"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// headers sent with every delivery
+const (
+	HEADER_SIGNATURE = "X-Webhook-Signature"
+	HEADER_TIMESTAMP = "X-Webhook-Timestamp"
+	HEADER_EVENT     = "X-Webhook-Event"
+	HEADER_DELIVERY  = "X-Webhook-Delivery"
+)
+
+// secrets shorter than this are too easy to guess
+const minSecretLength = 16
+
+// Subscription is a registered receiver, the filters are combined with AND and an empty filter matches everything
+type Subscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Events are the change types to deliver
+	Events []events.Type `json:"events,omitempty"`
+	// PlantIDs and Tags select plants, a plant matching either of them is delivered
+	PlantIDs []string `json:"plantIds,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// Secret is the HMAC key payloads are signed with, it is generated when empty
+	Secret string `json:"secret,omitempty"`
+	// Disabled subscriptions get no new deliveries, redelivering still works
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (s Subscription) Valid() map[string]string {
+	problems := make(map[string]string)
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems["url"] = "url must be an absolute http or https url"
+	}
+
+	for _, eventType := range s.Events {
+		if eventType != events.TypeCreated && eventType != events.TypeUpdated && eventType != events.TypeDeleted && eventType != events.TypeReminder {
+			problems["events"] = fmt.Sprintf("events must be any of: %s, %s, %s, %s", events.TypeCreated, events.TypeUpdated, events.TypeDeleted, events.TypeReminder)
+		}
+	}
+
+	if slices.Contains(s.PlantIDs, "") {
+		problems["plantIds"] = "plant ids cannot be empty"
+	}
+
+	if slices.Contains(s.Tags, "") {
+		problems["tags"] = "tags cannot be empty"
+	}
+
+	if s.Secret != "" && len(s.Secret) < minSecretLength {
+		problems["secret"] = fmt.Sprintf("secret must be at least %d characters", minSecretLength)
+	}
+
+	return problems
+}
+
+// Matches reports whether the event should be delivered to the subscription
+func (s Subscription) Matches(e events.Event) bool {
+	if s.Disabled {
+		return false
+	}
+	if len(s.Events) > 0 && !slices.Contains(s.Events, e.Type) {
+		return false
+	}
+	if len(s.PlantIDs) == 0 && len(s.Tags) == 0 {
+		return true
+	}
+	return slices.Contains(s.PlantIDs, e.Plant.ID) || e.Plant.HasAnyTag(s.Tags)
+}
+
+// LogValue leaves out the secret
+func (s Subscription) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", s.ID),
+		slog.String("url", s.URL),
+	)
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+type Status string
+
+const (
+	// StatusPending deliveries are queued or waiting for a retry
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	// StatusDead deliveries ran out of attempts, they are only retried by redelivering them
+	StatusDead Status = "dead"
+)
+
+// Delivery is a single event sent to a single subscription, together with every attempt to send it
+type Delivery struct {
+	ID             string       `json:"id"`
+	SubscriptionID string       `json:"subscriptionId"`
+	Status         Status       `json:"status"`
+	Event          events.Event `json:"event"`
+	Attempts       []Attempt    `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is retried
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type Attempt struct {
+	Time time.Time `json:"time"`
+	// StatusCode is zero when no response was received
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Sign computes the signature header value for a payload sent at timestamp,
+// the timestamp is signed too so captured requests cant be replayed later
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a received delivery, it is what receivers written in Go would use.
+// Deliveries signed more than tolerance away from now are rejected.
+func Verify(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(header.Get(HEADER_TIMESTAMP), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header", HEADER_TIMESTAMP)
+	}
+	timestamp := time.Unix(unix, 0)
+	if now.Sub(timestamp).Abs() > tolerance {
+		return errors.New("webhook timestamp is outside the tolerance")
+	}
+	if !hmac.Equal([]byte(header.Get(HEADER_SIGNATURE)), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("webhook signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package webhooks
+
+import (
+	"net/http"
+	"plants/events"
+	"plants/plants"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionMatches(t *testing.T) {
+	basil := plants.Plant{ID: "1", Name: "basil", Tags: []string{"herbs", "kitchen"}}
+	tests := map[string]struct {
+		sub   Subscription
+		event events.Event
+
+		want bool
+	}{
+		"no filters": {
+			event: events.Event{Type: events.TypeDeleted, Plant: basil},
+
+			want: true,
+		},
+		"event type": {
+			sub:   Subscription{Events: []events.Type{events.TypeCreated, events.TypeUpdated}},
+			event: events.Event{Type: events.TypeDeleted, Plant: basil},
+
+			want: false,
+		},
+		"plant id": {
+			sub:   Subscription{PlantIDs: []string{"2", "1"}},
+			event: events.Event{Type: events.TypeUpdated, Plant: basil},
+
+			want: true,
+		},
+		"tag": {
+			sub:   Subscription{PlantIDs: []string{"2"}, Tags: []string{"kitchen"}},
+			event: events.Event{Type: events.TypeUpdated, Plant: basil},
+
+			want: true,
+		},
+		"type and tag together": {
+			sub:   Subscription{Events: []events.Type{events.TypeCreated}, Tags: []string{"kitchen"}},
+			event: events.Event{Type: events.TypeUpdated, Plant: basil},
+
+			want: false,
+		},
+		"other plant": {
+			sub:   Subscription{Tags: []string{"balcony"}},
+			event: events.Event{Type: events.TypeCreated, Plant: basil},
+
+			want: false,
+		},
+		"disabled": {
+			sub:   Subscription{Disabled: true},
+			event: events.Event{Type: events.TypeCreated, Plant: basil},
+
+			want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.sub.Matches(tc.event))
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":1}`)
+	signed := func(secret string, at time.Time) http.Header {
+		header := make(http.Header)
+		header.Set(HEADER_TIMESTAMP, strconv.FormatInt(at.Unix(), 10))
+		header.Set(HEADER_SIGNATURE, Sign(secret, at, body))
+		return header
+	}
+
+	tests := map[string]struct {
+		header http.Header
+		body   []byte
+
+		wantErr string
+	}{
+		"valid": {
+			header: signed(testSecret, now.Add(-time.Minute)),
+			body:   body,
+		},
+		"tampered body": {
+			header: signed(testSecret, now),
+			body:   []byte(`{"id":2}`),
+
+			wantErr: "webhook signature does not match",
+		},
+		"other secret": {
+			header: signed("fedcba9876543210", now),
+			body:   body,
+
+			wantErr: "webhook signature does not match",
+		},
+		"replayed later": {
+			header: signed(testSecret, now.Add(-time.Hour)),
+			body:   body,
+
+			wantErr: "webhook timestamp is outside the tolerance",
+		},
+		"missing timestamp": {
+			header: http.Header{},
+			body:   body,
+
+			wantErr: "invalid X-Webhook-Timestamp header",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(testSecret, tc.header, tc.body, now, 5*time.Minute)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
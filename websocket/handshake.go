@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HandshakeError is a rejected upgrade request, Status is what it should be answered with
+type HandshakeError struct {
+	Status int
+	Err    error
+}
+
+func (e *HandshakeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// IsUpgrade reports whether r asks to switch to the websocket protocol
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SameOrigin reports whether the Origin header of r names the host r was sent to
+func SameOrigin(r *http.Request) bool {
+	u, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Upgrade completes the server side of the opening handshake and takes over the connection.
+// A *HandshakeError means nothing was written yet and the caller should answer the request itself.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts Options) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{Status: http.StatusMethodNotAllowed, Err: errors.New("websocket upgrade must use GET")}
+	}
+	if !IsUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Err: errors.New("websocket upgrade headers are missing")}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Err: errors.New("unsupported websocket version, expected 13")}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Err: errors.New("invalid Sec-WebSocket-Key")}
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		checkOrigin := opts.CheckOrigin
+		if checkOrigin == nil {
+			checkOrigin = SameOrigin
+		}
+		if !checkOrigin(r) {
+			return nil, &HandshakeError{Status: http.StatusForbidden, Err: fmt.Errorf("websocket origin '%s' is not allowed", origin)}
+		}
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijack connection: %w", err)
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+	// NOTE: the server may have set deadlines for the http request, they dont apply to the websocket
+	_ = conn.SetDeadline(time.Time{})
+
+	return newConn(conn, brw.Reader, opts, false), nil
+}
+
+// Dial opens a client connection to a ws:// or wss:// url, tlsConfig is only used for wss:// and may be nil
+func Dial(ctx context.Context, rawURL string, header http.Header, tlsConfig *tls.Config, opts Options) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaultPort := "80"
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+		defaultPort = "443"
+	default:
+		return nil, nil, fmt.Errorf("unsupported websocket scheme '%s'", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme == "https" {
+		cfg := &tls.Config{}
+		if tlsConfig != nil {
+			cfg = tlsConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		// NOTE: websockets need http/1.1, h2 connections cant be upgraded
+		cfg.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, res, err := clientHandshake(ctx, conn, u, header, opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, res, err
+	}
+	return c, res, nil
+}
+
+func clientHandshake(ctx context.Context, conn net.Conn, u *url.URL, header http.Header, opts Options) (*Conn, *http.Response, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err := req.Write(conn); err != nil {
+		return nil, nil, fmt.Errorf("write handshake: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read handshake: %w", err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		// NOTE: the connection is closed on failure, so the error body is read while it still can be
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return nil, res, &HandshakeError{Status: res.StatusCode, Err: fmt.Errorf("websocket handshake failed with status %d", res.StatusCode)}
+	}
+	if res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, res, errors.New("websocket handshake returned an invalid accept key")
+	}
+
+	return newConn(conn, br, opts, true), res, nil
+}
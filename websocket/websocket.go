@@ -0,0 +1,328 @@
+// Package websocket is a minimal RFC 6455 implementation on top of net/http,
+// without extensions (no per-message compression) and without subprotocol negotiation.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// close status codes, see RFC 6455 section 7.4.1
+const (
+	CLOSE_NORMAL          = 1000
+	CLOSE_GOING_AWAY      = 1001
+	CLOSE_PROTOCOL_ERROR  = 1002
+	CLOSE_NO_STATUS       = 1005
+	CLOSE_INVALID_PAYLOAD = 1007
+	CLOSE_POLICY          = 1008
+	CLOSE_TOO_BIG         = 1009
+	CLOSE_INTERNAL_ERROR  = 1011
+	CLOSE_TRY_AGAIN_LATER = 1013
+)
+
+// control frames cant carry more than this, see RFC 6455 section 5.5
+const maxControlPayload = 125
+
+// guid is appended to the client key to compute the handshake accept value
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrCloseSent is returned when writing data after a close frame was sent
+var ErrCloseSent = errors.New("websocket close frame already sent")
+
+// CloseError is returned by ReadMessage once the connection is closing, Code is the status
+// received from the peer, or the one sent to it when the peer broke the protocol
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d %s", e.Code, e.Reason)
+}
+
+// Options configure a connection, zero values mean no limit
+type Options struct {
+	// MaxMessageBytes caps the size of a single (possibly fragmented) incoming message
+	MaxMessageBytes int64
+	// ReadTimeout closes connections that send nothing, not even a pong, for this long
+	ReadTimeout time.Duration
+	// WriteTimeout fails writes to peers that stopped reading
+	WriteTimeout time.Duration
+	// CheckOrigin decides whether Upgrade accepts a request with an Origin header, nil accepts only
+	// the same host. Browsers send cookies and client certificates along with any page's upgrade request.
+	CheckOrigin func(r *http.Request) bool
+}
+
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	opts   Options
+	client bool
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, opts Options, client bool) *Conn {
+	return &Conn{conn: conn, br: br, opts: opts, client: client}
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + guid))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ReadMessage returns the next data message, pings are answered and pongs skipped along the way.
+// Once the peer closes the connection, or breaks the protocol, a *CloseError is returned.
+// It must not be called concurrently.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var messageType MessageType
+	var message []byte
+	inMessage := false
+
+	for {
+		if c.opts.ReadTimeout > 0 {
+			if err := c.conn.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout)); err != nil {
+				return 0, nil, err
+			}
+		}
+
+		fin, opcode, payload, err := c.readFrame(int64(len(message)))
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				_ = c.WriteClose(closeErr.Code, closeErr.Reason)
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := parseClose(payload)
+			// NOTE: the close handshake echoes the status, unless we started it
+			code := closeErr.Code
+			if code == CLOSE_NO_STATUS {
+				code = CLOSE_NORMAL
+			}
+			_ = c.WriteClose(code, "")
+			return 0, nil, closeErr
+		case opText, opBinary:
+			if inMessage {
+				return 0, nil, c.fail(CLOSE_PROTOCOL_ERROR, "new message before the previous one finished")
+			}
+			inMessage = true
+			messageType = MessageType(opcode)
+		case opContinuation:
+			if !inMessage {
+				return 0, nil, c.fail(CLOSE_PROTOCOL_ERROR, "continuation frame without a message")
+			}
+		default:
+			return 0, nil, c.fail(CLOSE_PROTOCOL_ERROR, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CLOSE_INVALID_PAYLOAD, "text message is not valid utf-8")
+		}
+		return messageType, message, nil
+	}
+}
+
+// fail starts the close handshake because the peer broke the protocol
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.WriteClose(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+func parseClose(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CLOSE_NO_STATUS}
+	}
+	return &CloseError{Code: int(binary.BigEndian.Uint16(payload)), Reason: string(payload[2:])}
+}
+
+// readFrame reads a single frame, read is how much of the current message was already read
+func (c *Conn) readFrame(read int64) (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	control := opcode&0x8 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &CloseError{Code: CLOSE_PROTOCOL_ERROR, Reason: "reserved bits set without an extension"}
+	}
+	// NOTE: clients must mask every frame, servers must not mask any
+	if masked == c.client {
+		return false, 0, nil, &CloseError{Code: CLOSE_PROTOCOL_ERROR, Reason: "unexpected frame masking"}
+	}
+
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return false, 0, nil, &CloseError{Code: CLOSE_PROTOCOL_ERROR, Reason: "invalid frame length"}
+		}
+	}
+
+	if control && (length > maxControlPayload || !fin) {
+		return false, 0, nil, &CloseError{Code: CLOSE_PROTOCOL_ERROR, Reason: "invalid control frame"}
+	}
+	// NOTE: checked before reading the payload, so an oversized message is never buffered
+	if !control && c.opts.MaxMessageBytes > 0 && read+length > c.opts.MaxMessageBytes {
+		return false, 0, nil, &CloseError{Code: CLOSE_TOO_BIG, Reason: fmt.Sprintf("message exceeds %d bytes", c.opts.MaxMessageBytes)}
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends a single unfragmented message, it is safe to call concurrently
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+	return c.writeFrame(byte(messageType), data)
+}
+
+// Ping sends a ping, the peer answers with a pong which ReadMessage consumes
+func (c *Conn) Ping(data []byte) error {
+	return c.writeFrame(opPing, data)
+}
+
+// WriteClose starts (or answers) the close handshake, nothing but control frames can be sent afterwards.
+// The peer answers with its own close frame, which ends ReadMessage.
+func (c *Conn) WriteClose(code int, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return c.writeFrameLocked(opClose, payload)
+}
+
+// Close closes the underlying connection right away, use WriteClose first for a graceful close
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if !c.client {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+
+	if c.opts.WriteTimeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
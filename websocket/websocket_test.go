@@ -0,0 +1,255 @@
+package websocket
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// NOTE: the example from RFC 6455 section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestUpgradeRejects(t *testing.T) {
+	tests := map[string]struct {
+		method  string
+		headers map[string]string
+
+		wantStatus int
+	}{
+		"not GET": {
+			method:  http.MethodPost,
+			headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="},
+
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		"plain http request": {
+			method: http.MethodGet,
+
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		"unsupported version": {
+			method:  http.MethodGet,
+			headers: map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="},
+
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		"invalid key": {
+			method:  http.MethodGet,
+			headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short"},
+
+			wantStatus: http.StatusBadRequest,
+		},
+		"cross origin": {
+			method:  http.MethodGet,
+			headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "https://evil.example.org"},
+
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			_, err := Upgrade(httptest.NewRecorder(), r, Options{})
+
+			var handshakeErr *HandshakeError
+			require.ErrorAs(t, err, &handshakeErr)
+			assert.Equal(t, tc.wantStatus, handshakeErr.Status)
+		})
+	}
+}
+
+// serve runs handler for every websocket connection to the returned ws:// url
+func serve(t *testing.T, opts Options, handler func(c *Conn)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer func() { _ = c.Close() }()
+		handler(c)
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, _, err := Dial(ctx, url, nil, nil, Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// echo answers every message with itself until the connection closes
+func echo(c *Conn) {
+	for {
+		messageType, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := c.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+// writeRaw writes a frame the public API cant produce, e.g. a fragment
+func (c *Conn) writeRaw(header0 byte, payload []byte) error {
+	frame := []byte{header0, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i, b := range payload {
+		frame = append(frame, b^frame[2+i%4])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func TestConnEcho(t *testing.T) {
+	c := dial(t, serve(t, Options{MaxMessageBytes: 1 << 20}, echo))
+
+	for _, size := range []int{0, 5, 125, 126, 70_000} {
+		msg := []byte(strings.Repeat("a", size))
+		require.NoError(t, c.WriteMessage(BinaryMessage, msg))
+		messageType, got, err := c.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, BinaryMessage, messageType)
+		assert.Equal(t, len(msg), len(got))
+	}
+
+	// NOTE: pings from the client are answered by the server before the echo
+	require.NoError(t, c.Ping([]byte("hi")))
+	require.NoError(t, c.writeRaw(opText, []byte("frag")))
+	require.NoError(t, c.writeRaw(0x80|opContinuation, []byte("mented")))
+	messageType, got, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, messageType)
+	assert.Equal(t, "fragmented", string(got))
+
+	require.NoError(t, c.WriteClose(CLOSE_NORMAL, "bye"))
+	_, _, err = c.ReadMessage()
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CLOSE_NORMAL, closeErr.Code)
+	assert.ErrorIs(t, c.WriteMessage(TextMessage, []byte("late")), ErrCloseSent)
+}
+
+func TestConnProtocolErrors(t *testing.T) {
+	tests := map[string]struct {
+		write func(c *Conn) error
+
+		wantCode int
+	}{
+		"message too big": {
+			write: func(c *Conn) error { return c.WriteMessage(TextMessage, []byte(strings.Repeat("a", 11))) },
+
+			wantCode: CLOSE_TOO_BIG,
+		},
+		"fragments too big together": {
+			write: func(c *Conn) error {
+				if err := c.writeRaw(opText, []byte("123456")); err != nil {
+					return err
+				}
+				return c.writeRaw(0x80|opContinuation, []byte("123456"))
+			},
+
+			wantCode: CLOSE_TOO_BIG,
+		},
+		"invalid utf-8": {
+			write: func(c *Conn) error { return c.WriteMessage(TextMessage, []byte{0xff, 0xfe}) },
+
+			wantCode: CLOSE_INVALID_PAYLOAD,
+		},
+		"continuation without message": {
+			write: func(c *Conn) error { return c.writeRaw(0x80|opContinuation, []byte("a")) },
+
+			wantCode: CLOSE_PROTOCOL_ERROR,
+		},
+		"reserved bits": {
+			write: func(c *Conn) error { return c.writeRaw(0x80|0x40|opText, []byte("a")) },
+
+			wantCode: CLOSE_PROTOCOL_ERROR,
+		},
+		"unmasked client frame": {
+			write: func(c *Conn) error {
+				_, err := c.conn.Write([]byte{0x80 | opText, 1, 'a'})
+				return err
+			},
+
+			wantCode: CLOSE_PROTOCOL_ERROR,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			serverErr := make(chan error, 1)
+			c := dial(t, serve(t, Options{MaxMessageBytes: 10}, func(c *Conn) {
+				_, _, err := c.ReadMessage()
+				serverErr <- err
+			}))
+
+			require.NoError(t, tc.write(c))
+
+			var closeErr *CloseError
+			require.ErrorAs(t, <-serverErr, &closeErr)
+			assert.Equal(t, tc.wantCode, closeErr.Code)
+			_, _, err := c.ReadMessage()
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, tc.wantCode, closeErr.Code)
+		})
+	}
+}
+
+func TestConnServerClose(t *testing.T) {
+	done := make(chan error, 1)
+	c := dial(t, serve(t, Options{}, func(c *Conn) {
+		_ = c.WriteClose(CLOSE_GOING_AWAY, "shutting down")
+		// NOTE: the close handshake completes once the client echoes the close frame
+		_, _, err := c.ReadMessage()
+		done <- err
+	}))
+
+	_, _, err := c.ReadMessage()
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, &CloseError{Code: CLOSE_GOING_AWAY, Reason: "shutting down"}, closeErr)
+
+	require.ErrorAs(t, <-done, &closeErr)
+	assert.Equal(t, CLOSE_GOING_AWAY, closeErr.Code)
+}
+
+func TestConnReadTimeout(t *testing.T) {
+	done := make(chan error, 1)
+	_ = dial(t, serve(t, Options{ReadTimeout: 20 * time.Millisecond}, func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		done <- err
+	}))
+
+	err := <-done
+	var netErr interface{ Timeout() bool }
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+}
+
+func TestParseClose(t *testing.T) {
+	assert.Equal(t, &CloseError{Code: CLOSE_NO_STATUS}, parseClose(nil))
+	assert.Equal(t, &CloseError{Code: CLOSE_POLICY, Reason: "nope"}, parseClose(append(binary.BigEndian.AppendUint16(nil, CLOSE_POLICY), "nope"...)))
+}